@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	// APIURL points at the /admin endpoints of a running observer (see the api package).
+	APIURL   string
+	APIToken string
+}
+
+type Command struct {
+	command       cobra.Command
+	pauseCommand  cobra.Command
+	resumeCommand cobra.Command
+	flags         CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "admin",
+		Short: "Control a running observer over its HTTP API",
+	}
+
+	pauseCommand := cobra.Command{
+		Use:   "pause",
+		Short: "Pause the crawl of a running observer without killing the process",
+	}
+
+	resumeCommand := cobra.Command{
+		Use:   "resume",
+		Short: "Resume a paused crawl",
+	}
+
+	instance := Command{
+		command:       command,
+		pauseCommand:  pauseCommand,
+		resumeCommand: resumeCommand,
+	}
+
+	instance.withAPIURL()
+
+	instance.command.AddCommand(&instance.pauseCommand)
+	instance.command.AddCommand(&instance.resumeCommand)
+
+	return &instance
+}
+
+func (command *Command) withAPIURL() {
+	flags := command.command.PersistentFlags()
+	flags.StringVar(&command.flags.APIURL, "api-url", "",
+		"base URL of the running observer API, e.g. http://127.0.0.1:37272")
+	flags.StringVar(&command.flags.APIToken, "api-token", "",
+		"bearer token of the observer API (if it requires one)")
+	if err := command.command.MarkPersistentFlagRequired("api-url"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) OnPause(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.pauseCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) OnResume(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.resumeCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
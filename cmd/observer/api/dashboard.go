@@ -0,0 +1,103 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// dashboardHTML is the embedded single-page dashboard (see handleDashboard):
+// a self-contained page without external dependencies,
+// so it also works on observers running in isolated networks.
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// dashboardHistoryWindow is how far back the crawl progress chart looks.
+const dashboardHistoryWindow = 30 * 24 * time.Hour
+
+// dashboardResponse feeds the dashboard charts: the client distribution pie,
+// the world map of nodes and the crawl progress over time.
+type dashboardResponse struct {
+	Clients   map[string]uint         `json:"clients"`
+	Countries map[string]uint         `json:"countries"`
+	History   []dashboardHistoryPoint `json:"history"`
+}
+
+type dashboardHistoryPoint struct {
+	Time      string `json:"time"`
+	Nodes     uint   `json:"nodes"`
+	IPs       uint   `json:"ips"`
+	Operators uint   `json:"operators,omitempty"`
+}
+
+// handleDashboard serves the embedded dashboard page at the server root.
+func (server *Server) handleDashboard(w http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != "/" {
+		http.NotFound(w, request)
+		return
+	}
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(dashboardHTML)
+}
+
+// handleDashboardData aggregates the data behind the dashboard charts.
+// An optional "network_id" parameter restricts the distributions to a chain.
+func (server *Server) handleDashboardData(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := request.Context()
+
+	var networkID uint
+	if param := request.URL.Query().Get("network_id"); param != "" {
+		value, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid network_id", http.StatusBadRequest)
+			return
+		}
+		networkID = uint(value)
+	}
+
+	var response dashboardResponse
+	var err error
+
+	if response.Clients, err = server.clientCounts(ctx, networkID); err == nil {
+		if response.Countries, err = server.db.CountNodesByCountry(ctx, networkID); err == nil {
+			response.Countries = applyCountThreshold(response.Countries, server.privacyThreshold)
+		}
+	}
+	if err != nil {
+		server.log.Error("Failed to aggregate the dashboard data", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	snapshots, err := server.db.FindStatsSnapshots(ctx, time.Now().Add(-dashboardHistoryWindow))
+	if err != nil {
+		server.log.Error("Failed to load the stats snapshots", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	response.History = make([]dashboardHistoryPoint, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		response.History = append(response.History, dashboardHistoryPoint{
+			Time:      snapshot.Time.Format(time.RFC3339),
+			Nodes:     snapshot.CompatibleNodes,
+			IPs:       snapshot.DistinctIPs,
+			Operators: snapshot.OperatorEstimate,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write a dashboard data response", "err", err)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// eventsSubscriberBuffer is the per-connection event buffer:
+// a client lagging further behind than this loses events (see events.Bus).
+const eventsSubscriberBuffer = 256
+
+// the bearer token check of withAuth already gates the upgrade request,
+// so cross-origin browser connections are not a concern here
+var eventsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(request *http.Request) bool { return true },
+}
+
+// handleEvents streams the change feed over a websocket:
+// every node event is sent as one JSON message (see events.Event).
+func (server *Server) handleEvents(w http.ResponseWriter, request *http.Request) {
+	if server.events == nil {
+		http.Error(w, "the event feed is disabled", http.StatusNotFound)
+		return
+	}
+
+	conn, err := eventsUpgrader.Upgrade(w, request, nil)
+	if err != nil {
+		server.log.Debug("Failed to upgrade an event feed connection", "err", err)
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	feed, unsubscribe := server.events.Subscribe(eventsSubscriberBuffer)
+	defer unsubscribe()
+
+	// the read loop only detects the client hanging up
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event := <-feed:
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+// The operator self-service flow lets a node operator prove control
+// of the node key and then manage what the observer stores about the node:
+//
+//  1. POST /operator/challenge?node=<id> returns a one-time challenge;
+//  2. the operator signs the challenge hash with the node private key
+//     (see OperatorChallengeHash) and redeems the signature
+//     with POST /operator/verify?node=<id>&signature=<hex> for a session token;
+//  3. the token (in the "X-Operator-Token" header) authorizes
+//     GET /operator/node, POST /operator/label?label=<text>
+//     and POST /operator/optout?opt_out=<bool> for that node.
+//
+// Challenges and sessions live in memory only: an observer restart
+// merely asks the operator to verify again, while the managed settings
+// persist in the database (see database.OperatorSettings).
+const (
+	operatorChallengeTTL = 10 * time.Minute
+	operatorSessionTTL   = 24 * time.Hour
+)
+
+type operatorChallenge struct {
+	nonce   string
+	expires time.Time
+}
+
+type operatorSession struct {
+	nodeID  database.NodeID
+	expires time.Time
+}
+
+// operatorAuth holds the pending challenges and the verified sessions
+// of the operator self-service flow.
+type operatorAuth struct {
+	mutex      sync.Mutex
+	challenges map[database.NodeID]operatorChallenge
+	sessions   map[string]operatorSession
+}
+
+func newOperatorAuth() *operatorAuth {
+	instance := operatorAuth{
+		challenges: make(map[database.NodeID]operatorChallenge),
+		sessions:   make(map[string]operatorSession),
+	}
+	return &instance
+}
+
+// OperatorChallengeHash is what the operator signs with the node private key:
+// the Keccak-256 hash of a fixed prefix, the node ID and the challenge nonce.
+// The prefix and the node ID bind the signature to this flow and this node,
+// so it cannot be replayed as (or from) any other protocol message.
+func OperatorChallengeHash(id database.NodeID, nonce string) []byte {
+	return crypto.Keccak256([]byte("observer operator challenge\n" + string(id) + "\n" + nonce))
+}
+
+// issueChallenge creates a fresh challenge nonce for the node,
+// replacing any previous unredeemed one.
+func (auth *operatorAuth) issueChallenge(id database.NodeID, now time.Time) (operatorChallenge, error) {
+	nonceBytes := make([]byte, 32)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return operatorChallenge{}, fmt.Errorf("failed to generate a challenge: %w", err)
+	}
+
+	challenge := operatorChallenge{
+		nonce:   hex.EncodeToString(nonceBytes),
+		expires: now.Add(operatorChallengeTTL),
+	}
+
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	auth.prune(now)
+	auth.challenges[id] = challenge
+	return challenge, nil
+}
+
+// redeemChallenge verifies the signature over the pending challenge of the node
+// and exchanges it for a session. The challenge is consumed on success.
+func (auth *operatorAuth) redeemChallenge(id database.NodeID, signatureHex string, now time.Time) (string, operatorSession, error) {
+	publicKey, err := node_utils.ParseHexPublicKey(id)
+	if err != nil {
+		return "", operatorSession{}, err
+	}
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return "", operatorSession{}, fmt.Errorf("failed to decode the signature: %w", err)
+	}
+	// tolerate a trailing recovery ID byte (see crypto.Sign)
+	if len(signature) == crypto.SignatureLength {
+		signature = signature[:crypto.RecoveryIDOffset]
+	}
+	if len(signature) != crypto.RecoveryIDOffset {
+		return "", operatorSession{}, fmt.Errorf("unexpected signature length: %d", len(signature))
+	}
+
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+
+	challenge, ok := auth.challenges[id]
+	if !ok || now.After(challenge.expires) {
+		return "", operatorSession{}, fmt.Errorf("no pending challenge, request a new one")
+	}
+
+	hash := OperatorChallengeHash(id, challenge.nonce)
+	if !crypto.VerifySignature(crypto.MarshalPubkeyStd(publicKey), hash, signature) {
+		return "", operatorSession{}, fmt.Errorf("signature does not match the node key")
+	}
+	delete(auth.challenges, id)
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", operatorSession{}, fmt.Errorf("failed to generate a session token: %w", err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+	session := operatorSession{nodeID: id, expires: now.Add(operatorSessionTTL)}
+	auth.sessions[token] = session
+	return token, session, nil
+}
+
+// authenticate resolves a session token to the node it manages.
+func (auth *operatorAuth) authenticate(token string, now time.Time) (database.NodeID, bool) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+
+	session, ok := auth.sessions[token]
+	if !ok || now.After(session.expires) {
+		return "", false
+	}
+	return session.nodeID, true
+}
+
+// prune drops the expired challenges and sessions. The caller holds the mutex.
+func (auth *operatorAuth) prune(now time.Time) {
+	for id, challenge := range auth.challenges {
+		if now.After(challenge.expires) {
+			delete(auth.challenges, id)
+		}
+	}
+	for token, session := range auth.sessions {
+		if now.After(session.expires) {
+			delete(auth.sessions, token)
+		}
+	}
+}
+
+type operatorChallengeResponse struct {
+	ID database.NodeID `json:"id"`
+	// Challenge is the nonce to sign (see OperatorChallengeHash).
+	Challenge string `json:"challenge"`
+	Expires   string `json:"expires"`
+}
+
+func (server *Server) handleOperatorChallenge(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := database.ParseNodeID(request.URL.Query().Get("node"))
+	if err != nil {
+		http.Error(w, "invalid node ID", http.StatusBadRequest)
+		return
+	}
+
+	// a challenge for a node the observer never saw manages nothing
+	addr, err := server.db.FindNodeAddr(request.Context(), id)
+	if err != nil {
+		server.log.Error("Failed to find the node of an operator challenge", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if addr == nil {
+		http.NotFound(w, request)
+		return
+	}
+
+	challenge, err := server.operators.issueChallenge(id, time.Now())
+	if err != nil {
+		server.log.Error("Failed to issue an operator challenge", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := operatorChallengeResponse{
+		ID:        id,
+		Challenge: challenge.nonce,
+		Expires:   challenge.expires.Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write an operator challenge response", "err", err)
+	}
+}
+
+type operatorVerifyResponse struct {
+	ID database.NodeID `json:"id"`
+	// Token authorizes the /operator management endpoints
+	// via the "X-Operator-Token" header.
+	Token   string `json:"token"`
+	Expires string `json:"expires"`
+}
+
+func (server *Server) handleOperatorVerify(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := database.ParseNodeID(request.URL.Query().Get("node"))
+	if err != nil {
+		http.Error(w, "invalid node ID", http.StatusBadRequest)
+		return
+	}
+	signatureHex := request.URL.Query().Get("signature")
+	if signatureHex == "" {
+		http.Error(w, "missing signature", http.StatusBadRequest)
+		return
+	}
+
+	token, session, err := server.operators.redeemChallenge(id, signatureHex, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	server.log.Info("Node operator verified", "node", id)
+
+	response := operatorVerifyResponse{
+		ID:      id,
+		Token:   token,
+		Expires: session.expires.Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write an operator verify response", "err", err)
+	}
+}
+
+// operatorSession resolves the session of a management request,
+// or answers it with an error and returns false.
+func (server *Server) operatorSession(w http.ResponseWriter, request *http.Request) (database.NodeID, bool) {
+	token := request.Header.Get("X-Operator-Token")
+	if token == "" {
+		http.Error(w, "missing X-Operator-Token", http.StatusUnauthorized)
+		return "", false
+	}
+	id, ok := server.operators.authenticate(token, time.Now())
+	if !ok {
+		http.Error(w, "invalid or expired operator token", http.StatusUnauthorized)
+		return "", false
+	}
+	return id, true
+}
+
+type operatorNodeResponse struct {
+	ID       database.NodeID `json:"id"`
+	IP       string          `json:"ip,omitempty"`
+	IPv6     string          `json:"ip_v6,omitempty"`
+	PortDisc uint16          `json:"port_disc,omitempty"`
+	PortRLPx uint16          `json:"port_rlpx,omitempty"`
+	ClientID string          `json:"client_id,omitempty"`
+
+	AddrUpdated    string `json:"addr_updated,omitempty"`
+	ClientVerified string `json:"client_verified,omitempty"`
+
+	HandshakeErrors []string `json:"handshake_errors,omitempty"`
+
+	OperatorLabel string `json:"operator_label,omitempty"`
+	OptedOut      bool   `json:"opted_out"`
+}
+
+// operatorNodeHandshakeErrorsLimit is how many recent handshake errors
+// the operator view of the node shows.
+const operatorNodeHandshakeErrorsLimit = 5
+
+// handleOperatorNode shows the verified operator everything the observer
+// stores about the node, including the operator-managed settings.
+func (server *Server) handleOperatorNode(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := server.operatorSession(w, request)
+	if !ok {
+		return
+	}
+	ctx := request.Context()
+
+	response := operatorNodeResponse{ID: id}
+
+	addr, err := server.db.FindNodeAddr(ctx, id)
+	if (err == nil) && (addr != nil) {
+		if addr.IP != nil {
+			response.IP = addr.IP.String()
+		}
+		if addr.IPv6.IP != nil {
+			response.IPv6 = addr.IPv6.IP.String()
+		}
+		response.PortDisc = addr.PortDisc
+		response.PortRLPx = addr.PortRLPx
+	}
+
+	var clientID *string
+	if err == nil {
+		clientID, err = server.db.FindClientID(ctx, id)
+		if clientID != nil {
+			response.ClientID = *clientID
+		}
+	}
+
+	var freshness *database.NodeFreshness
+	if err == nil {
+		freshness, err = server.db.FindNodeFreshness(ctx, id)
+		if freshness != nil {
+			response.AddrUpdated = freshness.AddrUpdated.Format(time.RFC3339)
+			if freshness.ClientVerified != nil {
+				response.ClientVerified = freshness.ClientVerified.Format(time.RFC3339)
+			}
+		}
+	}
+
+	var handshakeErrors []database.HandshakeError
+	if err == nil {
+		handshakeErrors, err = server.db.FindHandshakeLastErrors(ctx, id, operatorNodeHandshakeErrorsLimit)
+		for _, handshakeError := range handshakeErrors {
+			response.HandshakeErrors = append(response.HandshakeErrors, handshakeError.StringCode)
+		}
+	}
+
+	var settings *database.OperatorSettings
+	if err == nil {
+		settings, err = server.db.FindOperatorSettings(ctx, id)
+		if settings != nil {
+			if settings.Label != nil {
+				response.OperatorLabel = *settings.Label
+			}
+			response.OptedOut = settings.OptOut
+		}
+	}
+
+	if err != nil {
+		server.log.Error("Failed to collect the operator node view", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write an operator node response", "err", err)
+	}
+}
+
+type operatorSettingsResponse struct {
+	ID            database.NodeID `json:"id"`
+	OperatorLabel string          `json:"operator_label,omitempty"`
+	OptedOut      bool            `json:"opted_out"`
+}
+
+// handleOperatorLabel sets or clears (with an empty "label") the label
+// correction of the node.
+func (server *Server) handleOperatorLabel(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := server.operatorSession(w, request)
+	if !ok {
+		return
+	}
+
+	label := request.URL.Query().Get("label")
+	if err := server.db.UpdateOperatorLabel(request.Context(), id, label); err != nil {
+		server.log.Error("Failed to update the operator label", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	server.log.Info("Node operator updated the label", "node", id)
+	server.writeOperatorSettings(w, request, id)
+}
+
+// handleOperatorOptOut sets the opt-out flag of the node
+// from the mandatory "opt_out" boolean parameter.
+func (server *Server) handleOperatorOptOut(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := server.operatorSession(w, request)
+	if !ok {
+		return
+	}
+
+	optOut, err := strconv.ParseBool(request.URL.Query().Get("opt_out"))
+	if err != nil {
+		http.Error(w, "invalid opt_out", http.StatusBadRequest)
+		return
+	}
+
+	if err := server.db.UpdateOperatorOptOut(request.Context(), id, optOut); err != nil {
+		server.log.Error("Failed to update the operator opt-out flag", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	server.log.Info("Node operator updated the opt-out flag", "node", id, "optOut", optOut)
+	server.writeOperatorSettings(w, request, id)
+}
+
+// writeOperatorSettings answers a management request with the updated settings.
+func (server *Server) writeOperatorSettings(w http.ResponseWriter, request *http.Request, id database.NodeID) {
+	settings, err := server.db.FindOperatorSettings(request.Context(), id)
+	if err != nil {
+		server.log.Error("Failed to find the operator settings", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	response := operatorSettingsResponse{ID: id}
+	if settings != nil {
+		if settings.Label != nil {
+			response.OperatorLabel = *settings.Label
+		}
+		response.OptedOut = settings.OptOut
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write an operator settings response", "err", err)
+	}
+}
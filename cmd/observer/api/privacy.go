@@ -0,0 +1,28 @@
+package api
+
+// privacyGroupOther is the bucket the small aggregate groups are folded into
+// (see applyCountThreshold).
+const privacyGroupOther = "(other)"
+
+// applyCountThreshold folds the groups counting fewer nodes than the threshold
+// into a single "(other)" bucket, so published aggregates can't be used
+// to pinpoint individual home-run nodes (e.g. the single node of a small country).
+// A zero threshold leaves the counts unchanged.
+func applyCountThreshold(counts map[string]uint, threshold uint) map[string]uint {
+	if threshold == 0 {
+		return counts
+	}
+	result := make(map[string]uint, len(counts))
+	var other uint
+	for name, count := range counts {
+		if count < threshold {
+			other += count
+		} else {
+			result[name] = count
+		}
+	}
+	if other > 0 {
+		result[privacyGroupOther] += other
+	}
+	return result
+}
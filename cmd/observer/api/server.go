@@ -0,0 +1,627 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/bootnodes"
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/events"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// DiagnosisProvider supplies the self-diagnosis findings of the running crawl
+// (see observer.Diagnose). It is nil when the API runs without a crawler.
+type DiagnosisProvider func() []observer.DiagnosisFinding
+
+// Server exposes the crawler database to downstream consumers over HTTP.
+// With a non-empty token every request must carry "Authorization: Bearer <token>".
+// A non-zero privacyThreshold folds the small aggregate groups into an
+// "(other)" bucket before publishing (see applyCountThreshold).
+type Server struct {
+	db               database.DB
+	addr             string
+	token            string
+	chain            string
+	diagnose         DiagnosisProvider
+	pause            *observer.PauseControl
+	privacyThreshold uint
+	events           *events.Bus
+	operators        *operatorAuth
+	log              log.Logger
+}
+
+func NewServer(
+	addr string,
+	db database.DB,
+	token string,
+	chain string,
+	diagnose DiagnosisProvider,
+	pause *observer.PauseControl,
+	privacyThreshold uint,
+	eventBus *events.Bus,
+	logger log.Logger,
+) *Server {
+	instance := Server{
+		db,
+		addr,
+		token,
+		chain,
+		diagnose,
+		pause,
+		privacyThreshold,
+		eventBus,
+		newOperatorAuth(),
+		logger,
+	}
+	return &instance
+}
+
+// Listen serves the API until the context is cancelled.
+func (server *Server) Listen(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.handleDashboard)
+	mux.HandleFunc("/dashboard/data", server.handleDashboardData)
+	mux.HandleFunc("/nodes/", server.handleNodes)
+	mux.HandleFunc("/status", server.handleStatus)
+	mux.HandleFunc("/events/ws", server.handleEvents)
+	mux.HandleFunc("/diagnosis", server.handleDiagnosis)
+	mux.HandleFunc("/export", server.handleExport)
+	mux.HandleFunc("/export/nodes.ndjson", server.handleExportNodes)
+	mux.HandleFunc("/sql", server.handleSQL)
+	mux.HandleFunc("/bootnodes", server.handleBootnodes)
+	mux.HandleFunc("/admin/pause", server.handleAdminPause)
+	mux.HandleFunc("/admin/resume", server.handleAdminResume)
+	mux.HandleFunc("/operator/challenge", server.handleOperatorChallenge)
+	mux.HandleFunc("/operator/verify", server.handleOperatorVerify)
+	mux.HandleFunc("/operator/node", server.handleOperatorNode)
+	mux.HandleFunc("/operator/label", server.handleOperatorLabel)
+	mux.HandleFunc("/operator/optout", server.handleOperatorOptOut)
+
+	httpServer := http.Server{
+		Addr:    server.addr,
+		Handler: server.withAuth(mux),
+
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownContext, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownContext)
+	}()
+
+	server.log.Info("API server listening", "addr", server.addr)
+	err := httpServer.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// withAuth requires a bearer token on every request when the server has one configured.
+func (server *Server) withAuth(handler http.Handler) http.Handler {
+	if server.token == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		// the operator self-service flow authenticates with node key signatures
+		// (see operator.go), so it stays open to operators without the API token
+		if strings.HasPrefix(request.URL.Path, "/operator/") {
+			handler.ServeHTTP(w, request)
+			return
+		}
+		authorization := request.Header.Get("Authorization")
+		if authorization != "Bearer "+server.token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, request)
+	})
+}
+
+// nodeExportResponse mirrors the record shape of the export command
+// (see the export package). The federation client of other observers
+// consumes this stream to merge the nodes into its own database.
+type nodeExportResponse struct {
+	ID          database.NodeID `json:"id"`
+	IP          string          `json:"ip,omitempty"`
+	PortRLPx    uint16          `json:"port_rlpx,omitempty"`
+	ClientID    string          `json:"client_id,omitempty"`
+	NetworkID   uint            `json:"network_id,omitempty"`
+	EthVersion  uint            `json:"eth_version,omitempty"`
+	ASN         uint            `json:"asn,omitempty"`
+	AddrUpdated string          `json:"addr_updated"`
+	ChangeSeq   uint64          `json:"change_seq"`
+}
+
+// handleExport streams the nodes changed after the given change_seq
+// as JSON lines, in the change_seq order.
+// Pulling with "?since_change_seq=<the last seen change_seq>" gives the delta.
+func (server *Server) handleExport(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var sinceChangeSeq uint64
+	if param := request.URL.Query().Get("since_change_seq"); param != "" {
+		var err error
+		sinceChangeSeq, err = strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since_change_seq", http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+
+	var encodeErr error
+	enumFunc := func(node database.NodeExport) {
+		if encodeErr != nil {
+			return
+		}
+		encodeErr = encoder.Encode(makeNodeExportResponse(node))
+	}
+
+	err := server.db.EnumerateNodeExports(request.Context(), sinceChangeSeq, database.NodeExportFilter{}, enumFunc)
+	if err == nil {
+		err = encodeErr
+	}
+	if err != nil {
+		server.log.Error("Failed to stream a node export", "err", err)
+	}
+}
+
+func makeNodeExportResponse(node database.NodeExport) nodeExportResponse {
+	response := nodeExportResponse{
+		ID:          node.ID,
+		AddrUpdated: node.AddrUpdated.Format(time.RFC3339),
+		ChangeSeq:   node.ChangeSeq,
+	}
+	if node.IP != nil {
+		response.IP = *node.IP
+	}
+	if node.PortRLPx != nil {
+		response.PortRLPx = *node.PortRLPx
+	}
+	if node.ClientID != nil {
+		response.ClientID = *node.ClientID
+	}
+	if node.NetworkID != nil {
+		response.NetworkID = *node.NetworkID
+	}
+	if node.EthVersion != nil {
+		response.EthVersion = *node.EthVersion
+	}
+	if node.ASN != nil {
+		response.ASN = *node.ASN
+	}
+	return response
+}
+
+const (
+	// exportPageSize is how many rows a full export pulls from the database at once.
+	// Paging keeps any single query short, so the crawler writes are not starved
+	// by a long-running read.
+	exportPageSize = 1000
+	// exportPageThrottle is the pause between the pages of a full export.
+	exportPageThrottle = 100 * time.Millisecond
+)
+
+// handleExportNodes streams the full nodes table as JSON lines.
+// Unlike /export it is meant for complete pulls rather than incremental syncs:
+// it pages through the table internally, flushing and throttling between pages,
+// and stops as soon as the client disconnects.
+func (server *Server) handleExportNodes(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	var cursor uint64
+	for {
+		var count uint
+		var encodeErr error
+		enumFunc := func(node database.NodeExport) {
+			if encodeErr != nil {
+				return
+			}
+			count++
+			// the cursor advances to the last streamed row,
+			// so the next page continues where this one ended
+			cursor = node.ChangeSeq
+			encodeErr = encoder.Encode(makeNodeExportResponse(node))
+		}
+
+		filter := database.NodeExportFilter{Limit: exportPageSize}
+		err := server.db.EnumerateNodeExports(request.Context(), cursor, filter, enumFunc)
+		if err == nil {
+			err = encodeErr
+		}
+		if err != nil {
+			// a disconnected client cancels the request context mid-page:
+			// not an error worth logging
+			if request.Context().Err() == nil {
+				server.log.Error("Failed to stream a full node export", "err", err)
+			}
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if count < exportPageSize {
+			return
+		}
+		if err := utils.Sleep(request.Context(), exportPageThrottle); err != nil {
+			return
+		}
+	}
+}
+
+func (server *Server) handleNodes(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// expecting: /nodes/{id}/freshness or /nodes/{id}/enr
+	parts := strings.Split(strings.TrimPrefix(request.URL.Path, "/nodes/"), "/")
+	if (len(parts) != 2) || ((parts[1] != "freshness") && (parts[1] != "enr")) {
+		http.NotFound(w, request)
+		return
+	}
+
+	id, err := database.ParseNodeID(parts[0])
+	if err != nil {
+		http.Error(w, "invalid node ID", http.StatusBadRequest)
+		return
+	}
+
+	if parts[1] == "freshness" {
+		server.handleNodeFreshness(w, request, id)
+	} else {
+		server.handleNodeENR(w, request, id)
+	}
+}
+
+type enrResponse struct {
+	ID  database.NodeID `json:"id"`
+	Seq uint64          `json:"seq"`
+	// ENR is the signed record in the EIP-778 textual form: "enr:" + base64url(RLP).
+	ENR string `json:"enr"`
+}
+
+func (server *Server) handleNodeENR(w http.ResponseWriter, request *http.Request, id database.NodeID) {
+	nodeENR, err := server.db.FindNodeENR(request.Context(), id)
+	if err != nil {
+		server.log.Error("Failed to find node ENR", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if nodeENR == nil {
+		http.NotFound(w, request)
+		return
+	}
+
+	response := enrResponse{
+		ID:  id,
+		Seq: nodeENR.Seq,
+		ENR: "enr:" + base64.RawURLEncoding.EncodeToString(nodeENR.Record),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write an ENR response", "err", err)
+	}
+}
+
+type statusResponse struct {
+	Nodes              uint            `json:"nodes"`
+	QueueDepth         uint            `json:"queue_depth"`
+	Clients            map[string]uint `json:"clients"`
+	Networks           map[string]uint `json:"networks"`
+	HandshakeErrors    map[string]uint `json:"handshake_errors"`
+	ProtocolViolations map[string]uint `json:"protocol_violations"`
+}
+
+// handleStatus serves the crawl progress aggregates:
+// the total node count, the crawl frontier depth,
+// and the node counts by client name, network ID, last handshake error
+// and protocol violation category.
+// With "?network_id=<chain ID>" the aggregates cover only that chain.
+func (server *Server) handleStatus(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := request.Context()
+
+	var networkID uint
+	if param := request.URL.Query().Get("network_id"); param != "" {
+		value, err := strconv.ParseUint(param, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid network_id", http.StatusBadRequest)
+			return
+		}
+		networkID = uint(value)
+	}
+
+	var response statusResponse
+	var err error
+
+	if response.Nodes, err = server.db.CountAllNodes(ctx, networkID); err == nil {
+		if response.QueueDepth, err = server.db.CountFrontier(ctx); err == nil {
+			if response.Clients, err = server.clientCounts(ctx, networkID); err == nil {
+				if response.Networks, err = server.networkCounts(ctx); err == nil {
+					if response.HandshakeErrors, err = server.db.CountHandshakeErrorGroups(ctx, networkID); err == nil {
+						response.ProtocolViolations, err = server.db.CountProtocolViolations(ctx, networkID)
+					}
+				}
+			}
+		}
+	}
+	if err != nil {
+		server.log.Error("Failed to aggregate the status", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write a status response", "err", err)
+	}
+}
+
+// sqlQueryTimeout and sqlQueryMaxRows bound the ad-hoc queries of handleSQL.
+const (
+	sqlQueryTimeout = 15 * time.Second
+	sqlQueryMaxRows = 1000
+)
+
+type sqlResponse struct {
+	Columns   []string   `json:"columns"`
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated"`
+}
+
+// handleSQL runs a read-only ad-hoc SELECT against the sandbox views
+// (see database.RunSandboxedQuery) with a timeout and a row cap.
+// The query comes in the "q" parameter,
+// and "max_rows" may lower the row cap further.
+// The endpoint works only with a configured API token,
+// so an unprotected server never exposes ad-hoc queries.
+func (server *Server) handleSQL(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if server.token == "" {
+		http.Error(w, "the SQL endpoint requires a configured API token", http.StatusForbidden)
+		return
+	}
+
+	query := request.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "missing q", http.StatusBadRequest)
+		return
+	}
+
+	maxRows := uint(sqlQueryMaxRows)
+	if param := request.URL.Query().Get("max_rows"); param != "" {
+		value, err := strconv.ParseUint(param, 10, 32)
+		if (err != nil) || (value == 0) || (value > sqlQueryMaxRows) {
+			http.Error(w, "invalid max_rows", http.StatusBadRequest)
+			return
+		}
+		maxRows = uint(value)
+	}
+
+	result, err := database.RunSandboxedQuery(request.Context(), server.db, query, sqlQueryTimeout, maxRows)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := sqlResponse{Columns: result.Columns, Rows: result.Rows, Truncated: result.Truncated}
+	if response.Rows == nil {
+		response.Rows = [][]string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write a SQL response", "err", err)
+	}
+}
+
+// bootnodesDefaultLimit and bootnodesMaxLimit bound the "limit" parameter of handleBootnodes.
+const (
+	bootnodesDefaultLimit = 20
+	bootnodesMaxLimit     = 100
+)
+
+// handleBootnodes serves the most reliable fork-compatible nodes as plain text,
+// one per line (see the bootnodes package), so other clients can consume them
+// directly as static peers or bootnodes.
+// "?format=enr" switches to the captured EIP-778 records,
+// and "?limit=<n>" adjusts how many nodes are emitted.
+func (server *Server) handleBootnodes(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := uint(bootnodesDefaultLimit)
+	if param := request.URL.Query().Get("limit"); param != "" {
+		value, err := strconv.ParseUint(param, 10, 32)
+		if (err != nil) || (value == 0) || (value > bootnodesMaxLimit) {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = uint(value)
+	}
+
+	format := bootnodes.FormatEnode
+	if param := request.URL.Query().Get("format"); param != "" {
+		format = bootnodes.Format(param)
+	}
+
+	lines, err := bootnodes.List(request.Context(), server.db, server.chain, limit, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			server.log.Error("Failed to write a bootnodes response", "err", err)
+			return
+		}
+	}
+}
+
+type adminPauseResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// handleAdminPause pauses the crawl of the running process:
+// the workers stop taking candidates and dialing,
+// while the API and the reports stay available.
+func (server *Server) handleAdminPause(w http.ResponseWriter, request *http.Request) {
+	server.handleAdminPauseState(w, request, true)
+}
+
+// handleAdminResume resumes a crawl paused by handleAdminPause.
+func (server *Server) handleAdminResume(w http.ResponseWriter, request *http.Request) {
+	server.handleAdminPauseState(w, request, false)
+}
+
+func (server *Server) handleAdminPauseState(w http.ResponseWriter, request *http.Request, paused bool) {
+	if request.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// the API can run without a crawler (e.g. over a database copy)
+	if server.pause == nil {
+		http.NotFound(w, request)
+		return
+	}
+
+	if paused {
+		server.pause.Pause()
+		server.log.Info("Crawl paused via the admin API")
+	} else {
+		server.pause.Resume()
+		server.log.Info("Crawl resumed via the admin API")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(adminPauseResponse{Paused: paused}); err != nil {
+		server.log.Error("Failed to write an admin response", "err", err)
+	}
+}
+
+// handleDiagnosis serves the self-diagnosis findings of the crawl health.
+// A healthy crawl produces an empty list.
+func (server *Server) handleDiagnosis(w http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if server.diagnose == nil {
+		http.NotFound(w, request)
+		return
+	}
+
+	findings := server.diagnose()
+	if findings == nil {
+		findings = []observer.DiagnosisFinding{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(findings); err != nil {
+		server.log.Error("Failed to write a diagnosis response", "err", err)
+	}
+}
+
+// clientCounts reduces the per-client-ID counts to counts per client name.
+func (server *Server) clientCounts(ctx context.Context, networkID uint) (map[string]uint, error) {
+	groups, err := server.db.CountClientGroups(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]uint)
+	for clientID, count := range groups {
+		counts[reports.NameFromClientID(clientID)] += count
+	}
+	return applyCountThreshold(counts, server.privacyThreshold), nil
+}
+
+func (server *Server) networkCounts(ctx context.Context) (map[string]uint, error) {
+	groups, err := server.db.CountNetworkIDGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]uint)
+	for networkID, count := range groups {
+		counts[strconv.FormatUint(uint64(networkID), 10)] = count
+	}
+	return applyCountThreshold(counts, server.privacyThreshold), nil
+}
+
+type freshnessResponse struct {
+	ID             database.NodeID `json:"id"`
+	AddrUpdated    string          `json:"addr_updated"`
+	ClientVerified string          `json:"client_verified,omitempty"`
+	EnrUpdated     string          `json:"enr_updated,omitempty"`
+	ClientInfo     string          `json:"client_info_updated,omitempty"`
+}
+
+func (server *Server) handleNodeFreshness(w http.ResponseWriter, request *http.Request, id database.NodeID) {
+	freshness, err := server.db.FindNodeFreshness(request.Context(), id)
+	if err != nil {
+		server.log.Error("Failed to find node freshness", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if freshness == nil {
+		http.NotFound(w, request)
+		return
+	}
+
+	response := freshnessResponse{
+		ID:          id,
+		AddrUpdated: freshness.AddrUpdated.Format(time.RFC3339),
+	}
+	if freshness.ClientVerified != nil {
+		response.ClientVerified = freshness.ClientVerified.Format(time.RFC3339)
+	}
+	if freshness.EnrUpdated != nil {
+		response.EnrUpdated = freshness.EnrUpdated.Format(time.RFC3339)
+	}
+	if freshness.ClientInfoUpdated != nil {
+		response.ClientInfo = freshness.ClientInfoUpdated.Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		server.log.Error("Failed to write a freshness response", "err", err)
+	}
+}
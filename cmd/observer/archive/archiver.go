@@ -0,0 +1,179 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Archiver moves the attempt and address history older than a cutoff
+// out of the hot database into cold-storage segment files (see segment.go),
+// keeping the hot database small while retaining the full history.
+type Archiver struct {
+	db  database.DB
+	dir string
+	log log.Logger
+}
+
+func NewArchiver(db database.DB, dir string, logger log.Logger) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the archive directory: %w", err)
+	}
+
+	instance := Archiver{
+		db:  db,
+		dir: dir,
+		log: logger,
+	}
+	return &instance, nil
+}
+
+// Run archives the history older than the given time.
+// Each table is written to its own segment and registered in the index
+// before the archived rows are deleted from the hot database,
+// so a crash mid-run can at worst leave rows duplicated in the hot database,
+// never lost.
+func (archiver *Archiver) Run(ctx context.Context, before time.Time) error {
+	if err := archiver.archiveAttempts(ctx, before); err != nil {
+		return err
+	}
+	return archiver.archiveClientHistory(ctx, before)
+}
+
+func (archiver *Archiver) archiveAttempts(ctx context.Context, before time.Time) error {
+	writer, err := newSegmentWriter(archiver.dir, KindHandshakeAttempts)
+	if err != nil {
+		return err
+	}
+
+	var writeErr error
+	err = archiver.db.EnumerateHandshakeAttemptsBefore(ctx, before, func(id database.NodeID, attempt database.HandshakeAttempt) {
+		if writeErr != nil {
+			return
+		}
+		record := AttemptRecord{
+			NodeID:     string(id),
+			Time:       attempt.Time.Unix(),
+			Success:    attempt.Success,
+			Err:        attempt.Err,
+			DurationMs: attempt.Duration.Milliseconds(),
+		}
+		if attempt.SourceIP != nil {
+			record.SourceIP = attempt.SourceIP.String()
+		}
+		writeErr = writer.add(record, record.Time)
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		writer.discard()
+		return err
+	}
+
+	info, err := writer.finish()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return nil
+	}
+	if err := archiver.registerSegment(info); err != nil {
+		return err
+	}
+
+	deleted, err := archiver.db.DeleteHandshakeAttemptsBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	archiver.log.Info("Archived handshake attempts", "rows", deleted, "segment", info.FileName)
+	return nil
+}
+
+func (archiver *Archiver) archiveClientHistory(ctx context.Context, before time.Time) error {
+	writer, err := newSegmentWriter(archiver.dir, KindClientHistory)
+	if err != nil {
+		return err
+	}
+
+	var writeErr error
+	err = archiver.db.EnumerateClientHistoryBefore(ctx, before, func(id database.NodeID, observed time.Time, clientID string) {
+		if writeErr != nil {
+			return
+		}
+		record := ClientRecord{
+			NodeID:   string(id),
+			Observed: observed.Unix(),
+			ClientID: clientID,
+		}
+		writeErr = writer.add(record, record.Observed)
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		writer.discard()
+		return err
+	}
+
+	info, err := writer.finish()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return nil
+	}
+	if err := archiver.registerSegment(info); err != nil {
+		return err
+	}
+
+	deleted, err := archiver.db.DeleteClientHistoryBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	archiver.log.Info("Archived client history", "rows", deleted, "segment", info.FileName)
+	return nil
+}
+
+func (archiver *Archiver) registerSegment(info *SegmentInfo) error {
+	index, err := readIndex(archiver.dir)
+	if err != nil {
+		return err
+	}
+	index.Segments = append(index.Segments, *info)
+	return writeIndex(archiver.dir, index)
+}
+
+// Loop periodically archives the history older than maxAge.
+func Loop(
+	ctx context.Context,
+	db database.DB,
+	dir string,
+	maxAge time.Duration,
+	period time.Duration,
+	logger log.Logger,
+) {
+	archiver, err := NewArchiver(db, dir, logger)
+	if err != nil {
+		logger.Error("Failed to start the archiver", "err", err)
+		return
+	}
+
+	for ctx.Err() == nil {
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+
+		if err := archiver.Run(ctx, time.Now().Add(-maxAge)); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to archive the history", "err", err)
+			}
+		}
+	}
+}
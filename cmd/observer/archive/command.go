@@ -0,0 +1,67 @@
+package archive
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir    string
+	ArchiveDir string
+	KeepDays   uint
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "archive",
+		Short: "Move old attempt and address history into compressed cold-storage segment files",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withArchiveDir()
+	instance.withKeepDays()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withArchiveDir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.ArchiveDir, "archive-dir", "",
+		"write the segment files to this directory (default <datadir>/observer-archive)")
+}
+
+func (command *Command) withKeepDays() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.KeepDays, "keep-days", 90,
+		"keep this many days of history in the hot database")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
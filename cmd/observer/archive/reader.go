@@ -0,0 +1,104 @@
+package archive
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Reader queries the archived history back out of the segment files.
+// The index keeps the covered time range of each segment,
+// so a query only decompresses the segments overlapping its window.
+type Reader struct {
+	dir      string
+	segments []SegmentInfo
+}
+
+func NewReader(dir string) (*Reader, error) {
+	index, err := readIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	instance := Reader{
+		dir:      dir,
+		segments: index.Segments,
+	}
+	return &instance, nil
+}
+
+// EnumerateAttempts lists the archived handshake attempts of a node
+// made at or after the given time, in segment order.
+// A zero since does not restrict the time range.
+func (reader *Reader) EnumerateAttempts(nodeID string, since time.Time, enumFunc func(record AttemptRecord)) error {
+	return reader.enumerateSegments(KindHandshakeAttempts, since, func(decoder *json.Decoder) error {
+		var record AttemptRecord
+		if err := decoder.Decode(&record); err != nil {
+			return err
+		}
+		if (record.NodeID == nodeID) && !time.Unix(record.Time, 0).Before(since) {
+			enumFunc(record)
+		}
+		return nil
+	})
+}
+
+// EnumerateClientHistory lists the archived client ID observations of a node
+// made at or after the given time, in segment order.
+// A zero since does not restrict the time range.
+func (reader *Reader) EnumerateClientHistory(nodeID string, since time.Time, enumFunc func(record ClientRecord)) error {
+	return reader.enumerateSegments(KindClientHistory, since, func(decoder *json.Decoder) error {
+		var record ClientRecord
+		if err := decoder.Decode(&record); err != nil {
+			return err
+		}
+		if (record.NodeID == nodeID) && !time.Unix(record.Observed, 0).Before(since) {
+			enumFunc(record)
+		}
+		return nil
+	})
+}
+
+func (reader *Reader) enumerateSegments(kind string, since time.Time, decodeFunc func(decoder *json.Decoder) error) error {
+	for _, segment := range reader.segments {
+		if segment.Kind != kind {
+			continue
+		}
+		if !since.IsZero() && (segment.MaxTime < since.Unix()) {
+			continue
+		}
+		if err := reader.enumerateSegment(segment, decodeFunc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (reader *Reader) enumerateSegment(segment SegmentInfo, decodeFunc func(decoder *json.Decoder) error) error {
+	file, err := os.Open(filepath.Join(reader.dir, segment.FileName))
+	if err != nil {
+		return fmt.Errorf("failed to open segment %s: %w", segment.FileName, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	zip, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to decompress segment %s: %w", segment.FileName, err)
+	}
+	defer func() { _ = zip.Close() }()
+
+	decoder := json.NewDecoder(zip)
+	for decoder.More() {
+		if err := decodeFunc(decoder); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("failed to read segment %s: %w", segment.FileName, err)
+		}
+	}
+	return nil
+}
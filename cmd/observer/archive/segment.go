@@ -0,0 +1,176 @@
+package archive
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The archive directory holds compressed, append-only segment files:
+// each archiver run writes the rows it moved out of the hot database
+// into a new gzip-compressed JSON lines file, and registers it in the
+// index file. Segments are never rewritten, so the archive can be
+// synced and backed up incrementally.
+
+const (
+	// KindHandshakeAttempts marks the segments holding handshake attempt rows
+	// (see AttemptRecord).
+	KindHandshakeAttempts = "handshake_attempts"
+	// KindClientHistory marks the segments holding client ID observations
+	// (see ClientRecord).
+	KindClientHistory = "client_history"
+
+	indexFileName = "index.json"
+)
+
+// AttemptRecord is one archived handshake attempt
+// (see database.HandshakeAttempt).
+type AttemptRecord struct {
+	NodeID     string `json:"node_id"`
+	Time       int64  `json:"time"`
+	SourceIP   string `json:"source_ip,omitempty"`
+	Success    bool   `json:"success"`
+	Err        string `json:"err,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// ClientRecord is one archived client ID observation
+// (see node_client_history).
+type ClientRecord struct {
+	NodeID   string `json:"node_id"`
+	Observed int64  `json:"observed"`
+	ClientID string `json:"client_id"`
+}
+
+// SegmentInfo is the index entry of one segment file: the covered time range
+// lets a reader skip the segments outside of the queried window.
+type SegmentInfo struct {
+	FileName string `json:"file_name"`
+	Kind     string `json:"kind"`
+	MinTime  int64  `json:"min_time"`
+	MaxTime  int64  `json:"max_time"`
+	Rows     uint   `json:"rows"`
+	Created  int64  `json:"created"`
+}
+
+type segmentIndex struct {
+	Segments []SegmentInfo `json:"segments"`
+}
+
+func readIndex(dir string) (*segmentIndex, error) {
+	data, err := os.ReadFile(filepath.Join(dir, indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &segmentIndex{}, nil
+		}
+		return nil, fmt.Errorf("failed to read the archive index: %w", err)
+	}
+	var index segmentIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the archive index: %w", err)
+	}
+	return &index, nil
+}
+
+// writeIndex replaces the index atomically, so a crash mid-write
+// can't corrupt the registry of the existing segments.
+func writeIndex(dir string, index *segmentIndex) error {
+	data, err := json.MarshalIndent(index, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the archive index: %w", err)
+	}
+	tmpPath := filepath.Join(dir, indexFileName+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write the archive index: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, indexFileName)); err != nil {
+		return fmt.Errorf("failed to replace the archive index: %w", err)
+	}
+	return nil
+}
+
+// segmentWriter writes one segment file: the records stream into a temp file,
+// and finish renames it into place and returns its index entry.
+type segmentWriter struct {
+	dir     string
+	kind    string
+	file    *os.File
+	zip     *gzip.Writer
+	encoder *json.Encoder
+
+	minTime int64
+	maxTime int64
+	rows    uint
+}
+
+func newSegmentWriter(dir string, kind string) (*segmentWriter, error) {
+	file, err := os.CreateTemp(dir, kind+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a segment file: %w", err)
+	}
+	zip := gzip.NewWriter(file)
+
+	instance := segmentWriter{
+		dir:     dir,
+		kind:    kind,
+		file:    file,
+		zip:     zip,
+		encoder: json.NewEncoder(zip),
+	}
+	return &instance, nil
+}
+
+func (writer *segmentWriter) add(record interface{}, recordTime int64) error {
+	if err := writer.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to write a segment record: %w", err)
+	}
+	if (writer.rows == 0) || (recordTime < writer.minTime) {
+		writer.minTime = recordTime
+	}
+	if recordTime > writer.maxTime {
+		writer.maxTime = recordTime
+	}
+	writer.rows++
+	return nil
+}
+
+// finish seals the segment and renames it into place.
+// An empty segment is discarded and reported with a nil SegmentInfo.
+func (writer *segmentWriter) finish() (*SegmentInfo, error) {
+	if err := writer.zip.Close(); err != nil {
+		_ = writer.file.Close()
+		return nil, fmt.Errorf("failed to finish a segment file: %w", err)
+	}
+	if err := writer.file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close a segment file: %w", err)
+	}
+
+	if writer.rows == 0 {
+		_ = os.Remove(writer.file.Name())
+		return nil, nil
+	}
+
+	created := time.Now().Unix()
+	info := SegmentInfo{
+		FileName: fmt.Sprintf("%s-%d-%d-%d.jsonl.gz", writer.kind, writer.minTime, writer.maxTime, created),
+		Kind:     writer.kind,
+		MinTime:  writer.minTime,
+		MaxTime:  writer.maxTime,
+		Rows:     writer.rows,
+		Created:  created,
+	}
+	if err := os.Rename(writer.file.Name(), filepath.Join(writer.dir, info.FileName)); err != nil {
+		return nil, fmt.Errorf("failed to place a segment file: %w", err)
+	}
+	return &info, nil
+}
+
+// discard drops an unfinished segment.
+func (writer *segmentWriter) discard() {
+	_ = writer.zip.Close()
+	_ = writer.file.Close()
+	_ = os.Remove(writer.file.Name())
+}
@@ -0,0 +1,96 @@
+package bootnodes
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/params"
+)
+
+// Format is the output format of a bootnodes listing.
+type Format string
+
+const (
+	// FormatEnode emits enode:// URLs, suitable for --staticpeers and --bootnodes.
+	FormatEnode Format = "enode"
+	// FormatENR emits the captured EIP-778 node records as enr: strings.
+	// The records carry the signatures of the nodes themselves,
+	// so consumers can verify them without trusting this observer.
+	FormatENR Format = "enr"
+)
+
+// List emits the top-limit most reliable, fork-compatible nodes of the chain
+// in the given format, ranked by their recent sightings
+// (see database.DB.FindBootnodeCandidates).
+// In the ENR format the nodes without a captured record are skipped.
+func List(ctx context.Context, db database.DB, chain string, limit uint, format Format) ([]string, error) {
+	forkIDHashes, err := ChainForkIDHashes(chain)
+	if err != nil {
+		return nil, err
+	}
+	networkID := uint(params.NetworkIDByChainName(chain))
+
+	ids, err := db.FindBootnodeCandidates(ctx, networkID, forkIDHashes, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		switch format {
+		case FormatEnode:
+			addr, err := db.FindNodeAddr(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if addr == nil {
+				continue
+			}
+			node, err := node_utils.MakeNodeFromAddr(id, *addr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to make a node from its address: %w", err)
+			}
+			lines = append(lines, node.URLv4())
+
+		case FormatENR:
+			nodeENR, err := db.FindNodeENR(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if nodeENR == nil {
+				continue
+			}
+			lines = append(lines, "enr:"+base64.RawURLEncoding.EncodeToString(nodeENR.Record))
+
+		default:
+			return nil, fmt.Errorf("unsupported bootnodes format: %s", format)
+		}
+	}
+	return lines, nil
+}
+
+// ChainForkIDHashes computes the canonical fork ID hashes of the chain,
+// one per fork point, so that nodes on any canonical fork are accepted.
+func ChainForkIDHashes(chain string) ([]string, error) {
+	chainConfig := params.ChainConfigByChainName(chain)
+	genesisHash := params.GenesisHashByChainName(chain)
+	if (chainConfig == nil) || (genesisHash == nil) {
+		return nil, fmt.Errorf("unknown chain %s", chain)
+	}
+
+	heightForks := forkid.GatherForks(chainConfig)
+	hashes := make([]string, 0, len(heightForks)+1)
+	for i := 0; i <= len(heightForks); i++ {
+		var head uint64
+		if i > 0 {
+			head = heightForks[i-1]
+		}
+		id := forkid.NewIDFromForks(heightForks, *genesisHash, head)
+		hashes = append(hashes, fmt.Sprintf("%x", id.Hash))
+	}
+	return hashes, nil
+}
@@ -0,0 +1,77 @@
+package bootnodes
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/ledgerwatch/erigon/params/networkname"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+	Chain   string
+
+	// Limit is how many top nodes to emit.
+	Limit uint
+	// Format selects the output format: "enode" or "enr" (see Format).
+	Format string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "bootnodes",
+		Short: "Emit the most reliable fork-compatible nodes as enode:// URLs or ENR records",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withChain()
+	instance.withLimit()
+	instance.withFormat()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withChain() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Chain, utils.ChainFlag.Name, networkname.MainnetChainName, utils.ChainFlag.Usage)
+}
+
+func (command *Command) withLimit() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.Limit, "limit", 20, "how many top nodes to emit")
+}
+
+func (command *Command) withFormat() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Format, "format", string(FormatEnode),
+		"output format: enode (enode:// URLs) or enr (captured EIP-778 records)")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
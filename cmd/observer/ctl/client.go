@@ -0,0 +1,49 @@
+package ctl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const callTimeout = 30 * time.Second
+
+// Call sends one action over the control socket of a running observer
+// (see observer.ControlServer) and returns the response body.
+func Call(ctx context.Context, socketPath string, action string, arg string) (string, error) {
+	dialer := net.Dialer{Timeout: callTimeout}
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to the control socket (is the observer running with --control-socket?): %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(callTimeout))
+
+	request := action
+	if arg != "" {
+		request += " " + arg
+	}
+	if _, err := fmt.Fprintln(conn, request); err != nil {
+		return "", fmt.Errorf("failed to send the control request: %w", err)
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the control response: %w", err)
+	}
+
+	status := string(response)
+	body := ""
+	if separator := strings.IndexByte(status, '\n'); separator >= 0 {
+		body = strings.TrimSuffix(status[separator+1:], "\n")
+		status = status[:separator]
+	}
+
+	if status != "ok" {
+		return "", fmt.Errorf("%s", strings.TrimPrefix(status, "error: "))
+	}
+	return body, nil
+}
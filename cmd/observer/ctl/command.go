@@ -0,0 +1,110 @@
+package ctl
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	// Socket is the control socket path of the running observer
+	// (see the --control-socket flag of the crawler).
+	Socket string
+}
+
+// actionSpec describes one ctl subcommand and the control action it sends.
+type actionSpec struct {
+	use    string
+	short  string
+	action string
+	// argCount is how many positional arguments the subcommand takes;
+	// they are joined into the action argument.
+	argCount int
+}
+
+var actionSpecs = []actionSpec{
+	{
+		use:    "pause",
+		short:  "Pause the crawl of the running observer",
+		action: "pause",
+	},
+	{
+		use:    "resume",
+		short:  "Resume a paused crawl",
+		action: "resume",
+	},
+	{
+		use:      "bandwidth <bytes-per-sec>",
+		short:    "Change the bandwidth cap at runtime (0 removes the cap)",
+		action:   "bandwidth",
+		argCount: 1,
+	},
+	{
+		use:    "snapshot",
+		short:  "Force a stats snapshot outside of the regular schedule",
+		action: "snapshot",
+	},
+	{
+		use:      "export <file>",
+		short:    "Export the known nodes of the running observer to a JSON lines file",
+		action:   "export",
+		argCount: 1,
+	},
+	{
+		use:    "queues",
+		short:  "Dump the fill levels of the internal crawler queues",
+		action: "queues",
+	},
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "ctl",
+		Short: "Control a running observer over its unix control socket",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withSocket()
+
+	return &instance
+}
+
+func (command *Command) withSocket() {
+	flags := command.command.PersistentFlags()
+	flags.StringVar(&command.flags.Socket, "socket", "",
+		"path of the control socket of the running observer (see --control-socket)")
+	if err := command.command.MarkPersistentFlagRequired("socket"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags, action string, arg string) error) {
+	for _, spec := range actionSpecs {
+		action := spec.action
+		subCommand := cobra.Command{
+			Use:   spec.use,
+			Short: spec.short,
+			Args:  cobra.ExactArgs(spec.argCount),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				arg := ""
+				if len(args) > 0 {
+					arg = args[0]
+				}
+				return runFunc(cmd.Context(), command.flags, action, arg)
+			},
+		}
+		command.command.AddCommand(&subCommand)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
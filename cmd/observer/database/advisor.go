@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// NamedQuery pairs a query of this package with a stable name for diagnostics.
+type NamedQuery struct {
+	Name string
+	SQL  string
+}
+
+// advisorQueries is the query set the index advisor examines:
+// the recurring reads of the crawl scheduling, the reports and the exports.
+func advisorQueries() []NamedQuery {
+	return []NamedQuery{
+		{"FindFrontierCandidates", sqlFindFrontierCandidates},
+		{"FindCandidates", sqlFindCandidates},
+		{"EnumerateNodeExports", sqlEnumerateNodeExports},
+		{"EnumerateClientIDs", sqlEnumerateClientIDs},
+		{"EnumerateClientIDsAsOf", sqlEnumerateClientIDsAsOf},
+		{"EnumerateHandshakeErrorClientIDs", sqlEnumerateHandshakeErrorClientIDs},
+		{"EnumerateNodeLifetimes", sqlEnumerateNodeLifetimes},
+		{"CountNodes", sqlCountNodes},
+		{"CountIPs", sqlCountIPs},
+		{"CountClientGroups", sqlCountClientGroups},
+		{"CountNetworkIDGroups", sqlCountNetworkIDGroups},
+		{"CountHandshakeErrorGroups", sqlCountHandshakeErrorGroups},
+		{"CountNodesByCountry", sqlCountNodesByCountry},
+		{"CountNodesByCapability", sqlCountNodesByCapability},
+		{"CountProtocolViolations", sqlCountProtocolViolations},
+		{"CountHandshakeOutcomesSince", sqlCountHandshakeOutcomesSince},
+	}
+}
+
+// advisedIndex is an index the advisor may suggest when its table shows up
+// as a full scan in a query plan and its column appears in the query.
+// The list includes the default schema indexes,
+// so the advisor also repairs databases that lost them.
+type advisedIndex struct {
+	table  string
+	column string
+	name   string
+	// language=SQL
+	ddl string
+}
+
+var advisedIndexes = []advisedIndex{
+	{"nodes", "change_seq", "idx_nodes_change_seq", "CREATE INDEX idx_nodes_change_seq ON nodes (change_seq)"},
+	{"nodes", "crawl_retry_time", "idx_nodes_crawl_retry_time", "CREATE INDEX idx_nodes_crawl_retry_time ON nodes (crawl_retry_time)"},
+	{"nodes", "client_id", "idx_nodes_client_id", "CREATE INDEX idx_nodes_client_id ON nodes (client_id)"},
+	{"nodes", "network_id", "idx_nodes_network_id", "CREATE INDEX idx_nodes_network_id ON nodes (network_id)"},
+	{"nodes", "addr_updated", "idx_nodes_addr_updated", "CREATE INDEX idx_nodes_addr_updated ON nodes (addr_updated)"},
+	{"handshake_attempts", "node_id", "idx_handshake_attempts_node_id", "CREATE INDEX idx_handshake_attempts_node_id ON handshake_attempts (node_id)"},
+	{"handshake_attempts", "attempt_time", "idx_handshake_attempts_attempt_time", "CREATE INDEX idx_handshake_attempts_attempt_time ON handshake_attempts (attempt_time)"},
+	{"node_client_history", "observed", "idx_node_client_history_observed", "CREATE INDEX idx_node_client_history_observed ON node_client_history (observed)"},
+	{"node_protocol_violations", "node_id", "idx_node_protocol_violations_node_id", "CREATE INDEX idx_node_protocol_violations_node_id ON node_protocol_violations (node_id)"},
+	{"crawl_frontier", "priority", "idx_crawl_frontier_priority", "CREATE INDEX idx_crawl_frontier_priority ON crawl_frontier (priority)"},
+}
+
+// scanAliases maps the table aliases of the known queries back to their tables,
+// because EXPLAIN QUERY PLAN reports a scan under the alias.
+var scanAliases = map[string]string{
+	"frontier": "crawl_frontier",
+	"history":  "node_client_history",
+}
+
+// smallTableRows is the table size below which a full scan is considered fine:
+// an index on a tiny table costs more upkeep than it saves.
+const smallTableRows = 1000
+
+// IndexAdvice flags a full table scan found in the plan of a known query,
+// optionally with a missing index that would serve the scan.
+type IndexAdvice struct {
+	Query  string
+	Detail string // the EXPLAIN QUERY PLAN line that triggered the advice
+	Table  string
+	Rows   uint
+
+	// IndexName and DDL suggest a missing index, or stay empty
+	// if the advisor has nothing appropriate for this scan
+	// (e.g. the table is too small for an index to pay off).
+	IndexName string
+	DDL       string
+}
+
+// AdviseIndexes runs EXPLAIN QUERY PLAN over the known query set of this package
+// and flags the full table scans, suggesting missing indexes where the table
+// is large enough for an index to pay off. Only the SQLite backend supports it.
+func AdviseIndexes(ctx context.Context, db DB) ([]IndexAdvice, error) {
+	if _, ok := db.(*DBPostgres); ok {
+		return nil, fmt.Errorf("the index advisor supports only the SQLite backend")
+	}
+	sqlite, err := rawDB(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var advices []IndexAdvice
+	for _, query := range advisorQueries() {
+		scans, err := sqlite.explainFullScans(ctx, query.SQL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to explain query %s: %w", query.Name, err)
+		}
+
+		for _, scan := range scans {
+			table := scan.table
+			if alias, ok := scanAliases[table]; ok {
+				table = alias
+			}
+
+			advice := IndexAdvice{
+				Query:  query.Name,
+				Detail: scan.detail,
+				Table:  table,
+			}
+			if advice.Rows, err = sqlite.countTableRows(ctx, table); err != nil {
+				return nil, err
+			}
+
+			if advice.Rows >= smallTableRows {
+				if err := sqlite.suggestIndex(ctx, &advice, query.SQL); err != nil {
+					return nil, err
+				}
+			}
+			advices = append(advices, advice)
+		}
+	}
+	return advices, nil
+}
+
+// CreateAdvisedIndexes creates the indexes suggested by AdviseIndexes
+// and returns how many were created.
+func CreateAdvisedIndexes(ctx context.Context, db DB, advices []IndexAdvice) (uint, error) {
+	sqlite, err := rawDB(db)
+	if err != nil {
+		return 0, err
+	}
+
+	var created uint
+	createdNames := make(map[string]struct{})
+	for _, advice := range advices {
+		if advice.DDL == "" {
+			continue
+		}
+		if _, ok := createdNames[advice.IndexName]; ok {
+			continue
+		}
+		if _, err := sqlite.db.ExecContext(ctx, advice.DDL); err != nil {
+			return created, fmt.Errorf("failed to create index %s: %w", advice.IndexName, err)
+		}
+		createdNames[advice.IndexName] = struct{}{}
+		created++
+	}
+	return created, nil
+}
+
+// fullScan is a "SCAN <table>" step of a query plan that uses no index.
+type fullScan struct {
+	table  string
+	detail string
+}
+
+func (db *DBSQLite) explainFullScans(ctx context.Context, query string) ([]fullScan, error) {
+	// the placeholders are bound with zero values: the plan shape doesn't depend on them
+	args := make([]interface{}, strings.Count(query, "?"))
+	for i := range args {
+		args[i] = 0
+	}
+
+	cursor, err := db.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var scans []fullScan
+	for cursor.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := cursor.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, fmt.Errorf("failed to read a query plan step: %w", err)
+		}
+		if !strings.HasPrefix(detail, "SCAN ") || strings.Contains(detail, "USING") {
+			continue
+		}
+		table := strings.TrimPrefix(detail, "SCAN ")
+		if spacePos := strings.IndexRune(table, ' '); spacePos >= 0 {
+			table = table[:spacePos]
+		}
+		scans = append(scans, fullScan{table, detail})
+	}
+	return scans, cursor.Err()
+}
+
+func (db *DBSQLite) countTableRows(ctx context.Context, table string) (uint, error) {
+	var count uint
+	err := db.db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %q", table)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count the rows of %s: %w", table, err)
+	}
+	return count, nil
+}
+
+// suggestIndex fills in the first advised index of the scanned table
+// whose column the query uses and the database doesn't have yet.
+func (db *DBSQLite) suggestIndex(ctx context.Context, advice *IndexAdvice, querySQL string) error {
+	for _, index := range advisedIndexes {
+		if (index.table != advice.Table) || !strings.Contains(querySQL, index.column) {
+			continue
+		}
+		exists, err := db.indexExists(ctx, index.name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			advice.IndexName = index.name
+			advice.DDL = index.ddl
+			return nil
+		}
+	}
+	return nil
+}
+
+func (db *DBSQLite) indexExists(ctx context.Context, name string) (bool, error) {
+	var count uint
+	err := db.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE (type = 'index') AND (name = ?)", name).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check index %s: %w", name, err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/golang/snappy"
+)
+
+// Codec encodes large column values (e.g. ENR entries) before they are stored,
+// so that they can be compressed transparently without changing the callers.
+// The codec name of each stored value is recorded next to it (see EncodeColumn),
+// so that old values stay readable after the default codec changes.
+type Codec interface {
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+var codecs = make(map[string]Codec)
+
+// RegisterCodec adds a codec to the registry used by DecodeColumn.
+func RegisterCodec(codec Codec) {
+	codecs[codec.Name()] = codec
+}
+
+func init() {
+	RegisterCodec(PlainCodec{})
+	RegisterCodec(SnappyCodec{})
+}
+
+// codecMinCompressSize is the value size below which compression is not worth
+// the codec overhead, and DefaultCodec falls back to PlainCodec.
+const codecMinCompressSize = 64
+
+// DefaultCodec picks the codec for storing a new value.
+func DefaultCodec(data []byte) Codec {
+	if len(data) < codecMinCompressSize {
+		return PlainCodec{}
+	}
+	return SnappyCodec{}
+}
+
+// EncodeColumn encodes a column value with the given codec,
+// prefixing it with the codec name so that DecodeColumn is self-describing.
+func EncodeColumn(codec Codec, data []byte) ([]byte, error) {
+	encoded, err := codec.Encode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode a column value: %w", err)
+	}
+
+	name := codec.Name()
+	result := make([]byte, 0, 1+len(name)+len(encoded))
+	result = append(result, byte(len(name)))
+	result = append(result, name...)
+	result = append(result, encoded...)
+	return result, nil
+}
+
+// DecodeColumn decodes a column value produced by EncodeColumn.
+func DecodeColumn(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	nameLen := int(data[0])
+	if 1+nameLen > len(data) {
+		return nil, fmt.Errorf("failed to decode a column value: truncated codec name")
+	}
+	name := string(data[1 : 1+nameLen])
+
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("failed to decode a column value: unknown codec %q", name)
+	}
+	return codec.Decode(data[1+nameLen:])
+}
+
+// PlainCodec stores a value as is.
+type PlainCodec struct{}
+
+func (PlainCodec) Name() string { return "plain" }
+
+func (PlainCodec) Encode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (PlainCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// SnappyCodec compresses a value with snappy,
+// the same compression the RLPx protocol uses on the wire.
+type SnappyCodec struct{}
+
+func (SnappyCodec) Name() string { return "snappy" }
+
+func (SnappyCodec) Encode(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (SnappyCodec) Decode(data []byte) ([]byte, error) {
+	decoded, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress a column value: %w", err)
+	}
+	return decoded, nil
+}
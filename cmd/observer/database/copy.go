@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// copyBatchSize is the number of rows inserted per transaction during CopyDB.
+const copyBatchSize = 1000
+
+// CopyProgress reports the progress of CopyDB after each copied batch.
+type CopyProgress func(table string, copiedRows uint, totalRows uint)
+
+// CopyDB streams all tables from the source database to the target one,
+// so that operators can switch backends without losing history.
+// The target schema is created when the target backend is opened,
+// and the target tables are expected to be empty.
+// After each table the copied row count is checked against the source,
+// so the crawler must not write to the source during the copy.
+func CopyDB(ctx context.Context, source DB, target DB, progress CopyProgress) error {
+	sourceRaw, err := rawDB(source)
+	if err != nil {
+		return err
+	}
+	targetRaw, err := rawDB(target)
+	if err != nil {
+		return err
+	}
+
+	tables, err := source.DescribeSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		// the target records its own schema versions when it is opened (see migrateSchema)
+		if table.Name == "schema_version" {
+			continue
+		}
+		if err := copyTable(ctx, sourceRaw, targetRaw, table, progress); err != nil {
+			return fmt.Errorf("failed to copy table %s: %w", table.Name, err)
+		}
+	}
+
+	if targetPostgres, ok := target.(*DBPostgres); ok {
+		return targetPostgres.resetSequences(ctx)
+	}
+	return nil
+}
+
+// rawDB gives access to the shared statement-level implementation of a backend.
+func rawDB(db DB) (*DBSQLite, error) {
+	switch typed := db.(type) {
+	case *DBSQLite:
+		return typed, nil
+	case *DBPostgres:
+		return &typed.DBSQLite, nil
+	default:
+		return nil, fmt.Errorf("the database backend %T does not support copying", db)
+	}
+}
+
+func copyTable(ctx context.Context, source *DBSQLite, target *DBSQLite, table SchemaTable, progress CopyProgress) error {
+	columnNames := make([]string, len(table.Columns))
+	placeholders := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		columnNames[i] = fmt.Sprintf("%q", column.Name)
+		placeholders[i] = "?"
+	}
+	columns := strings.Join(columnNames, ", ")
+
+	// the table and column names come from the schema introspection, and are safe to quote
+	selectQuery := fmt.Sprintf(`SELECT %s FROM %q`, columns, table.Name)
+	insertQuery := target.stmt(fmt.Sprintf(`INSERT INTO %q (%s) VALUES (%s)`,
+		table.Name, columns, strings.Join(placeholders, ", ")))
+
+	cursor, err := source.db.QueryContext(ctx, selectQuery)
+	if err != nil {
+		return fmt.Errorf("failed to read the source rows: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	values := make([]interface{}, len(table.Columns))
+	pointers := make([]interface{}, len(table.Columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	tx, err := target.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin a target transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var copiedRows uint
+	for cursor.Next() {
+		if err := cursor.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to read a source row: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, insertQuery, values...); err != nil {
+			return fmt.Errorf("failed to insert a target row: %w", err)
+		}
+		copiedRows++
+
+		if copiedRows%copyBatchSize == 0 {
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit a target batch: %w", err)
+			}
+			if progress != nil {
+				progress(table.Name, copiedRows, table.RowCount)
+			}
+			if tx, err = target.db.BeginTx(ctx, nil); err != nil {
+				return fmt.Errorf("failed to begin a target transaction: %w", err)
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to iterate the source rows: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit the target rows: %w", err)
+	}
+	if progress != nil {
+		progress(table.Name, copiedRows, table.RowCount)
+	}
+
+	if copiedRows != table.RowCount {
+		return fmt.Errorf("consistency check failed: copied %d rows, but the source reported %d"+
+			" (was the source written to during the copy?)", copiedRows, table.RowCount)
+	}
+	return nil
+}
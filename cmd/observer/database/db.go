@@ -0,0 +1,926 @@
+package database
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+type NodeAddr1 struct {
+	IP       net.IP
+	PortDisc uint16
+	PortRLPx uint16
+}
+
+type NodeAddr struct {
+	NodeAddr1
+	IPv6 NodeAddr1
+}
+
+// ClientIDObservation is a pending client ID update of a node (see NodeWriteBatch).
+type ClientIDObservation struct {
+	ClientID string
+	Quality  ObservationQuality
+}
+
+// NodeWriteBatch is a coalesced set of the crawler hot-path writes,
+// applied in a single transaction by DB.FlushNodeWrites (see DBWriteCache).
+// PingErrors counts how many UpdatePingError calls were absorbed per node;
+// for the addresses and client IDs only the latest pending value is kept.
+type NodeWriteBatch struct {
+	Addrs      map[NodeID]NodeAddr
+	PingErrors map[NodeID]uint
+	ClientIDs  map[NodeID]ClientIDObservation
+}
+
+func MakeNodeWriteBatch() NodeWriteBatch {
+	return NodeWriteBatch{
+		Addrs:      make(map[NodeID]NodeAddr),
+		PingErrors: make(map[NodeID]uint),
+		ClientIDs:  make(map[NodeID]ClientIDObservation),
+	}
+}
+
+// Size is the number of pending writes in the batch.
+func (batch *NodeWriteBatch) Size() int {
+	return len(batch.Addrs) + len(batch.PingErrors) + len(batch.ClientIDs)
+}
+
+// HandshakeAttempt describes a single outgoing RLPx dial to a node.
+// SourceIP is the local address the dial originated from
+// (relevant on multi-homed hosts, see CommandFlags.SourceIPs).
+type HandshakeAttempt struct {
+	Time     time.Time
+	SourceIP net.IP
+	Success  bool
+	Err      string
+	Duration time.Duration
+}
+
+// HandshakeError is one entry of the handshake error history of a node
+// (see InsertHandshakeError). StringCode matches observer.HandshakeErrorID.
+type HandshakeError struct {
+	StringCode string
+	Time       time.Time
+}
+
+// NodeEventClientChanged is emitted when the stored client ID of a node changes,
+// including the first identification (with no old value).
+const NodeEventClientChanged = "client_changed"
+
+// NodeEventDiscovered is emitted when a node is seen for the first time,
+// with the discovery address as the new value.
+const NodeEventDiscovered = "discovered"
+
+// NodeEventWentOffline is emitted when a node transitions
+// into the unreachable class (see UpdateReachability).
+const NodeEventWentOffline = "went_offline"
+
+// NodeEvent is a recorded attribute transition of a node,
+// e.g. a client upgrade (see NodeEventClientChanged).
+// The old and the new value are captured atomically with the update itself,
+// so consumers can track transitions without a read-before-write race.
+type NodeEvent struct {
+	Kind     string
+	OldValue *string
+	NewValue *string
+	Time     time.Time
+}
+
+// DialFamilyStats sums the dial outcomes per address family,
+// so the IPv6 reachability can be compared against IPv4
+// (see the dual-stack handshake path in the observer package).
+type DialFamilyStats struct {
+	AttemptsV4 uint
+	SuccessV4  uint
+	AttemptsV6 uint
+	SuccessV6  uint
+}
+
+// OperatorSettings are the node settings managed by a verified node operator
+// through the self-service API (see the /operator endpoints in the api package):
+// a label correction, and the opt-out flag that keeps the node
+// out of the published exports.
+type OperatorSettings struct {
+	Label  *string
+	OptOut bool
+}
+
+// NodeHistoryEntry is one node ID observed behind an endpoint,
+// with the span it was seen there (see FindNodeHistory).
+type NodeHistoryEntry struct {
+	NodeID    NodeID
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// KeyRotationStats describes an endpoint (an IP and RLPx port pair)
+// that reappeared with different node IDs over time:
+// either the operator rotated the node key, or the address changed hands
+// (see EnumerateKeyRotations).
+type KeyRotationStats struct {
+	IP        string
+	PortRLPx  uint16
+	KeyCount  uint
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// NodeEventFeedEntry is a node event together with its global sequence number
+// and the node it belongs to, as consumed by the change feed
+// (see EnumerateNodeEventsAfter).
+type NodeEventFeedEntry struct {
+	Num    uint64
+	NodeID NodeID
+	Event  NodeEvent
+}
+
+// NodeCapability is a protocol capability a node announced in its Hello message,
+// e.g. eth/66 or snap/1.
+type NodeCapability struct {
+	Name    string
+	Version uint
+}
+
+// ProtocolViolation describes a wire protocol non-conformance observed during a handshake,
+// e.g. an oversized frame, a malformed RLP message, or an invalid snappy compression.
+// Code is a stable category name, and Detail is the free-form underlying error (optional).
+type ProtocolViolation struct {
+	Time   time.Time
+	Code   string
+	Detail string
+}
+
+// ObservationQuality tells how trustworthy a stored attribute is, based on how it was obtained.
+// Queries take a minimum quality so that consumers can restrict themselves to verified facts.
+type ObservationQuality uint
+
+const (
+	// ObservationQualityInferred - derived from other stored data, e.g. parsed client info.
+	ObservationQualityInferred ObservationQuality = iota
+	// ObservationQualitySelfReported - claimed by the node without verification, e.g. an ENR entry.
+	ObservationQualitySelfReported
+	// ObservationQualityVerified - observed directly in a protocol exchange, e.g. an RLPx handshake.
+	ObservationQualityVerified
+)
+
+func (quality ObservationQuality) String() string {
+	switch quality {
+	case ObservationQualitySelfReported:
+		return "self-reported"
+	case ObservationQualityVerified:
+		return "verified"
+	default:
+		return "inferred"
+	}
+}
+
+// The discovery protocol versions a node was found with (see nodes.disc_version).
+// A node reachable through both protocols is marked with DiscVersionBoth.
+const (
+	DiscVersionV4   uint = 4
+	DiscVersionV5   uint = 5
+	DiscVersionBoth uint = 45
+)
+
+// ClientInfo is the result of parsing a client ID like "Geth/v1.10.15-stable/linux-amd64/go1.17.5".
+type ClientInfo struct {
+	Name    string
+	Version string
+	OS      string
+	Arch    string
+}
+
+// MonitoredNode is a node whose availability is tracked against an SLO.
+type MonitoredNode struct {
+	ID         NodeID
+	Label      string
+	SLOTarget  float64 // target availability, e.g. 0.995
+	WindowDays uint
+}
+
+// SLOStatus is the persisted availability of a monitored node within its SLO window.
+// BudgetBurn is the consumed fraction of the error budget: 1.0 means the budget is exhausted.
+type SLOStatus struct {
+	ID           NodeID
+	WindowStart  time.Time
+	AttemptCount uint
+	SuccessCount uint
+	Availability float64
+	BudgetBurn   float64
+}
+
+// ProbePolicy throttles the handshake probing of a group of nodes.
+// A policy matches a node by the label of its monitored_nodes entry (Tag),
+// by its IP subnet, or by its GeoIP country code: every configured criterion must match.
+// MinInterval caps the probe frequency, and the quiet hours pause probing
+// within a local-time window (QuietStartHour may wrap past midnight over QuietEndHour).
+type ProbePolicy struct {
+	Name           string
+	Tag            string
+	Subnet         string // CIDR, e.g. 10.2.0.0/16
+	Country        string // ISO country code, e.g. DE
+	MinInterval    time.Duration
+	QuietStartHour int // equal start and end hours mean no quiet window
+	QuietEndHour   int
+	UTCOffset      time.Duration // the local timezone of the matched nodes
+	Added          time.Time
+}
+
+// ProbeTraits is what the probe policies match a node on (see ProbePolicy),
+// along with the time of the last handshake attempt.
+type ProbeTraits struct {
+	IP          *string
+	Country     *string
+	Tag         *string
+	LastAttempt *time.Time
+}
+
+// FrontierEntry is an uncrawled node awaiting its first crawl.
+// Source tells where the node was discovered: a neighbor node ID or "bootnode".
+type FrontierEntry struct {
+	ID       NodeID
+	Priority float64
+	Source   string
+	Depth    uint
+}
+
+// EnrEntry is a single key-value pair of a node record.
+// Value holds the raw RLP encoding of the value.
+type EnrEntry struct {
+	Key   string
+	Value []byte
+}
+
+// NodeENR is the raw signed RLP encoding of a node record and its sequence number.
+// Unlike the decoded EnrEntry pairs, the raw record keeps the signature,
+// so it can be re-verified and re-served later.
+type NodeENR struct {
+	Seq    uint64
+	Record []byte
+}
+
+// SchemaColumn describes a column of a live database table.
+type SchemaColumn struct {
+	Name         string
+	Type         string
+	NotNull      bool
+	IsPrimaryKey bool
+}
+
+// SchemaTable describes a table of the live database schema.
+type SchemaTable struct {
+	Name     string
+	Columns  []SchemaColumn
+	Indexes  []string
+	RowCount uint
+}
+
+// NodeFreshness tells when each stored attribute of a node was last verified,
+// so that consumers can judge staleness instead of treating all stored data as current.
+type NodeFreshness struct {
+	AddrUpdated time.Time
+	// ClientVerified is the time of the last successful handshake.
+	ClientVerified    *time.Time
+	EnrUpdated        *time.Time
+	ClientInfoUpdated *time.Time
+}
+
+// NodeLifetime tells how long a node has been part of the network:
+// FirstSeen is when its address was first discovered,
+// and LastSeen is the latest reachability evidence
+// (an address update or a successful handshake).
+type NodeLifetime struct {
+	ClientID  *string
+	ASN       *uint
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// NodeExport is the downstream-facing snapshot of a node row.
+// ChangeSeq orders the rows by their last change (see EnumerateNodeExports),
+// so that consumers can sync incrementally from a checkpoint.
+type NodeExport struct {
+	ID          NodeID
+	IP          *string
+	PortRLPx    *uint16
+	ClientID    *string
+	NetworkID   *uint
+	EthVersion  *uint
+	ASN         *uint
+	AddrUpdated time.Time
+	ChangeSeq   uint64
+}
+
+// NodeExportFilter restricts EnumerateNodeExports to a subset of the nodes.
+// Zero-valued fields do not restrict anything.
+type NodeExportFilter struct {
+	// ClientIDPrefix keeps only the nodes whose client ID starts with this prefix, e.g. "erigon".
+	ClientIDPrefix string
+	// NetworkID keeps only the nodes of this network.
+	NetworkID uint
+	// SeenSince keeps only the nodes seen after this moment
+	// (an address update or a successful handshake).
+	SeenSince time.Time
+	// Limit caps how many rows are returned. Together with the change sequence
+	// cursor it allows pulling a big export in bounded pages.
+	Limit uint
+}
+
+// NodeSightingPing and NodeSightingHandshake are the kinds of liveness evidence
+// recorded in the node_sightings table: a successful ping or a successful handshake.
+const (
+	NodeSightingPing      = "ping"
+	NodeSightingHandshake = "handshake"
+)
+
+// The reachability classes of a node (see UpdateReachability):
+// ReachabilityPublic means an RLPx connection was established
+// (even if the handshake then failed at the protocol level),
+// ReachabilityDiscoveryOnly means the node answers discovery pings
+// but refuses TCP (typically a NATed node without port forwarding),
+// and ReachabilityUnreachable means even the pings go unanswered.
+const (
+	ReachabilityPublic        = "public"
+	ReachabilityDiscoveryOnly = "discovery_only"
+	ReachabilityUnreachable   = "unreachable"
+)
+
+// ReachabilityCount is how many nodes fall into one reachability class
+// (see CountReachability).
+type ReachabilityCount struct {
+	Reachability string
+	Count        uint
+}
+
+// HandshakeHourStats is one time-of-day bucket of the handshake outcomes:
+// the successes and failures of the nodes of one country
+// within one UTC day-of-week (0 - Sunday) and hour-of-day.
+// An empty Country groups the nodes without a known location.
+type HandshakeHourStats struct {
+	DayOfWeek    uint
+	Hour         uint
+	Country      string
+	SuccessCount uint
+	FailureCount uint
+}
+
+// BadRow is a value that failed the strict read validation and was quarantined
+// into the bad_rows table instead of failing the query (see EnableStrictRead).
+type BadRow struct {
+	NodeID   NodeID
+	Field    string
+	Value    string
+	Reason   string
+	Detected time.Time
+}
+
+// NodeLatencyConnect, NodeLatencyHandshake and NodeLatencyPing are the kinds
+// of latency samples recorded in the node_latency table: the TCP connect time,
+// the RLPx handshake duration, and the discovery ping round trip.
+// Only successful attempts are recorded: a failed attempt measures a timeout
+// rather than the network.
+const (
+	NodeLatencyConnect   = "connect"
+	NodeLatencyHandshake = "handshake"
+	NodeLatencyPing      = "ping"
+)
+
+// NodeUptime summarizes the recorded sightings of a node (see node_sightings).
+// The uptime percentages are the fraction of the window hours with a sighting,
+// so a node only probed every few hours can't reach 1.0 even if it never failed.
+type NodeUptime struct {
+	ID         NodeID
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	UptimeDay  float64 // over the last 24 hours
+	UptimeWeek float64 // over the last 7 days
+}
+
+// NodeFacts are the stored attributes of a node that the classification rules
+// (the "rules" enrichment stage) evaluate against. Nil fields are unknown.
+type NodeFacts struct {
+	IP           *string
+	NetworkID    *uint
+	EthVersion   *uint
+	ASN          *uint
+	Country      *string
+	City         *string
+	ClientID     *string
+	ForkIDHash   *string
+	IPReputation *float64
+}
+
+// IPReputationScore is a cached IP reputation provider result (see the enrich package).
+// Score is the abuse confidence in [0, 1], with 0 meaning clean.
+type IPReputationScore struct {
+	Score   float64
+	Checked time.Time
+}
+
+// NodeGeo is the geographic location of a node IP, resolved by the GeoIP enrichment.
+// Country is an ISO 3166-1 alpha-2 code. Empty (or zero) fields are unknown,
+// and keep the already stored values when updating.
+type NodeGeo struct {
+	Country string
+	City    string
+	ASN     uint
+}
+
+// EnrichmentAttribute names a per-node derived attribute whose freshness
+// the database tracks (see MarkEnrichmentFresh and IsEnrichmentFresh),
+// so that the refresh policy lives in one place
+// instead of ad-hoc timestamp comparisons sprinkled across the stages.
+type EnrichmentAttribute string
+
+const (
+	EnrichmentAttributeGeo          EnrichmentAttribute = "geo"
+	EnrichmentAttributeRDNS         EnrichmentAttribute = "rdns"
+	EnrichmentAttributeReachability EnrichmentAttribute = "reachability"
+)
+
+// enrichmentTTLs is how long each attribute stays fresh after a refresh:
+// geo locations move rarely, reverse DNS names change occasionally,
+// and a reachability observation goes stale within the hour.
+var enrichmentTTLs = map[EnrichmentAttribute]time.Duration{
+	EnrichmentAttributeGeo:          30 * 24 * time.Hour,
+	EnrichmentAttributeRDNS:         7 * 24 * time.Hour,
+	EnrichmentAttributeReachability: time.Hour,
+}
+
+// EnrichmentTTL is how long the given attribute stays fresh after a refresh.
+func EnrichmentTTL(attribute EnrichmentAttribute) time.Duration {
+	return enrichmentTTLs[attribute]
+}
+
+// SeenWindowStats is the materialized size of the deduplicated set of nodes
+// seen within a rolling window (an address update or a successful handshake).
+// It is refreshed periodically (see RefreshSeenWindowStats),
+// so that the common report queries stay cheap on large histories.
+type SeenWindowStats struct {
+	Window    time.Duration
+	NodeCount uint
+	IPCount   uint
+	Refreshed time.Time
+}
+
+// StatsSnapshot is a point-in-time record of the network-wide statistics:
+// the total and compatible node counts, the distinct IP count,
+// the estimated operator count (see EstimateOperatorCount),
+// and the node counts per canonical client name.
+// Snapshots are taken periodically (see InsertStatsSnapshot),
+// so that the reports can show trends over days and weeks.
+type StatsSnapshot struct {
+	Time             time.Time
+	TotalNodes       uint
+	CompatibleNodes  uint
+	DistinctIPs      uint
+	OperatorEstimate uint
+	ClientCounts     map[string]uint
+}
+
+// VantagePointStats aggregates the handshake attempts made from a single source IP.
+type VantagePointStats struct {
+	SourceIP          string
+	AttemptCount      uint
+	SuccessCount      uint
+	DistinctNodeCount uint
+	AvgDuration       time.Duration
+}
+
+// BlockAnnouncement is one NewBlockHashes announcement of a peer,
+// timestamped when it arrived during a listening session.
+type BlockAnnouncement struct {
+	Hash   string
+	Number uint64
+	Time   time.Time
+}
+
+// BlockPropagationStats aggregates how early a node announces new blocks
+// relative to the first announcement of the same block seen from any node.
+type BlockPropagationStats struct {
+	NodeID     NodeID
+	ClientID   *string
+	BlockCount uint
+	AvgDelay   time.Duration
+}
+
+type DB interface {
+	io.Closer
+
+	UpsertNodeAddr(ctx context.Context, id NodeID, addr NodeAddr) error
+	FindNodeAddr(ctx context.Context, id NodeID) (*NodeAddr, error)
+
+	// FlushNodeWrites applies a coalesced batch of hot-path writes
+	// in a single transaction (see DBWriteCache).
+	FlushNodeWrites(ctx context.Context, batch NodeWriteBatch) error
+
+	ResetPingError(ctx context.Context, id NodeID) error
+	UpdatePingError(ctx context.Context, id NodeID) error
+	CountPingErrors(ctx context.Context, id NodeID) (*uint, error)
+
+	// UpdateClientID stores the latest observed client ID of a node
+	// and reports whether the value actually changed.
+	// A change also appends a client_changed node event with the old
+	// and the new value, captured atomically in the update transaction.
+	UpdateClientID(ctx context.Context, id NodeID, clientID string, quality ObservationQuality) (bool, error)
+	FindClientID(ctx context.Context, id NodeID) (*string, error)
+
+	// FindNodeEvents lists the recorded attribute transitions of a node, newest first.
+	FindNodeEvents(ctx context.Context, id NodeID, limit uint) ([]NodeEvent, error)
+	// FindLastNodeEventNum returns the sequence number of the latest
+	// recorded node event (zero when there are none).
+	FindLastNodeEventNum(ctx context.Context) (uint64, error)
+	// EnumerateNodeEventsAfter lists the node events recorded after the given
+	// sequence number in feed order, so the change feed can follow them
+	// incrementally (see the events package).
+	EnumerateNodeEventsAfter(ctx context.Context, afterNum uint64, limit uint, enumFunc func(entry NodeEventFeedEntry)) error
+	UpdateNetworkID(ctx context.Context, id NodeID, networkID uint, quality ObservationQuality) error
+	UpdateEthVersion(ctx context.Context, id NodeID, ethVersion uint, quality ObservationQuality) error
+	// UpdateForkID records the fork ID advertised in the eth Status message
+	// (the hex of the CRC32 hash and the next upcoming fork block, see core/forkid).
+	UpdateForkID(ctx context.Context, id NodeID, forkIDHash string, forkIDNext uint64, quality ObservationQuality) error
+	// UpdateForkCompatibility records the EIP-2124 verdict of the node's fork ID
+	// against the canonical chain config: the flag for filtering,
+	// and the verdict text for auditability (see observer.ForkVerifier).
+	UpdateForkCompatibility(ctx context.Context, id NodeID, compatible bool, verdict string) error
+	// UpdateChainHead records the head block hash and the total difficulty
+	// advertised in the eth Status message.
+	UpdateChainHead(ctx context.Context, id NodeID, headHash string, totalDifficulty string) error
+	// UpdatePortRLPxVerified records the RLPx port a handshake actually succeeded on,
+	// which may differ from the advertised port_rlpx when the port scan
+	// found the node listening elsewhere (see the --port-scan-ports flag).
+	UpdatePortRLPxVerified(ctx context.Context, id NodeID, port uint16) error
+	// UpdateReachability records the NAT/reachability class of the node
+	// (see ReachabilityPublic and friends) with the classification time,
+	// so the share of NATed nodes on the network can be quantified.
+	UpdateReachability(ctx context.Context, id NodeID, reachability string) error
+	// CountReachability tells how many nodes fall into each reachability class.
+	// A zero networkID does not restrict the counts to a chain.
+	CountReachability(ctx context.Context, networkID uint) ([]ReachabilityCount, error)
+	// UpdateSupportsSnap records whether the node announced the snap/1 capability
+	// in its Hello message.
+	UpdateSupportsSnap(ctx context.Context, id NodeID, supportsSnap bool) error
+	// UpdateTxAnnounceRate records the NewPooledTransactionHashes announcements
+	// per minute seen during a tx listening session:
+	// a live synced node announces steadily, while an idle shell stays silent.
+	UpdateTxAnnounceRate(ctx context.Context, id NodeID, rate float64) error
+	// InsertBlockAnnouncements records the NewBlockHashes announcements
+	// seen from the node during a listening session,
+	// keeping the earliest timestamp per node and block.
+	InsertBlockAnnouncements(ctx context.Context, id NodeID, announcements []BlockAnnouncement) error
+	// EnumerateBlockPropagationStats aggregates the recorded block announcements
+	// per node, the earliest average announcers first (see BlockPropagationStats).
+	// Nodes with fewer than minBlocks announced blocks are skipped as noise.
+	EnumerateBlockPropagationStats(ctx context.Context, minBlocks uint) ([]BlockPropagationStats, error)
+	// CountSnapSupport tells how many nodes announced the snap/1 capability,
+	// and how many nodes the flag is known for at all,
+	// so the snap-capable fraction of the network can be measured.
+	// A zero networkID does not restrict the counts to a chain.
+	CountSnapSupport(ctx context.Context, networkID uint) (snapNodes uint, knownNodes uint, err error)
+	// InsertHandshakeError appends to the handshake error history of the node,
+	// trimming it to the last few entries, and counts the failed try.
+	InsertHandshakeError(ctx context.Context, id NodeID, handshakeErr string) error
+	// DeleteHandshakeErrors clears the handshake error history of the node
+	// after a successful handshake, and resets the try counter.
+	DeleteHandshakeErrors(ctx context.Context, id NodeID) error
+	// FindHandshakeLastErrors returns the most recent handshake errors of the node,
+	// the latest first, so transient failures can be told from persistent ones.
+	FindHandshakeLastErrors(ctx context.Context, id NodeID, limit uint) ([]HandshakeError, error)
+
+	// InsertNodeLatency records a latency sample of the node
+	// (see NodeLatencyConnect, NodeLatencyHandshake and NodeLatencyPing),
+	// trimming the history to the last few samples per node and kind.
+	InsertNodeLatency(ctx context.Context, id NodeID, kind string, rtt time.Duration) error
+	// EnumerateNodeLatencies lists the recorded latency samples of one kind
+	// together with the country and client ID of the node,
+	// so the latency distribution can be grouped by region or client.
+	EnumerateNodeLatencies(ctx context.Context, kind string, enumFunc func(country *string, clientID *string, rtt time.Duration)) error
+
+	UpdateHandshakeRetryTime(ctx context.Context, id NodeID, retryTime time.Time) error
+	FindHandshakeRetryTime(ctx context.Context, id NodeID) (*time.Time, error)
+	CountHandshakeRetries(ctx context.Context, id NodeID) (*uint, error)
+
+	InsertHandshakeAttempt(ctx context.Context, id NodeID, attempt HandshakeAttempt) error
+	// IncrementHandshakeHourStats counts the handshake outcome into its
+	// UTC day-of-week/hour-of-day bucket per node country,
+	// so diurnal reachability patterns can be reported (see HandshakeHourStats).
+	IncrementHandshakeHourStats(ctx context.Context, id NodeID, success bool, moment time.Time) error
+	// EnumerateHandshakeHourStats lists the time-of-day buckets in bucket order.
+	EnumerateHandshakeHourStats(ctx context.Context) ([]HandshakeHourStats, error)
+
+	// EnableStrictRead turns on the strict read validation: unparsable IPs,
+	// malformed client IDs and inconsistent timestamps encountered on read
+	// are quarantined into the bad_rows table and skipped,
+	// instead of silently passing through or failing the query.
+	EnableStrictRead()
+	// FindBadRows lists the most recently quarantined values (see BadRow).
+	FindBadRows(ctx context.Context, limit uint) ([]BadRow, error)
+	// CompactHandshakeAttempts rolls the per-attempt history older than the given time
+	// into daily aggregates per node and vantage point (see handshake_attempt_days),
+	// and deletes the compacted rows. It returns the number of compacted attempts.
+	CompactHandshakeAttempts(ctx context.Context, before time.Time) (uint, error)
+	// EnumerateHandshakeAttemptsBefore lists the handshake attempts made before
+	// the given time in time order, so they can be moved to cold storage
+	// (see the archive package).
+	EnumerateHandshakeAttemptsBefore(ctx context.Context, before time.Time, enumFunc func(id NodeID, attempt HandshakeAttempt)) error
+	// DeleteHandshakeAttemptsBefore deletes the handshake attempts made before
+	// the given time and returns the number of deleted rows.
+	DeleteHandshakeAttemptsBefore(ctx context.Context, before time.Time) (uint, error)
+	// FindHandshakeAttempts lists the handshake attempts of a node in time order.
+	FindHandshakeAttempts(ctx context.Context, id NodeID) ([]HandshakeAttempt, error)
+	// EnumerateClientHistoryBefore lists the client ID observations made before
+	// the given time in time order (see node_client_history),
+	// so they can be moved to cold storage together with the attempts.
+	EnumerateClientHistoryBefore(ctx context.Context, before time.Time, enumFunc func(id NodeID, observed time.Time, clientID string)) error
+	// DeleteClientHistoryBefore deletes the client ID observations made before
+	// the given time and returns the number of deleted rows.
+	DeleteClientHistoryBefore(ctx context.Context, before time.Time) (uint, error)
+	EnumerateVantagePointStats(ctx context.Context) ([]VantagePointStats, error)
+
+	// InsertProtocolViolation records a wire protocol non-conformance of the node
+	// (see ProtocolViolation).
+	InsertProtocolViolation(ctx context.Context, id NodeID, violation ProtocolViolation) error
+	// CountProtocolViolations counts the non-conformant nodes per violation code.
+	CountProtocolViolations(ctx context.Context, networkID uint) (map[string]uint, error)
+
+	// UpsertNodeCapabilities records the capabilities a node announced (see NodeCapability).
+	// A capability seen before keeps its first_seen time and gets a fresh updated time,
+	// so capability adoption can be tracked over time.
+	UpsertNodeCapabilities(ctx context.Context, id NodeID, caps []NodeCapability) error
+	// CountNodesByCapability counts the nodes per announced capability, e.g. "eth/66".
+	CountNodesByCapability(ctx context.Context, networkID uint) (map[string]uint, error)
+
+	UpsertMonitoredNode(ctx context.Context, node MonitoredNode) error
+	DeleteMonitoredNode(ctx context.Context, id NodeID) error
+	EnumerateMonitoredNodes(ctx context.Context) ([]MonitoredNode, error)
+	// CountHandshakeOutcomesSince counts attempts and successes since the given time,
+	// including the compacted daily aggregates.
+	CountHandshakeOutcomesSince(ctx context.Context, id NodeID, since time.Time) (uint, uint, error)
+	UpsertSLOStatus(ctx context.Context, status SLOStatus) error
+	FindSLOStatus(ctx context.Context, id NodeID) (*SLOStatus, error)
+
+	// UpsertProbePolicy stores a probe scheduling policy (see ProbePolicy).
+	UpsertProbePolicy(ctx context.Context, policy ProbePolicy) error
+	DeleteProbePolicy(ctx context.Context, name string) error
+	EnumerateProbePolicies(ctx context.Context) ([]ProbePolicy, error)
+	// FindProbeTraits returns what the probe policies match the node on,
+	// or nil if the node is unknown.
+	FindProbeTraits(ctx context.Context, id NodeID) (*ProbeTraits, error)
+	// CountVantageDivergentNodes counts nodes which succeeded a handshake
+	// from one vantage point, but failed from another.
+	CountVantageDivergentNodes(ctx context.Context) (uint, error)
+
+	// UpdateEnrEntries replaces the stored ENR key-value pairs of a node.
+	UpdateEnrEntries(ctx context.Context, id NodeID, entries []EnrEntry) error
+	FindEnrEntries(ctx context.Context, id NodeID) ([]EnrEntry, error)
+	// EnumerateNodesByEnrKey lists nodes advertising the given ENR key,
+	// optionally filtered by the hex encoding of the raw RLP value.
+	EnumerateNodesByEnrKey(ctx context.Context, key string, valueHex string, limit uint) ([]NodeID, error)
+
+	// UpsertNodeENR stores the raw signed node record,
+	// keeping the one with the highest sequence number on conflict.
+	UpsertNodeENR(ctx context.Context, id NodeID, nodeENR NodeENR) error
+	FindNodeENR(ctx context.Context, id NodeID) (*NodeENR, error)
+
+	// FindNodeFreshness returns the verification times of the node attributes,
+	// or nil if the node is unknown.
+	FindNodeFreshness(ctx context.Context, id NodeID) (*NodeFreshness, error)
+
+	// UpdateNodeASN records the autonomous system number of a node's IP
+	// (filled in by an enrichment source, e.g. a GeoIP database).
+	UpdateNodeASN(ctx context.Context, id NodeID, asn uint) error
+	FindNodeASN(ctx context.Context, id NodeID) (*uint, error)
+
+	// UpdateNodeGeo records the geographic location of the node IP (see NodeGeo).
+	UpdateNodeGeo(ctx context.Context, id NodeID, geo NodeGeo) error
+	// CountNodesByCountry counts the located nodes per ISO country code.
+	CountNodesByCountry(ctx context.Context, networkID uint) (map[string]uint, error)
+	// CountNodesByASN counts the nodes per autonomous system number
+	// (see the GeoIP ASN enrichment stage), so the concentration of nodes
+	// on a few hosting providers can be quantified.
+	CountNodesByASN(ctx context.Context, networkID uint) (map[uint]uint, error)
+	// EnumerateNodeIPs lists the known addresses of the nodes together
+	// with their ASN, for the IP clustering aggregations
+	// (see the ip_clusters report section).
+	EnumerateNodeIPs(ctx context.Context, networkID uint, enumFunc func(ip *string, ipv6 *string, asn *uint)) error
+	// CountNodesByClientID counts the handshaked nodes per exact client ID string
+	// (the caller collapses the IDs to client names).
+	CountNodesByClientID(ctx context.Context, networkID uint) (map[string]uint, error)
+	// CountForkCompatibility counts the nodes per fork verdict class:
+	// "compatible", "incompatible" or "unknown".
+	CountForkCompatibility(ctx context.Context, networkID uint) (map[string]uint, error)
+
+	// UpdateNodeDiscVersion records which discovery protocol found the node
+	// (DiscVersionV4 or DiscVersionV5), so that the v4 and v5 populations can be compared.
+	// A node already marked with the other version is upgraded to DiscVersionBoth.
+	UpdateNodeDiscVersion(ctx context.Context, id NodeID, discVersion uint) error
+
+	// FindProbedBuckets returns the Kademlia bucket indices of a node
+	// that were already probed with a FindNode query.
+	FindProbedBuckets(ctx context.Context, id NodeID) ([]uint, error)
+	MarkProbedBuckets(ctx context.Context, id NodeID, buckets []uint) error
+
+	// UpsertFrontierEntry adds an uncrawled node to the crawl frontier,
+	// keeping the best (highest) priority and the smallest depth on conflict.
+	// Nodes that have already been crawled are not re-added.
+	UpsertFrontierEntry(ctx context.Context, entry FrontierEntry) error
+	DeleteFrontierEntry(ctx context.Context, id NodeID) error
+	CountFrontier(ctx context.Context) (uint, error)
+
+	// UpdateNodeDepth records the discovery depth of a node (hops from the bootnodes),
+	// keeping the smallest known depth.
+	UpdateNodeDepth(ctx context.Context, id NodeID, depth uint) error
+	FindNodeDepth(ctx context.Context, id NodeID) (*uint, error)
+
+	UpdateCrawlRetryTime(ctx context.Context, id NodeID, retryTime time.Time) error
+	// AcquireCandidates returns up to limit node IDs that are due for crawling,
+	// preferring the frontier nodes with the highest priority,
+	// and leases them to the owner so that concurrent crawlers don't pick them up again.
+	// An expired lease is reclaimed automatically:
+	// the node becomes a free candidate again, so a crashed worker can't leave it stuck.
+	// Only IDs within [idLower, idUpper) are considered,
+	// so that a sharded worker stays within its keyspace slice.
+	AcquireCandidates(ctx context.Context, owner string, leaseDuration time.Duration, networkID uint, limit uint, idLower NodeID, idUpper NodeID) ([]NodeID, error)
+	// RenewCandidateLeases extends the leases that the owner still holds,
+	// so that a slow batch doesn't lose its candidates to other workers mid-way.
+	RenewCandidateLeases(ctx context.Context, owner string, leaseDuration time.Duration, ids []NodeID) error
+	// ReleaseCandidateLeases clears the leases that the owner still holds,
+	// making the nodes available to other workers right away.
+	ReleaseCandidateLeases(ctx context.Context, owner string, ids []NodeID) error
+
+	IsConflictError(err error) bool
+
+	// EnumerateNodeIDsAfter lists up to limit node IDs greater than the given one
+	// in the ID order. It is used to scan the nodes table in resumable batches.
+	EnumerateNodeIDsAfter(ctx context.Context, afterID NodeID, limit uint) ([]NodeID, error)
+
+	UpsertNodeClientInfo(ctx context.Context, id NodeID, info ClientInfo) error
+
+	// InsertNodeSighting records a piece of liveness evidence
+	// (see NodeSightingPing and NodeSightingHandshake).
+	InsertNodeSighting(ctx context.Context, id NodeID, kind string) error
+	// FindNodeUptime aggregates the sightings of a node (see NodeUptime).
+	// It returns nil if the node was never sighted.
+	FindNodeUptime(ctx context.Context, id NodeID) (*NodeUptime, error)
+	// FindTopAvailableNodes ranks the sighted nodes by their uptime,
+	// the weekly percentage first.
+	FindTopAvailableNodes(ctx context.Context, limit uint) ([]NodeUptime, error)
+	// FindBootnodeCandidates lists the most reliable addressable nodes of a chain,
+	// ranked by their recent sightings, optionally restricted to the given
+	// fork ID hashes, so they can be published as bootnodes (see the bootnodes command).
+	FindBootnodeCandidates(ctx context.Context, networkID uint, forkIDHashes []string, limit uint) ([]NodeID, error)
+	// FindSnapPeerCandidates lists the reachable nodes which proved snap/1
+	// serving ability: they announced the snap/1 capability and a chain head,
+	// and handshaked after the given moment, ranked like the bootnode candidates.
+	FindSnapPeerCandidates(ctx context.Context, networkID uint, handshakedAfter time.Time, limit uint) ([]NodeID, error)
+
+	// FindNodeFacts reads the attributes the classification rules evaluate against
+	// (see NodeFacts and the "rules" enrichment stage).
+	FindNodeFacts(ctx context.Context, id NodeID) (*NodeFacts, error)
+	// UpsertNodeLabel stores a label produced by a classification rule.
+	UpsertNodeLabel(ctx context.Context, id NodeID, label string, score float64) error
+	// DeleteNodeLabels clears the labels of a node before it is re-classified.
+	DeleteNodeLabels(ctx context.Context, id NodeID) error
+	FindNodeLabels(ctx context.Context, id NodeID) (map[string]float64, error)
+
+	// UpsertIPReputation caches the result of an IP reputation provider,
+	// shared by all the nodes at that address (see the "reputation" enrichment stage).
+	UpsertIPReputation(ctx context.Context, ip string, provider string, score float64) error
+	FindIPReputation(ctx context.Context, ip string, provider string) (*IPReputationScore, error)
+	// UpdateNodeIPReputation stores the aggregated reputation score of a node,
+	// so the classification rules and the blocklist export can use it.
+	UpdateNodeIPReputation(ctx context.Context, id NodeID, score float64) error
+	// FindBlocklistIPs lists the distinct addresses whose reputation score
+	// is at or above the threshold, for blocklist exports.
+	FindBlocklistIPs(ctx context.Context, threshold float64) ([]string, error)
+
+	FindEnrichProgress(ctx context.Context, stage string) (*NodeID, error)
+	UpdateEnrichProgress(ctx context.Context, stage string, lastID NodeID) error
+	DeleteEnrichProgress(ctx context.Context, stage string) error
+
+	// MarkEnrichmentFresh records that the given attribute of the node
+	// was just refreshed, starting its TTL over (see EnrichmentTTL).
+	MarkEnrichmentFresh(ctx context.Context, id NodeID, attribute EnrichmentAttribute) error
+	// IsEnrichmentFresh tells if the attribute of the node is still within its TTL,
+	// so the enrichment pipeline knows what to refresh and what to skip.
+	IsEnrichmentFresh(ctx context.Context, id NodeID, attribute EnrichmentAttribute) (bool, error)
+
+	// DescribeSchema introspects the live schema
+	// (tables, columns, indexes, row counts) for documentation purposes.
+	DescribeSchema(ctx context.Context) ([]SchemaTable, error)
+
+	// DeleteStaleNodes prunes the nodes unreachable for too long:
+	// a node whose address was last confirmed before olderThan
+	// and whose ping try count reached maxPingTries is deleted
+	// together with its dependent per-node rows.
+	// It returns the deleted row counts per table
+	// (the node rows themselves under "nodes").
+	DeleteStaleNodes(ctx context.Context, olderThan time.Time, maxPingTries uint) (map[string]uint, error)
+	// CleanupOrphanRows deletes rows of the per-node side tables
+	// whose node row no longer exists (e.g. after a prune),
+	// and returns the deleted row counts per table.
+	CleanupOrphanRows(ctx context.Context) (map[string]uint, error)
+	// Vacuum returns the space freed by deleted rows to the file system.
+	Vacuum(ctx context.Context) error
+
+	// RefreshSeenWindowStats recomputes the materialized node set size
+	// of the given rolling window (see SeenWindowStats).
+	RefreshSeenWindowStats(ctx context.Context, window time.Duration) error
+	FindSeenWindowStats(ctx context.Context) ([]SeenWindowStats, error)
+
+	// CountRLPxPortGroups aggregates the nodes by their advertised RLPx port.
+	CountRLPxPortGroups(ctx context.Context) (map[string]uint, error)
+	// CountIPVersions counts the nodes with a known IPv4 and IPv6 address
+	// (a dual-stack node counts in both buckets),
+	// plus the IPv6-only nodes as a separate bucket.
+	CountIPVersions(ctx context.Context) (map[string]uint, error)
+	// UpdateDialStats counts one dial attempt of the chosen address family
+	// into the per-family outcome counters of the node.
+	UpdateDialStats(ctx context.Context, id NodeID, ipv6 bool, success bool) error
+	// CountDialFamilyStats sums the per-family dial outcome counters
+	// (see DialFamilyStats). A zero networkID does not restrict to a chain.
+	CountDialFamilyStats(ctx context.Context, networkID uint) (DialFamilyStats, error)
+
+	// UpdateOperatorLabel records a label correction of a verified node operator.
+	// An empty label clears the correction.
+	UpdateOperatorLabel(ctx context.Context, id NodeID, label string) error
+	// UpdateOperatorOptOut records whether a verified node operator opted
+	// the node out of the published exports (see EnumerateNodeExports).
+	UpdateOperatorOptOut(ctx context.Context, id NodeID, optOut bool) error
+	// FindOperatorSettings returns the operator-managed settings of the node,
+	// or nil for an unknown node.
+	FindOperatorSettings(ctx context.Context, id NodeID) (*OperatorSettings, error)
+
+	// EnumerateKeyRotations lists up to limit endpoints seen with at least
+	// minKeys distinct node IDs, the most rotated first (see KeyRotationStats).
+	EnumerateKeyRotations(ctx context.Context, minKeys uint, limit uint) ([]KeyRotationStats, error)
+	// FindNodeHistory lists the node IDs observed behind the endpoint
+	// in the order they first appeared there (see NodeHistoryEntry).
+	FindNodeHistory(ctx context.Context, ip string, portRLPx uint16) ([]NodeHistoryEntry, error)
+	// ReplaceAnalyticsAggregates swaps the materialized per-bucket node counts
+	// of one dashboard category (refreshed on a timer, see the analytics loop),
+	// so that dashboards read them instead of scanning the nodes table.
+	ReplaceAnalyticsAggregates(ctx context.Context, category string, counts map[string]uint) error
+	FindAnalyticsAggregates(ctx context.Context, category string) (map[string]uint, error)
+
+	// InsertStatsSnapshot records a point-in-time snapshot of the network statistics
+	// (see StatsSnapshot).
+	InsertStatsSnapshot(ctx context.Context, snapshot StatsSnapshot) error
+	// FindStatsSnapshots lists the snapshots taken after the given moment
+	// in chronological order, without the per-client counts.
+	FindStatsSnapshots(ctx context.Context, since time.Time) ([]StatsSnapshot, error)
+	// FindStatsSnapshotClients returns the per-client node counts of one snapshot.
+	FindStatsSnapshotClients(ctx context.Context, snapshotTime time.Time) (map[string]uint, error)
+	// DeleteStatsSnapshotsBefore enforces the snapshot retention policy.
+	// It returns the number of deleted snapshots.
+	DeleteStatsSnapshotsBefore(ctx context.Context, before time.Time) (uint, error)
+
+	// CountAllNodes and the Count*Groups aggregates back the HTTP status API.
+	// A zero networkID does not restrict the counts to a chain.
+	CountAllNodes(ctx context.Context, networkID uint) (uint, error)
+	CountClientGroups(ctx context.Context, networkID uint) (map[string]uint, error)
+	CountNetworkIDGroups(ctx context.Context) (map[uint]uint, error)
+	CountHandshakeErrorGroups(ctx context.Context, networkID uint) (map[string]uint, error)
+
+	CountNodes(ctx context.Context, maxPingTries uint, networkID uint) (uint, error)
+	CountIPs(ctx context.Context, maxPingTries uint, networkID uint) (uint, error)
+	// EstimateOperatorCount estimates how many distinct operators run the stored nodes.
+	// Nodes within one subnet (IPv4 /24 or IPv6 /48) running one client name
+	// are assumed to belong to the same operator, since fleets are typically
+	// deployed from a few adjacent addresses with a uniform setup;
+	// nodes without a known IP are counted individually.
+	// A zero networkID does not restrict the estimate to a chain.
+	EstimateOperatorCount(ctx context.Context, networkID uint) (uint, error)
+	CountClients(ctx context.Context, clientIDPrefix string, maxPingTries uint, networkID uint, minQuality ObservationQuality) (uint, error)
+	EnumerateClientIDs(ctx context.Context, maxPingTries uint, networkID uint, minQuality ObservationQuality, enumFunc func(clientID *string)) error
+	// EnumerateHandshakeErrorClientIDs lists the latest handshake error of each failing node
+	// together with the client ID of the node (if known),
+	// so the errors can be cross-tabulated against client versions.
+	// A zero networkID does not restrict the listing to a chain.
+	EnumerateHandshakeErrorClientIDs(ctx context.Context, networkID uint, enumFunc func(clientID *string, handshakeErr string)) error
+	// EnumerateClientIDsAsOf replays the client ID history (see node_client_history)
+	// and lists the client IDs as they were at the given moment.
+	// A node is part of the historic node set if it was observed
+	// within the window before that moment.
+	EnumerateClientIDsAsOf(ctx context.Context, asOf time.Time, window time.Duration, enumFunc func(clientID *string)) error
+	// EnumerateNodeLifetimes lists the observation spans of the nodes
+	// whose first sighting is known (see NodeLifetime).
+	EnumerateNodeLifetimes(ctx context.Context, enumFunc func(lifetime NodeLifetime)) error
+
+	// EnumerateNodeExports lists the nodes changed after the given change sequence number
+	// in the change order, optionally restricted by the filter (see NodeExport).
+	// Nodes whose operator opted out (see UpdateOperatorOptOut) are never listed.
+	EnumerateNodeExports(ctx context.Context, sinceChangeSeq uint64, filter NodeExportFilter, enumFunc func(node NodeExport)) error
+	// FindExportCheckpoint returns the change sequence number of the last export
+	// under the given checkpoint name, or 0 if it never ran.
+	FindExportCheckpoint(ctx context.Context, name string) (uint64, error)
+	UpdateExportCheckpoint(ctx context.Context, name string, changeSeq uint64) error
+
+	// UpsertFederatedNode attributes a node to the remote observer it was pulled from
+	// (see the federation package), keeping the time it was first and last reported.
+	UpsertFederatedNode(ctx context.Context, id NodeID, source string) error
+	// CountFederatedNodes counts the federated nodes per remote observer.
+	CountFederatedNodes(ctx context.Context) (map[string]uint, error)
+}
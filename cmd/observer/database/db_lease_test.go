@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+var (
+	errTestConflict = errors.New("test conflict")
+	errTestFatal    = errors.New("test fatal")
+)
+
+func testLogger() log.Logger {
+	logger := log.New()
+	logger.SetHandler(log.DiscardHandler())
+	return logger
+}
+
+// fakeClock drives the retry and lease scheduling in tests (see DBSQLite.SetClock).
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.now = clock.now.Add(d)
+}
+
+func newTestDB(t *testing.T) (*DBSQLite, *fakeClock) {
+	db, err := NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	clock := fakeClock{now: time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC)}
+	db.SetClock(&clock)
+	return db, &clock
+}
+
+func testNodeID(prefix string) NodeID {
+	return NodeID(prefix + strings.Repeat("0", 128-len(prefix)))
+}
+
+func testNodeAddr(ip string) NodeAddr {
+	var addr NodeAddr
+	addr.IP = net.ParseIP(ip)
+	addr.PortDisc = 30303
+	addr.PortRLPx = 30303
+	return addr
+}
+
+func TestAcquireCandidatesLeases(t *testing.T) {
+	ctx := context.Background()
+	db, clock := newTestDB(t)
+
+	id1 := testNodeID("aa")
+	id2 := testNodeID("bb")
+	require.NoError(t, db.UpsertNodeAddr(ctx, id1, testNodeAddr("10.0.0.1")))
+	require.NoError(t, db.UpsertNodeAddr(ctx, id2, testNodeAddr("10.0.0.2")))
+
+	leaseDuration := time.Minute
+
+	nodes, err := db.AcquireCandidates(ctx, "worker-a", leaseDuration, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+
+	// the leased nodes are not offered to another worker
+	nodes, err = db.AcquireCandidates(ctx, "worker-b", leaseDuration, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	// an expired lease is reclaimed
+	clock.Advance(leaseDuration + time.Second)
+	nodes, err = db.AcquireCandidates(ctx, "worker-b", leaseDuration, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func TestRenewCandidateLeases(t *testing.T) {
+	ctx := context.Background()
+	db, clock := newTestDB(t)
+
+	id := testNodeID("aa")
+	require.NoError(t, db.UpsertNodeAddr(ctx, id, testNodeAddr("10.0.0.1")))
+
+	leaseDuration := time.Minute
+
+	nodes, err := db.AcquireCandidates(ctx, "worker-a", leaseDuration, 0, 10, "", "g")
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+
+	// a renewed lease outlives the original expiry
+	clock.Advance(30 * time.Second)
+	require.NoError(t, db.RenewCandidateLeases(ctx, "worker-a", leaseDuration, nodes))
+	clock.Advance(45 * time.Second)
+
+	acquired, err := db.AcquireCandidates(ctx, "worker-b", leaseDuration, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Empty(t, acquired)
+
+	// a released lease frees the node immediately
+	require.NoError(t, db.ReleaseCandidateLeases(ctx, "worker-a", nodes))
+	acquired, err = db.AcquireCandidates(ctx, "worker-b", leaseDuration, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Len(t, acquired, 1)
+}
+
+func TestCrawlRetryTimeSchedule(t *testing.T) {
+	ctx := context.Background()
+	db, clock := newTestDB(t)
+
+	id := testNodeID("aa")
+	require.NoError(t, db.UpsertNodeAddr(ctx, id, testNodeAddr("10.0.0.1")))
+	require.NoError(t, db.UpdateCrawlRetryTime(ctx, id, clock.Now().Add(time.Hour)))
+
+	// the node is not due until its retry time passes
+	nodes, err := db.AcquireCandidates(ctx, "worker-a", time.Minute, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	clock.Advance(time.Hour + time.Second)
+	nodes, err = db.AcquireCandidates(ctx, "worker-a", time.Minute, 0, 10, "", "g")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+}
+
+func TestRetrierRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	attempts := 0
+	op := func(context.Context) (interface{}, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errTestConflict
+		}
+		return "done", nil
+	}
+	noDelay := func(int) time.Duration { return 0 }
+	isConflict := func(err error) bool { return err == errTestConflict }
+
+	result, err := utils.Retry(ctx, retryCount, noDelay, isConflict, testLogger(), "op", op)
+	require.NoError(t, err)
+	assert.Equal(t, "done", result)
+	assert.Equal(t, 3, attempts)
+
+	// a non-recoverable error is returned without retrying
+	attempts = 0
+	failOp := func(context.Context) (interface{}, error) {
+		attempts++
+		return nil, errTestFatal
+	}
+	_, err = utils.Retry(ctx, retryCount, noDelay, isConflict, testLogger(), "op", failOp)
+	assert.Equal(t, errTestFatal, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// register the "postgres" driver used by NewDBPostgres
+	_ "github.com/lib/pq"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+)
+
+// DBPostgres implements the DB interface on top of a shared PostgreSQL instance,
+// so that large crawls (millions of node rows) don't have to fit a local SQLite file.
+// It shares the statement set with DBSQLite, rewriting the statements
+// to the PostgreSQL dialect on the fly (see postgresStmt),
+// and overrides the few statements where the dialects genuinely diverge.
+type DBPostgres struct {
+	DBSQLite
+}
+
+// language=SQL
+const (
+	// sqlUpsertFrontierEntryPostgres mirrors sqlUpsertFrontierEntry:
+	// PostgreSQL spells the two-argument MIN/MAX of SQLite as LEAST/GREATEST.
+	sqlUpsertFrontierEntryPostgres = `
+INSERT INTO crawl_frontier(node_id, priority, source, depth, added)
+SELECT ?, ?, ?, ?, ?
+WHERE NOT EXISTS (SELECT 1 FROM nodes WHERE (id = ?) AND (crawl_retry_time IS NOT NULL))
+ON CONFLICT(node_id) DO UPDATE SET
+	priority = GREATEST(crawl_frontier.priority, excluded.priority),
+	depth = LEAST(crawl_frontier.depth, excluded.depth)
+`
+
+	// sqlCreateChangeTrackingTriggersPostgres mirrors sqlCreateChangeTrackingTriggers
+	// using a native sequence and a plpgsql trigger function.
+	sqlCreateChangeTrackingTriggersPostgres = `
+CREATE SEQUENCE IF NOT EXISTS nodes_change_seq;
+CREATE OR REPLACE FUNCTION observer_bump_change_seq() RETURNS trigger AS
+'BEGIN NEW.change_seq := nextval(''nodes_change_seq''); RETURN NEW; END'
+LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS trg_nodes_change_seq ON nodes;
+CREATE TRIGGER trg_nodes_change_seq BEFORE INSERT OR UPDATE ON nodes
+FOR EACH ROW EXECUTE PROCEDURE observer_bump_change_seq();
+`
+
+	sqlSchemaTablesPostgres = `
+SELECT table_name FROM information_schema.tables
+WHERE (table_schema = 'public') AND (table_type = 'BASE TABLE')
+ORDER BY table_name
+`
+
+	sqlSchemaTableColumnsPostgres = `
+SELECT column_name, data_type, is_nullable FROM information_schema.columns
+WHERE (table_schema = 'public') AND (table_name = ?)
+ORDER BY ordinal_position
+`
+
+	sqlSchemaTablePrimaryKeyPostgres = `
+SELECT kcu.column_name
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu ON (kcu.constraint_name = tc.constraint_name)
+WHERE (tc.table_schema = 'public') AND (tc.table_name = ?) AND (tc.constraint_type = 'PRIMARY KEY')
+`
+
+	sqlSchemaTableIndexesPostgres = `
+SELECT indexname FROM pg_indexes
+WHERE (schemaname = 'public') AND (tablename = ?) AND (indexname LIKE 'idx_%')
+ORDER BY indexname
+`
+)
+
+// NewDBPostgres connects to the primary at dsn, and optionally
+// to read replicas at readDSNs: the row set queries are then routed
+// to the replicas round-robin (see DBSQLite.reader), while all writes
+// and the crawl scheduling lookups stay on the primary.
+// The schema is managed on the primary only; the replicas are expected
+// to follow it through replication.
+func NewDBPostgres(dsn string, readDSNs []string) (*DBPostgres, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %w", err)
+	}
+
+	if err := migrateSchema(db, postgresDDL, postgresStmt); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqlCreateChangeTrackingTriggersPostgres); err != nil {
+		return nil, fmt.Errorf("failed to create the change tracking triggers: %w", err)
+	}
+
+	var readDBs []*sql.DB
+	for _, readDSN := range readDSNs {
+		readDB, err := sql.Open("postgres", readDSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open a read replica DB: %w", err)
+		}
+		readDBs = append(readDBs, readDB)
+	}
+
+	instance := DBPostgres{DBSQLite{db: db, readDBs: readDBs, stmtRewrite: postgresStmt, clock: utils.SystemClock{}}}
+	return &instance, nil
+}
+
+// postgresDDL derives the PostgreSQL DDL from the SQLite one,
+// so that the two schemas cannot drift apart.
+func postgresDDL(schema string) string {
+	schema = strings.ReplaceAll(schema, "num INTEGER PRIMARY KEY AUTOINCREMENT", "num BIGSERIAL PRIMARY KEY")
+	schema = strings.ReplaceAll(schema, "BLOB", "BYTEA")
+	schema = strings.ReplaceAll(schema, " REAL ", " DOUBLE PRECISION ")
+	return schema
+}
+
+// postgresStmt rewrites a statement from the SQLite dialect to PostgreSQL:
+// the "?" placeholders become "$1", "$2", ...
+func postgresStmt(statement string) string {
+	var builder strings.Builder
+	builder.Grow(len(statement))
+
+	placeholderIndex := 0
+	inString := false
+	for _, c := range statement {
+		switch {
+		case c == '\'':
+			inString = !inString
+			builder.WriteRune(c)
+		case (c == '?') && !inString:
+			placeholderIndex++
+			builder.WriteString(fmt.Sprintf("$%d", placeholderIndex))
+		default:
+			builder.WriteRune(c)
+		}
+	}
+	return builder.String()
+}
+
+// resetSequences advances the sequences behind the serial columns
+// after rows with explicit values were copied in (see CopyDB).
+func (db *DBPostgres) resetSequences(ctx context.Context) error {
+	_, err := db.db.ExecContext(ctx,
+		`SELECT setval(pg_get_serial_sequence('handshake_attempts', 'num'),
+			COALESCE((SELECT MAX(num) FROM handshake_attempts), 1))`)
+	if err != nil {
+		return fmt.Errorf("failed to reset sequences: %w", err)
+	}
+	return nil
+}
+
+func (db *DBPostgres) UpsertFrontierEntry(ctx context.Context, entry FrontierEntry) error {
+	added := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertFrontierEntryPostgres),
+		entry.ID, entry.Priority, nullableStr(entry.Source), entry.Depth, added, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a frontier entry: %w", err)
+	}
+	return nil
+}
+
+func (db *DBPostgres) IsConflictError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return strings.Contains(message, "could not serialize access") ||
+		strings.Contains(message, "deadlock detected")
+}
+
+func (db *DBPostgres) DescribeSchema(ctx context.Context) ([]SchemaTable, error) {
+	names, err := db.schemaTableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []SchemaTable
+	for _, name := range names {
+		table := SchemaTable{Name: name}
+
+		if table.Columns, err = db.schemaTableColumns(ctx, name); err != nil {
+			return nil, err
+		}
+		if table.Indexes, err = db.schemaTableIndexes(ctx, name); err != nil {
+			return nil, err
+		}
+
+		// the table name comes from information_schema, and is safe to quote
+		row := db.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, name))
+		if err := row.Scan(&table.RowCount); err != nil {
+			return nil, fmt.Errorf("failed to count table rows: %w", err)
+		}
+
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (db *DBPostgres) schemaTableNames(ctx context.Context) ([]string, error) {
+	cursor, err := db.db.QueryContext(ctx, db.stmt(sqlSchemaTablesPostgres))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate schema tables: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var names []string
+	for cursor.Next() {
+		var name string
+		if err := cursor.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read schema table data: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, cursor.Err()
+}
+
+func (db *DBPostgres) schemaTableColumns(ctx context.Context, tableName string) ([]SchemaColumn, error) {
+	primaryKey, err := db.schemaTablePrimaryKey(ctx, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := db.db.QueryContext(ctx, db.stmt(sqlSchemaTableColumnsPostgres), tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table columns: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var columns []SchemaColumn
+	for cursor.Next() {
+		var column SchemaColumn
+		var isNullable string
+		if err := cursor.Scan(&column.Name, &column.Type, &isNullable); err != nil {
+			return nil, fmt.Errorf("failed to read table column data: %w", err)
+		}
+		column.NotNull = isNullable == "NO"
+		column.IsPrimaryKey = primaryKey[column.Name]
+		columns = append(columns, column)
+	}
+	return columns, cursor.Err()
+}
+
+func (db *DBPostgres) schemaTablePrimaryKey(ctx context.Context, tableName string) (map[string]bool, error) {
+	cursor, err := db.db.QueryContext(ctx, db.stmt(sqlSchemaTablePrimaryKeyPostgres), tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the table primary key: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	primaryKey := make(map[string]bool)
+	for cursor.Next() {
+		var columnName string
+		if err := cursor.Scan(&columnName); err != nil {
+			return nil, fmt.Errorf("failed to read primary key data: %w", err)
+		}
+		primaryKey[columnName] = true
+	}
+	return primaryKey, cursor.Err()
+}
+
+func (db *DBPostgres) schemaTableIndexes(ctx context.Context, tableName string) ([]string, error) {
+	cursor, err := db.db.QueryContext(ctx, db.stmt(sqlSchemaTableIndexesPostgres), tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table indexes: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var indexes []string
+	for cursor.Next() {
+		var name string
+		if err := cursor.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read table index data: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	return indexes, cursor.Err()
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresDriverIsRegistered(t *testing.T) {
+	// sql.Open doesn't connect, but it fails if the driver is not registered
+	db, err := sql.Open("postgres", "postgres://localhost/observer")
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
+
+func TestPostgresDDLRewritesEveryAutoincrement(t *testing.T) {
+	ddl := postgresDDL(sqlCreateSchema)
+	assert.NotContains(t, ddl, "AUTOINCREMENT")
+	assert.NotContains(t, ddl, "BLOB")
+
+	serialCount := strings.Count(ddl, "num BIGSERIAL PRIMARY KEY")
+	autoincrementCount := strings.Count(sqlCreateSchema, "num INTEGER PRIMARY KEY AUTOINCREMENT")
+	assert.Positive(t, autoincrementCount)
+	assert.Equal(t, autoincrementCount, serialCount)
+}
+
+func TestPostgresDDLRewritesMigrations(t *testing.T) {
+	// migrateSchema applies postgresDDL to the incremental migrations too
+	for _, migration := range schemaMigrations {
+		ddl := postgresDDL(migration.statements)
+		assert.NotContains(t, ddl, "AUTOINCREMENT", "migration to version %d", migration.version)
+		assert.NotContains(t, ddl, "BLOB", "migration to version %d", migration.version)
+	}
+}
+
+func TestPostgresStmtPlaceholders(t *testing.T) {
+	assert.Equal(t, "SELECT id FROM nodes WHERE (ip = $1) AND (port_rlpx = $2)",
+		postgresStmt("SELECT id FROM nodes WHERE (ip = ?) AND (port_rlpx = ?)"))
+	// a "?" inside a string literal is not a placeholder
+	assert.Equal(t, "SELECT $1, '?', $2", postgresStmt("SELECT ?, '?', ?"))
+	assert.Equal(t, "SELECT 1", postgresStmt("SELECT 1"))
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderRoutesToPrimaryWithoutReplicas(t *testing.T) {
+	primary, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer func() { _ = primary.Close() }()
+
+	db := DBSQLite{db: primary}
+	assert.Same(t, primary, db.reader())
+	assert.Same(t, primary, db.reader())
+}
+
+func TestReaderRoundRobinsOverReplicas(t *testing.T) {
+	primary, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer func() { _ = primary.Close() }()
+
+	var replicas []*sql.DB
+	for i := 0; i < 2; i++ {
+		replica, err := sql.Open("sqlite", ":memory:")
+		require.NoError(t, err)
+		defer func() { _ = replica.Close() }()
+		replicas = append(replicas, replica)
+	}
+
+	db := DBSQLite{db: primary, readDBs: replicas}
+	first := db.reader()
+	second := db.reader()
+	third := db.reader()
+
+	assert.NotSame(t, primary, first)
+	assert.NotSame(t, first, second)
+	assert.Same(t, first, third)
+}
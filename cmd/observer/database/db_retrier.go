@@ -0,0 +1,475 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// DBRetrier retries database operations that fail with a recoverable
+// conflict error (e.g. SQLITE_BUSY) using a randomized exponential backoff.
+type DBRetrier struct {
+	db  DB
+	log log.Logger
+}
+
+func NewDBRetrier(db DB, logger log.Logger) DBRetrier {
+	return DBRetrier{db, logger}
+}
+
+const (
+	retryCount     = 40
+	retryDelayMin  = 10 * time.Millisecond
+	retryDelayStep = 2
+	retryDelayMax  = time.Second
+)
+
+func retryBackoffTime(attempt int) time.Duration {
+	if attempt <= 0 {
+		return 0
+	}
+	delay := retryDelayMin
+	for i := 1; i < attempt; i++ {
+		delay *= retryDelayStep
+		if delay >= retryDelayMax {
+			delay = retryDelayMax
+			break
+		}
+	}
+	jitter := time.Duration(rand.Int63n(int64(retryDelayMin))) // nolint: gosec
+	return delay + jitter
+}
+
+// The contention statistics back the crawl self-diagnosis (see observer.Diagnose)
+// and the periodic contention summary (see observer.ContentionLoggerLoop).
+var (
+	dbOpCount       uint64
+	dbConflictCount uint64
+
+	dbConflictCountsMu sync.Mutex
+	dbConflictCountsOp = make(map[string]uint64)
+)
+
+// DBOpStats returns how many retriable operations ran, and how many conflict errors they hit.
+func DBOpStats() (ops uint64, conflicts uint64) {
+	return atomic.LoadUint64(&dbOpCount), atomic.LoadUint64(&dbConflictCount)
+}
+
+// DBConflictStats returns how many conflict errors each operation has hit so far.
+func DBConflictStats() map[string]uint64 {
+	dbConflictCountsMu.Lock()
+	defer dbConflictCountsMu.Unlock()
+	counts := make(map[string]uint64, len(dbConflictCountsOp))
+	for opName, count := range dbConflictCountsOp {
+		counts[opName] = count
+	}
+	return counts
+}
+
+// countConflict labels a conflict error with the operation that hit it,
+// both on the Prometheus /metrics endpoint and for the contention summary.
+func countConflict(opName string) {
+	atomic.AddUint64(&dbConflictCount, 1)
+	metrics.GetOrCreateCounter(fmt.Sprintf(`observer_db_conflicts_total{op=%q}`, opName)).Inc()
+	dbConflictCountsMu.Lock()
+	dbConflictCountsOp[opName]++
+	dbConflictCountsMu.Unlock()
+}
+
+func (db DBRetrier) retry(ctx context.Context, opName string, op func(context.Context) (interface{}, error)) (interface{}, error) {
+	atomic.AddUint64(&dbOpCount, 1)
+	isConflictError := func(err error) bool {
+		if !db.db.IsConflictError(err) {
+			return false
+		}
+		countConflict(opName)
+		return true
+	}
+	startTime := time.Now()
+	result, err := utils.Retry(ctx, retryCount, retryBackoffTime, isConflictError, db.log, opName, op)
+	// the latency of the crawler database operations, including the conflict retries
+	metrics.GetOrCreateSummary(fmt.Sprintf(`observer_db_op_seconds{op=%q}`, opName)).UpdateDuration(startTime)
+	return result, err
+}
+
+func (db DBRetrier) UpsertNodeAddr(ctx context.Context, id NodeID, addr NodeAddr) error {
+	_, err := db.retry(ctx, "UpsertNodeAddr", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpsertNodeAddr(ctx, id, addr)
+	})
+	return err
+}
+
+func (db DBRetrier) FindNodeAddr(ctx context.Context, id NodeID) (*NodeAddr, error) {
+	resultAny, err := db.retry(ctx, "FindNodeAddr", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindNodeAddr(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*NodeAddr), err
+}
+
+func (db DBRetrier) ResetPingError(ctx context.Context, id NodeID) error {
+	_, err := db.retry(ctx, "ResetPingError", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.ResetPingError(ctx, id)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdatePingError(ctx context.Context, id NodeID) error {
+	_, err := db.retry(ctx, "UpdatePingError", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdatePingError(ctx, id)
+	})
+	return err
+}
+
+func (db DBRetrier) CountPingErrors(ctx context.Context, id NodeID) (*uint, error) {
+	resultAny, err := db.retry(ctx, "CountPingErrors", func(ctx context.Context) (interface{}, error) {
+		return db.db.CountPingErrors(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*uint), err
+}
+
+func (db DBRetrier) UpdateClientID(ctx context.Context, id NodeID, clientID string, quality ObservationQuality) (bool, error) {
+	resultAny, err := db.retry(ctx, "UpdateClientID", func(ctx context.Context) (interface{}, error) {
+		return db.db.UpdateClientID(ctx, id, clientID, quality)
+	})
+	if resultAny == nil {
+		return false, err
+	}
+	return resultAny.(bool), err
+}
+
+func (db DBRetrier) FindClientID(ctx context.Context, id NodeID) (*string, error) {
+	resultAny, err := db.retry(ctx, "FindClientID", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindClientID(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*string), err
+}
+
+func (db DBRetrier) UpdateNetworkID(ctx context.Context, id NodeID, networkID uint, quality ObservationQuality) error {
+	_, err := db.retry(ctx, "UpdateNetworkID", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateNetworkID(ctx, id, networkID, quality)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateEthVersion(ctx context.Context, id NodeID, ethVersion uint, quality ObservationQuality) error {
+	_, err := db.retry(ctx, "UpdateEthVersion", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateEthVersion(ctx, id, ethVersion, quality)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateForkID(ctx context.Context, id NodeID, forkIDHash string, forkIDNext uint64, quality ObservationQuality) error {
+	_, err := db.retry(ctx, "UpdateForkID", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateForkID(ctx, id, forkIDHash, forkIDNext, quality)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateForkCompatibility(ctx context.Context, id NodeID, compatible bool, verdict string) error {
+	_, err := db.retry(ctx, "UpdateForkCompatibility", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateForkCompatibility(ctx, id, compatible, verdict)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateChainHead(ctx context.Context, id NodeID, headHash string, totalDifficulty string) error {
+	_, err := db.retry(ctx, "UpdateChainHead", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateChainHead(ctx, id, headHash, totalDifficulty)
+	})
+	return err
+}
+
+func (db DBRetrier) InsertNodeSighting(ctx context.Context, id NodeID, kind string) error {
+	_, err := db.retry(ctx, "InsertNodeSighting", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.InsertNodeSighting(ctx, id, kind)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateSupportsSnap(ctx context.Context, id NodeID, supportsSnap bool) error {
+	_, err := db.retry(ctx, "UpdateSupportsSnap", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateSupportsSnap(ctx, id, supportsSnap)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdatePortRLPxVerified(ctx context.Context, id NodeID, port uint16) error {
+	_, err := db.retry(ctx, "UpdatePortRLPxVerified", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdatePortRLPxVerified(ctx, id, port)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateDialStats(ctx context.Context, id NodeID, ipv6 bool, success bool) error {
+	_, err := db.retry(ctx, "UpdateDialStats", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateDialStats(ctx, id, ipv6, success)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateOperatorLabel(ctx context.Context, id NodeID, label string) error {
+	_, err := db.retry(ctx, "UpdateOperatorLabel", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateOperatorLabel(ctx, id, label)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateOperatorOptOut(ctx context.Context, id NodeID, optOut bool) error {
+	_, err := db.retry(ctx, "UpdateOperatorOptOut", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateOperatorOptOut(ctx, id, optOut)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateReachability(ctx context.Context, id NodeID, reachability string) error {
+	_, err := db.retry(ctx, "UpdateReachability", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateReachability(ctx, id, reachability)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateTxAnnounceRate(ctx context.Context, id NodeID, rate float64) error {
+	_, err := db.retry(ctx, "UpdateTxAnnounceRate", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateTxAnnounceRate(ctx, id, rate)
+	})
+	return err
+}
+
+func (db DBRetrier) InsertBlockAnnouncements(ctx context.Context, id NodeID, announcements []BlockAnnouncement) error {
+	_, err := db.retry(ctx, "InsertBlockAnnouncements", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.InsertBlockAnnouncements(ctx, id, announcements)
+	})
+	return err
+}
+
+func (db DBRetrier) InsertHandshakeError(ctx context.Context, id NodeID, handshakeErr string) error {
+	_, err := db.retry(ctx, "InsertHandshakeError", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.InsertHandshakeError(ctx, id, handshakeErr)
+	})
+	return err
+}
+
+func (db DBRetrier) InsertNodeLatency(ctx context.Context, id NodeID, kind string, rtt time.Duration) error {
+	_, err := db.retry(ctx, "InsertNodeLatency", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.InsertNodeLatency(ctx, id, kind, rtt)
+	})
+	return err
+}
+
+func (db DBRetrier) DeleteHandshakeErrors(ctx context.Context, id NodeID) error {
+	_, err := db.retry(ctx, "DeleteHandshakeErrors", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.DeleteHandshakeErrors(ctx, id)
+	})
+	return err
+}
+
+func (db DBRetrier) FindHandshakeLastErrors(ctx context.Context, id NodeID, limit uint) ([]HandshakeError, error) {
+	resultAny, err := db.retry(ctx, "FindHandshakeLastErrors", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindHandshakeLastErrors(ctx, id, limit)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.([]HandshakeError), err
+}
+
+func (db DBRetrier) UpdateHandshakeRetryTime(ctx context.Context, id NodeID, retryTime time.Time) error {
+	_, err := db.retry(ctx, "UpdateHandshakeRetryTime", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateHandshakeRetryTime(ctx, id, retryTime)
+	})
+	return err
+}
+
+func (db DBRetrier) FindHandshakeRetryTime(ctx context.Context, id NodeID) (*time.Time, error) {
+	resultAny, err := db.retry(ctx, "FindHandshakeRetryTime", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindHandshakeRetryTime(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*time.Time), err
+}
+
+func (db DBRetrier) CountHandshakeRetries(ctx context.Context, id NodeID) (*uint, error) {
+	resultAny, err := db.retry(ctx, "CountHandshakeRetries", func(ctx context.Context) (interface{}, error) {
+		return db.db.CountHandshakeRetries(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*uint), err
+}
+
+func (db DBRetrier) EnumerateProbePolicies(ctx context.Context) ([]ProbePolicy, error) {
+	resultAny, err := db.retry(ctx, "EnumerateProbePolicies", func(ctx context.Context) (interface{}, error) {
+		return db.db.EnumerateProbePolicies(ctx)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.([]ProbePolicy), err
+}
+
+func (db DBRetrier) FindProbeTraits(ctx context.Context, id NodeID) (*ProbeTraits, error) {
+	resultAny, err := db.retry(ctx, "FindProbeTraits", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindProbeTraits(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*ProbeTraits), err
+}
+
+func (db DBRetrier) IncrementHandshakeHourStats(ctx context.Context, id NodeID, success bool, moment time.Time) error {
+	_, err := db.retry(ctx, "IncrementHandshakeHourStats", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.IncrementHandshakeHourStats(ctx, id, success, moment)
+	})
+	return err
+}
+
+func (db DBRetrier) InsertHandshakeAttempt(ctx context.Context, id NodeID, attempt HandshakeAttempt) error {
+	_, err := db.retry(ctx, "InsertHandshakeAttempt", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.InsertHandshakeAttempt(ctx, id, attempt)
+	})
+	return err
+}
+
+func (db DBRetrier) InsertProtocolViolation(ctx context.Context, id NodeID, violation ProtocolViolation) error {
+	_, err := db.retry(ctx, "InsertProtocolViolation", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.InsertProtocolViolation(ctx, id, violation)
+	})
+	return err
+}
+
+func (db DBRetrier) UpsertNodeCapabilities(ctx context.Context, id NodeID, caps []NodeCapability) error {
+	_, err := db.retry(ctx, "UpsertNodeCapabilities", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpsertNodeCapabilities(ctx, id, caps)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateEnrEntries(ctx context.Context, id NodeID, entries []EnrEntry) error {
+	_, err := db.retry(ctx, "UpdateEnrEntries", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateEnrEntries(ctx, id, entries)
+	})
+	return err
+}
+
+func (db DBRetrier) UpsertNodeENR(ctx context.Context, id NodeID, nodeENR NodeENR) error {
+	_, err := db.retry(ctx, "UpsertNodeENR", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpsertNodeENR(ctx, id, nodeENR)
+	})
+	return err
+}
+
+func (db DBRetrier) FindNodeASN(ctx context.Context, id NodeID) (*uint, error) {
+	resultAny, err := db.retry(ctx, "FindNodeASN", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindNodeASN(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*uint), err
+}
+
+func (db DBRetrier) UpdateNodeDiscVersion(ctx context.Context, id NodeID, discVersion uint) error {
+	_, err := db.retry(ctx, "UpdateNodeDiscVersion", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateNodeDiscVersion(ctx, id, discVersion)
+	})
+	return err
+}
+
+func (db DBRetrier) FindProbedBuckets(ctx context.Context, id NodeID) ([]uint, error) {
+	resultAny, err := db.retry(ctx, "FindProbedBuckets", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindProbedBuckets(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.([]uint), err
+}
+
+func (db DBRetrier) MarkProbedBuckets(ctx context.Context, id NodeID, buckets []uint) error {
+	_, err := db.retry(ctx, "MarkProbedBuckets", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.MarkProbedBuckets(ctx, id, buckets)
+	})
+	return err
+}
+
+func (db DBRetrier) UpsertFrontierEntry(ctx context.Context, entry FrontierEntry) error {
+	_, err := db.retry(ctx, "UpsertFrontierEntry", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpsertFrontierEntry(ctx, entry)
+	})
+	return err
+}
+
+func (db DBRetrier) DeleteFrontierEntry(ctx context.Context, id NodeID) error {
+	_, err := db.retry(ctx, "DeleteFrontierEntry", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.DeleteFrontierEntry(ctx, id)
+	})
+	return err
+}
+
+func (db DBRetrier) UpdateNodeDepth(ctx context.Context, id NodeID, depth uint) error {
+	_, err := db.retry(ctx, "UpdateNodeDepth", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateNodeDepth(ctx, id, depth)
+	})
+	return err
+}
+
+func (db DBRetrier) FindNodeDepth(ctx context.Context, id NodeID) (*uint, error) {
+	resultAny, err := db.retry(ctx, "FindNodeDepth", func(ctx context.Context) (interface{}, error) {
+		return db.db.FindNodeDepth(ctx, id)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.(*uint), err
+}
+
+func (db DBRetrier) UpdateCrawlRetryTime(ctx context.Context, id NodeID, retryTime time.Time) error {
+	_, err := db.retry(ctx, "UpdateCrawlRetryTime", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.UpdateCrawlRetryTime(ctx, id, retryTime)
+	})
+	return err
+}
+
+func (db DBRetrier) AcquireCandidates(ctx context.Context, owner string, leaseDuration time.Duration, networkID uint, limit uint, idLower NodeID, idUpper NodeID) ([]NodeID, error) {
+	resultAny, err := db.retry(ctx, "AcquireCandidates", func(ctx context.Context) (interface{}, error) {
+		return db.db.AcquireCandidates(ctx, owner, leaseDuration, networkID, limit, idLower, idUpper)
+	})
+	if resultAny == nil {
+		return nil, err
+	}
+	return resultAny.([]NodeID), err
+}
+
+func (db DBRetrier) RenewCandidateLeases(ctx context.Context, owner string, leaseDuration time.Duration, ids []NodeID) error {
+	_, err := db.retry(ctx, "RenewCandidateLeases", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.RenewCandidateLeases(ctx, owner, leaseDuration, ids)
+	})
+	return err
+}
+
+func (db DBRetrier) ReleaseCandidateLeases(ctx context.Context, owner string, ids []NodeID) error {
+	_, err := db.retry(ctx, "ReleaseCandidateLeases", func(ctx context.Context) (interface{}, error) {
+		return nil, db.db.ReleaseCandidateLeases(ctx, owner, ids)
+	})
+	return err
+}
@@ -0,0 +1,4292 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	_ "modernc.org/sqlite"
+)
+
+type DBSQLite struct {
+	db *sql.DB
+
+	// readDBs are optional read replica connections (see DBPostgres).
+	// The row set queries (reports, exports, enumerations) are routed
+	// to them round-robin, so analytics reads don't contend
+	// with the crawl write path on the primary.
+	// The single-row lookups backing the crawl scheduling stay
+	// on the primary, since they cannot tolerate replication lag.
+	readDBs     []*sql.DB
+	readCounter uint32
+
+	// stmtRewrite translates the statements to another SQL dialect.
+	// It is nil for SQLite itself, and set by backends sharing
+	// the statement set (see DBPostgres).
+	stmtRewrite func(statement string) string
+
+	// clock provides the stored timestamps (UTC seconds, see utils.ToTimestamp):
+	// the real wall clock normally, and a fake clock in deterministic tests.
+	clock utils.Clock
+
+	// strictRead turns on the read validation quarantining bad values
+	// into the bad_rows table (see EnableStrictRead).
+	strictRead bool
+}
+
+// SetClock replaces the wall clock behind the stored timestamps,
+// so tests can drive the retry and lease scheduling deterministically.
+func (db *DBSQLite) SetClock(clock utils.Clock) {
+	db.clock = clock
+}
+
+// reader returns the connection for a read-only row set query:
+// one of the read replicas round-robin if any are configured,
+// and the primary otherwise.
+func (db *DBSQLite) reader() *sql.DB {
+	if len(db.readDBs) == 0 {
+		return db.db
+	}
+	next := atomic.AddUint32(&db.readCounter, 1)
+	return db.readDBs[int(next)%len(db.readDBs)]
+}
+
+// stmt returns a statement in the dialect of the backend.
+func (db *DBSQLite) stmt(statement string) string {
+	if db.stmtRewrite == nil {
+		return statement
+	}
+	return db.stmtRewrite(statement)
+}
+
+// language=SQL
+const (
+	sqlCreateSchema = `
+CREATE TABLE IF NOT EXISTS nodes (
+    id TEXT PRIMARY KEY,
+
+    ip TEXT,
+    port_disc INTEGER,
+    port_rlpx INTEGER,
+    port_rlpx_verified INTEGER,
+    ip_v6 TEXT,
+    ip_v6_port_disc INTEGER,
+    ip_v6_port_rlpx INTEGER,
+    addr_updated INTEGER NOT NULL,
+    first_seen INTEGER,
+
+    ping_try INTEGER NOT NULL DEFAULT 0,
+
+    client_id TEXT,
+    client_id_quality INTEGER NOT NULL DEFAULT 0,
+    network_id INTEGER,
+    network_id_quality INTEGER NOT NULL DEFAULT 0,
+    eth_version INTEGER,
+    eth_version_quality INTEGER NOT NULL DEFAULT 0,
+    fork_id_hash TEXT,
+    fork_id_next INTEGER,
+    fork_id_quality INTEGER NOT NULL DEFAULT 0,
+    fork_compatible INTEGER,
+    fork_verdict TEXT,
+    head_hash TEXT,
+    head_td TEXT,
+    supports_snap INTEGER,
+    tx_announce_rate REAL,
+    handshake_try INTEGER NOT NULL DEFAULT 0,
+    handshake_retry_time INTEGER,
+
+    crawl_retry_time INTEGER,
+    lease_owner TEXT,
+    lease_expires INTEGER,
+    depth INTEGER,
+    asn INTEGER,
+    country TEXT,
+    city TEXT,
+    ip_reputation REAL,
+    disc_version INTEGER,
+    reachability TEXT,
+    reachability_updated INTEGER,
+
+    dial_attempts_v4 INTEGER NOT NULL DEFAULT 0,
+    dial_success_v4 INTEGER NOT NULL DEFAULT 0,
+    dial_attempts_v6 INTEGER NOT NULL DEFAULT 0,
+    dial_success_v6 INTEGER NOT NULL DEFAULT 0,
+
+    operator_label TEXT,
+    operator_opt_out INTEGER NOT NULL DEFAULT 0,
+
+    change_seq INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS node_client_history (
+    node_id TEXT NOT NULL,
+    observed INTEGER NOT NULL,
+    client_id TEXT NOT NULL,
+
+    PRIMARY KEY (node_id, observed)
+);
+
+CREATE TABLE IF NOT EXISTS node_history (
+    ip TEXT NOT NULL,
+    port_rlpx INTEGER NOT NULL,
+    node_id TEXT NOT NULL,
+    first_seen INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL,
+
+    PRIMARY KEY (ip, port_rlpx, node_id)
+);
+
+CREATE TABLE IF NOT EXISTS crawl_frontier (
+    node_id TEXT PRIMARY KEY,
+    priority REAL NOT NULL,
+    source TEXT,
+    depth INTEGER NOT NULL,
+    added INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS handshake_attempts (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    attempt_time INTEGER NOT NULL,
+    source_ip TEXT,
+    success INTEGER NOT NULL,
+    err TEXT,
+    duration_ms INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS handshake_errors (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    err TEXT NOT NULL,
+    updated INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS handshake_attempt_days (
+    node_id TEXT NOT NULL,
+    day INTEGER NOT NULL,
+    source_ip TEXT NOT NULL DEFAULT '',
+    attempt_count INTEGER NOT NULL,
+    success_count INTEGER NOT NULL,
+    total_duration_ms INTEGER NOT NULL,
+    PRIMARY KEY (node_id, day, source_ip)
+);
+
+CREATE TABLE IF NOT EXISTS monitored_nodes (
+    node_id TEXT PRIMARY KEY,
+    label TEXT,
+    slo_target REAL NOT NULL,
+    window_days INTEGER NOT NULL,
+    added INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS probe_policies (
+    name TEXT PRIMARY KEY,
+    tag TEXT,
+    subnet TEXT,
+    country TEXT,
+    min_interval_seconds INTEGER NOT NULL DEFAULT 0,
+    quiet_start_hour INTEGER NOT NULL DEFAULT 0,
+    quiet_end_hour INTEGER NOT NULL DEFAULT 0,
+    utc_offset_minutes INTEGER NOT NULL DEFAULT 0,
+    added INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS slo_status (
+    node_id TEXT PRIMARY KEY,
+    window_start INTEGER NOT NULL,
+    attempt_count INTEGER NOT NULL,
+    success_count INTEGER NOT NULL,
+    availability REAL NOT NULL,
+    budget_burn REAL NOT NULL,
+    updated INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS node_client_info (
+    node_id TEXT PRIMARY KEY,
+    client_name TEXT,
+    client_version TEXT,
+    client_os TEXT,
+    client_arch TEXT,
+    updated INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS node_sightings (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    sighted INTEGER NOT NULL,
+    kind TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_sightings_node_id ON node_sightings (node_id);
+
+CREATE TABLE IF NOT EXISTS node_labels (
+    node_id TEXT NOT NULL,
+    label TEXT NOT NULL,
+    score REAL NOT NULL DEFAULT 1,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (node_id, label)
+);
+
+CREATE TABLE IF NOT EXISTS node_probed_buckets (
+    node_id TEXT NOT NULL,
+    bucket INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (node_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS node_enr_entries (
+    node_id TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value BLOB,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (node_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS node_enr (
+    node_id TEXT PRIMARY KEY,
+    seq INTEGER NOT NULL,
+    record BLOB NOT NULL,
+    updated INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS enrich_progress (
+    stage TEXT PRIMARY KEY,
+    last_node_id TEXT NOT NULL,
+    updated INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS enrichment_freshness (
+    node_id TEXT NOT NULL,
+    attribute TEXT NOT NULL,
+    refreshed INTEGER NOT NULL,
+    PRIMARY KEY (node_id, attribute)
+);
+
+CREATE TABLE IF NOT EXISTS ip_reputation (
+    ip TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    score REAL NOT NULL,
+    checked INTEGER NOT NULL,
+    PRIMARY KEY (ip, provider)
+);
+
+CREATE TABLE IF NOT EXISTS node_events (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    happened INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS node_latency (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    rtt_us INTEGER NOT NULL,
+    measured INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS handshake_hour_stats (
+    dow INTEGER NOT NULL,
+    hour INTEGER NOT NULL,
+    country TEXT NOT NULL,
+    success_count INTEGER NOT NULL,
+    failure_count INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (dow, hour, country)
+);
+
+CREATE TABLE IF NOT EXISTS bad_rows (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    field TEXT NOT NULL,
+    value TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    detected INTEGER NOT NULL,
+    UNIQUE (node_id, field)
+);
+
+CREATE TABLE IF NOT EXISTS node_seen_windows (
+    window_hours INTEGER PRIMARY KEY,
+    node_count INTEGER NOT NULL,
+    ip_count INTEGER NOT NULL,
+    refreshed INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS node_capabilities (
+    node_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    first_seen INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (node_id, name, version)
+);
+
+CREATE TABLE IF NOT EXISTS node_protocol_violations (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    violation_time INTEGER NOT NULL,
+    code TEXT NOT NULL,
+    detail TEXT
+);
+
+CREATE TABLE IF NOT EXISTS stats_snapshots (
+    snapshot_time INTEGER PRIMARY KEY,
+    total_nodes INTEGER NOT NULL,
+    compatible_nodes INTEGER NOT NULL,
+    distinct_ips INTEGER NOT NULL,
+    operator_estimate INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS stats_snapshot_clients (
+    snapshot_time INTEGER NOT NULL,
+    client_name TEXT NOT NULL,
+    node_count INTEGER NOT NULL,
+    PRIMARY KEY (snapshot_time, client_name)
+);
+
+CREATE TABLE IF NOT EXISTS analytics_aggregates (
+    category TEXT NOT NULL,
+    bucket TEXT NOT NULL,
+    node_count INTEGER NOT NULL,
+    refreshed INTEGER NOT NULL,
+    PRIMARY KEY (category, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS block_announcements (
+    node_id TEXT NOT NULL,
+    block_hash TEXT NOT NULL,
+    block_number INTEGER NOT NULL,
+    announced INTEGER NOT NULL,
+    PRIMARY KEY (node_id, block_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_block_announcements_block_hash ON block_announcements (block_hash);
+
+CREATE TABLE IF NOT EXISTS federated_nodes (
+    node_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    first_pulled INTEGER NOT NULL,
+    last_pulled INTEGER NOT NULL,
+    PRIMARY KEY (node_id, source)
+);
+
+CREATE TABLE IF NOT EXISTS export_state (
+    name TEXT PRIMARY KEY,
+    last_change_seq INTEGER NOT NULL,
+    updated INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_nodes_change_seq ON nodes (change_seq);
+CREATE INDEX IF NOT EXISTS idx_nodes_crawl_retry_time ON nodes (crawl_retry_time);
+CREATE INDEX IF NOT EXISTS idx_handshake_attempts_node_id ON handshake_attempts (node_id);
+CREATE INDEX IF NOT EXISTS idx_handshake_errors_node_id ON handshake_errors (node_id);
+CREATE INDEX IF NOT EXISTS idx_node_protocol_violations_node_id ON node_protocol_violations (node_id);
+CREATE INDEX IF NOT EXISTS idx_node_enr_entries_key ON node_enr_entries (key);
+CREATE INDEX IF NOT EXISTS idx_crawl_frontier_priority ON crawl_frontier (priority);
+CREATE INDEX IF NOT EXISTS idx_node_events_node_id ON node_events (node_id);
+CREATE INDEX IF NOT EXISTS idx_node_latency_node_id ON node_latency (node_id);
+`
+
+	sqlUpsertNodeAddr = `
+INSERT INTO nodes(
+	id,
+	ip, port_disc, port_rlpx,
+	ip_v6, ip_v6_port_disc, ip_v6_port_rlpx,
+	addr_updated, first_seen
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+	ip = excluded.ip,
+	port_disc = excluded.port_disc,
+	port_rlpx = excluded.port_rlpx,
+	ip_v6 = excluded.ip_v6,
+	ip_v6_port_disc = excluded.ip_v6_port_disc,
+	ip_v6_port_rlpx = excluded.ip_v6_port_rlpx,
+	addr_updated = excluded.addr_updated
+`
+
+	sqlFindNodeAddr = `
+SELECT
+	ip, port_disc, port_rlpx,
+	ip_v6, ip_v6_port_disc, ip_v6_port_rlpx
+FROM nodes WHERE id = ?
+`
+
+	sqlResetPingError = `
+UPDATE nodes SET ping_try = 0 WHERE id = ?
+`
+
+	sqlUpdatePingError = `
+UPDATE nodes SET ping_try = nodes.ping_try + 1 WHERE id = ?
+`
+
+	sqlAddPingErrors = `
+UPDATE nodes SET ping_try = nodes.ping_try + ? WHERE id = ?
+`
+
+	sqlCountPingErrors = `
+SELECT ping_try FROM nodes WHERE id = ?
+`
+
+	sqlUpdateClientID = `
+UPDATE nodes SET client_id = ?, client_id_quality = ? WHERE id = ?
+`
+
+	sqlInsertClientHistory = `
+INSERT INTO node_client_history (node_id, observed, client_id)
+VALUES (?, ?, ?)
+ON CONFLICT (node_id, observed) DO UPDATE SET client_id = excluded.client_id
+`
+
+	// sqlInsertClientChangeEvent reads the previous client ID
+	// in the same statement that records the event,
+	// so nothing is inserted if the value is unchanged or the node is unknown
+	sqlInsertClientChangeEvent = `
+INSERT INTO node_events (node_id, kind, old_value, new_value, happened)
+SELECT id, 'client_changed', client_id, ?, ?
+FROM nodes
+WHERE (id = ?) AND ((client_id IS NULL) OR (client_id <> ?))
+`
+
+	// sqlInsertDiscoveredEvent records a discovery event only if the node row
+	// does not exist yet; it runs in the same transaction as the upsert
+	// that creates the row (see UpsertNodeAddr and FlushNodeWrites)
+	sqlInsertDiscoveredEvent = `
+INSERT INTO node_events (node_id, kind, new_value, happened)
+SELECT ?, 'discovered', ?, ?
+WHERE NOT EXISTS (SELECT 1 FROM nodes WHERE id = ?)
+`
+
+	// sqlUpsertNodeHistory records which node ID sits behind an endpoint;
+	// it runs in the same transaction as the node address upsert
+	// (see UpsertNodeAddr and FlushNodeWrites)
+	sqlUpsertNodeHistory = `
+INSERT INTO node_history(ip, port_rlpx, node_id, first_seen, last_seen)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(ip, port_rlpx, node_id) DO UPDATE SET last_seen = excluded.last_seen
+`
+
+	sqlEnumerateKeyRotations = `
+SELECT ip, port_rlpx, COUNT(*), MIN(first_seen), MAX(last_seen)
+FROM node_history
+GROUP BY ip, port_rlpx
+HAVING COUNT(*) >= ?
+ORDER BY COUNT(*) DESC, MAX(last_seen) DESC
+LIMIT ?
+`
+
+	sqlFindNodeHistory = `
+SELECT node_id, first_seen, last_seen
+FROM node_history
+WHERE (ip = ?) AND (port_rlpx = ?)
+ORDER BY first_seen
+`
+
+	// sqlInsertWentOfflineEvent reads the previous reachability class
+	// in the same statement that records the event,
+	// so nothing is inserted if the node was already unreachable
+	sqlInsertWentOfflineEvent = `
+INSERT INTO node_events (node_id, kind, old_value, new_value, happened)
+SELECT id, 'went_offline', reachability, ?, ?
+FROM nodes
+WHERE (id = ?) AND ((reachability IS NULL) OR (reachability <> ?))
+`
+
+	sqlFindLastNodeEventNum = `
+SELECT COALESCE(MAX(num), 0) FROM node_events
+`
+
+	sqlEnumerateNodeEventsAfter = `
+SELECT num, node_id, kind, old_value, new_value, happened
+FROM node_events
+WHERE num > ?
+ORDER BY num
+LIMIT ?
+`
+
+	sqlFindNodeEvents = `
+SELECT kind, old_value, new_value, happened
+FROM node_events
+WHERE node_id = ?
+ORDER BY num DESC
+LIMIT ?
+`
+
+	sqlFindClientID = `
+SELECT client_id FROM nodes WHERE id = ?
+`
+
+	sqlUpdateNetworkID = `
+UPDATE nodes SET network_id = ?, network_id_quality = ? WHERE id = ?
+`
+
+	sqlUpdateEthVersion = `
+UPDATE nodes SET eth_version = ?, eth_version_quality = ? WHERE id = ?
+`
+
+	sqlUpdateForkID = `
+UPDATE nodes SET fork_id_hash = ?, fork_id_next = ?, fork_id_quality = ? WHERE id = ?
+`
+
+	sqlUpdateForkCompatibility = `
+UPDATE nodes SET fork_compatible = ?, fork_verdict = ? WHERE id = ?
+`
+
+	sqlUpdateChainHead = `
+UPDATE nodes SET head_hash = ?, head_td = ? WHERE id = ?
+`
+
+	sqlUpdateSupportsSnap = `
+UPDATE nodes SET supports_snap = ? WHERE id = ?
+`
+
+	sqlUpdatePortRLPxVerified = `
+UPDATE nodes SET port_rlpx_verified = ? WHERE id = ?
+`
+
+	sqlUpdateReachability = `
+UPDATE nodes SET reachability = ?, reachability_updated = ? WHERE id = ?
+`
+
+	sqlCountReachability = `
+SELECT reachability, COUNT(*) FROM nodes
+WHERE (reachability IS NOT NULL)
+	AND ((? = 0) OR (network_id = ?))
+GROUP BY reachability
+`
+
+	sqlUpdateTxAnnounceRate = `
+UPDATE nodes SET tx_announce_rate = ? WHERE id = ?
+`
+
+	// sqlInsertBlockAnnouncement keeps the earliest announcement per node and block:
+	// a re-announcement of the same block carries no extra propagation information.
+	sqlInsertBlockAnnouncement = `
+INSERT INTO block_announcements(node_id, block_hash, block_number, announced)
+VALUES(?, ?, ?, ?)
+ON CONFLICT(node_id, block_hash) DO NOTHING
+`
+
+	// sqlBlockPropagationStats measures each announcement against the earliest
+	// announcement of the same block across all nodes. The announced column
+	// holds unix milliseconds, so the average delay is in milliseconds too.
+	sqlBlockPropagationStats = `
+SELECT announcements.node_id,
+	nodes.client_id,
+	COUNT(*),
+	AVG(announcements.announced - first_announcements.first_announced)
+FROM block_announcements AS announcements
+JOIN (
+	SELECT block_hash, MIN(announced) AS first_announced
+	FROM block_announcements
+	GROUP BY block_hash
+) AS first_announcements ON announcements.block_hash = first_announcements.block_hash
+LEFT JOIN nodes ON nodes.id = announcements.node_id
+GROUP BY announcements.node_id, nodes.client_id
+HAVING COUNT(*) >= ?
+ORDER BY AVG(announcements.announced - first_announcements.first_announced)
+`
+
+	sqlCountSnapSupport = `
+SELECT COALESCE(SUM(supports_snap), 0), COUNT(supports_snap) FROM nodes
+WHERE (? = 0) OR (network_id = ?)
+`
+
+	sqlEnumerateOperatorTraits = `
+SELECT ip, client_id FROM nodes
+WHERE (? = 0) OR (network_id = ?)
+`
+
+	sqlInsertHandshakeError = `
+INSERT INTO handshake_errors(node_id, err, updated)
+VALUES (?, ?, ?)
+`
+
+	sqlTrimHandshakeErrors = `
+DELETE FROM handshake_errors
+WHERE (node_id = ?) AND num NOT IN (
+	SELECT num FROM handshake_errors WHERE node_id = ? ORDER BY num DESC LIMIT ?)
+`
+
+	sqlInsertNodeLatency = `
+INSERT INTO node_latency(node_id, kind, rtt_us, measured)
+VALUES (?, ?, ?, ?)
+`
+
+	sqlTrimNodeLatency = `
+DELETE FROM node_latency
+WHERE (node_id = ?) AND (kind = ?) AND num NOT IN (
+	SELECT num FROM node_latency WHERE (node_id = ?) AND (kind = ?) ORDER BY num DESC LIMIT ?)
+`
+
+	sqlEnumerateNodeLatencies = `
+SELECT nodes.country, nodes.client_id, node_latency.rtt_us FROM node_latency
+JOIN nodes ON (nodes.id = node_latency.node_id)
+WHERE node_latency.kind = ?
+`
+
+	sqlMarkHandshakeError = `
+UPDATE nodes SET handshake_try = nodes.handshake_try + 1 WHERE id = ?
+`
+
+	sqlDeleteHandshakeErrors = `
+DELETE FROM handshake_errors WHERE node_id = ?
+`
+
+	sqlResetHandshakeTries = `
+UPDATE nodes SET handshake_try = 0 WHERE id = ?
+`
+
+	sqlFindHandshakeLastErrors = `
+SELECT err, updated FROM handshake_errors
+WHERE node_id = ?
+ORDER BY num DESC
+LIMIT ?
+`
+
+	sqlUpdateHandshakeRetryTime = `
+UPDATE nodes SET handshake_retry_time = ? WHERE id = ?
+`
+
+	sqlFindHandshakeRetryTime = `
+SELECT handshake_retry_time FROM nodes WHERE id = ?
+`
+
+	sqlCountHandshakeRetries = `
+SELECT handshake_try FROM nodes WHERE id = ?
+`
+
+	sqlInsertHandshakeAttempt = `
+INSERT INTO handshake_attempts(node_id, attempt_time, source_ip, success, err, duration_ms)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+	sqlIncrementHandshakeHourStats = `
+INSERT INTO handshake_hour_stats(dow, hour, country, success_count, failure_count, updated)
+VALUES (?, ?, (SELECT COALESCE(country, '') FROM nodes WHERE id = ?), ?, ?, ?)
+ON CONFLICT(dow, hour, country) DO UPDATE SET
+	success_count = handshake_hour_stats.success_count + excluded.success_count,
+	failure_count = handshake_hour_stats.failure_count + excluded.failure_count,
+	updated = excluded.updated
+`
+
+	sqlEnumerateHandshakeHourStats = `
+SELECT dow, hour, country, success_count, failure_count FROM handshake_hour_stats
+ORDER BY dow, hour, country
+`
+
+	sqlQuarantineBadRow = `
+INSERT INTO bad_rows(node_id, field, value, reason, detected)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(node_id, field) DO UPDATE SET
+	value = excluded.value,
+	reason = excluded.reason,
+	detected = excluded.detected
+`
+
+	sqlFindBadRows = `
+SELECT node_id, field, value, reason, detected FROM bad_rows
+ORDER BY detected DESC, num DESC
+LIMIT ?
+`
+
+	sqlInsertProtocolViolation = `
+INSERT INTO node_protocol_violations(node_id, violation_time, code, detail)
+VALUES (?, ?, ?, ?)
+`
+
+	sqlCountProtocolViolations = `
+SELECT code, COUNT(DISTINCT node_id) FROM node_protocol_violations
+WHERE (? = 0) OR EXISTS (SELECT 1 FROM nodes
+	WHERE (id = node_protocol_violations.node_id) AND (network_id = ?))
+GROUP BY code
+`
+
+	sqlUpsertNodeCapability = `
+INSERT INTO node_capabilities(node_id, name, version, first_seen, updated)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(node_id, name, version) DO UPDATE SET updated = excluded.updated
+`
+
+	sqlCountNodesByCapability = `
+SELECT name || '/' || CAST(version AS TEXT), COUNT(DISTINCT node_id)
+FROM node_capabilities
+WHERE (? = 0) OR EXISTS (SELECT 1 FROM nodes
+	WHERE (id = node_capabilities.node_id) AND (network_id = ?))
+GROUP BY name, version
+`
+
+	sqlUpsertFederatedNode = `
+INSERT INTO federated_nodes(node_id, source, first_pulled, last_pulled)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(node_id, source) DO UPDATE SET last_pulled = excluded.last_pulled
+`
+
+	sqlCountFederatedNodes = `
+SELECT source, COUNT(*) FROM federated_nodes
+GROUP BY source
+`
+
+	sqlInsertStatsSnapshot = `
+INSERT INTO stats_snapshots(snapshot_time, total_nodes, compatible_nodes, distinct_ips, operator_estimate)
+VALUES (?, ?, ?, ?, ?)
+`
+
+	sqlInsertStatsSnapshotClient = `
+INSERT INTO stats_snapshot_clients(snapshot_time, client_name, node_count)
+VALUES (?, ?, ?)
+`
+
+	sqlFindStatsSnapshots = `
+SELECT snapshot_time, total_nodes, compatible_nodes, distinct_ips, operator_estimate
+FROM stats_snapshots
+WHERE snapshot_time >= ?
+ORDER BY snapshot_time
+`
+
+	sqlFindStatsSnapshotClients = `
+SELECT client_name, node_count FROM stats_snapshot_clients WHERE snapshot_time = ?
+`
+
+	sqlDeleteStatsSnapshots = `
+DELETE FROM stats_snapshots WHERE snapshot_time < ?
+`
+
+	sqlDeleteStatsSnapshotClients = `
+DELETE FROM stats_snapshot_clients WHERE snapshot_time < ?
+`
+
+	sqlDeleteStaleNodes = `
+DELETE FROM nodes WHERE (addr_updated < ?) AND (ping_try >= ?)
+`
+
+	sqlCountRLPxPortGroups = `
+SELECT port_rlpx, COUNT(*) FROM nodes
+WHERE port_rlpx IS NOT NULL
+GROUP BY port_rlpx
+`
+
+	sqlCountIPVersions = `
+SELECT 'ipv4', COUNT(ip) FROM nodes
+UNION ALL
+SELECT 'ipv6', COUNT(ip_v6) FROM nodes
+UNION ALL
+SELECT 'ipv6_only', COUNT(*) FROM nodes WHERE (ip IS NULL) AND (ip_v6 IS NOT NULL)
+`
+
+	sqlUpdateDialStatsV4 = `
+UPDATE nodes SET dial_attempts_v4 = dial_attempts_v4 + 1, dial_success_v4 = dial_success_v4 + ?
+WHERE id = ?
+`
+
+	sqlUpdateDialStatsV6 = `
+UPDATE nodes SET dial_attempts_v6 = dial_attempts_v6 + 1, dial_success_v6 = dial_success_v6 + ?
+WHERE id = ?
+`
+
+	sqlCountDialFamilyStats = `
+SELECT
+	COALESCE(SUM(dial_attempts_v4), 0),
+	COALESCE(SUM(dial_success_v4), 0),
+	COALESCE(SUM(dial_attempts_v6), 0),
+	COALESCE(SUM(dial_success_v6), 0)
+FROM nodes
+WHERE (? = 0) OR (network_id = ?)
+`
+
+	sqlUpdateOperatorLabel = `
+UPDATE nodes SET operator_label = (CASE WHEN ? = '' THEN NULL ELSE ? END) WHERE id = ?
+`
+
+	sqlUpdateOperatorOptOut = `
+UPDATE nodes SET operator_opt_out = ? WHERE id = ?
+`
+
+	sqlFindOperatorSettings = `
+SELECT operator_label, operator_opt_out FROM nodes WHERE id = ?
+`
+
+	sqlDeleteAnalyticsAggregates = `
+DELETE FROM analytics_aggregates WHERE category = ?
+`
+
+	sqlInsertAnalyticsAggregate = `
+INSERT INTO analytics_aggregates(category, bucket, node_count, refreshed)
+VALUES (?, ?, ?, ?)
+`
+
+	sqlFindAnalyticsAggregates = `
+SELECT bucket, node_count FROM analytics_aggregates WHERE category = ?
+`
+
+	sqlUpsertMonitoredNode = `
+INSERT INTO monitored_nodes(node_id, label, slo_target, window_days, added)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(node_id) DO UPDATE SET
+	label = excluded.label,
+	slo_target = excluded.slo_target,
+	window_days = excluded.window_days
+`
+
+	sqlDeleteMonitoredNode = `
+DELETE FROM monitored_nodes WHERE node_id = ?
+`
+
+	sqlEnumerateMonitoredNodes = `
+SELECT node_id, label, slo_target, window_days FROM monitored_nodes ORDER BY node_id
+`
+
+	sqlCountHandshakeOutcomesSince = `
+SELECT COUNT(*), COALESCE(SUM(success), 0) FROM handshake_attempts
+WHERE (node_id = ?) AND (attempt_time >= ?)
+`
+
+	sqlCountCompactedHandshakeOutcomesSince = `
+SELECT COALESCE(SUM(attempt_count), 0), COALESCE(SUM(success_count), 0) FROM handshake_attempt_days
+WHERE (node_id = ?) AND (day >= ?)
+`
+
+	sqlUpsertProbePolicy = `
+INSERT INTO probe_policies(name, tag, subnet, country,
+	min_interval_seconds, quiet_start_hour, quiet_end_hour, utc_offset_minutes, added)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+	tag = excluded.tag,
+	subnet = excluded.subnet,
+	country = excluded.country,
+	min_interval_seconds = excluded.min_interval_seconds,
+	quiet_start_hour = excluded.quiet_start_hour,
+	quiet_end_hour = excluded.quiet_end_hour,
+	utc_offset_minutes = excluded.utc_offset_minutes
+`
+
+	sqlDeleteProbePolicy = `
+DELETE FROM probe_policies WHERE name = ?
+`
+
+	sqlEnumerateProbePolicies = `
+SELECT name, tag, subnet, country,
+	min_interval_seconds, quiet_start_hour, quiet_end_hour, utc_offset_minutes
+FROM probe_policies ORDER BY name
+`
+
+	sqlFindProbeTraits = `
+SELECT
+	ip,
+	country,
+	(SELECT label FROM monitored_nodes WHERE node_id = nodes.id),
+	(SELECT MAX(attempt_time) FROM handshake_attempts WHERE node_id = nodes.id)
+FROM nodes WHERE id = ?
+`
+
+	sqlUpsertSLOStatus = `
+INSERT INTO slo_status(node_id, window_start, attempt_count, success_count, availability, budget_burn, updated)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(node_id) DO UPDATE SET
+	window_start = excluded.window_start,
+	attempt_count = excluded.attempt_count,
+	success_count = excluded.success_count,
+	availability = excluded.availability,
+	budget_burn = excluded.budget_burn,
+	updated = excluded.updated
+`
+
+	sqlFindSLOStatus = `
+SELECT window_start, attempt_count, success_count, availability, budget_burn
+FROM slo_status WHERE node_id = ?
+`
+
+	sqlCompactHandshakeAttempts = `
+INSERT INTO handshake_attempt_days(node_id, day, source_ip, attempt_count, success_count, total_duration_ms)
+SELECT
+	node_id,
+	(attempt_time / 86400) * 86400,
+	COALESCE(source_ip, ''),
+	COUNT(*),
+	SUM(success),
+	SUM(COALESCE(duration_ms, 0))
+FROM handshake_attempts
+WHERE attempt_time < ?
+GROUP BY node_id, (attempt_time / 86400) * 86400, COALESCE(source_ip, '')
+ON CONFLICT(node_id, day, source_ip) DO UPDATE SET
+	attempt_count = attempt_count + excluded.attempt_count,
+	success_count = success_count + excluded.success_count,
+	total_duration_ms = total_duration_ms + excluded.total_duration_ms
+`
+
+	sqlDeleteCompactedHandshakeAttempts = `
+DELETE FROM handshake_attempts WHERE attempt_time < ?
+`
+
+	sqlEnumerateHandshakeAttemptsBefore = `
+SELECT node_id, attempt_time, source_ip, success, err, duration_ms
+FROM handshake_attempts
+WHERE attempt_time < ?
+ORDER BY attempt_time, num
+`
+
+	sqlDeleteHandshakeAttemptsBefore = `
+DELETE FROM handshake_attempts WHERE attempt_time < ?
+`
+
+	sqlFindHandshakeAttempts = `
+SELECT attempt_time, source_ip, success, err, duration_ms
+FROM handshake_attempts
+WHERE node_id = ?
+ORDER BY attempt_time, num
+`
+
+	sqlEnumerateClientHistoryBefore = `
+SELECT node_id, observed, client_id
+FROM node_client_history
+WHERE observed < ?
+ORDER BY observed, node_id
+`
+
+	sqlDeleteClientHistoryBefore = `
+DELETE FROM node_client_history WHERE observed < ?
+`
+
+	sqlEnumerateVantagePointStats = `
+SELECT
+	source_ip,
+	COUNT(*),
+	SUM(success),
+	COUNT(DISTINCT node_id),
+	AVG(duration_ms)
+FROM handshake_attempts
+WHERE source_ip IS NOT NULL
+GROUP BY source_ip
+ORDER BY source_ip
+`
+
+	sqlCountVantageDivergentNodes = `
+SELECT COUNT(*) FROM (
+	SELECT node_id FROM handshake_attempts
+	WHERE source_ip IS NOT NULL
+	GROUP BY node_id
+	HAVING (COUNT(DISTINCT source_ip) > 1) AND (MIN(success) = 0) AND (MAX(success) = 1)
+)
+`
+
+	sqlDeleteEnrEntries = `
+DELETE FROM node_enr_entries WHERE node_id = ?
+`
+
+	sqlInsertEnrEntry = `
+INSERT INTO node_enr_entries(node_id, key, value, updated) VALUES (?, ?, ?, ?)
+`
+
+	sqlFindEnrEntries = `
+SELECT key, value FROM node_enr_entries WHERE node_id = ? ORDER BY key
+`
+
+	sqlUpsertNodeENR = `
+INSERT INTO node_enr(node_id, seq, record, updated) VALUES (?, ?, ?, ?)
+ON CONFLICT(node_id) DO UPDATE SET
+	seq = excluded.seq,
+	record = excluded.record,
+	updated = excluded.updated
+WHERE excluded.seq >= node_enr.seq
+`
+
+	sqlFindNodeENR = `
+SELECT seq, record FROM node_enr WHERE node_id = ?
+`
+
+	sqlEnumerateNodesByEnrKey = `
+SELECT node_id, value FROM node_enr_entries
+WHERE key = ?
+ORDER BY node_id
+`
+
+	sqlFindNodeFreshness = `
+SELECT
+	addr_updated,
+	(SELECT MAX(attempt_time) FROM handshake_attempts WHERE (node_id = nodes.id) AND (success = 1)),
+	(SELECT MAX(updated) FROM node_enr_entries WHERE node_id = nodes.id),
+	(SELECT updated FROM node_client_info WHERE node_id = nodes.id)
+FROM nodes WHERE id = ?
+`
+
+	sqlUpdateNodeASN = `
+UPDATE nodes SET asn = ? WHERE id = ?
+`
+
+	sqlFindNodeASN = `
+SELECT asn FROM nodes WHERE id = ?
+`
+
+	sqlUpdateNodeGeo = `
+UPDATE nodes SET
+	country = COALESCE(?, country),
+	city = COALESCE(?, city),
+	asn = COALESCE(?, asn)
+WHERE id = ?
+`
+
+	sqlCountNodesByCountry = `
+SELECT country, COUNT(*) FROM nodes
+WHERE (country IS NOT NULL)
+	AND ((? = 0) OR (network_id = ?))
+GROUP BY country
+`
+
+	sqlCountNodesByASN = `
+SELECT asn, COUNT(*) FROM nodes
+WHERE (asn IS NOT NULL)
+	AND ((? = 0) OR (network_id = ?))
+GROUP BY asn
+`
+
+	sqlEnumerateNodeIPs = `
+SELECT ip, ip_v6, asn FROM nodes
+WHERE ((ip IS NOT NULL) OR (ip_v6 IS NOT NULL))
+	AND ((? = 0) OR (network_id = ?))
+`
+
+	sqlCountNodesByClientID = `
+SELECT client_id, COUNT(*) FROM nodes
+WHERE (client_id IS NOT NULL)
+	AND ((? = 0) OR (network_id = ?))
+GROUP BY client_id
+`
+
+	sqlCountForkCompatibility = `
+SELECT
+	CASE
+		WHEN fork_compatible IS NULL THEN 'unknown'
+		WHEN fork_compatible = 1 THEN 'compatible'
+		ELSE 'incompatible'
+	END AS verdict,
+	COUNT(*)
+FROM nodes
+WHERE ((? = 0) OR (network_id = ?))
+GROUP BY verdict
+`
+
+	sqlUpdateNodeDiscVersion = `
+UPDATE nodes SET disc_version = CASE
+	WHEN disc_version IS NULL THEN ?
+	WHEN disc_version = ? THEN disc_version
+	ELSE 45
+END
+WHERE id = ?
+`
+
+	sqlFindProbedBuckets = `
+SELECT bucket FROM node_probed_buckets WHERE node_id = ?
+`
+
+	sqlMarkProbedBucket = `
+INSERT INTO node_probed_buckets(node_id, bucket, updated) VALUES (?, ?, ?)
+ON CONFLICT(node_id, bucket) DO UPDATE SET updated = excluded.updated
+`
+
+	sqlUpsertFrontierEntry = `
+INSERT INTO crawl_frontier(node_id, priority, source, depth, added)
+SELECT ?, ?, ?, ?, ?
+WHERE NOT EXISTS (SELECT 1 FROM nodes WHERE (id = ?) AND (crawl_retry_time IS NOT NULL))
+ON CONFLICT(node_id) DO UPDATE SET
+	priority = MAX(priority, excluded.priority),
+	depth = MIN(depth, excluded.depth)
+`
+
+	sqlDeleteFrontierEntry = `
+DELETE FROM crawl_frontier WHERE node_id = ?
+`
+
+	sqlCountFrontier = `
+SELECT COUNT(*) FROM crawl_frontier
+`
+
+	sqlUpdateNodeDepth = `
+UPDATE nodes SET depth = ? WHERE (id = ?) AND ((depth IS NULL) OR (depth > ?))
+`
+
+	sqlFindNodeDepth = `
+SELECT depth FROM nodes WHERE id = ?
+`
+
+	sqlUpdateCrawlRetryTime = `
+UPDATE nodes SET crawl_retry_time = ? WHERE id = ?
+`
+
+	sqlFindFrontierCandidates = `
+SELECT frontier.node_id FROM crawl_frontier frontier
+JOIN nodes ON nodes.id = frontier.node_id
+WHERE ((nodes.lease_expires IS NULL) OR (nodes.lease_expires <= ?))
+	AND ((? = 0) OR (nodes.network_id = ?) OR (nodes.network_id IS NULL))
+	AND (frontier.node_id >= ?) AND (frontier.node_id < ?)
+ORDER BY frontier.priority DESC
+LIMIT ?
+`
+
+	sqlFindCandidates = `
+SELECT id FROM nodes
+WHERE ((crawl_retry_time IS NULL) OR (crawl_retry_time <= ?))
+	AND ((lease_expires IS NULL) OR (lease_expires <= ?))
+	AND ((? = 0) OR (network_id = ?) OR (network_id IS NULL))
+	AND (id >= ?) AND (id < ?)
+ORDER BY crawl_retry_time
+LIMIT ?
+`
+
+	sqlAcquireCandidateLeases = `
+UPDATE nodes SET lease_owner = ?, lease_expires = ? WHERE id IN (123)
+`
+
+	sqlRenewCandidateLeases = `
+UPDATE nodes SET lease_expires = ? WHERE (lease_owner = ?) AND (id IN (123))
+`
+
+	sqlReleaseCandidateLeases = `
+UPDATE nodes SET lease_owner = NULL, lease_expires = NULL WHERE (lease_owner = ?) AND (id IN (123))
+`
+
+	sqlEnumerateNodeIDsAfter = `
+SELECT id FROM nodes WHERE id > ? ORDER BY id LIMIT ?
+`
+
+	sqlUpsertNodeClientInfo = `
+INSERT INTO node_client_info(node_id, client_name, client_version, client_os, client_arch, updated)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT(node_id) DO UPDATE SET
+	client_name = excluded.client_name,
+	client_version = excluded.client_version,
+	client_os = excluded.client_os,
+	client_arch = excluded.client_arch,
+	updated = excluded.updated
+`
+
+	sqlInsertNodeSighting = `
+INSERT INTO node_sightings(node_id, sighted, kind)
+VALUES (?, ?, ?)
+`
+
+	// the sighted timestamps are grouped into hour buckets,
+	// so an uptime percentage is the fraction of the window hours with a sighting
+	sqlFindNodeUptime = `
+SELECT MIN(sighted), MAX(sighted),
+	COUNT(DISTINCT CASE WHEN sighted > ? THEN sighted / 3600 END),
+	COUNT(DISTINCT CASE WHEN sighted > ? THEN sighted / 3600 END)
+FROM node_sightings
+WHERE node_id = ?
+`
+
+	sqlFindTopAvailableNodes = `
+SELECT node_id, MIN(sighted), MAX(sighted),
+	COUNT(DISTINCT CASE WHEN sighted > ? THEN sighted / 3600 END) AS day_hours,
+	COUNT(DISTINCT CASE WHEN sighted > ? THEN sighted / 3600 END) AS week_hours
+FROM node_sightings
+GROUP BY node_id
+ORDER BY week_hours DESC, day_hours DESC, node_id
+LIMIT ?
+`
+
+	// sqlFindBootnodeCandidates ranks the addressable nodes of a chain
+	// by their sightings within the reliability window, the most sighted first.
+	// The %s placeholder optionally restricts the rows to the canonical
+	// fork ID hashes of the chain (see FindBootnodeCandidates).
+	sqlFindBootnodeCandidates = `
+SELECT id FROM nodes
+WHERE (ip IS NOT NULL)
+	AND (port_rlpx IS NOT NULL)
+	AND (ping_try = 0)
+	AND ((? = 0) OR (network_id = ?))
+	%s
+ORDER BY (
+	SELECT COUNT(*) FROM node_sightings
+	WHERE (node_sightings.node_id = nodes.id) AND (node_sightings.sighted > ?)
+) DESC, addr_updated DESC
+LIMIT ?
+`
+
+	// sqlFindSnapPeerCandidates lists the reachable nodes which proved
+	// snap/1 serving ability: they announced the snap/1 capability
+	// and a chain head, and handshaked within the freshness window.
+	// Ranked like the bootnode candidates, the most sighted first.
+	sqlFindSnapPeerCandidates = `
+SELECT id FROM nodes
+WHERE (ip IS NOT NULL)
+	AND (port_rlpx IS NOT NULL)
+	AND (ping_try = 0)
+	AND (supports_snap = 1)
+	AND (head_hash IS NOT NULL)
+	AND ((? = 0) OR (network_id = ?))
+	AND (EXISTS (
+		SELECT 1 FROM node_sightings
+		WHERE (node_sightings.node_id = nodes.id)
+			AND (node_sightings.kind = 'handshake')
+			AND (node_sightings.sighted > ?)
+	))
+ORDER BY (
+	SELECT COUNT(*) FROM node_sightings
+	WHERE (node_sightings.node_id = nodes.id) AND (node_sightings.sighted > ?)
+) DESC, addr_updated DESC
+LIMIT ?
+`
+
+	sqlFindNodeFacts = `
+SELECT ip, network_id, eth_version, asn, country, city, client_id, fork_id_hash, ip_reputation
+FROM nodes WHERE id = ?
+`
+
+	sqlUpsertNodeLabel = `
+INSERT INTO node_labels(node_id, label, score, updated)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(node_id, label) DO UPDATE SET
+	score = excluded.score,
+	updated = excluded.updated
+`
+
+	sqlDeleteNodeLabels = `
+DELETE FROM node_labels WHERE node_id = ?
+`
+
+	sqlFindNodeLabels = `
+SELECT label, score FROM node_labels WHERE node_id = ?
+`
+
+	sqlUpsertIPReputation = `
+INSERT INTO ip_reputation(ip, provider, score, checked)
+VALUES (?, ?, ?, ?)
+ON CONFLICT(ip, provider) DO UPDATE SET
+	score = excluded.score,
+	checked = excluded.checked
+`
+
+	sqlFindIPReputation = `
+SELECT score, checked FROM ip_reputation WHERE (ip = ?) AND (provider = ?)
+`
+
+	sqlUpdateNodeIPReputation = `
+UPDATE nodes SET ip_reputation = ? WHERE id = ?
+`
+
+	sqlFindBlocklistIPs = `
+SELECT DISTINCT COALESCE(ip, ip_v6) AS blocked_ip FROM nodes
+WHERE (ip_reputation >= ?) AND (COALESCE(ip, ip_v6) IS NOT NULL)
+ORDER BY blocked_ip
+`
+
+	sqlFindEnrichProgress = `
+SELECT last_node_id FROM enrich_progress WHERE stage = ?
+`
+
+	sqlUpdateEnrichProgress = `
+INSERT INTO enrich_progress(stage, last_node_id, updated)
+VALUES (?, ?, ?)
+ON CONFLICT(stage) DO UPDATE SET
+	last_node_id = excluded.last_node_id,
+	updated = excluded.updated
+`
+
+	sqlDeleteEnrichProgress = `
+DELETE FROM enrich_progress WHERE stage = ?
+`
+
+	sqlMarkEnrichmentFresh = `
+INSERT INTO enrichment_freshness(node_id, attribute, refreshed)
+VALUES (?, ?, ?)
+ON CONFLICT(node_id, attribute) DO UPDATE SET
+	refreshed = excluded.refreshed
+`
+
+	sqlFindEnrichmentRefreshed = `
+SELECT refreshed FROM enrichment_freshness WHERE (node_id = ?) AND (attribute = ?)
+`
+
+	sqlSchemaTables = `
+SELECT name FROM sqlite_master
+WHERE (type = 'table') AND (name NOT LIKE 'sqlite_%')
+ORDER BY name
+`
+
+	sqlSchemaTableIndexes = `
+SELECT name FROM sqlite_master
+WHERE (type = 'index') AND (tbl_name = ?) AND (name NOT LIKE 'sqlite_%')
+ORDER BY name
+`
+
+	sqlCountNodes = `
+SELECT COUNT(*) FROM nodes
+WHERE (ping_try < ?)
+	AND ((network_id = ?) OR (network_id IS NULL))
+`
+
+	sqlCountIPs = `
+SELECT COUNT(DISTINCT ip) FROM nodes
+WHERE (ping_try < ?)
+	AND ((network_id = ?) OR (network_id IS NULL))
+`
+
+	sqlCountClients = `
+SELECT COUNT(*) FROM nodes
+WHERE (client_id LIKE ?)
+	AND (ping_try < ?)
+	AND (network_id = ?)
+	AND (client_id_quality >= ?)
+`
+
+	sqlEnumerateClientIDs = `
+SELECT id, client_id FROM nodes
+WHERE (ping_try < ?)
+	AND (network_id = ?)
+	AND (client_id_quality >= ?)
+`
+
+	sqlEnumerateClientIDsAsOf = `
+SELECT client_id FROM node_client_history history
+WHERE (observed <= ?)
+	AND (observed > ?)
+	AND (observed = (
+		SELECT MAX(observed) FROM node_client_history
+		WHERE (node_id = history.node_id) AND (observed <= ?)))
+`
+
+	// sqlCreateChangeTrackingTriggers bumps nodes.change_seq on every write,
+	// so that exports can cheaply find the rows changed since a checkpoint.
+	// SQLite serializes the writes, so MAX(change_seq) + 1 is race-free here.
+	sqlCreateChangeTrackingTriggers = `
+CREATE TRIGGER IF NOT EXISTS trg_nodes_change_seq_insert AFTER INSERT ON nodes
+BEGIN
+    UPDATE nodes SET change_seq = (SELECT COALESCE(MAX(change_seq), 0) + 1 FROM nodes) WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS trg_nodes_change_seq_update AFTER UPDATE ON nodes
+WHEN NEW.change_seq = OLD.change_seq
+BEGIN
+    UPDATE nodes SET change_seq = (SELECT COALESCE(MAX(change_seq), 0) + 1 FROM nodes) WHERE id = NEW.id;
+END;
+`
+
+	sqlEnumerateNodeExports = `
+SELECT id, ip, port_rlpx, client_id, network_id, eth_version, asn, addr_updated, change_seq
+FROM nodes
+WHERE (change_seq > ?)
+	AND (operator_opt_out = 0)
+	AND ((? = '') OR (client_id LIKE ? || '%'))
+	AND ((? = 0) OR (network_id = ?))
+	AND ((? = 0)
+		OR (addr_updated >= ?)
+		OR EXISTS (SELECT 1 FROM handshake_attempts
+			WHERE (node_id = nodes.id) AND (success = 1) AND (attempt_time >= ?)))
+ORDER BY change_seq
+LIMIT (CASE WHEN ? = 0 THEN NULL ELSE ? END)
+`
+
+	sqlFindExportCheckpoint = `
+SELECT last_change_seq FROM export_state WHERE name = ?
+`
+
+	sqlUpsertExportCheckpoint = `
+INSERT INTO export_state(name, last_change_seq, updated) VALUES (?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET
+	last_change_seq = excluded.last_change_seq,
+	updated = excluded.updated
+`
+
+	sqlRefreshSeenWindow = `
+INSERT INTO node_seen_windows(window_hours, node_count, ip_count, refreshed)
+SELECT ?, COUNT(*), COUNT(DISTINCT ip), ?
+FROM nodes
+WHERE (addr_updated >= ?)
+	OR EXISTS (SELECT 1 FROM handshake_attempts
+		WHERE (node_id = nodes.id) AND (success = 1) AND (attempt_time >= ?))
+ON CONFLICT(window_hours) DO UPDATE SET
+	node_count = excluded.node_count,
+	ip_count = excluded.ip_count,
+	refreshed = excluded.refreshed
+`
+
+	sqlFindSeenWindows = `
+SELECT window_hours, node_count, ip_count, refreshed
+FROM node_seen_windows
+ORDER BY window_hours
+`
+
+	sqlCountAllNodes = `
+SELECT COUNT(*) FROM nodes
+WHERE (? = 0) OR (network_id = ?)
+`
+
+	sqlCountClientGroups = `
+SELECT client_id, COUNT(*) FROM nodes
+WHERE (client_id IS NOT NULL)
+	AND ((? = 0) OR (network_id = ?))
+GROUP BY client_id
+`
+
+	sqlCountNetworkIDGroups = `
+SELECT network_id, COUNT(*) FROM nodes
+WHERE network_id IS NOT NULL
+GROUP BY network_id
+`
+
+	sqlCountHandshakeErrorGroups = `
+SELECT err, COUNT(*) FROM handshake_errors
+WHERE num IN (SELECT MAX(num) FROM handshake_errors GROUP BY node_id)
+	AND ((? = 0) OR EXISTS (SELECT 1 FROM nodes
+		WHERE (id = handshake_errors.node_id) AND (network_id = ?)))
+GROUP BY err
+`
+
+	sqlEnumerateHandshakeErrorClientIDs = `
+SELECT nodes.client_id, handshake_errors.err FROM handshake_errors
+JOIN nodes ON (nodes.id = handshake_errors.node_id)
+WHERE (handshake_errors.num IN (SELECT MAX(num) FROM handshake_errors GROUP BY node_id))
+	AND ((? = 0) OR (nodes.network_id = ?))
+`
+
+	sqlEnumerateNodeLifetimes = `
+SELECT
+	client_id,
+	asn,
+	first_seen,
+	addr_updated,
+	(SELECT MAX(attempt_time) FROM handshake_attempts
+		WHERE (node_id = nodes.id) AND (success = 1)),
+	(SELECT MAX(day) FROM handshake_attempt_days
+		WHERE (node_id = nodes.id) AND (success_count > 0))
+FROM nodes
+WHERE first_seen IS NOT NULL
+`
+)
+
+func NewDBSQLite(filePath string) (*DBSQLite, error) {
+	db, err := sql.Open("sqlite", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DB: %w", err)
+	}
+
+	if err := migrateSchema(db, nil, nil); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(sqlCreateChangeTrackingTriggers); err != nil {
+		return nil, fmt.Errorf("failed to create the change tracking triggers: %w", err)
+	}
+
+	instance := DBSQLite{db: db, clock: utils.SystemClock{}}
+	return &instance, nil
+}
+
+func (db *DBSQLite) Close() error {
+	for _, readDB := range db.readDBs {
+		_ = readDB.Close()
+	}
+	return db.db.Close()
+}
+
+func (db *DBSQLite) UpsertNodeAddr(ctx context.Context, id NodeID, addr NodeAddr) error {
+	// a node row is created here, so a corrupt ID is caught before it is stored
+	if err := id.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a node address: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	updated := utils.ToTimestamp(db.clock.Now())
+
+	// the discovery event sees the missing row in the same transaction
+	// as the upsert that creates it, so a first sighting is recorded exactly once
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertDiscoveredEvent), id, nodeAddrEventValue(addr), updated, id); err != nil {
+		return fmt.Errorf("failed to insert a discovery event: %w", err)
+	}
+
+	args := append(append([]interface{}{id}, nodeAddrArgs(addr)...), updated, updated)
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlUpsertNodeAddr), args...); err != nil {
+		return fmt.Errorf("failed to upsert a node address: %w", err)
+	}
+
+	if err := db.upsertNodeHistory(ctx, tx, id, addr, updated); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to upsert a node address: %w", err)
+	}
+	return nil
+}
+
+// upsertNodeHistory records which node ID was seen behind each advertised
+// endpoint, so that the same IP:port reappearing with a different ID
+// (a key rotation or an operator change) stays visible afterwards.
+func (db *DBSQLite) upsertNodeHistory(ctx context.Context, tx *sql.Tx, id NodeID, addr NodeAddr, updated int64) error {
+	if addr.IP != nil {
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlUpsertNodeHistory),
+			addr.IP.String(), addr.PortRLPx, id, updated, updated); err != nil {
+			return fmt.Errorf("failed to upsert node history: %w", err)
+		}
+	}
+	if addr.IPv6.IP != nil {
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlUpsertNodeHistory),
+			addr.IPv6.IP.String(), addr.IPv6.PortRLPx, id, updated, updated); err != nil {
+			return fmt.Errorf("failed to upsert node history: %w", err)
+		}
+	}
+	return nil
+}
+
+// nodeAddrEventValue is the address stored with a discovery event:
+// the IPv4 address when known, the IPv6 one otherwise.
+func nodeAddrEventValue(addr NodeAddr) *string {
+	if addr.IP != nil {
+		value := addr.IP.String()
+		return &value
+	}
+	if addr.IPv6.IP != nil {
+		value := addr.IPv6.IP.String()
+		return &value
+	}
+	return nil
+}
+
+// nodeAddrArgs prepares the nullable address columns of sqlUpsertNodeAddr:
+// ip, port_disc, port_rlpx, ip_v6, ip_v6_port_disc, ip_v6_port_rlpx.
+func nodeAddrArgs(addr NodeAddr) []interface{} {
+	var ip *string
+	if addr.IP != nil {
+		value := addr.IP.String()
+		ip = &value
+	}
+
+	var ipV6 *string
+	if addr.IPv6.IP != nil {
+		value := addr.IPv6.IP.String()
+		ipV6 = &value
+	}
+
+	var portDisc *int
+	if (ip != nil) && (addr.PortDisc != 0) {
+		value := int(addr.PortDisc)
+		portDisc = &value
+	}
+
+	var ipV6PortDisc *int
+	if (ipV6 != nil) && (addr.IPv6.PortDisc != 0) {
+		value := int(addr.IPv6.PortDisc)
+		ipV6PortDisc = &value
+	}
+
+	var portRLPx *int
+	if (ip != nil) && (addr.PortRLPx != 0) {
+		value := int(addr.PortRLPx)
+		portRLPx = &value
+	}
+
+	var ipV6PortRLPx *int
+	if (ipV6 != nil) && (addr.IPv6.PortRLPx != 0) {
+		value := int(addr.IPv6.PortRLPx)
+		ipV6PortRLPx = &value
+	}
+
+	return []interface{}{ip, portDisc, portRLPx, ipV6, ipV6PortDisc, ipV6PortRLPx}
+}
+
+// FlushNodeWrites applies a coalesced batch of hot-path writes in one transaction,
+// so a write-behind cache pays for a single commit instead of one per call
+// (see DBWriteCache). The addresses go first: the ping error and client ID
+// updates may target rows the same batch creates.
+func (db *DBSQLite) FlushNodeWrites(ctx context.Context, batch NodeWriteBatch) error {
+	if batch.Size() == 0 {
+		return nil
+	}
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to flush node writes: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	updated := utils.ToTimestamp(db.clock.Now())
+	for id, addr := range batch.Addrs {
+		if err := id.Validate(); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertDiscoveredEvent), id, nodeAddrEventValue(addr), updated, id); err != nil {
+			return fmt.Errorf("failed to insert a discovery event: %w", err)
+		}
+		args := append(append([]interface{}{id}, nodeAddrArgs(addr)...), updated, updated)
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlUpsertNodeAddr), args...); err != nil {
+			return fmt.Errorf("failed to upsert a node address: %w", err)
+		}
+		if err := db.upsertNodeHistory(ctx, tx, id, addr, updated); err != nil {
+			return err
+		}
+	}
+	for id, count := range batch.PingErrors {
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlAddPingErrors), count, id); err != nil {
+			return fmt.Errorf("failed to update ping error: %w", err)
+		}
+	}
+	for id, observation := range batch.ClientIDs {
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertClientChangeEvent), observation.ClientID, updated, id, observation.ClientID); err != nil {
+			return fmt.Errorf("failed to insert a client change event: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlUpdateClientID), observation.ClientID, observation.Quality, id); err != nil {
+			return fmt.Errorf("failed to update client ID: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertClientHistory), id, updated, observation.ClientID); err != nil {
+			return fmt.Errorf("failed to insert client history: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to flush node writes: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindNodeAddr(ctx context.Context, id NodeID) (*NodeAddr, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeAddr), id)
+
+	var ip sql.NullString
+	var portDisc sql.NullInt32
+	var portRLPx sql.NullInt32
+	var ipV6 sql.NullString
+	var ipV6PortDisc sql.NullInt32
+	var ipV6PortRLPx sql.NullInt32
+
+	err := row.Scan(
+		&ip, &portDisc, &portRLPx,
+		&ipV6, &ipV6PortDisc, &ipV6PortRLPx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find a node address: %w", err)
+	}
+
+	var addr NodeAddr
+
+	if ip.Valid {
+		value := net.ParseIP(ip.String)
+		if value != nil {
+			addr.IP = value
+		} else if !db.quarantineBadValue(ctx, id, "ip", ip.String, "unparsable IP") {
+			return nil, errors.New("failed to parse node IP")
+		}
+	}
+	if ipV6.Valid {
+		value := net.ParseIP(ipV6.String)
+		if value != nil {
+			addr.IPv6.IP = value
+		} else if !db.quarantineBadValue(ctx, id, "ip_v6", ipV6.String, "unparsable IP") {
+			return nil, errors.New("failed to parse node IPv6")
+		}
+	}
+	if portDisc.Valid {
+		addr.PortDisc = uint16(portDisc.Int32)
+	}
+	if ipV6PortDisc.Valid {
+		addr.IPv6.PortDisc = uint16(ipV6PortDisc.Int32)
+	}
+	if portRLPx.Valid {
+		addr.PortRLPx = uint16(portRLPx.Int32)
+	}
+	if ipV6PortRLPx.Valid {
+		addr.IPv6.PortRLPx = uint16(ipV6PortRLPx.Int32)
+	}
+
+	return &addr, nil
+}
+
+func (db *DBSQLite) ResetPingError(ctx context.Context, id NodeID) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlResetPingError), id)
+	if err != nil {
+		return fmt.Errorf("failed to reset ping error: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdatePingError(ctx context.Context, id NodeID) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdatePingError), id)
+	if err != nil {
+		return fmt.Errorf("failed to update ping error: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountPingErrors(ctx context.Context, id NodeID) (*uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountPingErrors), id)
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to count ping errors: %w", err)
+	}
+	return &count, nil
+}
+
+func (db *DBSQLite) UpdateClientID(ctx context.Context, id NodeID, clientID string, quality ObservationQuality) (bool, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to update client ID: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	observed := utils.ToTimestamp(db.clock.Now())
+
+	// the event insert sees the previous value in the same transaction as the update,
+	// so the old/new pair cannot be broken by a concurrent writer
+	result, err := tx.ExecContext(ctx, db.stmt(sqlInsertClientChangeEvent), clientID, observed, id, clientID)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert a client change event: %w", err)
+	}
+	changedRows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to insert a client change event: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlUpdateClientID), clientID, quality, id); err != nil {
+		return false, fmt.Errorf("failed to update client ID: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertClientHistory), id, observed, clientID); err != nil {
+		return false, fmt.Errorf("failed to insert client history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to update client ID: %w", err)
+	}
+	return changedRows > 0, nil
+}
+
+func (db *DBSQLite) FindNodeEvents(ctx context.Context, id NodeID, limit uint) ([]NodeEvent, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindNodeEvents), id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the node events: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var events []NodeEvent
+	for cursor.Next() {
+		var event NodeEvent
+		var oldValue sql.NullString
+		var newValue sql.NullString
+		var happenedTimestamp int64
+		if err := cursor.Scan(&event.Kind, &oldValue, &newValue, &happenedTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to read a node event: %w", err)
+		}
+		if oldValue.Valid {
+			event.OldValue = &oldValue.String
+		}
+		if newValue.Valid {
+			event.NewValue = &newValue.String
+		}
+		event.Time = utils.FromTimestamp(happenedTimestamp)
+		events = append(events, event)
+	}
+	return events, cursor.Err()
+}
+
+func (db *DBSQLite) FindLastNodeEventNum(ctx context.Context) (uint64, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindLastNodeEventNum))
+	var num uint64
+	if err := row.Scan(&num); err != nil {
+		return 0, fmt.Errorf("failed to find the last node event: %w", err)
+	}
+	return num, nil
+}
+
+func (db *DBSQLite) EnumerateNodeEventsAfter(
+	ctx context.Context,
+	afterNum uint64,
+	limit uint,
+	enumFunc func(entry NodeEventFeedEntry),
+) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodeEventsAfter), afterNum, limit)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate the node events: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var entry NodeEventFeedEntry
+		var oldValue sql.NullString
+		var newValue sql.NullString
+		var happenedTimestamp int64
+		err := cursor.Scan(&entry.Num, &entry.NodeID, &entry.Event.Kind,
+			&oldValue, &newValue, &happenedTimestamp)
+		if err != nil {
+			return fmt.Errorf("failed to read a node event: %w", err)
+		}
+		if oldValue.Valid {
+			entry.Event.OldValue = &oldValue.String
+		}
+		if newValue.Valid {
+			entry.Event.NewValue = &newValue.String
+		}
+		entry.Event.Time = utils.FromTimestamp(happenedTimestamp)
+		enumFunc(entry)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) FindClientID(ctx context.Context, id NodeID) (*string, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindClientID), id)
+	var clientID sql.NullString
+	if err := row.Scan(&clientID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find the client ID: %w", err)
+	}
+	if !clientID.Valid {
+		return nil, nil
+	}
+	return &clientID.String, nil
+}
+
+func (db *DBSQLite) UpdateNetworkID(ctx context.Context, id NodeID, networkID uint, quality ObservationQuality) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateNetworkID), networkID, quality, id)
+	if err != nil {
+		return fmt.Errorf("failed to update network ID: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateEthVersion(ctx context.Context, id NodeID, ethVersion uint, quality ObservationQuality) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateEthVersion), ethVersion, quality, id)
+	if err != nil {
+		return fmt.Errorf("failed to update eth version: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateForkID(ctx context.Context, id NodeID, forkIDHash string, forkIDNext uint64, quality ObservationQuality) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateForkID), forkIDHash, forkIDNext, quality, id)
+	if err != nil {
+		return fmt.Errorf("failed to update fork ID: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateForkCompatibility(ctx context.Context, id NodeID, compatible bool, verdict string) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateForkCompatibility), compatible, verdict, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the fork compatibility: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateChainHead(ctx context.Context, id NodeID, headHash string, totalDifficulty string) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateChainHead), headHash, totalDifficulty, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the chain head: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateSupportsSnap(ctx context.Context, id NodeID, supportsSnap bool) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateSupportsSnap), supportsSnap, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the snap support flag: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdatePortRLPxVerified(ctx context.Context, id NodeID, port uint16) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdatePortRLPxVerified), port, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the verified RLPx port: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateReachability(ctx context.Context, id NodeID, reachability string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update the reachability: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	updated := utils.ToTimestamp(db.clock.Now())
+
+	// a transition into the unreachable class is recorded as an offline event;
+	// the event insert sees the previous class in the same transaction as the update
+	if reachability == ReachabilityUnreachable {
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertWentOfflineEvent), reachability, updated, id, reachability); err != nil {
+			return fmt.Errorf("failed to insert an offline event: %w", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlUpdateReachability), reachability, updated, id); err != nil {
+		return fmt.Errorf("failed to update the reachability: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update the reachability: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountReachability(ctx context.Context, networkID uint) ([]ReachabilityCount, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlCountReachability), networkID, networkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the reachability classes: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var counts []ReachabilityCount
+	for cursor.Next() {
+		var count ReachabilityCount
+		if err := cursor.Scan(&count.Reachability, &count.Count); err != nil {
+			return nil, fmt.Errorf("failed to read reachability count data: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	return counts, cursor.Err()
+}
+
+func (db *DBSQLite) UpdateTxAnnounceRate(ctx context.Context, id NodeID, rate float64) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateTxAnnounceRate), rate, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the tx announce rate: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) InsertBlockAnnouncements(ctx context.Context, id NodeID, announcements []BlockAnnouncement) error {
+	for _, announcement := range announcements {
+		announced := announcement.Time.UnixMilli()
+		_, err := db.db.ExecContext(ctx, db.stmt(sqlInsertBlockAnnouncement),
+			id, announcement.Hash, announcement.Number, announced)
+		if err != nil {
+			return fmt.Errorf("failed to insert a block announcement: %w", err)
+		}
+	}
+	return nil
+}
+
+func (db *DBSQLite) EnumerateBlockPropagationStats(ctx context.Context, minBlocks uint) ([]BlockPropagationStats, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlBlockPropagationStats), minBlocks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate block propagation stats: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var stats []BlockPropagationStats
+	for cursor.Next() {
+		var entry BlockPropagationStats
+		var clientID sql.NullString
+		var avgDelayMilliseconds float64
+		if err := cursor.Scan(&entry.NodeID, &clientID, &entry.BlockCount, &avgDelayMilliseconds); err != nil {
+			return nil, fmt.Errorf("failed to read block propagation stats: %w", err)
+		}
+		if clientID.Valid {
+			entry.ClientID = &clientID.String
+		}
+		entry.AvgDelay = time.Duration(avgDelayMilliseconds * float64(time.Millisecond))
+		stats = append(stats, entry)
+	}
+	return stats, cursor.Err()
+}
+
+func (db *DBSQLite) CountSnapSupport(ctx context.Context, networkID uint) (uint, uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountSnapSupport), networkID, networkID)
+	var snapNodes, knownNodes uint
+	if err := row.Scan(&snapNodes, &knownNodes); err != nil {
+		return 0, 0, fmt.Errorf("failed to count snap support: %w", err)
+	}
+	return snapNodes, knownNodes, nil
+}
+
+// operatorClusterKey clusters a node for EstimateOperatorCount:
+// nodes within one subnet (IPv4 /24 or IPv6 /48) running one client name
+// are assumed to belong to the same operator.
+func operatorClusterKey(ip string, clientID string) string {
+	clientName := strings.ToLower(clientID)
+	if slash := strings.Index(clientName, "/"); slash >= 0 {
+		clientName = clientName[:slash]
+	}
+
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return ip + "|" + clientName
+	}
+	var mask net.IPMask
+	if parsedIP.To4() != nil {
+		mask = net.CIDRMask(24, 32)
+	} else {
+		mask = net.CIDRMask(48, 128)
+	}
+	return parsedIP.Mask(mask).String() + "|" + clientName
+}
+
+func (db *DBSQLite) EstimateOperatorCount(ctx context.Context, networkID uint) (uint, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateOperatorTraits), networkID, networkID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enumerate operator traits: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	clusters := make(map[string]struct{})
+	var unclustered uint
+	for cursor.Next() {
+		var ip sql.NullString
+		var clientID sql.NullString
+		if err := cursor.Scan(&ip, &clientID); err != nil {
+			return 0, fmt.Errorf("failed to read operator trait data: %w", err)
+		}
+		// a node without a known IP can't be clustered and counts individually
+		if !ip.Valid {
+			unclustered++
+			continue
+		}
+		clusters[operatorClusterKey(ip.String, clientID.String)] = struct{}{}
+	}
+	return uint(len(clusters)) + unclustered, cursor.Err()
+}
+
+// keepHandshakeErrorsPerNode bounds the handshake error history of a node
+// (see InsertHandshakeError).
+const keepHandshakeErrorsPerNode = 10
+
+func (db *DBSQLite) InsertHandshakeError(ctx context.Context, id NodeID, handshakeErr string) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to insert a handshake error: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	updated := utils.ToTimestamp(db.clock.Now())
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertHandshakeError), id, handshakeErr, updated); err != nil {
+		return fmt.Errorf("failed to insert a handshake error: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlTrimHandshakeErrors), id, id, keepHandshakeErrorsPerNode); err != nil {
+		return fmt.Errorf("failed to trim the handshake errors: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlMarkHandshakeError), id); err != nil {
+		return fmt.Errorf("failed to count the handshake error: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert a handshake error: %w", err)
+	}
+	return nil
+}
+
+// keepLatencySamplesPerNode bounds the latency sample history of a node per kind
+// (see InsertNodeLatency). The percentiles only need the recent samples,
+// and the table is written on every successful probe.
+const keepLatencySamplesPerNode = 20
+
+func (db *DBSQLite) InsertNodeLatency(ctx context.Context, id NodeID, kind string, rtt time.Duration) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to insert a latency sample: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	measured := utils.ToTimestamp(db.clock.Now())
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertNodeLatency), id, kind, rtt.Microseconds(), measured); err != nil {
+		return fmt.Errorf("failed to insert a latency sample: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlTrimNodeLatency), id, kind, id, kind, keepLatencySamplesPerNode); err != nil {
+		return fmt.Errorf("failed to trim the latency samples: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert a latency sample: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) DeleteHandshakeErrors(ctx context.Context, id NodeID) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete the handshake errors: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlDeleteHandshakeErrors), id); err != nil {
+		return fmt.Errorf("failed to delete the handshake errors: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlResetHandshakeTries), id); err != nil {
+		return fmt.Errorf("failed to reset the handshake tries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete the handshake errors: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindHandshakeLastErrors(ctx context.Context, id NodeID, limit uint) ([]HandshakeError, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindHandshakeLastErrors), id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the handshake errors: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var handshakeErrors []HandshakeError
+	for cursor.Next() {
+		var stringCode string
+		var updatedTimestamp int64
+		if err := cursor.Scan(&stringCode, &updatedTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to read a handshake error: %w", err)
+		}
+		handshakeErrors = append(handshakeErrors, HandshakeError{
+			StringCode: stringCode,
+			Time:       utils.FromTimestamp(updatedTimestamp),
+		})
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate the handshake errors: %w", err)
+	}
+	return handshakeErrors, nil
+}
+
+func (db *DBSQLite) UpdateHandshakeRetryTime(ctx context.Context, id NodeID, retryTime time.Time) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateHandshakeRetryTime), utils.ToTimestamp(retryTime), id)
+	if err != nil {
+		return fmt.Errorf("failed to update handshake retry time: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindHandshakeRetryTime(ctx context.Context, id NodeID) (*time.Time, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindHandshakeRetryTime), id)
+
+	var retryTime sql.NullInt64
+	if err := row.Scan(&retryTime); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find handshake retry time: %w", err)
+	}
+	if !retryTime.Valid {
+		return nil, nil
+	}
+
+	value := utils.FromTimestamp(retryTime.Int64)
+	return &value, nil
+}
+
+func (db *DBSQLite) CountHandshakeRetries(ctx context.Context, id NodeID) (*uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountHandshakeRetries), id)
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to count handshake retries: %w", err)
+	}
+	return &count, nil
+}
+
+func (db *DBSQLite) InsertHandshakeAttempt(ctx context.Context, id NodeID, attempt HandshakeAttempt) error {
+	var sourceIP *string
+	if attempt.SourceIP != nil {
+		value := attempt.SourceIP.String()
+		sourceIP = &value
+	}
+
+	var attemptErr *string
+	if attempt.Err != "" {
+		attemptErr = &attempt.Err
+	}
+
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlInsertHandshakeAttempt),
+		id, utils.ToTimestamp(attempt.Time), sourceIP, attempt.Success, attemptErr, attempt.Duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to insert a handshake attempt: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) IncrementHandshakeHourStats(ctx context.Context, id NodeID, success bool, moment time.Time) error {
+	utcMoment := moment.UTC()
+	successCount := 0
+	failureCount := 0
+	if success {
+		successCount = 1
+	} else {
+		failureCount = 1
+	}
+
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlIncrementHandshakeHourStats),
+		int(utcMoment.Weekday()), utcMoment.Hour(), id, successCount, failureCount, utils.ToTimestamp(db.clock.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to increment the handshake hour stats: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) EnumerateHandshakeHourStats(ctx context.Context) ([]HandshakeHourStats, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateHandshakeHourStats))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate the handshake hour stats: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var stats []HandshakeHourStats
+	for cursor.Next() {
+		var entry HandshakeHourStats
+		if err := cursor.Scan(&entry.DayOfWeek, &entry.Hour, &entry.Country,
+			&entry.SuccessCount, &entry.FailureCount); err != nil {
+			return nil, fmt.Errorf("failed to read handshake hour stats data: %w", err)
+		}
+		stats = append(stats, entry)
+	}
+	return stats, cursor.Err()
+}
+
+func (db *DBSQLite) EnableStrictRead() {
+	db.strictRead = true
+}
+
+// quarantineBadValue handles a value that failed the strict read validation.
+// It returns true if strict mode is on and the value was quarantined,
+// telling the caller to skip the value; otherwise the caller proceeds as before.
+// A quarantine failure is swallowed: the quarantine must not fail the read
+// it was meant to protect.
+func (db *DBSQLite) quarantineBadValue(ctx context.Context, id NodeID, field string, value string, reason string) bool {
+	if !db.strictRead {
+		return false
+	}
+	detected := utils.ToTimestamp(db.clock.Now())
+	_, _ = db.db.ExecContext(ctx, db.stmt(sqlQuarantineBadRow), id, field, value, reason, detected)
+	return true
+}
+
+func (db *DBSQLite) FindBadRows(ctx context.Context, limit uint) ([]BadRow, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindBadRows), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find bad rows: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var rows []BadRow
+	for cursor.Next() {
+		var row BadRow
+		var detectedTimestamp int64
+		if err := cursor.Scan(&row.NodeID, &row.Field, &row.Value, &row.Reason, &detectedTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to read bad row data: %w", err)
+		}
+		row.Detected = utils.FromTimestamp(detectedTimestamp)
+		rows = append(rows, row)
+	}
+	return rows, cursor.Err()
+}
+
+func (db *DBSQLite) InsertProtocolViolation(ctx context.Context, id NodeID, violation ProtocolViolation) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlInsertProtocolViolation),
+		id, utils.ToTimestamp(violation.Time), violation.Code, nullableStr(violation.Detail))
+	if err != nil {
+		return fmt.Errorf("failed to insert a protocol violation: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountProtocolViolations(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountProtocolViolations, networkID, networkID)
+}
+
+func (db *DBSQLite) UpsertNodeCapabilities(ctx context.Context, id NodeID, caps []NodeCapability) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to upsert node capabilities: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := utils.ToTimestamp(db.clock.Now())
+	for _, capability := range caps {
+		_, err := tx.ExecContext(ctx, db.stmt(sqlUpsertNodeCapability),
+			id, capability.Name, capability.Version, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to upsert a node capability: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to upsert node capabilities: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountNodesByCapability(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountNodesByCapability, networkID, networkID)
+}
+
+func (db *DBSQLite) UpsertFederatedNode(ctx context.Context, id NodeID, source string) error {
+	now := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertFederatedNode), id, source, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a federated node: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountFederatedNodes(ctx context.Context) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountFederatedNodes)
+}
+
+func (db *DBSQLite) InsertStatsSnapshot(ctx context.Context, snapshot StatsSnapshot) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to insert a stats snapshot: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	snapshotTime := utils.ToTimestamp(snapshot.Time)
+	_, err = tx.ExecContext(ctx, db.stmt(sqlInsertStatsSnapshot),
+		snapshotTime, snapshot.TotalNodes, snapshot.CompatibleNodes, snapshot.DistinctIPs,
+		snapshot.OperatorEstimate)
+	if err != nil {
+		return fmt.Errorf("failed to insert a stats snapshot: %w", err)
+	}
+
+	for clientName, nodeCount := range snapshot.ClientCounts {
+		_, err := tx.ExecContext(ctx, db.stmt(sqlInsertStatsSnapshotClient),
+			snapshotTime, clientName, nodeCount)
+		if err != nil {
+			return fmt.Errorf("failed to insert a stats snapshot client count: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to insert a stats snapshot: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindStatsSnapshots(ctx context.Context, since time.Time) ([]StatsSnapshot, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindStatsSnapshots), utils.ToTimestamp(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stats snapshots: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var snapshots []StatsSnapshot
+	for cursor.Next() {
+		var snapshotTime int64
+		var snapshot StatsSnapshot
+		if err := cursor.Scan(&snapshotTime,
+			&snapshot.TotalNodes, &snapshot.CompatibleNodes, &snapshot.DistinctIPs,
+			&snapshot.OperatorEstimate); err != nil {
+			return nil, fmt.Errorf("failed to read stats snapshot data: %w", err)
+		}
+		snapshot.Time = utils.FromTimestamp(snapshotTime)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, cursor.Err()
+}
+
+func (db *DBSQLite) FindStatsSnapshotClients(ctx context.Context, snapshotTime time.Time) (map[string]uint, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindStatsSnapshotClients), utils.ToTimestamp(snapshotTime))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find stats snapshot clients: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	counts := make(map[string]uint)
+	for cursor.Next() {
+		var clientName string
+		var nodeCount uint
+		if err := cursor.Scan(&clientName, &nodeCount); err != nil {
+			return nil, fmt.Errorf("failed to read stats snapshot client data: %w", err)
+		}
+		counts[clientName] = nodeCount
+	}
+	return counts, cursor.Err()
+}
+
+func (db *DBSQLite) DeleteStatsSnapshotsBefore(ctx context.Context, before time.Time) (uint, error) {
+	if _, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteStatsSnapshotClients), utils.ToTimestamp(before)); err != nil {
+		return 0, fmt.Errorf("failed to delete old stats snapshot clients: %w", err)
+	}
+	result, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteStatsSnapshots), utils.ToTimestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old stats snapshots: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count the deleted stats snapshots: %w", err)
+	}
+	return uint(deleted), nil
+}
+
+func (db *DBSQLite) UpsertMonitoredNode(ctx context.Context, node MonitoredNode) error {
+	added := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertMonitoredNode),
+		node.ID, nullableStr(node.Label), node.SLOTarget, node.WindowDays, added)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a monitored node: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) DeleteMonitoredNode(ctx context.Context, id NodeID) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteMonitoredNode), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete a monitored node: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) EnumerateMonitoredNodes(ctx context.Context) ([]MonitoredNode, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateMonitoredNodes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate monitored nodes: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var nodes []MonitoredNode
+	for cursor.Next() {
+		var node MonitoredNode
+		var label sql.NullString
+		if err := cursor.Scan(&node.ID, &label, &node.SLOTarget, &node.WindowDays); err != nil {
+			return nil, fmt.Errorf("failed to read monitored node data: %w", err)
+		}
+		node.Label = label.String
+		nodes = append(nodes, node)
+	}
+	return nodes, cursor.Err()
+}
+
+func (db *DBSQLite) UpsertProbePolicy(ctx context.Context, policy ProbePolicy) error {
+	added := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertProbePolicy),
+		policy.Name, nullableStr(policy.Tag), nullableStr(policy.Subnet), nullableStr(policy.Country),
+		int64(policy.MinInterval.Seconds()), policy.QuietStartHour, policy.QuietEndHour,
+		int64(policy.UTCOffset.Minutes()), added)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a probe policy: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) DeleteProbePolicy(ctx context.Context, name string) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteProbePolicy), name)
+	if err != nil {
+		return fmt.Errorf("failed to delete a probe policy: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) EnumerateProbePolicies(ctx context.Context) ([]ProbePolicy, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateProbePolicies))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate probe policies: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var policies []ProbePolicy
+	for cursor.Next() {
+		var policy ProbePolicy
+		var tag, subnet, country sql.NullString
+		var minIntervalSeconds, utcOffsetMinutes int64
+		err := cursor.Scan(&policy.Name, &tag, &subnet, &country,
+			&minIntervalSeconds, &policy.QuietStartHour, &policy.QuietEndHour, &utcOffsetMinutes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read probe policy data: %w", err)
+		}
+		policy.Tag = tag.String
+		policy.Subnet = subnet.String
+		policy.Country = country.String
+		policy.MinInterval = time.Duration(minIntervalSeconds) * time.Second
+		policy.UTCOffset = time.Duration(utcOffsetMinutes) * time.Minute
+		policies = append(policies, policy)
+	}
+	return policies, cursor.Err()
+}
+
+func (db *DBSQLite) FindProbeTraits(ctx context.Context, id NodeID) (*ProbeTraits, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindProbeTraits), id)
+
+	var ip, country, tag sql.NullString
+	var lastAttempt sql.NullInt64
+	if err := row.Scan(&ip, &country, &tag, &lastAttempt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find the probe traits: %w", err)
+	}
+
+	var traits ProbeTraits
+	if ip.Valid {
+		traits.IP = &ip.String
+	}
+	if country.Valid {
+		traits.Country = &country.String
+	}
+	if tag.Valid {
+		traits.Tag = &tag.String
+	}
+	if lastAttempt.Valid {
+		value := utils.FromTimestamp(lastAttempt.Int64)
+		traits.LastAttempt = &value
+	}
+	return &traits, nil
+}
+
+func (db *DBSQLite) CountHandshakeOutcomesSince(ctx context.Context, id NodeID, since time.Time) (uint, uint, error) {
+	sinceTimestamp := utils.ToTimestamp(since)
+
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountHandshakeOutcomesSince), id, sinceTimestamp)
+	var attempts, successes uint
+	if err := row.Scan(&attempts, &successes); err != nil {
+		return 0, 0, fmt.Errorf("failed to count handshake outcomes: %w", err)
+	}
+
+	row = db.db.QueryRowContext(ctx, db.stmt(sqlCountCompactedHandshakeOutcomesSince), id, sinceTimestamp)
+	var compactedAttempts, compactedSuccesses uint
+	if err := row.Scan(&compactedAttempts, &compactedSuccesses); err != nil {
+		return 0, 0, fmt.Errorf("failed to count compacted handshake outcomes: %w", err)
+	}
+
+	return attempts + compactedAttempts, successes + compactedSuccesses, nil
+}
+
+func (db *DBSQLite) UpsertSLOStatus(ctx context.Context, status SLOStatus) error {
+	updated := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertSLOStatus),
+		status.ID, utils.ToTimestamp(status.WindowStart), status.AttemptCount, status.SuccessCount,
+		status.Availability, status.BudgetBurn, updated)
+	if err != nil {
+		return fmt.Errorf("failed to upsert SLO status: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindSLOStatus(ctx context.Context, id NodeID) (*SLOStatus, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindSLOStatus), id)
+
+	status := SLOStatus{ID: id}
+	var windowStart int64
+	err := row.Scan(&windowStart, &status.AttemptCount, &status.SuccessCount,
+		&status.Availability, &status.BudgetBurn)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find SLO status: %w", err)
+	}
+	status.WindowStart = utils.FromTimestamp(windowStart)
+	return &status, nil
+}
+
+func (db *DBSQLite) CompactHandshakeAttempts(ctx context.Context, before time.Time) (uint, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compact handshake attempts: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	beforeTimestamp := utils.ToTimestamp(before)
+
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlCompactHandshakeAttempts), beforeTimestamp); err != nil {
+		return 0, fmt.Errorf("failed to aggregate handshake attempts: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, db.stmt(sqlDeleteCompactedHandshakeAttempts), beforeTimestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete compacted handshake attempts: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count compacted handshake attempts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to compact handshake attempts: %w", err)
+	}
+	return uint(deleted), nil
+}
+
+func (db *DBSQLite) EnumerateHandshakeAttemptsBefore(
+	ctx context.Context,
+	before time.Time,
+	enumFunc func(id NodeID, attempt HandshakeAttempt),
+) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateHandshakeAttemptsBefore), utils.ToTimestamp(before))
+	if err != nil {
+		return fmt.Errorf("failed to enumerate handshake attempts: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		id, attempt, err := scanHandshakeAttempt(cursor, true)
+		if err != nil {
+			return err
+		}
+		enumFunc(id, attempt)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) DeleteHandshakeAttemptsBefore(ctx context.Context, before time.Time) (uint, error) {
+	result, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteHandshakeAttemptsBefore), utils.ToTimestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete handshake attempts: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted handshake attempts: %w", err)
+	}
+	return uint(deleted), nil
+}
+
+func (db *DBSQLite) FindHandshakeAttempts(ctx context.Context, id NodeID) ([]HandshakeAttempt, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindHandshakeAttempts), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find handshake attempts: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var attempts []HandshakeAttempt
+	for cursor.Next() {
+		_, attempt, err := scanHandshakeAttempt(cursor, false)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, cursor.Err()
+}
+
+// scanHandshakeAttempt reads a handshake attempt row
+// (with a leading node_id column when withID is set).
+func scanHandshakeAttempt(cursor *sql.Rows, withID bool) (NodeID, HandshakeAttempt, error) {
+	var id NodeID
+	var attemptTime int64
+	var sourceIP sql.NullString
+	var success bool
+	var attemptErr sql.NullString
+	var durationMs sql.NullInt64
+
+	columns := []interface{}{&attemptTime, &sourceIP, &success, &attemptErr, &durationMs}
+	if withID {
+		columns = append([]interface{}{&id}, columns...)
+	}
+	if err := cursor.Scan(columns...); err != nil {
+		return "", HandshakeAttempt{}, fmt.Errorf("failed to read handshake attempt data: %w", err)
+	}
+
+	attempt := HandshakeAttempt{
+		Time:    utils.FromTimestamp(attemptTime),
+		Success: success,
+	}
+	if sourceIP.Valid {
+		attempt.SourceIP = net.ParseIP(sourceIP.String)
+	}
+	if attemptErr.Valid {
+		attempt.Err = attemptErr.String
+	}
+	if durationMs.Valid {
+		attempt.Duration = time.Duration(durationMs.Int64) * time.Millisecond
+	}
+	return id, attempt, nil
+}
+
+func (db *DBSQLite) EnumerateClientHistoryBefore(
+	ctx context.Context,
+	before time.Time,
+	enumFunc func(id NodeID, observed time.Time, clientID string),
+) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateClientHistoryBefore), utils.ToTimestamp(before))
+	if err != nil {
+		return fmt.Errorf("failed to enumerate client history: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var id NodeID
+		var observed int64
+		var clientID string
+		if err := cursor.Scan(&id, &observed, &clientID); err != nil {
+			return fmt.Errorf("failed to read client history data: %w", err)
+		}
+		enumFunc(id, utils.FromTimestamp(observed), clientID)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) DeleteClientHistoryBefore(ctx context.Context, before time.Time) (uint, error) {
+	result, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteClientHistoryBefore), utils.ToTimestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete client history: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted client history: %w", err)
+	}
+	return uint(deleted), nil
+}
+
+func (db *DBSQLite) EnumerateVantagePointStats(ctx context.Context) ([]VantagePointStats, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateVantagePointStats))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate vantage point stats: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var stats []VantagePointStats
+	for cursor.Next() {
+		var entry VantagePointStats
+		var avgDurationMs sql.NullFloat64
+		if err := cursor.Scan(&entry.SourceIP, &entry.AttemptCount, &entry.SuccessCount,
+			&entry.DistinctNodeCount, &avgDurationMs); err != nil {
+			return nil, fmt.Errorf("failed to read vantage point stats data: %w", err)
+		}
+		if avgDurationMs.Valid {
+			entry.AvgDuration = time.Duration(avgDurationMs.Float64 * float64(time.Millisecond))
+		}
+		stats = append(stats, entry)
+	}
+	return stats, cursor.Err()
+}
+
+func (db *DBSQLite) CountVantageDivergentNodes(ctx context.Context) (uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountVantageDivergentNodes))
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count vantage divergent nodes: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DBSQLite) UpdateEnrEntries(ctx context.Context, id NodeID, entries []EnrEntry) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to update ENR entries: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlDeleteEnrEntries), id); err != nil {
+		return fmt.Errorf("failed to delete old ENR entries: %w", err)
+	}
+
+	updated := utils.ToTimestamp(db.clock.Now())
+	for _, entry := range entries {
+		value, err := EncodeColumn(DefaultCodec(entry.Value), entry.Value)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlInsertEnrEntry), id, entry.Key, value, updated); err != nil {
+			return fmt.Errorf("failed to insert an ENR entry: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update ENR entries: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindEnrEntries(ctx context.Context, id NodeID) ([]EnrEntry, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindEnrEntries), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ENR entries: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var entries []EnrEntry
+	for cursor.Next() {
+		var entry EnrEntry
+		var value []byte
+		if err := cursor.Scan(&entry.Key, &value); err != nil {
+			return nil, fmt.Errorf("failed to read ENR entry data: %w", err)
+		}
+		entry.Value, err = DecodeColumn(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, cursor.Err()
+}
+
+func (db *DBSQLite) UpsertNodeENR(ctx context.Context, id NodeID, nodeENR NodeENR) error {
+	record, err := EncodeColumn(DefaultCodec(nodeENR.Record), nodeENR.Record)
+	if err != nil {
+		return err
+	}
+	updated := utils.ToTimestamp(db.clock.Now())
+	_, err = db.db.ExecContext(ctx, db.stmt(sqlUpsertNodeENR), id, nodeENR.Seq, record, updated)
+	if err != nil {
+		return fmt.Errorf("failed to upsert node ENR: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindNodeENR(ctx context.Context, id NodeID) (*NodeENR, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeENR), id)
+	var nodeENR NodeENR
+	var record []byte
+	if err := row.Scan(&nodeENR.Seq, &record); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find node ENR: %w", err)
+	}
+	var err error
+	nodeENR.Record, err = DecodeColumn(record)
+	if err != nil {
+		return nil, err
+	}
+	return &nodeENR, nil
+}
+
+func (db *DBSQLite) EnumerateNodesByEnrKey(ctx context.Context, key string, valueHex string, limit uint) ([]NodeID, error) {
+	valueHex = strings.ToUpper(valueHex)
+
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodesByEnrKey), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate nodes by ENR key: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var ids []NodeID
+	for (uint(len(ids)) < limit) && cursor.Next() {
+		var id string
+		var value []byte
+		if err := cursor.Scan(&id, &value); err != nil {
+			return nil, fmt.Errorf("failed to read node ID data: %w", err)
+		}
+		// the value filter can't be applied in SQL, since the stored values are encoded (see EncodeColumn)
+		if valueHex != "" {
+			decoded, err := DecodeColumn(value)
+			if err != nil {
+				return nil, err
+			}
+			if strings.ToUpper(hex.EncodeToString(decoded)) != valueHex {
+				continue
+			}
+		}
+		ids = append(ids, NodeID(id))
+	}
+	return ids, cursor.Err()
+}
+
+func (db *DBSQLite) FindNodeFreshness(ctx context.Context, id NodeID) (*NodeFreshness, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeFreshness), id)
+
+	var addrUpdated int64
+	var clientVerified sql.NullInt64
+	var enrUpdated sql.NullInt64
+	var clientInfoUpdated sql.NullInt64
+	err := row.Scan(&addrUpdated, &clientVerified, &enrUpdated, &clientInfoUpdated)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find node freshness: %w", err)
+	}
+
+	// a non-positive or far-future timestamp marks a corrupted row,
+	// e.g. written with a broken wall clock
+	if (addrUpdated <= 0) || (utils.FromTimestamp(addrUpdated).After(db.clock.Now().Add(24 * time.Hour))) {
+		if db.quarantineBadValue(ctx, id, "addr_updated",
+			strconv.FormatInt(addrUpdated, 10), "inconsistent timestamp") {
+			return nil, nil
+		}
+	}
+
+	freshness := NodeFreshness{AddrUpdated: utils.FromTimestamp(addrUpdated)}
+	if clientVerified.Valid {
+		value := utils.FromTimestamp(clientVerified.Int64)
+		freshness.ClientVerified = &value
+	}
+	if enrUpdated.Valid {
+		value := utils.FromTimestamp(enrUpdated.Int64)
+		freshness.EnrUpdated = &value
+	}
+	if clientInfoUpdated.Valid {
+		value := utils.FromTimestamp(clientInfoUpdated.Int64)
+		freshness.ClientInfoUpdated = &value
+	}
+	return &freshness, nil
+}
+
+func (db *DBSQLite) UpdateNodeASN(ctx context.Context, id NodeID, asn uint) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateNodeASN), asn, id)
+	if err != nil {
+		return fmt.Errorf("failed to update node ASN: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindNodeASN(ctx context.Context, id NodeID) (*uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeASN), id)
+	var asn sql.NullInt64
+	if err := row.Scan(&asn); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find node ASN: %w", err)
+	}
+	if !asn.Valid {
+		return nil, nil
+	}
+	value := uint(asn.Int64)
+	return &value, nil
+}
+
+func (db *DBSQLite) UpdateNodeGeo(ctx context.Context, id NodeID, geo NodeGeo) error {
+	// the unresolved fields keep their stored values (see the COALESCE in the statement)
+	var country, city sql.NullString
+	if geo.Country != "" {
+		country = sql.NullString{String: geo.Country, Valid: true}
+	}
+	if geo.City != "" {
+		city = sql.NullString{String: geo.City, Valid: true}
+	}
+	var asn sql.NullInt64
+	if geo.ASN != 0 {
+		asn = sql.NullInt64{Int64: int64(geo.ASN), Valid: true}
+	}
+
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateNodeGeo), country, city, asn, id)
+	if err != nil {
+		return fmt.Errorf("failed to update node geo location: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountNodesByCountry(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountNodesByCountry, networkID, networkID)
+}
+
+func (db *DBSQLite) CountNodesByClientID(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountNodesByClientID, networkID, networkID)
+}
+
+func (db *DBSQLite) CountNodesByASN(ctx context.Context, networkID uint) (map[uint]uint, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlCountNodesByASN), networkID, networkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count nodes by ASN: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	counts := make(map[uint]uint)
+	for cursor.Next() {
+		var asn uint
+		var count uint
+		if err := cursor.Scan(&asn, &count); err != nil {
+			return nil, fmt.Errorf("failed to read ASN count data: %w", err)
+		}
+		counts[asn] = count
+	}
+	return counts, cursor.Err()
+}
+
+func (db *DBSQLite) EnumerateNodeIPs(ctx context.Context, networkID uint, enumFunc func(ip *string, ipv6 *string, asn *uint)) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodeIPs), networkID, networkID)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate node IPs: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var ip sql.NullString
+		var ipv6 sql.NullString
+		var asn sql.NullInt64
+		if err := cursor.Scan(&ip, &ipv6, &asn); err != nil {
+			return fmt.Errorf("failed to read node IP data: %w", err)
+		}
+
+		var ipValue *string
+		if ip.Valid {
+			ipValue = &ip.String
+		}
+		var ipv6Value *string
+		if ipv6.Valid {
+			ipv6Value = &ipv6.String
+		}
+		var asnValue *uint
+		if asn.Valid {
+			value := uint(asn.Int64)
+			asnValue = &value
+		}
+		enumFunc(ipValue, ipv6Value, asnValue)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) CountForkCompatibility(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountForkCompatibility, networkID, networkID)
+}
+
+func (db *DBSQLite) UpdateNodeDiscVersion(ctx context.Context, id NodeID, discVersion uint) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateNodeDiscVersion), discVersion, discVersion, id)
+	if err != nil {
+		return fmt.Errorf("failed to update node disc version: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindProbedBuckets(ctx context.Context, id NodeID) ([]uint, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindProbedBuckets), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find probed buckets: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var buckets []uint
+	for cursor.Next() {
+		var bucket uint
+		if err := cursor.Scan(&bucket); err != nil {
+			return nil, fmt.Errorf("failed to read probed bucket data: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, cursor.Err()
+}
+
+func (db *DBSQLite) MarkProbedBuckets(ctx context.Context, id NodeID, buckets []uint) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to mark probed buckets: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	updated := utils.ToTimestamp(db.clock.Now())
+	for _, bucket := range buckets {
+		if _, err := tx.ExecContext(ctx, db.stmt(sqlMarkProbedBucket), id, bucket, updated); err != nil {
+			return fmt.Errorf("failed to mark a probed bucket: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to mark probed buckets: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpsertFrontierEntry(ctx context.Context, entry FrontierEntry) error {
+	added := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertFrontierEntry),
+		entry.ID, entry.Priority, nullableStr(entry.Source), entry.Depth, added, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a frontier entry: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) DeleteFrontierEntry(ctx context.Context, id NodeID) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteFrontierEntry), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete a frontier entry: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountFrontier(ctx context.Context) (uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountFrontier))
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count the frontier: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DBSQLite) UpdateNodeDepth(ctx context.Context, id NodeID, depth uint) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateNodeDepth), depth, id, depth)
+	if err != nil {
+		return fmt.Errorf("failed to update node depth: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindNodeDepth(ctx context.Context, id NodeID) (*uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeDepth), id)
+	var depth sql.NullInt64
+	if err := row.Scan(&depth); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find node depth: %w", err)
+	}
+	if !depth.Valid {
+		return nil, nil
+	}
+	value := uint(depth.Int64)
+	return &value, nil
+}
+
+func (db *DBSQLite) UpdateCrawlRetryTime(ctx context.Context, id NodeID, retryTime time.Time) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateCrawlRetryTime), utils.ToTimestamp(retryTime), id)
+	if err != nil {
+		return fmt.Errorf("failed to update crawl retry time: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) AcquireCandidates(
+	ctx context.Context,
+	owner string,
+	leaseDuration time.Duration,
+	networkID uint,
+	limit uint,
+	idLower NodeID,
+	idUpper NodeID,
+) ([]NodeID, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire candidates: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// expired leases are reclaimed by treating their nodes as free candidates
+	now := db.clock.Now()
+
+	// the frontier nodes with the highest priority go first
+	nodes, err := queryCandidates(ctx, tx, db.stmt(sqlFindFrontierCandidates),
+		utils.ToTimestamp(now), networkID, networkID, idLower, idUpper, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint(len(nodes)) < limit {
+		seen := make(map[NodeID]struct{}, len(nodes))
+		for _, id := range nodes {
+			seen[id] = struct{}{}
+		}
+
+		dueNodes, err := queryCandidates(ctx, tx, db.stmt(sqlFindCandidates),
+			utils.ToTimestamp(now), utils.ToTimestamp(now), networkID, networkID, idLower, idUpper, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range dueNodes {
+			if uint(len(nodes)) >= limit {
+				break
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			nodes = append(nodes, id)
+		}
+	}
+
+	if len(nodes) > 0 {
+		query, values := bindNodeIDs(sqlAcquireCandidateLeases, nodes, owner, utils.ToTimestamp(now.Add(leaseDuration)))
+		if _, err := tx.ExecContext(ctx, db.stmt(query), values...); err != nil {
+			return nil, fmt.Errorf("failed to lease candidates: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to acquire candidates: %w", err)
+	}
+	return nodes, nil
+}
+
+// RenewCandidateLeases extends the leases that the owner still holds.
+func (db *DBSQLite) RenewCandidateLeases(ctx context.Context, owner string, leaseDuration time.Duration, ids []NodeID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, values := bindNodeIDs(sqlRenewCandidateLeases, ids, utils.ToTimestamp(db.clock.Now().Add(leaseDuration)), owner)
+	if _, err := db.db.ExecContext(ctx, db.stmt(query), values...); err != nil {
+		return fmt.Errorf("failed to renew candidate leases: %w", err)
+	}
+	return nil
+}
+
+// ReleaseCandidateLeases clears the leases that the owner still holds.
+func (db *DBSQLite) ReleaseCandidateLeases(ctx context.Context, owner string, ids []NodeID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, values := bindNodeIDs(sqlReleaseCandidateLeases, ids, owner)
+	if _, err := db.db.ExecContext(ctx, db.stmt(query), values...); err != nil {
+		return fmt.Errorf("failed to release candidate leases: %w", err)
+	}
+	return nil
+}
+
+func queryCandidates(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]NodeID, error) {
+	cursor, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find candidates: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var nodes []NodeID
+	for cursor.Next() {
+		var id string
+		if err := cursor.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read candidate data: %w", err)
+		}
+		nodes = append(nodes, NodeID(id))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate candidates: %w", err)
+	}
+	return nodes, nil
+}
+
+// bindNodeIDs expands the "IN (123)" placeholder of a lease statement
+// to one "?" per node ID, and orders the prefix arguments before the IDs.
+func bindNodeIDs(query string, ids []NodeID, prefixArgs ...interface{}) (string, []interface{}) {
+	values := make([]interface{}, 0, len(ids)+len(prefixArgs))
+	values = append(values, prefixArgs...)
+	placeholders := ""
+	for i, id := range ids {
+		if i > 0 {
+			placeholders += ","
+		}
+		placeholders += "?"
+		values = append(values, id)
+	}
+	return strings.Replace(query, "123", placeholders, 1), values
+}
+
+func (db *DBSQLite) IsConflictError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(err.Error(), "SQLITE_BUSY")
+}
+
+func (db *DBSQLite) EnumerateNodeIDsAfter(ctx context.Context, afterID NodeID, limit uint) ([]NodeID, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodeIDsAfter), afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate node IDs: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var ids []NodeID
+	for cursor.Next() {
+		var id string
+		if err := cursor.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read node ID data: %w", err)
+		}
+		ids = append(ids, NodeID(id))
+	}
+	return ids, cursor.Err()
+}
+
+func (db *DBSQLite) UpsertNodeClientInfo(ctx context.Context, id NodeID, info ClientInfo) error {
+	updated := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertNodeClientInfo),
+		id, nullableStr(info.Name), nullableStr(info.Version), nullableStr(info.OS), nullableStr(info.Arch), updated)
+	if err != nil {
+		return fmt.Errorf("failed to upsert node client info: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) InsertNodeSighting(ctx context.Context, id NodeID, kind string) error {
+	sighted := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlInsertNodeSighting), id, sighted, kind)
+	if err != nil {
+		return fmt.Errorf("failed to insert a node sighting: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindNodeUptime(ctx context.Context, id NodeID) (*NodeUptime, error) {
+	now := db.clock.Now()
+	dayStart := utils.ToTimestamp(now.Add(-24 * time.Hour))
+	weekStart := utils.ToTimestamp(now.Add(-7 * 24 * time.Hour))
+
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeUptime), dayStart, weekStart, id)
+
+	var firstSeen, lastSeen sql.NullInt64
+	var dayHours, weekHours uint
+	if err := row.Scan(&firstSeen, &lastSeen, &dayHours, &weekHours); err != nil {
+		return nil, fmt.Errorf("failed to find node uptime: %w", err)
+	}
+	if !firstSeen.Valid {
+		return nil, nil
+	}
+
+	uptime := makeNodeUptime(id, firstSeen.Int64, lastSeen.Int64, dayHours, weekHours)
+	return &uptime, nil
+}
+
+func (db *DBSQLite) FindTopAvailableNodes(ctx context.Context, limit uint) ([]NodeUptime, error) {
+	now := db.clock.Now()
+	dayStart := utils.ToTimestamp(now.Add(-24 * time.Hour))
+	weekStart := utils.ToTimestamp(now.Add(-7 * 24 * time.Hour))
+
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindTopAvailableNodes), dayStart, weekStart, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find top available nodes: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var uptimes []NodeUptime
+	for cursor.Next() {
+		var id string
+		var firstSeen, lastSeen int64
+		var dayHours, weekHours uint
+		if err := cursor.Scan(&id, &firstSeen, &lastSeen, &dayHours, &weekHours); err != nil {
+			return nil, fmt.Errorf("failed to read node uptime data: %w", err)
+		}
+		uptimes = append(uptimes, makeNodeUptime(NodeID(id), firstSeen, lastSeen, dayHours, weekHours))
+	}
+	return uptimes, cursor.Err()
+}
+
+// bootnodeReliabilityWindow is how far back the sightings count
+// for the bootnode candidate ranking.
+const bootnodeReliabilityWindow = 7 * 24 * time.Hour
+
+func (db *DBSQLite) FindBootnodeCandidates(ctx context.Context, networkID uint, forkIDHashes []string, limit uint) ([]NodeID, error) {
+	// the fork filter is an IN list of variable length, so it is built here;
+	// the values themselves are still bound as parameters
+	forkFilter := ""
+	args := []interface{}{networkID, networkID}
+	if len(forkIDHashes) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(forkIDHashes)), ", ")
+		forkFilter = fmt.Sprintf("AND (fork_id_hash IN (%s))", placeholders)
+		for _, hash := range forkIDHashes {
+			args = append(args, hash)
+		}
+	}
+	sightedSince := utils.ToTimestamp(db.clock.Now().Add(-bootnodeReliabilityWindow))
+	args = append(args, sightedSince, limit)
+
+	// the filter is spliced in before the dialect rewrite,
+	// so that its placeholders are rewritten along with the fixed ones
+	query := db.stmt(fmt.Sprintf(sqlFindBootnodeCandidates, forkFilter))
+	cursor, err := db.reader().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find bootnode candidates: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var ids []NodeID
+	for cursor.Next() {
+		var id string
+		if err := cursor.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read a bootnode candidate: %w", err)
+		}
+		ids = append(ids, NodeID(id))
+	}
+	return ids, cursor.Err()
+}
+
+func (db *DBSQLite) FindSnapPeerCandidates(ctx context.Context, networkID uint, handshakedAfter time.Time, limit uint) ([]NodeID, error) {
+	handshakedSince := utils.ToTimestamp(handshakedAfter)
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindSnapPeerCandidates),
+		networkID, networkID, handshakedSince, handshakedSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find snap peer candidates: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var ids []NodeID
+	for cursor.Next() {
+		var id string
+		if err := cursor.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to read a snap peer candidate: %w", err)
+		}
+		ids = append(ids, NodeID(id))
+	}
+	return ids, cursor.Err()
+}
+
+func makeNodeUptime(id NodeID, firstSeen int64, lastSeen int64, dayHours uint, weekHours uint) NodeUptime {
+	return NodeUptime{
+		ID:         id,
+		FirstSeen:  utils.FromTimestamp(firstSeen),
+		LastSeen:   utils.FromTimestamp(lastSeen),
+		UptimeDay:  uptimeFraction(dayHours, 24),
+		UptimeWeek: uptimeFraction(weekHours, 7*24),
+	}
+}
+
+// uptimeFraction caps the sighted hour count at the window size:
+// a sighting in the partial current hour could otherwise push the fraction past 1.0.
+func uptimeFraction(sightedHours uint, windowHours uint) float64 {
+	if sightedHours > windowHours {
+		sightedHours = windowHours
+	}
+	return float64(sightedHours) / float64(windowHours)
+}
+
+func (db *DBSQLite) FindNodeFacts(ctx context.Context, id NodeID) (*NodeFacts, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindNodeFacts), id)
+
+	var ip, country, city, clientID, forkIDHash sql.NullString
+	var networkID, ethVersion, asn sql.NullInt64
+	var ipReputation sql.NullFloat64
+	if err := row.Scan(&ip, &networkID, &ethVersion, &asn, &country, &city, &clientID, &forkIDHash, &ipReputation); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find node facts: %w", err)
+	}
+
+	var facts NodeFacts
+	if ip.Valid {
+		facts.IP = &ip.String
+	}
+	if networkID.Valid {
+		value := uint(networkID.Int64)
+		facts.NetworkID = &value
+	}
+	if ethVersion.Valid {
+		value := uint(ethVersion.Int64)
+		facts.EthVersion = &value
+	}
+	if asn.Valid {
+		value := uint(asn.Int64)
+		facts.ASN = &value
+	}
+	if country.Valid {
+		facts.Country = &country.String
+	}
+	if city.Valid {
+		facts.City = &city.String
+	}
+	if clientID.Valid {
+		facts.ClientID = &clientID.String
+	}
+	if forkIDHash.Valid {
+		facts.ForkIDHash = &forkIDHash.String
+	}
+	if ipReputation.Valid {
+		facts.IPReputation = &ipReputation.Float64
+	}
+	return &facts, nil
+}
+
+func (db *DBSQLite) UpsertNodeLabel(ctx context.Context, id NodeID, label string, score float64) error {
+	updated := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertNodeLabel), id, label, score, updated)
+	if err != nil {
+		return fmt.Errorf("failed to upsert a node label: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) DeleteNodeLabels(ctx context.Context, id NodeID) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteNodeLabels), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete node labels: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindNodeLabels(ctx context.Context, id NodeID) (map[string]float64, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindNodeLabels), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find node labels: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	labels := make(map[string]float64)
+	for cursor.Next() {
+		var label string
+		var score float64
+		if err := cursor.Scan(&label, &score); err != nil {
+			return nil, fmt.Errorf("failed to read node label data: %w", err)
+		}
+		labels[label] = score
+	}
+	return labels, cursor.Err()
+}
+
+func (db *DBSQLite) UpsertIPReputation(ctx context.Context, ip string, provider string, score float64) error {
+	checked := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertIPReputation), ip, provider, score, checked)
+	if err != nil {
+		return fmt.Errorf("failed to upsert IP reputation: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindIPReputation(ctx context.Context, ip string, provider string) (*IPReputationScore, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindIPReputation), ip, provider)
+	var reputation IPReputationScore
+	var checkedTimestamp int64
+	if err := row.Scan(&reputation.Score, &checkedTimestamp); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find IP reputation: %w", err)
+	}
+	reputation.Checked = utils.FromTimestamp(checkedTimestamp)
+	return &reputation, nil
+}
+
+func (db *DBSQLite) UpdateNodeIPReputation(ctx context.Context, id NodeID, score float64) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateNodeIPReputation), score, id)
+	if err != nil {
+		return fmt.Errorf("failed to update node IP reputation: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindBlocklistIPs(ctx context.Context, threshold float64) ([]string, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindBlocklistIPs), threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find blocklist IPs: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var ips []string
+	for cursor.Next() {
+		var ip string
+		if err := cursor.Scan(&ip); err != nil {
+			return nil, fmt.Errorf("failed to read blocklist IP data: %w", err)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, cursor.Err()
+}
+
+func (db *DBSQLite) FindEnrichProgress(ctx context.Context, stage string) (*NodeID, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindEnrichProgress), stage)
+	var lastID string
+	if err := row.Scan(&lastID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find enrich progress: %w", err)
+	}
+	id := NodeID(lastID)
+	return &id, nil
+}
+
+func (db *DBSQLite) UpdateEnrichProgress(ctx context.Context, stage string, lastID NodeID) error {
+	updated := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateEnrichProgress), stage, lastID, updated)
+	if err != nil {
+		return fmt.Errorf("failed to update enrich progress: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) DeleteEnrichProgress(ctx context.Context, stage string) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteEnrichProgress), stage)
+	if err != nil {
+		return fmt.Errorf("failed to delete enrich progress: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) MarkEnrichmentFresh(ctx context.Context, id NodeID, attribute EnrichmentAttribute) error {
+	refreshed := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlMarkEnrichmentFresh), id, string(attribute), refreshed)
+	if err != nil {
+		return fmt.Errorf("failed to mark the %s enrichment fresh: %w", attribute, err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) IsEnrichmentFresh(ctx context.Context, id NodeID, attribute EnrichmentAttribute) (bool, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindEnrichmentRefreshed), id, string(attribute))
+	var refreshedTimestamp int64
+	if err := row.Scan(&refreshedTimestamp); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find the %s enrichment freshness: %w", attribute, err)
+	}
+	refreshed := utils.FromTimestamp(refreshedTimestamp)
+	return db.clock.Now().Before(refreshed.Add(EnrichmentTTL(attribute))), nil
+}
+
+func nullableStr(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (db *DBSQLite) DescribeSchema(ctx context.Context) ([]SchemaTable, error) {
+	names, err := db.schemaTableNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []SchemaTable
+	for _, name := range names {
+		table := SchemaTable{Name: name}
+
+		if table.Columns, err = db.schemaTableColumns(ctx, name); err != nil {
+			return nil, err
+		}
+		if table.Indexes, err = db.schemaTableIndexes(ctx, name); err != nil {
+			return nil, err
+		}
+
+		// the table name comes from sqlite_master, and is safe to quote
+		row := db.db.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, name))
+		if err := row.Scan(&table.RowCount); err != nil {
+			return nil, fmt.Errorf("failed to count table rows: %w", err)
+		}
+
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func (db *DBSQLite) schemaTableNames(ctx context.Context) ([]string, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlSchemaTables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate schema tables: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var names []string
+	for cursor.Next() {
+		var name string
+		if err := cursor.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read schema table data: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, cursor.Err()
+}
+
+func (db *DBSQLite) schemaTableColumns(ctx context.Context, tableName string) ([]SchemaColumn, error) {
+	cursor, err := db.reader().QueryContext(ctx, fmt.Sprintf(`PRAGMA table_info("%s")`, tableName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table columns: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var columns []SchemaColumn
+	for cursor.Next() {
+		var cid int
+		var column SchemaColumn
+		var notNull int
+		var defaultValue sql.NullString
+		var pk int
+		if err := cursor.Scan(&cid, &column.Name, &column.Type, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to read table column data: %w", err)
+		}
+		column.NotNull = notNull != 0
+		column.IsPrimaryKey = pk != 0
+		columns = append(columns, column)
+	}
+	return columns, cursor.Err()
+}
+
+func (db *DBSQLite) schemaTableIndexes(ctx context.Context, tableName string) ([]string, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlSchemaTableIndexes), tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate table indexes: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var indexes []string
+	for cursor.Next() {
+		var name string
+		if err := cursor.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read table index data: %w", err)
+		}
+		indexes = append(indexes, name)
+	}
+	return indexes, cursor.Err()
+}
+
+func (db *DBSQLite) CountNodes(ctx context.Context, maxPingTries uint, networkID uint) (uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountNodes), maxPingTries, networkID)
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count nodes: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DBSQLite) CountIPs(ctx context.Context, maxPingTries uint, networkID uint) (uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountIPs), maxPingTries, networkID)
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count IPs: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DBSQLite) CountClients(ctx context.Context, clientIDPrefix string, maxPingTries uint, networkID uint, minQuality ObservationQuality) (uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountClients), clientIDPrefix+"%", maxPingTries, networkID, minQuality)
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count clients: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DBSQLite) RefreshSeenWindowStats(ctx context.Context, window time.Duration) error {
+	windowHours := uint(window / time.Hour)
+	now := db.clock.Now()
+	cutoff := utils.ToTimestamp(now.Add(-window))
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlRefreshSeenWindow), windowHours, utils.ToTimestamp(now), cutoff, cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to refresh the seen window stats: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindSeenWindowStats(ctx context.Context) ([]SeenWindowStats, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindSeenWindows))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the seen window stats: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var statsList []SeenWindowStats
+	for cursor.Next() {
+		var stats SeenWindowStats
+		var windowHours uint
+		var refreshedTimestamp int64
+		if err := cursor.Scan(&windowHours, &stats.NodeCount, &stats.IPCount, &refreshedTimestamp); err != nil {
+			return nil, fmt.Errorf("failed to read seen window stats data: %w", err)
+		}
+		stats.Window = time.Duration(windowHours) * time.Hour
+		stats.Refreshed = utils.FromTimestamp(refreshedTimestamp)
+		statsList = append(statsList, stats)
+	}
+	return statsList, cursor.Err()
+}
+
+func (db *DBSQLite) CountAllNodes(ctx context.Context, networkID uint) (uint, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlCountAllNodes), networkID, networkID)
+	var count uint
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count all nodes: %w", err)
+	}
+	return count, nil
+}
+
+func (db *DBSQLite) CountClientGroups(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountClientGroups, networkID, networkID)
+}
+
+func (db *DBSQLite) CountHandshakeErrorGroups(ctx context.Context, networkID uint) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountHandshakeErrorGroups, networkID, networkID)
+}
+
+func (db *DBSQLite) CountNetworkIDGroups(ctx context.Context) (map[uint]uint, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlCountNetworkIDGroups))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count network ID groups: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	counts := make(map[uint]uint)
+	for cursor.Next() {
+		var networkID uint
+		var count uint
+		if err := cursor.Scan(&networkID, &count); err != nil {
+			return nil, fmt.Errorf("failed to read network ID group data: %w", err)
+		}
+		counts[networkID] = count
+	}
+	return counts, cursor.Err()
+}
+
+func (db *DBSQLite) countGroups(ctx context.Context, query string, args ...interface{}) (map[string]uint, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count groups: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	counts := make(map[string]uint)
+	for cursor.Next() {
+		var group string
+		var count uint
+		if err := cursor.Scan(&group, &count); err != nil {
+			return nil, fmt.Errorf("failed to read group count data: %w", err)
+		}
+		counts[group] = count
+	}
+	return counts, cursor.Err()
+}
+
+func (db *DBSQLite) EnumerateClientIDs(
+	ctx context.Context,
+	maxPingTries uint,
+	networkID uint,
+	minQuality ObservationQuality,
+	enumFunc func(clientID *string),
+) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateClientIDs), maxPingTries, networkID, minQuality)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate client IDs: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	// the quarantine writes are deferred until the cursor is closed,
+	// since a write during the iteration would contend with the open read cursor
+	type badClientID struct {
+		id    NodeID
+		value string
+	}
+	var badClientIDs []badClientID
+
+	for cursor.Next() {
+		var id NodeID
+		var clientID sql.NullString
+		if err := cursor.Scan(&id, &clientID); err != nil {
+			return fmt.Errorf("failed to read client ID data: %w", err)
+		}
+		if clientID.Valid {
+			if db.strictRead && isMalformedClientID(clientID.String) {
+				badClientIDs = append(badClientIDs, badClientID{id, clientID.String})
+				continue
+			}
+			enumFunc(&clientID.String)
+		} else {
+			enumFunc(nil)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	_ = cursor.Close()
+
+	for _, bad := range badClientIDs {
+		db.quarantineBadValue(ctx, bad.id, "client_id", bad.value, "malformed client ID")
+	}
+	return nil
+}
+
+// isMalformedClientID tells if a self-reported client ID can't be displayed safely:
+// it is not valid UTF-8 or contains control characters.
+func isMalformedClientID(value string) bool {
+	if !utf8.ValidString(value) {
+		return true
+	}
+	return strings.IndexFunc(value, unicode.IsControl) >= 0
+}
+
+func (db *DBSQLite) EnumerateHandshakeErrorClientIDs(
+	ctx context.Context,
+	networkID uint,
+	enumFunc func(clientID *string, handshakeErr string),
+) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateHandshakeErrorClientIDs), networkID, networkID)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate handshake error client IDs: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var clientID sql.NullString
+		var handshakeErr string
+		if err := cursor.Scan(&clientID, &handshakeErr); err != nil {
+			return fmt.Errorf("failed to read handshake error client ID data: %w", err)
+		}
+		if clientID.Valid {
+			enumFunc(&clientID.String, handshakeErr)
+		} else {
+			enumFunc(nil, handshakeErr)
+		}
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) EnumerateNodeLatencies(
+	ctx context.Context,
+	kind string,
+	enumFunc func(country *string, clientID *string, rtt time.Duration),
+) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodeLatencies), kind)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate latency samples: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var country sql.NullString
+		var clientID sql.NullString
+		var rttMicros int64
+		if err := cursor.Scan(&country, &clientID, &rttMicros); err != nil {
+			return fmt.Errorf("failed to read latency sample data: %w", err)
+		}
+
+		var countryPtr *string
+		if country.Valid {
+			countryPtr = &country.String
+		}
+		var clientIDPtr *string
+		if clientID.Valid {
+			clientIDPtr = &clientID.String
+		}
+		enumFunc(countryPtr, clientIDPtr, time.Duration(rttMicros)*time.Microsecond)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) EnumerateClientIDsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	window time.Duration,
+	enumFunc func(clientID *string),
+) error {
+	asOfTimestamp := utils.ToTimestamp(asOf)
+	windowStartTimestamp := utils.ToTimestamp(asOf.Add(-window))
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateClientIDsAsOf), asOfTimestamp, windowStartTimestamp, asOfTimestamp)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate historic client IDs: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var clientID string
+		if err := cursor.Scan(&clientID); err != nil {
+			return fmt.Errorf("failed to read historic client ID data: %w", err)
+		}
+		enumFunc(&clientID)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) EnumerateNodeLifetimes(ctx context.Context, enumFunc func(lifetime NodeLifetime)) error {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodeLifetimes))
+	if err != nil {
+		return fmt.Errorf("failed to enumerate node lifetimes: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var clientID sql.NullString
+		var asn sql.NullInt64
+		var firstSeenTimestamp int64
+		var addrUpdatedTimestamp int64
+		var lastHandshakeTimestamp sql.NullInt64
+		var lastHandshakeDayTimestamp sql.NullInt64
+
+		err := cursor.Scan(&clientID, &asn,
+			&firstSeenTimestamp, &addrUpdatedTimestamp,
+			&lastHandshakeTimestamp, &lastHandshakeDayTimestamp)
+		if err != nil {
+			return fmt.Errorf("failed to read node lifetime data: %w", err)
+		}
+
+		var lifetime NodeLifetime
+		if clientID.Valid {
+			lifetime.ClientID = &clientID.String
+		}
+		if asn.Valid {
+			value := uint(asn.Int64)
+			lifetime.ASN = &value
+		}
+		lifetime.FirstSeen = utils.FromTimestamp(firstSeenTimestamp)
+
+		// the last contact is the latest reachability evidence across
+		// the address updates and the successful handshake attempt history
+		// (including the compacted daily aggregates)
+		lastSeenTimestamp := addrUpdatedTimestamp
+		if lastHandshakeTimestamp.Valid && (lastHandshakeTimestamp.Int64 > lastSeenTimestamp) {
+			lastSeenTimestamp = lastHandshakeTimestamp.Int64
+		}
+		if lastHandshakeDayTimestamp.Valid && (lastHandshakeDayTimestamp.Int64 > lastSeenTimestamp) {
+			lastSeenTimestamp = lastHandshakeDayTimestamp.Int64
+		}
+		lifetime.LastSeen = utils.FromTimestamp(lastSeenTimestamp)
+
+		enumFunc(lifetime)
+	}
+	return cursor.Err()
+}
+
+// nodeChildTables are the tables holding per-node side data keyed by node_id.
+// monitored_nodes is not included: it is operator configuration,
+// and may legitimately reference nodes that were not discovered yet.
+var nodeChildTables = []string{
+	"handshake_attempts",
+	"handshake_attempt_days",
+	"node_client_history",
+	"node_client_info",
+	"node_probed_buckets",
+	"node_enr_entries",
+	"node_enr",
+	"node_capabilities",
+	"node_protocol_violations",
+	"handshake_errors",
+	"node_sightings",
+	"node_labels",
+	"block_announcements",
+	"enrichment_freshness",
+	"federated_nodes",
+	"crawl_frontier",
+}
+
+func (db *DBSQLite) CleanupOrphanRows(ctx context.Context) (map[string]uint, error) {
+	counts := make(map[string]uint)
+	for _, table := range nodeChildTables {
+		// the table name comes from the fixed list above, and is safe to quote
+		query := fmt.Sprintf(`DELETE FROM %q WHERE node_id NOT IN (SELECT id FROM nodes)`, table)
+		result, err := db.db.ExecContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete orphan rows of %s: %w", table, err)
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count deleted rows of %s: %w", table, err)
+		}
+		counts[table] = uint(deleted)
+	}
+	return counts, nil
+}
+
+func (db *DBSQLite) CountRLPxPortGroups(ctx context.Context) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountRLPxPortGroups)
+}
+
+func (db *DBSQLite) CountIPVersions(ctx context.Context) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlCountIPVersions)
+}
+
+func (db *DBSQLite) UpdateDialStats(ctx context.Context, id NodeID, ipv6 bool, success bool) error {
+	statement := sqlUpdateDialStatsV4
+	if ipv6 {
+		statement = sqlUpdateDialStatsV6
+	}
+	successCount := 0
+	if success {
+		successCount = 1
+	}
+	if _, err := db.db.ExecContext(ctx, db.stmt(statement), successCount, id); err != nil {
+		return fmt.Errorf("failed to update dial stats: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) CountDialFamilyStats(ctx context.Context, networkID uint) (DialFamilyStats, error) {
+	row := db.reader().QueryRowContext(ctx, db.stmt(sqlCountDialFamilyStats), networkID, networkID)
+	var stats DialFamilyStats
+	err := row.Scan(&stats.AttemptsV4, &stats.SuccessV4, &stats.AttemptsV6, &stats.SuccessV6)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count dial stats: %w", err)
+	}
+	return stats, nil
+}
+
+func (db *DBSQLite) UpdateOperatorLabel(ctx context.Context, id NodeID, label string) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateOperatorLabel), label, label, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the operator label: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) UpdateOperatorOptOut(ctx context.Context, id NodeID, optOut bool) error {
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpdateOperatorOptOut), optOut, id)
+	if err != nil {
+		return fmt.Errorf("failed to update the operator opt-out flag: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindOperatorSettings(ctx context.Context, id NodeID) (*OperatorSettings, error) {
+	row := db.reader().QueryRowContext(ctx, db.stmt(sqlFindOperatorSettings), id)
+	var label sql.NullString
+	var settings OperatorSettings
+	err := row.Scan(&label, &settings.OptOut)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find the operator settings: %w", err)
+	}
+	if label.Valid {
+		settings.Label = &label.String
+	}
+	return &settings, nil
+}
+
+func (db *DBSQLite) EnumerateKeyRotations(ctx context.Context, minKeys uint, limit uint) ([]KeyRotationStats, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateKeyRotations), minKeys, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate key rotations: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var rotations []KeyRotationStats
+	for cursor.Next() {
+		var entry KeyRotationStats
+		var firstSeen int64
+		var lastSeen int64
+		if err := cursor.Scan(&entry.IP, &entry.PortRLPx, &entry.KeyCount, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to read key rotation data: %w", err)
+		}
+		entry.FirstSeen = utils.FromTimestamp(firstSeen)
+		entry.LastSeen = utils.FromTimestamp(lastSeen)
+		rotations = append(rotations, entry)
+	}
+	return rotations, cursor.Err()
+}
+
+func (db *DBSQLite) FindNodeHistory(ctx context.Context, ip string, portRLPx uint16) ([]NodeHistoryEntry, error) {
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlFindNodeHistory), ip, portRLPx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find node history: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	var entries []NodeHistoryEntry
+	for cursor.Next() {
+		var entry NodeHistoryEntry
+		var firstSeen int64
+		var lastSeen int64
+		if err := cursor.Scan(&entry.NodeID, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to read node history data: %w", err)
+		}
+		entry.FirstSeen = utils.FromTimestamp(firstSeen)
+		entry.LastSeen = utils.FromTimestamp(lastSeen)
+		entries = append(entries, entry)
+	}
+	return entries, cursor.Err()
+}
+
+func (db *DBSQLite) ReplaceAnalyticsAggregates(ctx context.Context, category string, counts map[string]uint) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to replace analytics aggregates: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, db.stmt(sqlDeleteAnalyticsAggregates), category); err != nil {
+		return fmt.Errorf("failed to delete analytics aggregates: %w", err)
+	}
+
+	refreshed := utils.ToTimestamp(db.clock.Now())
+	for bucket, nodeCount := range counts {
+		_, err := tx.ExecContext(ctx, db.stmt(sqlInsertAnalyticsAggregate),
+			category, bucket, nodeCount, refreshed)
+		if err != nil {
+			return fmt.Errorf("failed to insert an analytics aggregate: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to replace analytics aggregates: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) FindAnalyticsAggregates(ctx context.Context, category string) (map[string]uint, error) {
+	return db.countGroups(ctx, sqlFindAnalyticsAggregates, category)
+}
+
+func (db *DBSQLite) DeleteStaleNodes(ctx context.Context, olderThan time.Time, maxPingTries uint) (map[string]uint, error) {
+	result, err := db.db.ExecContext(ctx, db.stmt(sqlDeleteStaleNodes), utils.ToTimestamp(olderThan), maxPingTries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete stale nodes: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the deleted stale nodes: %w", err)
+	}
+
+	// the dependent rows of the deleted nodes are now orphans
+	counts, err := db.CleanupOrphanRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts["nodes"] = uint(deleted)
+	return counts, nil
+}
+
+func (db *DBSQLite) Vacuum(ctx context.Context) error {
+	if _, err := db.db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+	return nil
+}
+
+func (db *DBSQLite) EnumerateNodeExports(ctx context.Context, sinceChangeSeq uint64, filter NodeExportFilter, enumFunc func(node NodeExport)) error {
+	var seenSince int64
+	if !filter.SeenSince.IsZero() {
+		seenSince = utils.ToTimestamp(filter.SeenSince)
+	}
+
+	cursor, err := db.reader().QueryContext(ctx, db.stmt(sqlEnumerateNodeExports),
+		sinceChangeSeq,
+		filter.ClientIDPrefix, filter.ClientIDPrefix,
+		filter.NetworkID, filter.NetworkID,
+		seenSince, seenSince, seenSince,
+		filter.Limit, filter.Limit)
+	if err != nil {
+		return fmt.Errorf("failed to enumerate node exports: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	for cursor.Next() {
+		var node NodeExport
+		var ip sql.NullString
+		var portRLPx sql.NullInt32
+		var clientID sql.NullString
+		var networkID sql.NullInt64
+		var ethVersion sql.NullInt32
+		var asn sql.NullInt64
+		var addrUpdatedTimestamp int64
+
+		err := cursor.Scan(&node.ID, &ip, &portRLPx,
+			&clientID, &networkID, &ethVersion, &asn,
+			&addrUpdatedTimestamp, &node.ChangeSeq)
+		if err != nil {
+			return fmt.Errorf("failed to read node export data: %w", err)
+		}
+
+		if ip.Valid {
+			node.IP = &ip.String
+		}
+		if portRLPx.Valid {
+			value := uint16(portRLPx.Int32)
+			node.PortRLPx = &value
+		}
+		if clientID.Valid {
+			node.ClientID = &clientID.String
+		}
+		if networkID.Valid {
+			value := uint(networkID.Int64)
+			node.NetworkID = &value
+		}
+		if ethVersion.Valid {
+			value := uint(ethVersion.Int32)
+			node.EthVersion = &value
+		}
+		if asn.Valid {
+			value := uint(asn.Int64)
+			node.ASN = &value
+		}
+		node.AddrUpdated = utils.FromTimestamp(addrUpdatedTimestamp)
+
+		enumFunc(node)
+	}
+	return cursor.Err()
+}
+
+func (db *DBSQLite) FindExportCheckpoint(ctx context.Context, name string) (uint64, error) {
+	row := db.db.QueryRowContext(ctx, db.stmt(sqlFindExportCheckpoint), name)
+	var changeSeq uint64
+	if err := row.Scan(&changeSeq); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to find the export checkpoint: %w", err)
+	}
+	return changeSeq, nil
+}
+
+func (db *DBSQLite) UpdateExportCheckpoint(ctx context.Context, name string, changeSeq uint64) error {
+	updated := utils.ToTimestamp(db.clock.Now())
+	_, err := db.db.ExecContext(ctx, db.stmt(sqlUpsertExportCheckpoint), name, changeSeq, updated)
+	if err != nil {
+		return fmt.Errorf("failed to update the export checkpoint: %w", err)
+	}
+	return nil
+}
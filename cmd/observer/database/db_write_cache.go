@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// writeCacheCloseTimeout bounds the final flush on Close,
+// when the run context is already cancelled.
+const writeCacheCloseTimeout = 10 * time.Second
+
+// DBWriteCache is a write-behind cache in front of a DB.
+// The crawler hot-path writes (UpsertNodeAddr, UpdatePingError, UpdateClientID)
+// are coalesced in memory per node and applied in a single transaction
+// once per flush period (see DB.FlushNodeWrites), so the crawl throughput
+// is not bottlenecked by one synchronous commit per call.
+// Reads of the cached entities see the pending writes.
+// Close flushes the outstanding batch before closing the underlying DB.
+//
+// Coalescing is lossy by design: only the latest pending address
+// and client ID of a node reach the database, and the client history
+// receives at most one entry per node per flush.
+type DBWriteCache struct {
+	DB
+	flushPeriod time.Duration
+	log         log.Logger
+
+	mu    sync.Mutex
+	batch NodeWriteBatch
+
+	stopFlush chan struct{}
+	doneFlush chan struct{}
+}
+
+func NewDBWriteCache(db DB, flushPeriod time.Duration, logger log.Logger) *DBWriteCache {
+	instance := DBWriteCache{
+		DB:          db,
+		flushPeriod: flushPeriod,
+		log:         logger,
+		batch:       MakeNodeWriteBatch(),
+		stopFlush:   make(chan struct{}),
+		doneFlush:   make(chan struct{}),
+	}
+	go instance.flushLoop()
+	return &instance
+}
+
+func (db *DBWriteCache) flushLoop() {
+	defer close(db.doneFlush)
+	ticker := time.NewTicker(db.flushPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.flush(context.Background()); err != nil {
+				db.log.Warn("Failed to flush the write cache, will retry", "err", err)
+			}
+		case <-db.stopFlush:
+			return
+		}
+	}
+}
+
+// flush takes the pending batch and applies it in one transaction.
+// On failure the batch is put back for the next flush,
+// without clobbering the writes that arrived in the meantime.
+func (db *DBWriteCache) flush(ctx context.Context) error {
+	db.mu.Lock()
+	batch := db.batch
+	db.batch = MakeNodeWriteBatch()
+	db.mu.Unlock()
+
+	if batch.Size() == 0 {
+		return nil
+	}
+	err := db.DB.FlushNodeWrites(ctx, batch)
+	if err == nil {
+		return nil
+	}
+
+	db.mu.Lock()
+	for id, addr := range batch.Addrs {
+		if _, ok := db.batch.Addrs[id]; !ok {
+			db.batch.Addrs[id] = addr
+		}
+	}
+	for id, count := range batch.PingErrors {
+		db.batch.PingErrors[id] += count
+	}
+	for id, observation := range batch.ClientIDs {
+		if _, ok := db.batch.ClientIDs[id]; !ok {
+			db.batch.ClientIDs[id] = observation
+		}
+	}
+	db.mu.Unlock()
+	return err
+}
+
+func (db *DBWriteCache) UpsertNodeAddr(ctx context.Context, id NodeID, addr NodeAddr) error {
+	// the ID check of the write path happens at enqueue time,
+	// so the caller learns about a corrupt ID synchronously
+	if err := id.Validate(); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	db.batch.Addrs[id] = addr
+	db.mu.Unlock()
+	return nil
+}
+
+func (db *DBWriteCache) FindNodeAddr(ctx context.Context, id NodeID) (*NodeAddr, error) {
+	db.mu.Lock()
+	addr, ok := db.batch.Addrs[id]
+	db.mu.Unlock()
+	if ok {
+		return &addr, nil
+	}
+	return db.DB.FindNodeAddr(ctx, id)
+}
+
+func (db *DBWriteCache) UpdatePingError(ctx context.Context, id NodeID) error {
+	db.mu.Lock()
+	db.batch.PingErrors[id]++
+	db.mu.Unlock()
+	return nil
+}
+
+// ResetPingError discards the pending failure count and writes through:
+// a reset must not be undone by an older pending increment.
+func (db *DBWriteCache) ResetPingError(ctx context.Context, id NodeID) error {
+	db.mu.Lock()
+	delete(db.batch.PingErrors, id)
+	db.mu.Unlock()
+	return db.DB.ResetPingError(ctx, id)
+}
+
+func (db *DBWriteCache) CountPingErrors(ctx context.Context, id NodeID) (*uint, error) {
+	count, err := db.DB.CountPingErrors(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	pending := db.batch.PingErrors[id]
+	_, hasAddr := db.batch.Addrs[id]
+	db.mu.Unlock()
+
+	if count == nil {
+		// the row might not exist yet if its address upsert is still pending
+		if !hasAddr {
+			return nil, nil
+		}
+		count = new(uint)
+	}
+	value := *count + pending
+	return &value, nil
+}
+
+// UpdateClientID reports the change against the latest visible value;
+// the authoritative client_changed event is emitted by the flush.
+func (db *DBWriteCache) UpdateClientID(ctx context.Context, id NodeID, clientID string, quality ObservationQuality) (bool, error) {
+	previous, err := db.FindClientID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	db.mu.Lock()
+	db.batch.ClientIDs[id] = ClientIDObservation{clientID, quality}
+	db.mu.Unlock()
+	return (previous == nil) || (*previous != clientID), nil
+}
+
+func (db *DBWriteCache) FindClientID(ctx context.Context, id NodeID) (*string, error) {
+	db.mu.Lock()
+	observation, ok := db.batch.ClientIDs[id]
+	db.mu.Unlock()
+	if ok {
+		return &observation.ClientID, nil
+	}
+	return db.DB.FindClientID(ctx, id)
+}
+
+func (db *DBWriteCache) Close() error {
+	close(db.stopFlush)
+	<-db.doneFlush
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeCacheCloseTimeout)
+	defer cancel()
+	if err := db.flush(ctx); err != nil {
+		db.log.Error("Failed to flush the write cache on close", "err", err)
+	}
+	return db.DB.Close()
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyFlushDB fails a number of FlushNodeWrites calls before letting them
+// through, and runs an optional callback while a flush is in flight,
+// standing in for the writes arriving between a failure and the retry.
+type flakyFlushDB struct {
+	DB
+	failures      int
+	duringFailure func()
+}
+
+func (db *flakyFlushDB) FlushNodeWrites(ctx context.Context, batch NodeWriteBatch) error {
+	if db.failures > 0 {
+		db.failures--
+		if db.duringFailure != nil {
+			db.duringFailure()
+		}
+		return errTestConflict
+	}
+	return db.DB.FlushNodeWrites(ctx, batch)
+}
+
+func TestWriteCacheMergesFailedFlush(t *testing.T) {
+	ctx := context.Background()
+
+	sqlite, err := NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+
+	flaky := flakyFlushDB{DB: sqlite, failures: 1}
+	cache := NewDBWriteCache(&flaky, time.Hour, testLogger())
+	defer func() { _ = cache.Close() }()
+
+	id1 := testNodeID("aa")
+	id2 := testNodeID("bb")
+	oldAddr := testNodeAddr("10.0.0.1")
+	newAddr := testNodeAddr("10.0.0.2")
+
+	require.NoError(t, cache.UpsertNodeAddr(ctx, id1, oldAddr))
+	require.NoError(t, cache.UpsertNodeAddr(ctx, id2, oldAddr))
+	require.NoError(t, cache.UpdatePingError(ctx, id1))
+	require.NoError(t, cache.UpdatePingError(ctx, id1))
+	_, err = cache.UpdateClientID(ctx, id1, "erigon/old", ObservationQualityVerified)
+	require.NoError(t, err)
+
+	// newer writes arrive while the failing flush is in flight
+	flaky.duringFailure = func() {
+		require.NoError(t, cache.UpsertNodeAddr(ctx, id1, newAddr))
+		require.NoError(t, cache.UpdatePingError(ctx, id1))
+		_, err := cache.UpdateClientID(ctx, id1, "erigon/new", ObservationQualityVerified)
+		require.NoError(t, err)
+	}
+	require.Error(t, cache.flush(ctx))
+	flaky.duringFailure = nil
+
+	// the failed batch is put back without clobbering the newer writes:
+	// the latest address and client ID win, and the failure counts add up
+	cache.mu.Lock()
+	assert.Equal(t, newAddr, cache.batch.Addrs[id1])
+	assert.Equal(t, oldAddr, cache.batch.Addrs[id2])
+	assert.Equal(t, uint(3), cache.batch.PingErrors[id1])
+	assert.Equal(t, "erigon/new", cache.batch.ClientIDs[id1].ClientID)
+	cache.mu.Unlock()
+
+	// the retried flush persists the merged batch
+	require.NoError(t, cache.flush(ctx))
+	cache.mu.Lock()
+	assert.Zero(t, cache.batch.Size())
+	cache.mu.Unlock()
+
+	addr, err := sqlite.FindNodeAddr(ctx, id1)
+	require.NoError(t, err)
+	require.NotNil(t, addr)
+	assert.Equal(t, newAddr.IP.String(), addr.IP.String())
+
+	count, err := sqlite.CountPingErrors(ctx, id1)
+	require.NoError(t, err)
+	require.NotNil(t, count)
+	assert.Equal(t, uint(3), *count)
+
+	clientID, err := sqlite.FindClientID(ctx, id1)
+	require.NoError(t, err)
+	require.NotNil(t, clientID)
+	assert.Equal(t, "erigon/new", *clientID)
+}
+
+func TestWriteCacheReadsSeePendingWrites(t *testing.T) {
+	ctx := context.Background()
+
+	sqlite, err := NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+
+	cache := NewDBWriteCache(sqlite, time.Hour, testLogger())
+	defer func() { _ = cache.Close() }()
+
+	id := testNodeID("aa")
+	addr := testNodeAddr("10.0.0.1")
+	require.NoError(t, cache.UpsertNodeAddr(ctx, id, addr))
+	require.NoError(t, cache.UpdatePingError(ctx, id))
+
+	found, err := cache.FindNodeAddr(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, addr.IP.String(), found.IP.String())
+
+	count, err := cache.CountPingErrors(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, count)
+	assert.Equal(t, uint(1), *count)
+}
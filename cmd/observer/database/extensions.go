@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// language=SQL
+const (
+	sqlCreateExtensionVersionTable = `
+CREATE TABLE IF NOT EXISTS ext_schema_version (
+    extension TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    applied INTEGER NOT NULL,
+    PRIMARY KEY (extension, version)
+);
+`
+
+	sqlSelectExtensionVersion = `
+SELECT COALESCE(MAX(version), 0) FROM ext_schema_version WHERE extension = ?
+`
+
+	sqlInsertExtensionVersion = `
+INSERT INTO ext_schema_version(extension, version, applied) VALUES (?, ?, ?)
+`
+)
+
+// SchemaExtension is a named group of schema migrations owned by a downstream
+// fork of the observer. Extensions version their migrations independently
+// of the upstream schema, recorded per extension in the ext_schema_version
+// table, so a rebase onto a new upstream (which appends to schemaMigrations)
+// never conflicts with the extension history.
+//
+// Every table and index an extension creates must carry the "ext_<name>_"
+// prefix; upstream promises to never create objects under the ext_ prefix.
+// Extensions must not alter the upstream tables: add a prefixed side table
+// keyed by node_id instead of a column, so upstream ALTERs keep applying.
+type SchemaExtension struct {
+	// Name identifies the extension: lowercase letters, digits and underscores,
+	// starting with a letter, e.g. "myfork".
+	Name string
+
+	Migrations []ExtensionMigration
+}
+
+// ExtensionMigration is one schema upgrade of an extension, versioned from 1.
+// Like the upstream migrations the statements must be valid
+// in the SQLite dialect: the backends rewrite them on the fly.
+type ExtensionMigration struct {
+	Version    uint
+	Statements string
+}
+
+var schemaExtensions []SchemaExtension
+
+var extensionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// RegisterSchemaExtension adds the extension migrations to apply whenever
+// a database is opened (see migrateSchema). Call it before opening the
+// database, e.g. from an init function of the fork's package.
+// The registration is rejected if the extension touches any schema object
+// outside of its own "ext_<name>_" prefix.
+func RegisterSchemaExtension(extension SchemaExtension) error {
+	if !extensionNamePattern.MatchString(extension.Name) {
+		return fmt.Errorf("invalid schema extension name %q", extension.Name)
+	}
+	for _, registered := range schemaExtensions {
+		if registered.Name == extension.Name {
+			return fmt.Errorf("schema extension %q is already registered", extension.Name)
+		}
+	}
+
+	prefix := "ext_" + extension.Name + "_"
+	var version uint
+	for _, migration := range extension.Migrations {
+		if migration.Version != version+1 {
+			return fmt.Errorf("schema extension %q migrations must be versioned 1, 2, ... without gaps", extension.Name)
+		}
+		version = migration.Version
+
+		for _, target := range schemaTargets(migration.Statements) {
+			if !strings.HasPrefix(target, prefix) {
+				return fmt.Errorf("schema extension %q migration %d touches %q outside of its %q prefix",
+					extension.Name, migration.Version, target, prefix)
+			}
+		}
+	}
+
+	schemaExtensions = append(schemaExtensions, extension)
+	return nil
+}
+
+// schemaTargets extracts the names of the schema objects the statements
+// create, alter or drop, so the extension prefix rule can be enforced.
+// Reading the upstream tables (e.g. a backfill SELECT) is not a target.
+func schemaTargets(statements string) []string {
+	tokens := strings.Fields(strings.ReplaceAll(statements, "(", " ("))
+
+	var targets []string
+	for i := 0; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "CREATE", "ALTER", "DROP":
+			if i+1 >= len(tokens) {
+				continue
+			}
+			kind := strings.ToUpper(tokens[i+1])
+			if (kind != "TABLE") && (kind != "INDEX") && (kind != "TRIGGER") && (kind != "VIEW") {
+				continue
+			}
+			i += 2
+			// skip the "IF NOT EXISTS" and "IF EXISTS" clauses
+			for (i < len(tokens)) && (strings.ToUpper(tokens[i]) == "IF" ||
+				strings.ToUpper(tokens[i]) == "NOT" || strings.ToUpper(tokens[i]) == "EXISTS") {
+				i++
+			}
+			if i < len(tokens) {
+				targets = append(targets, strings.TrimSuffix(tokens[i], ";"))
+			}
+		}
+	}
+	return targets
+}
+
+// migrateExtensions applies the pending migrations of the registered schema
+// extensions after the upstream schema is up to date (see migrateSchema).
+func migrateExtensions(db *sql.DB, ddlRewrite func(statement string) string, stmtRewrite func(statement string) string) error {
+	if len(schemaExtensions) == 0 {
+		return nil
+	}
+
+	if _, err := db.Exec(ddlRewrite(sqlCreateExtensionVersionTable)); err != nil {
+		return fmt.Errorf("failed to create the extension version table: %w", err)
+	}
+
+	for _, extension := range schemaExtensions {
+		var version uint
+		if err := db.QueryRow(stmtRewrite(sqlSelectExtensionVersion), extension.Name).Scan(&version); err != nil {
+			return fmt.Errorf("failed to read the %q extension version: %w", extension.Name, err)
+		}
+
+		for _, migration := range extension.Migrations {
+			if migration.Version <= version {
+				continue
+			}
+			upgrade := schemaMigration{version: migration.Version, statements: migration.Statements}
+			if err := applyMigration(db, ddlRewrite, upgrade); err != nil {
+				return fmt.Errorf("failed to apply migration %d of the %q schema extension: %w",
+					migration.Version, extension.Name, err)
+			}
+			applied := time.Now().Unix()
+			if _, err := db.Exec(stmtRewrite(sqlInsertExtensionVersion), extension.Name, migration.Version, applied); err != nil {
+				return fmt.Errorf("failed to record the %q extension version %d: %w",
+					extension.Name, migration.Version, err)
+			}
+		}
+	}
+	return nil
+}
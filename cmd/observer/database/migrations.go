@@ -0,0 +1,540 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// language=SQL
+const (
+	sqlCreateSchemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    applied INTEGER NOT NULL
+);
+`
+
+	sqlSelectSchemaVersion = `
+SELECT COALESCE(MAX(version), 0) FROM schema_version
+`
+
+	sqlInsertSchemaVersion = `
+INSERT INTO schema_version(version, applied) VALUES (?, ?)
+`
+)
+
+// schemaMigration upgrades the schema of an existing database by one version.
+// The statements must be valid in the SQLite dialect:
+// the backends rewrite them to their own dialect (see migrateSchema).
+type schemaMigration struct {
+	version    uint
+	statements string
+}
+
+// schemaMigrations upgrade databases created by older observer versions.
+// sqlCreateSchema always holds the latest schema and is used for fresh databases,
+// so every change to it needs a matching migration here.
+// language=SQL
+var schemaMigrations = []schemaMigration{
+	{
+		version: 1,
+		statements: `
+ALTER TABLE nodes ADD COLUMN client_id_quality INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN network_id_quality INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN eth_version_quality INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS node_client_history (
+    node_id TEXT NOT NULL,
+    observed INTEGER NOT NULL,
+    client_id TEXT NOT NULL,
+    PRIMARY KEY (node_id, observed)
+);
+`,
+	},
+	{
+		version: 2,
+		statements: `
+ALTER TABLE nodes ADD COLUMN first_seen INTEGER;
+`,
+	},
+	{
+		version: 3,
+		statements: `
+ALTER TABLE nodes ADD COLUMN change_seq INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS export_state (
+    name TEXT PRIMARY KEY,
+    last_change_seq INTEGER NOT NULL,
+    updated INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_nodes_change_seq ON nodes (change_seq);
+`,
+	},
+	{
+		version: 4,
+		statements: `
+ALTER TABLE nodes ADD COLUMN disc_version INTEGER;
+UPDATE nodes SET disc_version = 4 WHERE disc_version IS NULL;
+`,
+	},
+	{
+		version: 5,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_enr (
+    node_id TEXT PRIMARY KEY,
+    seq INTEGER NOT NULL,
+    record BLOB NOT NULL,
+    updated INTEGER NOT NULL
+);
+`,
+	},
+	{
+		version: 6,
+		statements: `
+ALTER TABLE nodes ADD COLUMN country TEXT;
+ALTER TABLE nodes ADD COLUMN city TEXT;
+`,
+	},
+	{
+		version: 7,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_seen_windows (
+    window_hours INTEGER PRIMARY KEY,
+    node_count INTEGER NOT NULL,
+    ip_count INTEGER NOT NULL,
+    refreshed INTEGER NOT NULL
+);
+`,
+	},
+	{
+		version: 8,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_protocol_violations (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    violation_time INTEGER NOT NULL,
+    code TEXT NOT NULL,
+    detail TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_node_protocol_violations_node_id ON node_protocol_violations (node_id);
+`,
+	},
+	{
+		version: 9,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_capabilities (
+    node_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    first_seen INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (node_id, name, version)
+);
+`,
+	},
+	{
+		version: 10,
+		statements: `
+CREATE TABLE IF NOT EXISTS stats_snapshots (
+    snapshot_time INTEGER PRIMARY KEY,
+    total_nodes INTEGER NOT NULL,
+    compatible_nodes INTEGER NOT NULL,
+    distinct_ips INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS stats_snapshot_clients (
+    snapshot_time INTEGER NOT NULL,
+    client_name TEXT NOT NULL,
+    node_count INTEGER NOT NULL,
+    PRIMARY KEY (snapshot_time, client_name)
+);
+`,
+	},
+	{
+		version: 11,
+		statements: `
+CREATE TABLE IF NOT EXISTS federated_nodes (
+    node_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    first_pulled INTEGER NOT NULL,
+    last_pulled INTEGER NOT NULL,
+    PRIMARY KEY (node_id, source)
+);
+`,
+	},
+	{
+		// the candidate lease pair replaces the taken_time marker;
+		// the old column stays unused in the upgraded databases
+		version: 12,
+		statements: `
+ALTER TABLE nodes ADD COLUMN lease_owner TEXT;
+ALTER TABLE nodes ADD COLUMN lease_expires INTEGER;
+`,
+	},
+	{
+		// the handshake error history replaces the handshake_err column,
+		// which stays unused in the upgraded databases;
+		// the last known error is carried over with the retry time
+		// as the best available timestamp
+		version: 13,
+		statements: `
+CREATE TABLE IF NOT EXISTS handshake_errors (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    err TEXT NOT NULL,
+    updated INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_handshake_errors_node_id ON handshake_errors (node_id);
+INSERT INTO handshake_errors (node_id, err, updated)
+    SELECT id, handshake_err, COALESCE(handshake_retry_time, addr_updated)
+    FROM nodes WHERE handshake_err IS NOT NULL;
+`,
+	},
+	{
+		version: 14,
+		statements: `
+CREATE TABLE IF NOT EXISTS probe_policies (
+    name TEXT PRIMARY KEY,
+    tag TEXT,
+    subnet TEXT,
+    country TEXT,
+    min_interval_seconds INTEGER NOT NULL DEFAULT 0,
+    quiet_start_hour INTEGER NOT NULL DEFAULT 0,
+    quiet_end_hour INTEGER NOT NULL DEFAULT 0,
+    utc_offset_minutes INTEGER NOT NULL DEFAULT 0,
+    added INTEGER NOT NULL
+);
+`,
+	},
+	{
+		version: 15,
+		statements: `
+ALTER TABLE nodes ADD COLUMN fork_id_hash TEXT;
+ALTER TABLE nodes ADD COLUMN fork_id_next INTEGER;
+ALTER TABLE nodes ADD COLUMN fork_id_quality INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN head_hash TEXT;
+ALTER TABLE nodes ADD COLUMN head_td TEXT;
+`,
+	},
+	{
+		version: 16,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_labels (
+    node_id TEXT NOT NULL,
+    label TEXT NOT NULL,
+    score REAL NOT NULL DEFAULT 1,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (node_id, label)
+);
+`,
+	},
+	{
+		version: 17,
+		statements: `
+ALTER TABLE nodes ADD COLUMN supports_snap INTEGER;
+`,
+	},
+	{
+		version: 18,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_sightings (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    sighted INTEGER NOT NULL,
+    kind TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_node_sightings_node_id ON node_sightings (node_id);
+`,
+	},
+	{
+		// snapshots taken before this version keep the zero default,
+		// which the trend calculation treats as "no estimate recorded"
+		version: 19,
+		statements: `
+ALTER TABLE stats_snapshots ADD COLUMN operator_estimate INTEGER NOT NULL DEFAULT 0;
+`,
+	},
+	{
+		version: 20,
+		statements: `
+CREATE TABLE IF NOT EXISTS analytics_aggregates (
+    category TEXT NOT NULL,
+    bucket TEXT NOT NULL,
+    node_count INTEGER NOT NULL,
+    refreshed INTEGER NOT NULL,
+    PRIMARY KEY (category, bucket)
+);
+`,
+	},
+	{
+		version: 21,
+		statements: `
+ALTER TABLE nodes ADD COLUMN tx_announce_rate REAL;
+`,
+	},
+	{
+		// the announced column holds unix milliseconds:
+		// block propagation deltas are too fine-grained for whole seconds
+		version: 22,
+		statements: `
+CREATE TABLE IF NOT EXISTS block_announcements (
+    node_id TEXT NOT NULL,
+    block_hash TEXT NOT NULL,
+    block_number INTEGER NOT NULL,
+    announced INTEGER NOT NULL,
+    PRIMARY KEY (node_id, block_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_block_announcements_block_hash ON block_announcements (block_hash);
+`,
+	},
+	{
+		version: 23,
+		statements: `
+CREATE TABLE IF NOT EXISTS enrichment_freshness (
+    node_id TEXT NOT NULL,
+    attribute TEXT NOT NULL,
+    refreshed INTEGER NOT NULL,
+    PRIMARY KEY (node_id, attribute)
+);
+`,
+	},
+	{
+		// the ip_reputation table caches the provider results per IP,
+		// since many nodes share an address and the provider APIs are rate limited
+		version: 24,
+		statements: `
+ALTER TABLE nodes ADD COLUMN ip_reputation REAL;
+
+CREATE TABLE IF NOT EXISTS ip_reputation (
+    ip TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    score REAL NOT NULL,
+    checked INTEGER NOT NULL,
+    PRIMARY KEY (ip, provider)
+);
+`,
+	},
+	{
+		// node_events records attribute transitions (e.g. client_changed)
+		// with both the old and the new value,
+		// detected atomically inside the update transactions
+		version: 25,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_events (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    happened INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_events_node_id ON node_events (node_id);
+`,
+	},
+	{
+		// the fork compatibility verdict is computed by the observer
+		// against the canonical chain config (see observer.ForkVerifier),
+		// and stored next to the raw fork ID for auditability
+		version: 26,
+		statements: `
+ALTER TABLE nodes ADD COLUMN fork_compatible INTEGER;
+ALTER TABLE nodes ADD COLUMN fork_verdict TEXT;
+`,
+	},
+	{
+		// per-attempt latency samples: TCP connect time, RLPx handshake duration
+		// and disc ping RTT (see InsertNodeLatency), trimmed per node and kind
+		version: 27,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_latency (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    rtt_us INTEGER NOT NULL,
+    measured INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_latency_node_id ON node_latency (node_id);
+`,
+	},
+	{
+		// the RLPx port a handshake actually succeeded on, which may differ
+		// from the advertised port_rlpx when the port scan found the node
+		// listening elsewhere (see the --port-scan-ports flag)
+		version: 28,
+		statements: `
+ALTER TABLE nodes ADD COLUMN port_rlpx_verified INTEGER;
+`,
+	},
+	{
+		// the NAT/reachability class of the node: public, discovery_only
+		// or unreachable (see UpdateReachability), with the time it was
+		// last (re)classified
+		version: 29,
+		statements: `
+ALTER TABLE nodes ADD COLUMN reachability TEXT;
+ALTER TABLE nodes ADD COLUMN reachability_updated INTEGER;
+`,
+	},
+	{
+		// handshake successes and failures bucketed by UTC day-of-week,
+		// hour-of-day and node country (see IncrementHandshakeHourStats),
+		// revealing diurnal patterns like residential nodes going offline at night
+		version: 30,
+		statements: `
+CREATE TABLE IF NOT EXISTS handshake_hour_stats (
+    dow INTEGER NOT NULL,
+    hour INTEGER NOT NULL,
+    country TEXT NOT NULL,
+    success_count INTEGER NOT NULL,
+    failure_count INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    PRIMARY KEY (dow, hour, country)
+);
+`,
+	},
+	{
+		// values that failed the strict read validation (unparsable IPs,
+		// malformed client IDs, inconsistent timestamps) are quarantined
+		// here instead of failing queries mid-crawl (see EnableStrictRead)
+		version: 31,
+		statements: `
+CREATE TABLE IF NOT EXISTS bad_rows (
+    num INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    field TEXT NOT NULL,
+    value TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    detected INTEGER NOT NULL,
+    UNIQUE (node_id, field)
+);
+`,
+	},
+	{
+		// per-family dial outcome counters for the dual-stack handshake path,
+		// so IPv6 reachability can be measured separately from IPv4
+		version: 32,
+		statements: `
+ALTER TABLE nodes ADD COLUMN dial_attempts_v4 INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN dial_success_v4 INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN dial_attempts_v6 INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE nodes ADD COLUMN dial_success_v6 INTEGER NOT NULL DEFAULT 0;
+`,
+	},
+	{
+		// settings a verified node operator manages through the self-service
+		// API endpoints: a corrected label, and an opt-out flag that keeps
+		// the node out of the published exports (see EnumerateNodeExports)
+		version: 33,
+		statements: `
+ALTER TABLE nodes ADD COLUMN operator_label TEXT;
+ALTER TABLE nodes ADD COLUMN operator_opt_out INTEGER NOT NULL DEFAULT 0;
+`,
+	},
+	{
+		// which node IDs were seen behind each advertised IP:port endpoint,
+		// so that key rotations and operator changes can be studied
+		// (see EnumerateKeyRotations)
+		version: 34,
+		statements: `
+CREATE TABLE IF NOT EXISTS node_history (
+    ip TEXT NOT NULL,
+    port_rlpx INTEGER NOT NULL,
+    node_id TEXT NOT NULL,
+    first_seen INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL,
+    PRIMARY KEY (ip, port_rlpx, node_id)
+);
+`,
+	},
+}
+
+// latestSchemaVersion is the version a fresh or fully migrated database has.
+func latestSchemaVersion() uint {
+	return schemaMigrations[len(schemaMigrations)-1].version
+}
+
+// migrateSchema brings the database schema up to date.
+// A fresh database gets the latest schema in one go (see sqlCreateSchema),
+// while a database created by an older observer version
+// is upgraded incrementally by the pending schemaMigrations.
+// The applied versions are recorded in the schema_version table.
+// The schema extensions of downstream forks are applied afterwards
+// with their own versioning (see RegisterSchemaExtension).
+func migrateSchema(db *sql.DB, ddlRewrite func(statement string) string, stmtRewrite func(statement string) string) error {
+	if ddlRewrite == nil {
+		ddlRewrite = func(statement string) string { return statement }
+	}
+	if stmtRewrite == nil {
+		stmtRewrite = func(statement string) string { return statement }
+	}
+
+	if _, err := db.Exec(ddlRewrite(sqlCreateSchemaVersionTable)); err != nil {
+		return fmt.Errorf("failed to create the schema version table: %w", err)
+	}
+
+	var version uint
+	if err := db.QueryRow(sqlSelectSchemaVersion).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read the schema version: %w", err)
+	}
+
+	if (version == 0) && !tableExists(db, "nodes") {
+		// a fresh database: create the latest schema directly
+		if _, err := db.Exec(ddlRewrite(sqlCreateSchema)); err != nil {
+			return fmt.Errorf("failed to create the DB schema: %w", err)
+		}
+		if err := recordSchemaVersion(db, stmtRewrite, latestSchemaVersion()); err != nil {
+			return err
+		}
+		return migrateExtensions(db, ddlRewrite, stmtRewrite)
+	}
+
+	for _, migration := range schemaMigrations {
+		if migration.version <= version {
+			continue
+		}
+		if err := applyMigration(db, ddlRewrite, migration); err != nil {
+			return fmt.Errorf("failed to apply schema migration %d: %w", migration.version, err)
+		}
+		if err := recordSchemaVersion(db, stmtRewrite, migration.version); err != nil {
+			return err
+		}
+	}
+	return migrateExtensions(db, ddlRewrite, stmtRewrite)
+}
+
+func applyMigration(db *sql.DB, ddlRewrite func(statement string) string, migration schemaMigration) error {
+	for _, statement := range strings.Split(migration.statements, ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		_, err := db.Exec(ddlRewrite(statement))
+		// a database created before the schema versioning was introduced
+		// may already contain a part of a migration
+		if (err != nil) && !isAlreadyMigratedError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordSchemaVersion(db *sql.DB, stmtRewrite func(statement string) string, version uint) error {
+	applied := time.Now().Unix()
+	if _, err := db.Exec(stmtRewrite(sqlInsertSchemaVersion), version, applied); err != nil {
+		return fmt.Errorf("failed to record schema version %d: %w", version, err)
+	}
+	return nil
+}
+
+func tableExists(db *sql.DB, tableName string) bool {
+	// the probe query works in both supported dialects
+	_, err := db.Exec(fmt.Sprintf("SELECT COUNT(*) FROM %q", tableName))
+	return err == nil
+}
+
+func isAlreadyMigratedError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "duplicate column name") || // SQLite
+		strings.Contains(message, "already exists") // PostgreSQL
+}
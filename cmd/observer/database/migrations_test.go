@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// describeSchemaSQL captures the full DDL of the database:
+// sqlite_master keeps the literal CREATE statements, including the columns
+// appended by ALTER TABLE, so any schema drift shows up in the comparison.
+func describeSchemaSQL(t *testing.T, db *sql.DB) map[string]string {
+	cursor, err := db.Query(
+		"SELECT name, COALESCE(sql, '') FROM sqlite_master WHERE name NOT LIKE 'sqlite_%'")
+	require.NoError(t, err)
+	defer func() { _ = cursor.Close() }()
+
+	schema := make(map[string]string)
+	for cursor.Next() {
+		var name string
+		var ddl string
+		require.NoError(t, cursor.Scan(&name, &ddl))
+		schema[name] = ddl
+	}
+	require.NoError(t, cursor.Err())
+	return schema
+}
+
+func TestFreshSchemaMatchesMigrations(t *testing.T) {
+	db, err := NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// replaying the DDL of every migration over the fresh schema must change
+	// nothing: anything a migration adds beyond sqlCreateSchema would show up
+	// as a diff. The data backfills are skipped, since they may refer
+	// to columns of the old schemas they migrate from.
+	fresh := describeSchemaSQL(t, db.db)
+	for _, migration := range schemaMigrations {
+		for _, statement := range strings.Split(migration.statements, ";") {
+			statement = strings.TrimSpace(statement)
+			isDDL := strings.HasPrefix(statement, "CREATE") || strings.HasPrefix(statement, "ALTER")
+			if !isDDL {
+				continue
+			}
+			_, err := db.db.Exec(statement)
+			if (err != nil) && !isAlreadyMigratedError(err) {
+				t.Fatalf("migration to version %d failed: %v", migration.version, err)
+			}
+		}
+	}
+	assert.Equal(t, fresh, describeSchemaSQL(t, db.db))
+}
+
+func TestFreshSchemaRecordsLatestVersion(t *testing.T) {
+	db, err := NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	var version uint
+	require.NoError(t, db.db.QueryRow(sqlSelectSchemaVersion).Scan(&version))
+	assert.Equal(t, latestSchemaVersion(), version)
+}
+
+func TestIncrementalMigrationCatchesUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "observer.sqlite")
+	db, err := NewDBSQLite(path)
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// pretend the database was created by an older observer version
+	backVersion := latestSchemaVersion() - 3
+	_, err = db.db.Exec("DELETE FROM schema_version")
+	require.NoError(t, err)
+	_, err = db.db.Exec(sqlInsertSchemaVersion, backVersion, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, migrateSchema(db.db, nil, nil))
+
+	var version uint
+	require.NoError(t, db.db.QueryRow(sqlSelectSchemaVersion).Scan(&version))
+	assert.Equal(t, latestSchemaVersion(), version)
+}
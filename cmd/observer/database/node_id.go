@@ -0,0 +1,64 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// NodeID is the hex-encoded secp256k1 public key of a node
+// in the 64 bytes "uncompressed" format (without the leading constant byte).
+// The discovery protocols derive their 32-byte addresses from it by hashing
+// (see enode.ID), but the database keys nodes by the full public key,
+// since the hash is not reversible.
+//
+// The canonical form is lowercase hex without a "0x" prefix:
+// the database compares IDs textually (e.g. the crawler shard bounds),
+// so a single spelling must reach the insert statements.
+// Externally provided IDs go through ParseNodeID to canonicalize them,
+// and the write paths reject IDs that fail Validate.
+type NodeID string
+
+// NodeIDByteLen is the decoded length of a NodeID:
+// the X and Y coordinates of the public key, 32 bytes each.
+const NodeIDByteLen = 64
+
+// ParseNodeID validates and canonicalizes an externally provided node ID
+// (e.g. from a CLI flag, an API request, or an imported file).
+// It tolerates a "0x" prefix and uppercase hex digits.
+// It does not verify that the key is a point on the secp256k1 curve;
+// use node_utils.ParseHexPublicKey where a usable key is needed.
+func ParseNodeID(value string) (NodeID, error) {
+	id := NodeID(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "0x"))
+	if err := id.Validate(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Validate checks that the ID is in the canonical form.
+func (id NodeID) Validate() error {
+	if _, err := id.Bytes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Bytes returns the decoded public key: 32 bytes of X followed by 32 bytes of Y.
+func (id NodeID) Bytes() ([]byte, error) {
+	data, err := hex.DecodeString(string(id))
+	if err != nil {
+		return nil, fmt.Errorf("invalid node ID %q: %w", id, err)
+	}
+	if len(data) != NodeIDByteLen {
+		return nil, fmt.Errorf("invalid node ID %q: %d bytes instead of %d", id, len(data), NodeIDByteLen)
+	}
+	if strings.ToLower(string(id)) != string(id) {
+		return nil, fmt.Errorf("invalid node ID %q: expecting lowercase hex", id)
+	}
+	return data, nil
+}
+
+func (id NodeID) String() string {
+	return string(id)
+}
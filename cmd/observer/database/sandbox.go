@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SandboxQueryResult holds the rendered rows of a sandboxed ad-hoc query
+// (see RunSandboxedQuery). The values are formatted as strings,
+// with NULL rendered as an empty string.
+type SandboxQueryResult struct {
+	Columns   []string
+	Rows      [][]string
+	Truncated bool
+}
+
+// sandboxView is one of the curated read-only views an ad-hoc query may use.
+// The views expose a stable friendly shape and hide the operational columns
+// (retry times, leases, change sequences) of the base tables.
+type sandboxView struct {
+	name string
+	// language=SQL
+	definition string
+}
+
+var sandboxViews = []sandboxView{
+	{"node_overview", `
+SELECT id, ip, port_rlpx, network_id, eth_version, client_id,
+	fork_id_hash, head_td, supports_snap, tx_announce_rate, asn, country, city,
+	first_seen, addr_updated
+FROM nodes`},
+	{"client_info", `
+SELECT node_id, client_name, client_version, client_os, client_arch, updated
+FROM node_client_info`},
+	{"client_history", `
+SELECT node_id, observed, client_id
+FROM node_client_history`},
+	{"handshake_error_log", `
+SELECT node_id, err, updated
+FROM handshake_errors`},
+	{"label_list", `
+SELECT node_id, label, score, updated
+FROM node_labels`},
+	{"sighting_log", `
+SELECT node_id, sighted, kind
+FROM node_sightings`},
+	{"block_announcement_log", `
+SELECT node_id, block_hash, block_number, announced
+FROM block_announcements`},
+	{"stats_history", `
+SELECT snapshot_time, total_nodes, compatible_nodes, distinct_ips, operator_estimate
+FROM stats_snapshots`},
+	{"analytics", `
+SELECT category, bucket, node_count, refreshed
+FROM analytics_aggregates`},
+}
+
+// SandboxViewNames lists the views an ad-hoc query may reference.
+func SandboxViewNames() []string {
+	names := make([]string, 0, len(sandboxViews))
+	for _, view := range sandboxViews {
+		names = append(names, view.name)
+	}
+	return names
+}
+
+// sandboxForbiddenKeywords rejects the statements that could leave the sandbox:
+// writes are already refused by the query_only pragma,
+// but ATTACH could still open other database files,
+// and PRAGMA could lift the query_only guard itself.
+var sandboxForbiddenKeywords = map[string]struct{}{
+	"attach": {}, "detach": {}, "pragma": {}, "vacuum": {}, "reindex": {}, "analyze": {},
+	"insert": {}, "update": {}, "delete": {}, "replace": {},
+	"create": {}, "drop": {}, "alter": {},
+	"begin": {}, "commit": {}, "rollback": {},
+}
+
+var (
+	sandboxIdentifierRegexp    = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	sandboxStringLiteralRegexp = regexp.MustCompile(`'[^']*'`)
+	// SQLite accepts a single-quoted string as a table name after FROM/JOIN
+	sandboxStringTableRegexp = regexp.MustCompile(`(?i)\b(from|join)\s*'`)
+)
+
+// sandboxForbiddenNamePrefixes rejects the built-in schema objects
+// that are not listed in sqlite_master itself: the sqlite_* tables
+// (sqlite_master, sqlite_temp_master) expose the base table DDL,
+// and the pragma_* table-valued functions reach the PRAGMA machinery
+// bypassing the keyword check.
+var sandboxForbiddenNamePrefixes = []string{"sqlite_", "pragma_"}
+
+// validateSandboxQuery checks that the query is a single SELECT statement
+// referencing only the sandbox views: any schema object name (base table,
+// view or index) appearing in it is rejected.
+func validateSandboxQuery(query string, schemaNames map[string]struct{}) error {
+	trimmed := strings.TrimSpace(query)
+	if trimmed == "" {
+		return fmt.Errorf("the query is empty")
+	}
+
+	// string literals count neither as identifiers nor as statement separators,
+	// but SQLite tolerates them as table names, so a literal may not stand
+	// in a table position or spell the name of a schema object
+	if sandboxStringTableRegexp.MatchString(trimmed) {
+		return fmt.Errorf("a string literal is not allowed as a table name")
+	}
+	for _, literal := range sandboxStringLiteralRegexp.FindAllString(trimmed, -1) {
+		content := strings.ToLower(strings.Trim(literal, "'"))
+		if _, isSchemaName := schemaNames[content]; isSchemaName {
+			return fmt.Errorf("a string literal is not allowed to name a schema object")
+		}
+		for _, prefix := range sandboxForbiddenNamePrefixes {
+			if strings.HasPrefix(content, prefix) {
+				return fmt.Errorf("a string literal is not allowed to name a schema object")
+			}
+		}
+	}
+
+	scannable := sandboxStringLiteralRegexp.ReplaceAllString(trimmed, "''")
+	if strings.ContainsRune(scannable, ';') {
+		return fmt.Errorf("expecting a single statement without \";\"")
+	}
+
+	firstWord := strings.ToLower(sandboxIdentifierRegexp.FindString(scannable))
+	if (firstWord != "select") && (firstWord != "with") {
+		return fmt.Errorf("expecting a SELECT query")
+	}
+
+	for _, identifier := range sandboxIdentifierRegexp.FindAllString(scannable, -1) {
+		identifier = strings.ToLower(identifier)
+		if _, forbidden := sandboxForbiddenKeywords[identifier]; forbidden {
+			return fmt.Errorf("keyword %s is not allowed in the sandbox", strings.ToUpper(identifier))
+		}
+		for _, prefix := range sandboxForbiddenNamePrefixes {
+			if strings.HasPrefix(identifier, prefix) {
+				return fmt.Errorf("%s names are not allowed in the sandbox", prefix+"*")
+			}
+		}
+		if _, isSchemaName := schemaNames[identifier]; isSchemaName {
+			return fmt.Errorf("%s is not a sandbox view; the available views are: %s",
+				identifier, strings.Join(SandboxViewNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// RunSandboxedQuery executes one ad-hoc read-only SELECT against the sandbox
+// views with the given timeout and row cap, for power users who need queries
+// the canned reports don't cover, without direct access to the database file.
+// The query runs on a pinned connection in query_only mode,
+// and may reference only the temporary sandbox views, never the base tables.
+// Only the SQLite backend supports it.
+func RunSandboxedQuery(ctx context.Context, db DB, query string, timeout time.Duration, maxRows uint) (*SandboxQueryResult, error) {
+	if _, ok := db.(*DBPostgres); ok {
+		return nil, fmt.Errorf("the SQL sandbox supports only the SQLite backend")
+	}
+	sqlite, err := rawDB(db)
+	if err != nil {
+		return nil, err
+	}
+
+	schemaNames, err := sqlite.findSchemaObjectNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSandboxQuery(query, schemaNames); err != nil {
+		return nil, err
+	}
+
+	conn, err := sqlite.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a sandbox connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// the views must exist before query_only blocks changes to the temp schema
+	for _, view := range sandboxViews {
+		createView := fmt.Sprintf("CREATE TEMP VIEW IF NOT EXISTS %s AS %s", view.name, view.definition)
+		if _, err := conn.ExecContext(ctx, createView); err != nil {
+			return nil, fmt.Errorf("failed to create sandbox view %s: %w", view.name, err)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, fmt.Errorf("failed to enter the read-only mode: %w", err)
+	}
+	// the connection goes back to the shared pool afterwards
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+		for _, view := range sandboxViews {
+			_, _ = conn.ExecContext(context.Background(), "DROP VIEW IF EXISTS temp."+view.name)
+		}
+	}()
+
+	queryContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cursor, err := conn.QueryContext(queryContext, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the query: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	columns, err := cursor.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the query columns: %w", err)
+	}
+
+	result := SandboxQueryResult{Columns: columns}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for cursor.Next() {
+		if uint(len(result.Rows)) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		if err := cursor.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to read a query row: %w", err)
+		}
+		row := make([]string, len(columns))
+		for i, value := range values {
+			row[i] = formatSandboxValue(value)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the query rows: %w", err)
+	}
+	return &result, nil
+}
+
+// findSchemaObjectNames lists the schema objects an ad-hoc query must not reference.
+func (db *DBSQLite) findSchemaObjectNames(ctx context.Context) (map[string]struct{}, error) {
+	cursor, err := db.db.QueryContext(ctx, "SELECT name FROM sqlite_master")
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate the schema objects: %w", err)
+	}
+	defer func() { _ = cursor.Close() }()
+
+	names := make(map[string]struct{})
+	for cursor.Next() {
+		var name string
+		if err := cursor.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read a schema object name: %w", err)
+		}
+		names[strings.ToLower(name)] = struct{}{}
+	}
+	return names, cursor.Err()
+}
+
+// formatSandboxValue renders a database value for the result table,
+// with NULL as an empty string.
+func formatSandboxValue(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(typed)
+	default:
+		return fmt.Sprint(typed)
+	}
+}
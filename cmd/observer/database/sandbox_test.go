@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSandboxQuery(t *testing.T) {
+	schemaNames := map[string]struct{}{
+		"nodes":                {},
+		"node_client_info":     {},
+		"idx_nodes_ip":         {},
+		"crawl_frontier":       {},
+		"node_history":         {},
+		"handshake_attempts":   {},
+		"analytics_aggregates": {},
+	}
+
+	allowed := []string{
+		"SELECT * FROM node_overview LIMIT 10",
+		"SELECT client_id, COUNT(*) FROM node_overview GROUP BY client_id",
+		"WITH top AS (SELECT * FROM client_info) SELECT * FROM top",
+		"SELECT * FROM node_overview WHERE country = 'DE'",
+	}
+	for _, query := range allowed {
+		assert.NoError(t, validateSandboxQuery(query, schemaNames), query)
+	}
+
+	rejected := []string{
+		"",
+		"DELETE FROM nodes",
+		"SELECT * FROM node_overview; DROP TABLE nodes",
+		"PRAGMA query_only = OFF",
+		"SELECT * FROM nodes",
+		"SELECT * FROM crawl_frontier",
+		// SQLite accepts a quoted string as a table name
+		"SELECT * FROM 'nodes'",
+		"SELECT * FROM node_overview, 'nodes'",
+		"SELECT * FROM 'sqlite_master'",
+		// the built-in schema tables are not listed in sqlite_master itself
+		"SELECT * FROM sqlite_master",
+		"SELECT * FROM sqlite_temp_master",
+		"SELECT sql FROM sqlite_schema",
+		// the pragma_* table-valued functions bypass the PRAGMA keyword check
+		"SELECT * FROM pragma_database_list()",
+		"SELECT * FROM pragma_table_info('node_overview')",
+	}
+	for _, query := range rejected {
+		assert.Error(t, validateSandboxQuery(query, schemaNames), query)
+	}
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// snapshotTables are the tables included in a lite snapshot:
+// the latest state per node without any history or operational bookkeeping.
+var snapshotTables = []string{
+	"nodes",
+	"node_client_info",
+	"node_capabilities",
+	"node_enr",
+	"node_enr_entries",
+	"node_seen_windows",
+}
+
+// SnapshotDB writes a small single-file SQLite snapshot of the source database
+// containing only the current-state tables (see snapshotTables).
+// History tables like handshake_attempts are left out, so the snapshot stays
+// small enough to ship to analysts and dashboards that don't need full history.
+// The target file must not exist yet.
+func SnapshotDB(ctx context.Context, source DB, targetPath string, progress CopyProgress) error {
+	sourceRaw, err := rawDB(source)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(targetPath); err == nil {
+		return fmt.Errorf("the snapshot file already exists: %s", targetPath)
+	}
+
+	target, err := NewDBSQLite(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to create the snapshot database: %w", err)
+	}
+	defer func() { _ = target.Close() }()
+
+	tables, err := source.DescribeSchema(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if !isSnapshotTable(table.Name) {
+			continue
+		}
+		if err := copyTable(ctx, sourceRaw, target, table, progress); err != nil {
+			return fmt.Errorf("failed to snapshot table %s: %w", table.Name, err)
+		}
+	}
+	return target.Vacuum(ctx)
+}
+
+func isSnapshotTable(name string) bool {
+	for _, tableName := range snapshotTables {
+		if tableName == name {
+			return true
+		}
+	}
+	return false
+}
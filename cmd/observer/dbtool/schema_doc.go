@@ -0,0 +1,69 @@
+package dbtool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// FormatSchemaDoc renders a data dictionary of the live schema,
+// either as Markdown or as plain text.
+// It is generated from the introspected schema so that it never drifts from the code.
+func FormatSchemaDoc(tables []database.SchemaTable, markdown bool) string {
+	if markdown {
+		return formatSchemaDocMarkdown(tables)
+	}
+	return formatSchemaDocPlain(tables)
+}
+
+func formatSchemaDocMarkdown(tables []database.SchemaTable) string {
+	var builder strings.Builder
+	builder.WriteString("# Data dictionary\n")
+
+	for _, table := range tables {
+		builder.WriteString(fmt.Sprintf("\n## %s\n\n", table.Name))
+		builder.WriteString(fmt.Sprintf("Rows: %d\n\n", table.RowCount))
+
+		builder.WriteString("| Column | Type | Constraints |\n")
+		builder.WriteString("|--------|------|-------------|\n")
+		for _, column := range table.Columns {
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s |\n",
+				column.Name, column.Type, columnConstraints(column)))
+		}
+
+		if len(table.Indexes) > 0 {
+			builder.WriteString(fmt.Sprintf("\nIndexes: %s\n", strings.Join(table.Indexes, ", ")))
+		}
+	}
+	return builder.String()
+}
+
+func formatSchemaDocPlain(tables []database.SchemaTable) string {
+	var builder strings.Builder
+	for _, table := range tables {
+		builder.WriteString(fmt.Sprintf("%s (%d rows)\n", table.Name, table.RowCount))
+		for _, column := range table.Columns {
+			constraints := columnConstraints(column)
+			if constraints != "" {
+				constraints = " " + constraints
+			}
+			builder.WriteString(fmt.Sprintf("    %s %s%s\n", column.Name, column.Type, constraints))
+		}
+		for _, index := range table.Indexes {
+			builder.WriteString(fmt.Sprintf("    index %s\n", index))
+		}
+	}
+	return builder.String()
+}
+
+func columnConstraints(column database.SchemaColumn) string {
+	var constraints []string
+	if column.IsPrimaryKey {
+		constraints = append(constraints, "PRIMARY KEY")
+	}
+	if column.NotNull {
+		constraints = append(constraints, "NOT NULL")
+	}
+	return strings.Join(constraints, ", ")
+}
@@ -0,0 +1,99 @@
+package dnstree
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/ledgerwatch/erigon/params/networkname"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+	Chain   string
+
+	// Domain is the DNS domain the tree is published under.
+	Domain string
+	// Limit is how many top nodes to include in the tree.
+	Limit uint
+	// KeyFile is the tree signing key file.
+	// An empty value defaults to "dnstree-key" in the data directory.
+	KeyFile string
+	// OutputFile is where the TXT records are written.
+	// An empty value defaults to "dnstree.json" in the data directory.
+	OutputFile string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "dnstree",
+		Short: "Publish the most reliable fork-compatible nodes as an EIP-1459 DNS node tree",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withChain()
+	instance.withDomain()
+	instance.withLimit()
+	instance.withKeyFile()
+	instance.withOutputFile()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withChain() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Chain, utils.ChainFlag.Name, networkname.MainnetChainName, utils.ChainFlag.Usage)
+}
+
+func (command *Command) withDomain() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Domain, "domain", "", "DNS domain to publish the tree under")
+	if err := command.command.MarkFlagRequired("domain"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withLimit() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.Limit, "limit", 100, "how many top nodes to include in the tree")
+}
+
+func (command *Command) withKeyFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.KeyFile, "key-file", "",
+		"tree signing key file (default \"dnstree-key\" in the data directory)")
+}
+
+func (command *Command) withOutputFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.OutputFile, "output", "",
+		"TXT records output file (default \"dnstree.json\" in the data directory)")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
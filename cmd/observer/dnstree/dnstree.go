@@ -0,0 +1,193 @@
+package dnstree
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/bootnodes"
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/p2p/dnsdisc"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/erigon/p2p/enr"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// Result describes a published DNS discovery tree (EIP-1459).
+type Result struct {
+	// URL is the enrtree:// link of the tree, derived from the signing key and the domain.
+	// It is stable across publishes as long as the key and the domain stay the same.
+	URL string
+	// Seq is the sequence number of this version of the tree.
+	Seq uint
+	// NodeCount is how many node records the tree contains.
+	NodeCount int
+	// Changed reports whether the node set differs from the previously published tree,
+	// in which case Seq was incremented.
+	Changed bool
+	// Records maps DNS names to the TXT record contents of the tree.
+	Records map[string]string
+}
+
+// Publish builds an EIP-1459 DNS node tree from the most reliable,
+// fork-compatible nodes of the chain, signs it with the given key,
+// and writes the TXT records as a JSON object to outputPath.
+//
+// Only nodes with a captured signed record are included:
+// the tree re-serves the records verbatim, so clients can verify
+// the signatures of the nodes themselves.
+//
+// Publishing is incremental: the sequence number is read back
+// from the previous output file and incremented only if the node set changed,
+// so resolvers and caches are not invalidated by a republish of the same tree.
+func Publish(
+	ctx context.Context,
+	db database.DB,
+	chain string,
+	domain string,
+	limit uint,
+	key *ecdsa.PrivateKey,
+	outputPath string,
+) (*Result, error) {
+	nodes, err := treeNodes(ctx, db, chain, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no compatible nodes with captured records to publish")
+	}
+
+	prevSeq, prevERoot, prevLRoot := previousRoot(outputPath, domain)
+
+	tree, err := dnsdisc.MakeTree(prevSeq, nodes, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make a node tree: %w", err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the node tree: %w", err)
+	}
+
+	records := tree.ToTXT(domain)
+	eRoot, lRoot, err := parseRootHashes(records[domain])
+	if err != nil {
+		return nil, err
+	}
+
+	changed := (eRoot != prevERoot) || (lRoot != prevLRoot)
+	if changed {
+		// The content changed: rebuild with the next sequence number,
+		// so that syncing clients notice the update.
+		tree, err = dnsdisc.MakeTree(prevSeq+1, nodes, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make a node tree: %w", err)
+		}
+		url, err = tree.Sign(key, domain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign the node tree: %w", err)
+		}
+		records = tree.ToTXT(domain)
+	}
+
+	if err := writeRecords(outputPath, records); err != nil {
+		return nil, err
+	}
+
+	result := Result{
+		URL:       url,
+		Seq:       tree.Seq(),
+		NodeCount: len(nodes),
+		Changed:   changed,
+		Records:   records,
+	}
+	return &result, nil
+}
+
+// treeNodes selects the nodes of the tree: the most reliable,
+// fork-compatible nodes of the chain (see database.DB.FindBootnodeCandidates)
+// that have a captured signed record. Nodes whose records
+// fail to decode or verify are skipped.
+func treeNodes(ctx context.Context, db database.DB, chain string, limit uint) ([]*enode.Node, error) {
+	forkIDHashes, err := bootnodes.ChainForkIDHashes(chain)
+	if err != nil {
+		return nil, err
+	}
+	networkID := uint(params.NetworkIDByChainName(chain))
+
+	ids, err := db.FindBootnodeCandidates(ctx, networkID, forkIDHashes, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*enode.Node, 0, len(ids))
+	for _, id := range ids {
+		nodeENR, err := db.FindNodeENR(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if nodeENR == nil {
+			continue
+		}
+
+		record := new(enr.Record)
+		if err := rlp.DecodeBytes(nodeENR.Record, record); err != nil {
+			continue
+		}
+		node, err := enode.New(enode.ValidSchemes, record)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// previousRoot recovers the sequence number and the subtree hashes
+// of the previously published tree from the output file.
+// If there is no previous output, the first publish starts at sequence number 1.
+func previousRoot(outputPath string, domain string) (seq uint, eRoot string, lRoot string) {
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return 0, "", ""
+	}
+	var records map[string]string
+	if err := json.Unmarshal(data, &records); err != nil {
+		return 0, "", ""
+	}
+	eRoot, lRoot, seqValue, err := parseRoot(records[domain])
+	if err != nil {
+		return 0, "", ""
+	}
+	return seqValue, eRoot, lRoot
+}
+
+func parseRootHashes(root string) (eRoot string, lRoot string, err error) {
+	eRoot, lRoot, _, err = parseRoot(root)
+	return eRoot, lRoot, err
+}
+
+func parseRoot(root string) (eRoot string, lRoot string, seq uint, err error) {
+	var sig string
+	_, err = fmt.Sscanf(root, "enrtree-root:v1 e=%s l=%s seq=%d sig=%s", &eRoot, &lRoot, &seq, &sig)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to parse the tree root record: %w", err)
+	}
+	return eRoot, lRoot, seq, nil
+}
+
+// writeRecords stores the TXT records as a JSON object mapping DNS names
+// to record contents, ready to feed to a DNS provisioning tool.
+func writeRecords(outputPath string, records map[string]string) error {
+	data, err := json.MarshalIndent(records, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode the TXT records: %w", err)
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write the TXT records: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package enrich
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir   string
+	Stages    []string
+	BatchSize uint
+
+	// GeoIPCityFile and GeoIPASNFile enable the "geo" stage (see GeoStage).
+	GeoIPCityFile string
+	GeoIPASNFile  string
+
+	// RulesFile enables the "rules" stage (see RulesStage).
+	RulesFile string
+
+	// ReputationProviders and the provider credentials below
+	// enable the "reputation" stage (see ReputationStage).
+	ReputationProviders []string
+	AbuseIPDBKey        string
+	GreyNoiseKey        string
+	ReputationListFile  string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "enrich",
+		Short: "Backfill enrichment data for the existing nodes in the database",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withStages()
+	instance.withBatchSize()
+	instance.withGeoIP()
+	instance.withRules()
+	instance.withReputation()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withStages() {
+	flags := command.command.Flags()
+	flags.StringSliceVar(&command.flags.Stages, "stages", []string{"parse"},
+		"enrichment stages to run (comma separated)")
+}
+
+func (command *Command) withBatchSize() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.BatchSize, "batch-size", 1000, "how many nodes to process between progress checkpoints")
+}
+
+func (command *Command) withGeoIP() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.GeoIPCityFile, "geoip-city-file", "",
+		"MaxMind GeoLite2 City database for the geo stage (empty - no country/city resolution)")
+	flags.StringVar(&command.flags.GeoIPASNFile, "geoip-asn-file", "",
+		"MaxMind GeoLite2 ASN database for the geo stage (empty - no ASN resolution)")
+}
+
+func (command *Command) withRules() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.RulesFile, "rules-file", "",
+		"YAML classification rules file for the rules stage (empty - no classification)")
+	if err := command.command.MarkFlagFilename("rules-file"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withReputation() {
+	flags := command.command.Flags()
+	flags.StringSliceVar(&command.flags.ReputationProviders, "reputation-providers", nil,
+		"IP reputation providers for the reputation stage: abuseipdb, greynoise, list (comma separated)")
+	flags.StringVar(&command.flags.AbuseIPDBKey, "abuseipdb-key", "", "AbuseIPDB API key")
+	flags.StringVar(&command.flags.GreyNoiseKey, "greynoise-key", "", "GreyNoise API key")
+	flags.StringVar(&command.flags.ReputationListFile, "reputation-list-file", "",
+		"internal IP reputation list file with one \"IP-or-CIDR [score]\" entry per line")
+	if err := command.command.MarkFlagFilename("reputation-list-file"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
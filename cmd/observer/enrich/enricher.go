@@ -0,0 +1,104 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Enricher runs enrichment stages over all existing nodes in resumable batches.
+// The progress of each stage is persisted, so an interrupted run
+// continues from where it stopped.
+type Enricher struct {
+	db        database.DB
+	stages    []Stage
+	batchSize uint
+	log       log.Logger
+}
+
+func NewEnricher(db database.DB, stages []Stage, batchSize uint, logger log.Logger) *Enricher {
+	instance := Enricher{
+		db,
+		stages,
+		batchSize,
+		logger,
+	}
+	return &instance
+}
+
+func (enricher *Enricher) Run(ctx context.Context) error {
+	for _, stage := range enricher.stages {
+		if err := enricher.runStage(ctx, stage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackgroundLoop re-runs the stages periodically while the crawler fills the database,
+// so that the fresh nodes get enriched without a separate backfill run.
+func BackgroundLoop(ctx context.Context, db database.DB, stages []Stage, batchSize uint, period time.Duration, logger log.Logger) {
+	enricher := NewEnricher(db, stages, batchSize, logger)
+	for ctx.Err() == nil {
+		if err := enricher.Run(ctx); (err != nil) && !errors.Is(err, context.Canceled) {
+			logger.Error("Background enrichment failed", "err", err)
+		}
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+	}
+}
+
+func (enricher *Enricher) runStage(ctx context.Context, stage Stage) error {
+	logger := enricher.log.New("stage", stage.Name())
+
+	var afterID database.NodeID
+	lastID, err := enricher.db.FindEnrichProgress(ctx, stage.Name())
+	if err != nil {
+		return err
+	}
+	if lastID != nil {
+		afterID = *lastID
+		logger.Info("Resuming enrichment", "after", afterID)
+	}
+
+	startTime := time.Now()
+	var doneCount uint
+
+	for ctx.Err() == nil {
+		ids, err := enricher.db.EnumerateNodeIDsAfter(ctx, afterID, enricher.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		for _, id := range ids {
+			if err := stage.Enrich(ctx, enricher.db, id); err != nil {
+				return err
+			}
+			doneCount++
+		}
+
+		afterID = ids[len(ids)-1]
+		if err := enricher.db.UpdateEnrichProgress(ctx, stage.Name(), afterID); err != nil {
+			return err
+		}
+		logger.Info("Enrichment progress", "nodes", doneCount, "elapsed", time.Since(startTime).Round(time.Second))
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	// the stage is complete - a future run starts from scratch
+	if err := enricher.db.DeleteEnrichProgress(ctx, stage.Name()); err != nil {
+		return err
+	}
+	logger.Info("Enrichment done", "nodes", doneCount, "elapsed", time.Since(startTime).Round(time.Second))
+	return nil
+}
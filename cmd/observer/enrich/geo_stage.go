@@ -0,0 +1,117 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// GeoStage resolves the IP of a node to a country, city and ASN
+// using the MaxMind GeoLite2 City and ASN databases
+// (https://dev.maxmind.com/geoip/geolite2-free-geolocation-data).
+// Either database may be omitted; the stage fills in what it can resolve.
+type GeoStage struct {
+	cityDB *mmdbReader
+	asnDB  *mmdbReader
+}
+
+func NewGeoStage(cityFile string, asnFile string) (*GeoStage, error) {
+	if (cityFile == "") && (asnFile == "") {
+		return nil, fmt.Errorf("the geo stage needs a GeoLite2 City or ASN database file")
+	}
+
+	var instance GeoStage
+	var err error
+	if cityFile != "" {
+		if instance.cityDB, err = openMMDB(cityFile); err != nil {
+			return nil, err
+		}
+	}
+	if asnFile != "" {
+		if instance.asnDB, err = openMMDB(asnFile); err != nil {
+			return nil, err
+		}
+	}
+	return &instance, nil
+}
+
+func (stage *GeoStage) Name() string {
+	return "geo"
+}
+
+func (stage *GeoStage) Enrich(ctx context.Context, db database.DB, id database.NodeID) error {
+	// the database tracks the geo TTL, so periodic re-runs skip the fresh nodes
+	fresh, err := db.IsEnrichmentFresh(ctx, id, database.EnrichmentAttributeGeo)
+	if err != nil {
+		return err
+	}
+	if fresh {
+		return nil
+	}
+
+	addr, err := db.FindNodeAddr(ctx, id)
+	if err != nil {
+		return err
+	}
+	if addr == nil {
+		return nil
+	}
+	ip := addr.IP
+	if ip == nil {
+		ip = addr.IPv6.IP
+	}
+	if ip == nil {
+		return nil
+	}
+
+	geo, err := stage.resolve(ip)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the geo location: %w", err)
+	}
+	if (geo.Country == "") && (geo.City == "") && (geo.ASN == 0) {
+		return nil
+	}
+	if err := db.UpdateNodeGeo(ctx, id, geo); err != nil {
+		return err
+	}
+	return db.MarkEnrichmentFresh(ctx, id, database.EnrichmentAttributeGeo)
+}
+
+func (stage *GeoStage) resolve(ip net.IP) (database.NodeGeo, error) {
+	var geo database.NodeGeo
+
+	if stage.cityDB != nil {
+		record, err := stage.cityDB.Lookup(ip)
+		if err != nil {
+			return geo, err
+		}
+		geo.Country = lookupString(record, "country", "iso_code")
+		geo.City = lookupString(record, "city", "names", "en")
+	}
+
+	if stage.asnDB != nil {
+		record, err := stage.asnDB.Lookup(ip)
+		if err != nil {
+			return geo, err
+		}
+		if asn, ok := record["autonomous_system_number"].(uint64); ok {
+			geo.ASN = uint(asn)
+		}
+	}
+
+	return geo, nil
+}
+
+// lookupString digs a string out of a nested record map, e.g. "city" / "names" / "en".
+func lookupString(record map[string]interface{}, path ...string) string {
+	for _, key := range path[:len(path)-1] {
+		record, _ = record[key].(map[string]interface{})
+		if record == nil {
+			return ""
+		}
+	}
+	value, _ := record[path[len(path)-1]].(string)
+	return value
+}
@@ -0,0 +1,286 @@
+package enrich
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+)
+
+// mmdbReader is a minimal reader of the MaxMind DB binary format
+// (https://maxmind.github.io/MaxMind-DB/), as used by the GeoLite2 databases.
+// It covers only what GeoStage needs (maps, strings, unsigned integers),
+// which keeps the observer free of an external GeoIP dependency.
+type mmdbReader struct {
+	data []byte
+
+	nodeCount      uint32
+	recordSize     uint
+	ipVersion      uint
+	searchTreeSize uint32
+}
+
+// mmdbMetadataMarker precedes the metadata map at the end of the file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+func openMMDB(filePath string) (*mmdbReader, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the MMDB file: %w", err)
+	}
+
+	markerStart := bytes.LastIndex(data, mmdbMetadataMarker)
+	if markerStart < 0 {
+		return nil, fmt.Errorf("failed to parse %s: no MMDB metadata found", filePath)
+	}
+
+	reader := mmdbReader{data: data}
+	metadataAny, _, err := reader.decodeValue(uint32(markerStart + len(mmdbMetadataMarker)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s metadata: %w", filePath, err)
+	}
+	metadata, ok := metadataAny.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to parse %s: unexpected metadata type", filePath)
+	}
+
+	nodeCount, ok := metadata["node_count"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse %s: no node_count", filePath)
+	}
+	recordSize, ok := metadata["record_size"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse %s: no record_size", filePath)
+	}
+	ipVersion, ok := metadata["ip_version"].(uint64)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse %s: no ip_version", filePath)
+	}
+	if (recordSize != 24) && (recordSize != 28) && (recordSize != 32) {
+		return nil, fmt.Errorf("failed to parse %s: unsupported record size %d", filePath, recordSize)
+	}
+
+	reader.nodeCount = uint32(nodeCount)
+	reader.recordSize = uint(recordSize)
+	reader.ipVersion = uint(ipVersion)
+	reader.searchTreeSize = reader.nodeCount * uint32(reader.recordSize) / 4
+	return &reader, nil
+}
+
+// Lookup walks the search tree with the IP bits and decodes the record it lands on.
+// It returns nil if the database has no data for the IP.
+func (reader *mmdbReader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	bits := ip.To16()
+	if bits == nil {
+		return nil, fmt.Errorf("invalid IP")
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		if reader.ipVersion == 6 {
+			// an IPv4 address sits under the 96 zero bits prefix of the IPv6 tree
+			bits = append(make(net.IP, 12), ipv4...)
+		} else {
+			bits = ipv4
+		}
+	} else if reader.ipVersion == 4 {
+		return nil, nil
+	}
+
+	var node uint32
+	for _, octet := range bits {
+		for bitIndex := 7; bitIndex >= 0; bitIndex-- {
+			if node >= reader.nodeCount {
+				break
+			}
+			bit := (octet >> uint(bitIndex)) & 1
+			var err error
+			node, err = reader.readNodeRecord(node, bit)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if node == reader.nodeCount {
+		// an empty record: the IP is not in the database
+		return nil, nil
+	}
+	if node < reader.nodeCount {
+		return nil, fmt.Errorf("invalid MMDB search tree")
+	}
+
+	dataOffset := reader.searchTreeSize + (node - reader.nodeCount)
+	valueAny, _, err := reader.decodeValue(dataOffset)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := valueAny.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected MMDB record type")
+	}
+	return value, nil
+}
+
+// readNodeRecord reads the left (bit 0) or right (bit 1) record of a search tree node.
+func (reader *mmdbReader) readNodeRecord(node uint32, bit byte) (uint32, error) {
+	nodeStart := uint64(node) * uint64(reader.recordSize) / 4
+	if nodeStart+uint64(reader.recordSize)/4 > uint64(len(reader.data)) {
+		return 0, fmt.Errorf("invalid MMDB node %d", node)
+	}
+	data := reader.data[nodeStart:]
+
+	switch reader.recordSize {
+	case 24:
+		if bit == 0 {
+			return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2]), nil
+		}
+		return uint32(data[3])<<16 | uint32(data[4])<<8 | uint32(data[5]), nil
+	case 28:
+		if bit == 0 {
+			return uint32(data[3]>>4)<<24 | uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2]), nil
+		}
+		return uint32(data[3]&0x0F)<<24 | uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6]), nil
+	default: // 32
+		if bit == 0 {
+			return binary.BigEndian.Uint32(data), nil
+		}
+		return binary.BigEndian.Uint32(data[4:]), nil
+	}
+}
+
+// The MMDB data field types (see the format specification).
+const (
+	mmdbTypePointer  = 1
+	mmdbTypeString   = 2
+	mmdbTypeBytes    = 4
+	mmdbTypeUint16   = 5
+	mmdbTypeUint32   = 6
+	mmdbTypeMap      = 7
+	mmdbTypeInt32    = 8
+	mmdbTypeUint64   = 9
+	mmdbTypeUint128  = 10
+	mmdbTypeArray    = 11
+	mmdbTypeBool     = 14
+	mmdbTypeExtended = 0
+)
+
+// decodeValue decodes the data field at the offset,
+// and returns it together with the offset right after the field.
+// The unsigned integer types are widened to uint64, and doubles/floats
+// are returned as raw bytes, since the GeoStage does not use them.
+func (reader *mmdbReader) decodeValue(offset uint32) (interface{}, uint32, error) {
+	if offset >= uint32(len(reader.data)) {
+		return nil, 0, fmt.Errorf("invalid MMDB data offset %d", offset)
+	}
+	controlByte := reader.data[offset]
+	offset++
+
+	fieldType := uint(controlByte >> 5)
+	if fieldType == mmdbTypeExtended {
+		fieldType = uint(reader.data[offset]) + 7
+		offset++
+	}
+
+	if fieldType == mmdbTypePointer {
+		return reader.decodePointer(controlByte, offset)
+	}
+
+	size := uint32(controlByte & 0x1F)
+	switch size {
+	case 29:
+		size = 29 + uint32(reader.data[offset])
+		offset++
+	case 30:
+		size = 285 + uint32(reader.data[offset])<<8 + uint32(reader.data[offset+1])
+		offset += 2
+	case 31:
+		size = 65821 + uint32(reader.data[offset])<<16 + uint32(reader.data[offset+1])<<8 + uint32(reader.data[offset+2])
+		offset += 3
+	}
+	if uint64(offset)+uint64(size) > uint64(len(reader.data)) {
+		return nil, 0, fmt.Errorf("invalid MMDB field size %d at offset %d", size, offset)
+	}
+
+	switch fieldType {
+	case mmdbTypeString:
+		return string(reader.data[offset : offset+size]), offset + size, nil
+	case mmdbTypeUint16, mmdbTypeUint32, mmdbTypeUint64:
+		var value uint64
+		for _, octet := range reader.data[offset : offset+size] {
+			value = value<<8 | uint64(octet)
+		}
+		return value, offset + size, nil
+	case mmdbTypeInt32:
+		var value uint64
+		for _, octet := range reader.data[offset : offset+size] {
+			value = value<<8 | uint64(octet)
+		}
+		return int64(int32(value)), offset + size, nil
+	case mmdbTypeBool:
+		return size != 0, offset, nil
+	case mmdbTypeMap:
+		value := make(map[string]interface{}, size)
+		for i := uint32(0); i < size; i++ {
+			keyAny, keyEnd, err := reader.decodeValue(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := keyAny.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("unexpected MMDB map key type at offset %d", offset)
+			}
+			item, itemEnd, err := reader.decodeValue(keyEnd)
+			if err != nil {
+				return nil, 0, err
+			}
+			value[key] = item
+			offset = itemEnd
+		}
+		return value, offset, nil
+	case mmdbTypeArray:
+		value := make([]interface{}, 0, size)
+		for i := uint32(0); i < size; i++ {
+			item, itemEnd, err := reader.decodeValue(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			value = append(value, item)
+			offset = itemEnd
+		}
+		return value, offset, nil
+	default:
+		// bytes, doubles, floats and uint128 values are passed through raw
+		return reader.data[offset : offset+size], offset + size, nil
+	}
+}
+
+// decodePointer resolves a pointer field to the value it references.
+// Pointers are relative to the data section, which starts 16 bytes
+// (the data section separator) past the search tree.
+func (reader *mmdbReader) decodePointer(controlByte byte, offset uint32) (interface{}, uint32, error) {
+	pointerSize := uint32(controlByte>>3) & 0x3
+	prefix := uint32(controlByte & 0x7)
+	if uint64(offset)+uint64(pointerSize)+1 > uint64(len(reader.data)) {
+		return nil, 0, fmt.Errorf("invalid MMDB pointer at offset %d", offset)
+	}
+
+	var pointer uint32
+	switch pointerSize {
+	case 0:
+		pointer = prefix<<8 | uint32(reader.data[offset])
+	case 1:
+		pointer = (prefix<<16 | uint32(reader.data[offset])<<8 | uint32(reader.data[offset+1])) + 2048
+	case 2:
+		pointer = (prefix<<24 | uint32(reader.data[offset])<<16 | uint32(reader.data[offset+1])<<8 |
+			uint32(reader.data[offset+2])) + 526336
+	case 3:
+		pointer = binary.BigEndian.Uint32(reader.data[offset:])
+	}
+	nextOffset := offset + pointerSize + 1
+
+	value, _, err := reader.decodeValue(reader.searchTreeSize + 16 + pointer)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, nextOffset, nil
+}
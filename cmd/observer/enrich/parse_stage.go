@@ -0,0 +1,48 @@
+package enrich
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// ParseStage splits the raw client ID of a node
+// into the name, version, OS and architecture parts.
+type ParseStage struct{}
+
+func (ParseStage) Name() string {
+	return "parse"
+}
+
+func (stage ParseStage) Enrich(ctx context.Context, db database.DB, id database.NodeID) error {
+	clientID, err := db.FindClientID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if clientID == nil {
+		return nil
+	}
+	return db.UpsertNodeClientInfo(ctx, id, ParseClientID(*clientID))
+}
+
+// ParseClientID parses a client ID like "Geth/v1.10.15-stable/linux-amd64/go1.17.5".
+// Unrecognized parts are left empty.
+func ParseClientID(clientID string) database.ClientInfo {
+	var info database.ClientInfo
+
+	parts := strings.Split(clientID, "/")
+	info.Name = parts[0]
+
+	for _, part := range parts[1:] {
+		switch {
+		case (info.Version == "") && strings.HasPrefix(part, "v"):
+			info.Version = part
+		case (info.OS == "") && strings.Contains(part, "-"):
+			osArch := strings.SplitN(part, "-", 2)
+			info.OS = osArch[0]
+			info.Arch = osArch[1]
+		}
+	}
+	return info
+}
@@ -0,0 +1,252 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// IPReputation scores the abuse reputation of an IP address.
+// Implementations query an external service or a local list;
+// the "reputation" enrichment stage caches their results in the database
+// (see ReputationStage).
+type IPReputation interface {
+	Name() string
+	// Score returns the abuse confidence in [0, 1], with 0 meaning clean.
+	Score(ctx context.Context, ip net.IP) (float64, error)
+}
+
+// MakeIPReputations resolves a list of provider names like "abuseipdb,list".
+func MakeIPReputations(names []string, options StageOptions) ([]IPReputation, error) {
+	providers := make([]IPReputation, 0, len(names))
+	for _, name := range names {
+		provider, err := makeIPReputation(strings.TrimSpace(name), options)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+func makeIPReputation(name string, options StageOptions) (IPReputation, error) {
+	switch name {
+	case "abuseipdb":
+		return NewAbuseIPDBReputation(options.AbuseIPDBKey)
+	case "greynoise":
+		return NewGreyNoiseReputation(options.GreyNoiseKey)
+	case "list":
+		return NewListReputation(options.ReputationListFile)
+	default:
+		return nil, fmt.Errorf("unknown IP reputation provider: %s", name)
+	}
+}
+
+const reputationRequestTimeout = 30 * time.Second
+
+// AbuseIPDBReputation queries the AbuseIPDB check API
+// (https://docs.abuseipdb.com/#check-endpoint) and maps
+// its abuse confidence percentage to [0, 1].
+type AbuseIPDBReputation struct {
+	apiKey     string
+	apiURL     string
+	httpClient http.Client
+}
+
+func NewAbuseIPDBReputation(apiKey string) (*AbuseIPDBReputation, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("the abuseipdb provider needs an API key")
+	}
+	instance := AbuseIPDBReputation{
+		apiKey:     apiKey,
+		apiURL:     "https://api.abuseipdb.com/api/v2/check",
+		httpClient: http.Client{Timeout: reputationRequestTimeout},
+	}
+	return &instance, nil
+}
+
+func (provider *AbuseIPDBReputation) Name() string {
+	return "abuseipdb"
+}
+
+func (provider *AbuseIPDBReputation) Score(ctx context.Context, ip net.IP) (float64, error) {
+	requestURL := provider.apiURL + "?ipAddress=" + url.QueryEscape(ip.String())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make an abuseipdb request: %w", err)
+	}
+	request.Header.Set("Key", provider.apiKey)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query abuseipdb: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected abuseipdb response status: %s", response.Status)
+	}
+
+	var body struct {
+		Data struct {
+			AbuseConfidenceScore float64 `json:"abuseConfidenceScore"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode an abuseipdb response: %w", err)
+	}
+	return body.Data.AbuseConfidenceScore / 100, nil
+}
+
+// GreyNoiseReputation queries the GreyNoise community API
+// (https://docs.greynoise.io/reference/get_v3-community-ip) and maps
+// its classification to a score: "malicious" is 1, "benign" is 0,
+// and an unclassified noisy scanner counts as 0.5.
+type GreyNoiseReputation struct {
+	apiKey     string
+	apiURL     string
+	httpClient http.Client
+}
+
+func NewGreyNoiseReputation(apiKey string) (*GreyNoiseReputation, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("the greynoise provider needs an API key")
+	}
+	instance := GreyNoiseReputation{
+		apiKey:     apiKey,
+		apiURL:     "https://api.greynoise.io/v3/community/",
+		httpClient: http.Client{Timeout: reputationRequestTimeout},
+	}
+	return &instance, nil
+}
+
+func (provider *GreyNoiseReputation) Name() string {
+	return "greynoise"
+}
+
+func (provider *GreyNoiseReputation) Score(ctx context.Context, ip net.IP) (float64, error) {
+	requestURL := provider.apiURL + url.PathEscape(ip.String())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make a greynoise request: %w", err)
+	}
+	request.Header.Set("key", provider.apiKey)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := provider.httpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query greynoise: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	// an IP unknown to GreyNoise has not been seen scanning - treat it as clean
+	if response.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected greynoise response status: %s", response.Status)
+	}
+
+	var body struct {
+		Noise          bool   `json:"noise"`
+		Classification string `json:"classification"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode a greynoise response: %w", err)
+	}
+
+	switch body.Classification {
+	case "malicious":
+		return 1, nil
+	case "benign":
+		return 0, nil
+	}
+	if body.Noise {
+		return 0.5, nil
+	}
+	return 0, nil
+}
+
+// ListReputation scores the IPs against an internal list file,
+// with one "<IP or CIDR> [score]" entry per line (the score defaults to 1).
+// Blank lines and lines starting with # are skipped.
+// An IP not on the list scores 0.
+type ListReputation struct {
+	entries []listReputationEntry
+}
+
+type listReputationEntry struct {
+	subnet *net.IPNet
+	score  float64
+}
+
+func NewListReputation(listFile string) (*ListReputation, error) {
+	if listFile == "" {
+		return nil, fmt.Errorf("the list provider needs a list file")
+	}
+	data, err := os.ReadFile(listFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the reputation list: %w", err)
+	}
+
+	var instance ListReputation
+	for lineNum, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseListReputationEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reputation list line %d: %w", lineNum+1, err)
+		}
+		instance.entries = append(instance.entries, entry)
+	}
+	return &instance, nil
+}
+
+func parseListReputationEntry(line string) (listReputationEntry, error) {
+	fields := strings.Fields(line)
+	entry := listReputationEntry{score: 1}
+
+	subnetText := fields[0]
+	if !strings.Contains(subnetText, "/") {
+		if strings.Contains(subnetText, ":") {
+			subnetText += "/128"
+		} else {
+			subnetText += "/32"
+		}
+	}
+	var err error
+	if _, entry.subnet, err = net.ParseCIDR(subnetText); err != nil {
+		return entry, err
+	}
+
+	if len(fields) > 1 {
+		if _, err := fmt.Sscanf(fields[1], "%f", &entry.score); err != nil {
+			return entry, fmt.Errorf("invalid score %q", fields[1])
+		}
+	}
+	return entry, nil
+}
+
+func (provider *ListReputation) Name() string {
+	return "list"
+}
+
+func (provider *ListReputation) Score(_ context.Context, ip net.IP) (float64, error) {
+	var score float64
+	for _, entry := range provider.entries {
+		if entry.subnet.Contains(ip) && (entry.score > score) {
+			score = entry.score
+		}
+	}
+	return score, nil
+}
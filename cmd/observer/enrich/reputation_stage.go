@@ -0,0 +1,99 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// reputationCacheTTL is how long a cached provider result stays valid.
+// Reputation drifts slowly, and the provider APIs are rate limited.
+const reputationCacheTTL = 24 * time.Hour
+
+// ReputationStage scores the IP of each node with the configured
+// IP reputation providers (see IPReputation) and stores the worst score
+// on the node, where the classification rules ("ip_reputation" attribute)
+// and the blocklist report pick it up.
+// The provider results are cached in the database per IP,
+// so the nodes sharing an address cost a single provider query.
+type ReputationStage struct {
+	providers []IPReputation
+}
+
+func NewReputationStage(providerNames []string, options StageOptions) (*ReputationStage, error) {
+	if len(providerNames) == 0 {
+		return nil, fmt.Errorf("the reputation stage needs at least one provider")
+	}
+	providers, err := MakeIPReputations(providerNames, options)
+	if err != nil {
+		return nil, err
+	}
+	instance := ReputationStage{
+		providers: providers,
+	}
+	return &instance, nil
+}
+
+func (stage *ReputationStage) Name() string {
+	return "reputation"
+}
+
+func (stage *ReputationStage) Enrich(ctx context.Context, db database.DB, id database.NodeID) error {
+	addr, err := db.FindNodeAddr(ctx, id)
+	if err != nil {
+		return err
+	}
+	if addr == nil {
+		return nil
+	}
+	ip := addr.IP
+	if ip == nil {
+		ip = addr.IPv6.IP
+	}
+	if ip == nil {
+		return nil
+	}
+
+	// the node score is the worst the providers report:
+	// one bad verdict is enough to distrust an address
+	var score float64
+	for _, provider := range stage.providers {
+		providerScore, err := stage.providerScore(ctx, db, provider, ip)
+		if err != nil {
+			return err
+		}
+		if providerScore > score {
+			score = providerScore
+		}
+	}
+	return db.UpdateNodeIPReputation(ctx, id, score)
+}
+
+// providerScore returns the cached result of the provider if it is still valid,
+// querying and re-caching it otherwise.
+func (stage *ReputationStage) providerScore(
+	ctx context.Context,
+	db database.DB,
+	provider IPReputation,
+	ip net.IP,
+) (float64, error) {
+	cached, err := db.FindIPReputation(ctx, ip.String(), provider.Name())
+	if err != nil {
+		return 0, err
+	}
+	if (cached != nil) && (time.Since(cached.Checked) < reputationCacheTTL) {
+		return cached.Score, nil
+	}
+
+	score, err := provider.Score(ctx, ip)
+	if err != nil {
+		return 0, fmt.Errorf("failed to score IP reputation with %s: %w", provider.Name(), err)
+	}
+	if err := db.UpsertIPReputation(ctx, ip.String(), provider.Name(), score); err != nil {
+		return 0, err
+	}
+	return score, nil
+}
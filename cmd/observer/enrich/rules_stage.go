@@ -0,0 +1,305 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// RulesStage evaluates user-defined classification rules against the node attributes
+// and stores the labels of the matching rules (with their scores) in the database.
+// It lets users encode classifications like "probably-validator-infra"
+// without forking the code.
+//
+// The rules file is a YAML list of rules:
+//
+//	# rules.yaml
+//	- label: probably-validator-infra
+//	  score: 0.8
+//	  all:
+//	    - country == DE
+//	    - client_name prefix Geth
+//	  any:
+//	    - asn == 24940
+//	    - asn == 16276
+//
+// A rule matches a node if all the "all" conditions hold
+// and at least one "any" condition holds (either list may be omitted).
+// A condition is a whitespace-separated "attribute operator operand" triple;
+// see parseRuleCondition for the operators and ruleAttributes for the attributes.
+type RulesStage struct {
+	rules []classificationRule
+}
+
+type classificationRule struct {
+	label string
+	score float64
+	all   []ruleCondition
+	any   []ruleCondition
+}
+
+type ruleCondition struct {
+	attribute string
+	operator  string
+	operand   string
+	operands  []string
+	pattern   *regexp.Regexp
+}
+
+// ruleFileEntry is the YAML shape of a single rule.
+// A zero score counts as the default score of 1.
+type ruleFileEntry struct {
+	Label string   `yaml:"label"`
+	Score float64  `yaml:"score"`
+	All   []string `yaml:"all"`
+	Any   []string `yaml:"any"`
+}
+
+func NewRulesStage(rulesFile string) (*RulesStage, error) {
+	if rulesFile == "" {
+		return nil, fmt.Errorf("the rules stage needs a rules file")
+	}
+	data, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the rules file: %w", err)
+	}
+
+	var entries []ruleFileEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse the rules file: %w", err)
+	}
+
+	var instance RulesStage
+	for _, entry := range entries {
+		rule, err := makeClassificationRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		instance.rules = append(instance.rules, rule)
+	}
+	return &instance, nil
+}
+
+func makeClassificationRule(entry ruleFileEntry) (classificationRule, error) {
+	rule := classificationRule{
+		label: entry.Label,
+		score: entry.Score,
+	}
+	if rule.label == "" {
+		return rule, fmt.Errorf("a rule needs a label")
+	}
+	if rule.score == 0 {
+		rule.score = 1
+	}
+	if (len(entry.All) == 0) && (len(entry.Any) == 0) {
+		return rule, fmt.Errorf("rule %q needs at least one condition", rule.label)
+	}
+
+	for _, condition := range append(append([]string{}, entry.All...), entry.Any...) {
+		parsed, err := parseRuleCondition(condition)
+		if err != nil {
+			return rule, fmt.Errorf("rule %q: %w", rule.label, err)
+		}
+		if len(rule.all) < len(entry.All) {
+			rule.all = append(rule.all, parsed)
+		} else {
+			rule.any = append(rule.any, parsed)
+		}
+	}
+	return rule, nil
+}
+
+// parseRuleCondition parses a condition like "asn == 24940" or "client_name prefix Geth".
+// The operators are: == != < > (< and > compare numerically),
+// "in" (any of the listed operands), "prefix", "suffix", "contains",
+// "matches" (a regular expression), and "exists" (no operand, the attribute is known).
+func parseRuleCondition(condition string) (ruleCondition, error) {
+	fields := strings.Fields(condition)
+	if len(fields) < 2 {
+		return ruleCondition{}, fmt.Errorf("failed to parse condition %q: expecting \"attribute operator operand\"", condition)
+	}
+
+	parsed := ruleCondition{
+		attribute: fields[0],
+		operator:  fields[1],
+		operands:  fields[2:],
+	}
+	if len(parsed.operands) > 0 {
+		parsed.operand = parsed.operands[0]
+	}
+
+	switch parsed.operator {
+	case "exists":
+		if len(parsed.operands) != 0 {
+			return parsed, fmt.Errorf("condition %q: \"exists\" takes no operand", condition)
+		}
+	case "==", "!=", "prefix", "suffix", "contains":
+		if len(parsed.operands) != 1 {
+			return parsed, fmt.Errorf("condition %q: %q takes a single operand", condition, parsed.operator)
+		}
+	case "<", ">":
+		if len(parsed.operands) != 1 {
+			return parsed, fmt.Errorf("condition %q: %q takes a single operand", condition, parsed.operator)
+		}
+		if _, err := strconv.ParseFloat(parsed.operand, 64); err != nil {
+			return parsed, fmt.Errorf("condition %q: %q needs a numeric operand", condition, parsed.operator)
+		}
+	case "in":
+		if len(parsed.operands) == 0 {
+			return parsed, fmt.Errorf("condition %q: \"in\" needs at least one operand", condition)
+		}
+	case "matches":
+		pattern, err := regexp.Compile(strings.Join(parsed.operands, " "))
+		if err != nil {
+			return parsed, fmt.Errorf("condition %q: %w", condition, err)
+		}
+		parsed.pattern = pattern
+	default:
+		return parsed, fmt.Errorf("condition %q: unknown operator %q", condition, parsed.operator)
+	}
+	return parsed, nil
+}
+
+func (stage *RulesStage) Name() string {
+	return "rules"
+}
+
+func (stage *RulesStage) Enrich(ctx context.Context, db database.DB, id database.NodeID) error {
+	facts, err := db.FindNodeFacts(ctx, id)
+	if err != nil {
+		return err
+	}
+	if facts == nil {
+		return nil
+	}
+	attributes := ruleAttributes(facts)
+
+	// re-classification starts from a clean slate, so stale labels don't linger
+	if err := db.DeleteNodeLabels(ctx, id); err != nil {
+		return err
+	}
+	for _, rule := range stage.rules {
+		if !rule.matches(attributes) {
+			continue
+		}
+		if err := db.UpsertNodeLabel(ctx, id, rule.label, rule.score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ruleAttributes flattens the node facts into the attribute set
+// the conditions refer to. Unknown attributes stay nil.
+func ruleAttributes(facts *database.NodeFacts) map[string]*string {
+	attributes := map[string]*string{
+		"ip":            facts.IP,
+		"country":       facts.Country,
+		"city":          facts.City,
+		"client_id":     facts.ClientID,
+		"fork_id_hash":  facts.ForkIDHash,
+		"network_id":    uintAttribute(facts.NetworkID),
+		"eth_version":   uintAttribute(facts.EthVersion),
+		"asn":           uintAttribute(facts.ASN),
+		"ip_reputation": floatAttribute(facts.IPReputation),
+	}
+	if facts.ClientID != nil {
+		info := ParseClientID(*facts.ClientID)
+		attributes["client_name"] = nonEmptyAttribute(info.Name)
+		attributes["client_version"] = nonEmptyAttribute(info.Version)
+		attributes["client_os"] = nonEmptyAttribute(info.OS)
+		attributes["client_arch"] = nonEmptyAttribute(info.Arch)
+	}
+	return attributes
+}
+
+func uintAttribute(value *uint) *string {
+	if value == nil {
+		return nil
+	}
+	text := strconv.FormatUint(uint64(*value), 10)
+	return &text
+}
+
+func floatAttribute(value *float64) *string {
+	if value == nil {
+		return nil
+	}
+	text := strconv.FormatFloat(*value, 'f', -1, 64)
+	return &text
+}
+
+func nonEmptyAttribute(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func (rule *classificationRule) matches(attributes map[string]*string) bool {
+	for _, condition := range rule.all {
+		if !condition.eval(attributes[condition.attribute]) {
+			return false
+		}
+	}
+	if len(rule.any) == 0 {
+		return true
+	}
+	for _, condition := range rule.any {
+		if condition.eval(attributes[condition.attribute]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (condition *ruleCondition) eval(value *string) bool {
+	if condition.operator == "exists" {
+		return value != nil
+	}
+	if value == nil {
+		return false
+	}
+
+	switch condition.operator {
+	case "==":
+		return *value == condition.operand
+	case "!=":
+		return *value != condition.operand
+	case "<", ">":
+		number, err := strconv.ParseFloat(*value, 64)
+		if err != nil {
+			return false
+		}
+		// the operand was validated to be numeric at parse time
+		operand, _ := strconv.ParseFloat(condition.operand, 64)
+		if condition.operator == "<" {
+			return number < operand
+		}
+		return number > operand
+	case "in":
+		for _, operand := range condition.operands {
+			if *value == operand {
+				return true
+			}
+		}
+		return false
+	case "prefix":
+		return strings.HasPrefix(*value, condition.operand)
+	case "suffix":
+		return strings.HasSuffix(*value, condition.operand)
+	case "contains":
+		return strings.Contains(*value, condition.operand)
+	case "matches":
+		return condition.pattern.MatchString(*value)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,65 @@
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// Stage computes derived data for a single node and stores it in the database.
+// Stages run both during crawling (for fresh nodes) and
+// from the "enrich" backfill command (for existing databases).
+type Stage interface {
+	Name() string
+	Enrich(ctx context.Context, db database.DB, id database.NodeID) error
+}
+
+// StageOptions configures the stages that need external resources.
+type StageOptions struct {
+	// GeoIPCityFile and GeoIPASNFile are MaxMind GeoLite2 database paths (see GeoStage).
+	GeoIPCityFile string
+	GeoIPASNFile  string
+
+	// RulesFile is a YAML classification rules file (see RulesStage).
+	RulesFile string
+
+	// ReputationProviders selects the IP reputation providers of the
+	// reputation stage, e.g. "abuseipdb,list" (see MakeIPReputations).
+	ReputationProviders []string
+	// AbuseIPDBKey and GreyNoiseKey are the API keys of the respective providers.
+	AbuseIPDBKey string
+	GreyNoiseKey string
+	// ReputationListFile is an internal IP list file (see ListReputation).
+	ReputationListFile string
+}
+
+// MakeStages resolves a list of stage names like "geo,parse".
+// The set of known stages depends on what enrichment support is compiled in.
+func MakeStages(names []string, options StageOptions) ([]Stage, error) {
+	stages := make([]Stage, 0, len(names))
+	for _, name := range names {
+		stage, err := makeStage(strings.TrimSpace(name), options)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+func makeStage(name string, options StageOptions) (Stage, error) {
+	switch name {
+	case "parse":
+		return ParseStage{}, nil
+	case "geo":
+		return NewGeoStage(options.GeoIPCityFile, options.GeoIPASNFile)
+	case "rules":
+		return NewRulesStage(options.RulesFile)
+	case "reputation":
+		return NewReputationStage(options.ReputationProviders, options)
+	default:
+		return nil, fmt.Errorf("unknown enrichment stage: %s", name)
+	}
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Event is one entry of the change feed: a node attribute transition
+// such as a first sighting, a client ID change or a node going offline
+// (see the database.NodeEvent kinds).
+type Event struct {
+	Num      uint64    `json:"num"`
+	NodeID   string    `json:"node_id"`
+	Kind     string    `json:"kind"`
+	OldValue string    `json:"old_value,omitempty"`
+	NewValue string    `json:"new_value,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Sink receives every published event. The in-tree JSONLinesSink bridges
+// the feed to external brokers; a NATS or Kafka producer plugs in
+// by implementing this interface and registering with Bus.AddSink.
+type Sink interface {
+	Name() string
+	PublishEvent(event Event) error
+}
+
+// Bus fans the change feed out to its consumers: the subscribed Go channels
+// (see Subscribe) and the registered sinks. A slow subscriber loses events
+// instead of stalling the feed; sinks are expected to not block.
+type Bus struct {
+	mutex       sync.Mutex
+	subscribers map[uint64]chan Event
+	nextSubID   uint64
+	sinks       []Sink
+	log         log.Logger
+}
+
+func NewBus(logger log.Logger) *Bus {
+	instance := Bus{
+		subscribers: make(map[uint64]chan Event),
+		log:         logger,
+	}
+	return &instance
+}
+
+// Subscribe returns a channel receiving the published events
+// and a function that cancels the subscription and closes the channel.
+// Events published while the channel buffer is full are dropped.
+func (bus *Bus) Subscribe(buffer uint) (<-chan Event, func()) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	id := bus.nextSubID
+	bus.nextSubID++
+	channel := make(chan Event, buffer)
+	bus.subscribers[id] = channel
+
+	unsubscribe := func() {
+		bus.mutex.Lock()
+		defer bus.mutex.Unlock()
+		if _, ok := bus.subscribers[id]; ok {
+			delete(bus.subscribers, id)
+			close(channel)
+		}
+	}
+	return channel, unsubscribe
+}
+
+func (bus *Bus) AddSink(sink Sink) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+	bus.sinks = append(bus.sinks, sink)
+}
+
+// Publish delivers the event to every subscriber and sink.
+func (bus *Bus) Publish(event Event) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for _, channel := range bus.subscribers {
+		select {
+		case channel <- event:
+		default:
+			bus.log.Debug("Event feed subscriber is lagging, dropping an event", "num", event.Num)
+		}
+	}
+
+	for _, sink := range bus.sinks {
+		if err := sink.PublishEvent(event); err != nil {
+			bus.log.Error("Event feed sink failed", "sink", sink.Name(), "err", err)
+		}
+	}
+}
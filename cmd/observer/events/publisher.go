@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// publishBatchSize bounds how many backlogged events one poll publishes.
+const publishBatchSize = 1000
+
+// PublishLoop follows the node_events table and publishes every new row
+// to the bus. The database records the events transactionally with the
+// mutations themselves (see database.NodeEventDiscovered and friends),
+// so the feed sees every change regardless of which code path wrote it.
+// The loop starts after the latest already-recorded event: a fresh
+// subscriber only sees the changes from now on.
+func PublishLoop(
+	ctx context.Context,
+	db database.DB,
+	bus *Bus,
+	period time.Duration,
+	logger log.Logger,
+) {
+	lastNum, err := db.FindLastNodeEventNum(ctx)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to start the event feed", "err", err)
+		}
+		return
+	}
+
+	for ctx.Err() == nil {
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+
+		for {
+			count := uint(0)
+			err := db.EnumerateNodeEventsAfter(ctx, lastNum, publishBatchSize, func(entry database.NodeEventFeedEntry) {
+				bus.Publish(makeEvent(entry))
+				lastNum = entry.Num
+				count++
+			})
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					logger.Error("Failed to poll the event feed", "err", err)
+				}
+				break
+			}
+			if count < publishBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func makeEvent(entry database.NodeEventFeedEntry) Event {
+	event := Event{
+		Num:    entry.Num,
+		NodeID: string(entry.NodeID),
+		Kind:   entry.Event.Kind,
+		Time:   entry.Event.Time,
+	}
+	if entry.Event.OldValue != nil {
+		event.OldValue = *entry.Event.OldValue
+	}
+	if entry.Event.NewValue != nil {
+		event.NewValue = *entry.Event.NewValue
+	}
+	return event
+}
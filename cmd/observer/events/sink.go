@@ -0,0 +1,33 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSONLinesSink writes every event as a JSON line to a writer,
+// typically a file tailed by an external broker producer.
+type JSONLinesSink struct {
+	mutex   sync.Mutex
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+func NewJSONLinesSink(writer io.Writer) *JSONLinesSink {
+	instance := JSONLinesSink{
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}
+	return &instance
+}
+
+func (sink *JSONLinesSink) Name() string {
+	return "jsonl"
+}
+
+func (sink *JSONLinesSink) PublishEvent(event Event) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.encoder.Encode(event)
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+
+	// OutputFile is where the exported rows are written ("-" - stdout).
+	OutputFile string
+
+	// Format is the output format: jsonl (default) or csv.
+	Format string
+
+	// Client, NetworkID and SeenWithin optionally restrict the export
+	// to a subset of the nodes.
+	Client     string
+	NetworkID  uint
+	SeenWithin time.Duration
+
+	// SinceLast exports only the rows changed since the previous export run
+	// under the same checkpoint name.
+	SinceLast  bool
+	Checkpoint string
+
+	// SnapPeers switches to the snap peer preset: only reachable peers that
+	// proved snap/1 serving ability and recent heads are exported as static
+	// peer entries (see ExportSnapPeers). Limit caps the preset output.
+	SnapPeers bool
+	Limit     uint
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "export",
+		Short: "Export the known nodes as JSON lines for downstream consumers",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withOutputFile()
+	instance.withFormat()
+	instance.withFilter()
+	instance.withSinceLast()
+	instance.withSnapPeers()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withOutputFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.OutputFile, "output", "-",
+		"write the exported rows to this file (\"-\" - stdout)")
+}
+
+func (command *Command) withFormat() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Format, "format", string(FormatJSONLines),
+		"output format: jsonl or csv")
+}
+
+func (command *Command) withFilter() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Client, "client", "",
+		"export only the nodes whose client ID starts with this prefix, e.g. erigon")
+	flags.UintVar(&command.flags.NetworkID, "network-id", 0,
+		"export only the nodes of this network (0 - all networks)")
+	flags.DurationVar(&command.flags.SeenWithin, "seen-within", 0,
+		"export only the nodes seen within this window, e.g. 24h (0 - all nodes)")
+}
+
+func (command *Command) withSinceLast() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.SinceLast, "since-last", false,
+		"export only the rows changed since the previous export run")
+	flags.StringVar(&command.flags.Checkpoint, "checkpoint", "default",
+		"name of the --since-last checkpoint (use distinct names for independent consumers)")
+}
+
+func (command *Command) withSnapPeers() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.SnapPeers, "snap-peers", false,
+		"export only reachable peers that proved snap/1 serving ability and recent heads, "+
+			"formatted for the static peer flags of erigon/geth (--format enode or json)")
+	flags.UintVar(&command.flags.Limit, "limit", 100,
+		"how many peers the --snap-peers preset exports at most")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+)
+
+// The output formats of the snap peer preset (see ExportSnapPeers).
+const (
+	// FormatEnodeLines emits enode:// URLs one per line,
+	// ready to join with commas for the --staticpeers flags of erigon and geth.
+	FormatEnodeLines Format = "enode"
+	// FormatStaticNodesJSON emits a JSON array of enode:// URLs,
+	// the static-nodes.json format of geth.
+	FormatStaticNodesJSON Format = "json"
+)
+
+// ExportSnapPeers writes the peers suitable for bootstrapping snap sync:
+// reachable nodes that announced the snap/1 capability together with a chain
+// head in a handshake within the freshness window, as static peer entries
+// (see database.DB.FindSnapPeerCandidates).
+// It returns how many peers were written.
+func ExportSnapPeers(
+	ctx context.Context,
+	db database.DB,
+	output io.Writer,
+	format Format,
+	networkID uint,
+	handshakedWithin time.Duration,
+	limit uint,
+) (uint, error) {
+	handshakedAfter := time.Now().Add(-handshakedWithin)
+	ids, err := db.FindSnapPeerCandidates(ctx, networkID, handshakedAfter, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var urls []string
+	for _, id := range ids {
+		addr, err := db.FindNodeAddr(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if addr == nil {
+			continue
+		}
+		node, err := node_utils.MakeNodeFromAddr(id, *addr)
+		if err != nil {
+			return 0, fmt.Errorf("failed to make a node from its address: %w", err)
+		}
+		urls = append(urls, node.URLv4())
+	}
+
+	switch format {
+	case FormatEnodeLines:
+		for _, url := range urls {
+			if _, err := fmt.Fprintln(output, url); err != nil {
+				return 0, fmt.Errorf("failed to write a snap peer: %w", err)
+			}
+		}
+	case FormatStaticNodesJSON:
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(urls); err != nil {
+			return 0, fmt.Errorf("failed to write the snap peers: %w", err)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported snap peers format: %s", format)
+	}
+	return uint(len(urls)), nil
+}
@@ -0,0 +1,189 @@
+// Package federation pulls node summaries from remote observer HTTP APIs
+// and merges them into the local database with source attribution,
+// giving multiple observer deployments a shared global view.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Client periodically pulls the /export stream of each configured peer observer
+// and merges the records into the local database.
+// The merged observations use database.ObservationQualityInferred,
+// so a local handshake always wins over a federated record.
+// The pulls are incremental: the last seen change_seq of each peer
+// is checkpointed under the name "federation:<peer URL>" (see export_state).
+type Client struct {
+	db         database.DB
+	peers      []string
+	token      string
+	period     time.Duration
+	httpClient http.Client
+	log        log.Logger
+}
+
+func NewClient(
+	db database.DB,
+	peers []string,
+	token string,
+	period time.Duration,
+	logger log.Logger,
+) *Client {
+	instance := Client{
+		db,
+		peers,
+		token,
+		period,
+		http.Client{Timeout: time.Minute},
+		logger,
+	}
+	return &instance
+}
+
+// Run pulls from all peers once per period until the context is cancelled.
+func (client *Client) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		for _, peerURL := range client.peers {
+			merged, err := client.pullPeer(ctx, peerURL)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					client.log.Warn("Failed to pull a federation peer", "peer", peerURL, "err", err)
+				}
+				continue
+			}
+			if merged > 0 {
+				client.log.Info("Merged federated nodes", "peer", peerURL, "nodes", merged)
+			}
+		}
+
+		if err := utils.Sleep(ctx, client.period); err != nil {
+			break
+		}
+	}
+}
+
+// nodeExportRecord mirrors the record shape of the /export API endpoint.
+type nodeExportRecord struct {
+	ID         database.NodeID `json:"id"`
+	IP         string          `json:"ip"`
+	PortRLPx   uint16          `json:"port_rlpx"`
+	ClientID   string          `json:"client_id"`
+	NetworkID  uint            `json:"network_id"`
+	EthVersion uint            `json:"eth_version"`
+	ChangeSeq  uint64          `json:"change_seq"`
+}
+
+func (client *Client) pullPeer(ctx context.Context, peerURL string) (uint, error) {
+	checkpointName := "federation:" + peerURL
+	sinceChangeSeq, err := client.db.FindExportCheckpoint(ctx, checkpointName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the peer checkpoint: %w", err)
+	}
+
+	requestURL := peerURL + "/export?since_change_seq=" + strconv.FormatUint(sinceChangeSeq, 10)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to make a pull request: %w", err)
+	}
+	if client.token != "" {
+		request.Header.Set("Authorization", "Bearer "+client.token)
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pull the peer: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected pull response status: %s", response.Status)
+	}
+
+	var merged uint
+	lastChangeSeq := sinceChangeSeq
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var record nodeExportRecord
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return merged, fmt.Errorf("failed to decode an export record: %w", err)
+		}
+
+		if err := client.mergeNode(ctx, peerURL, record); err != nil {
+			return merged, fmt.Errorf("failed to merge node %s: %w", record.ID, err)
+		}
+		merged++
+
+		if record.ChangeSeq > lastChangeSeq {
+			lastChangeSeq = record.ChangeSeq
+		}
+	}
+
+	if lastChangeSeq > sinceChangeSeq {
+		if err := client.db.UpdateExportCheckpoint(ctx, checkpointName, lastChangeSeq); err != nil {
+			return merged, fmt.Errorf("failed to update the peer checkpoint: %w", err)
+		}
+	}
+	return merged, nil
+}
+
+// mergeNode upserts a single federated record into the local database.
+// Records with a corrupt node ID or without a usable IP address are skipped:
+// the peer data is not under our control, and the local crawler
+// could neither ping nor handshake such a node anyway.
+func (client *Client) mergeNode(ctx context.Context, peerURL string, record nodeExportRecord) error {
+	if record.ID.Validate() != nil {
+		return nil
+	}
+	ip := net.ParseIP(record.IP)
+	if ip == nil {
+		return nil
+	}
+
+	var addr database.NodeAddr
+	if ip.To4() != nil {
+		addr.IP = ip
+		addr.PortRLPx = record.PortRLPx
+	} else {
+		addr.IPv6.IP = ip
+		addr.IPv6.PortRLPx = record.PortRLPx
+	}
+	if err := client.db.UpsertNodeAddr(ctx, record.ID, addr); err != nil {
+		return err
+	}
+
+	if record.ClientID != "" {
+		_, err := client.db.UpdateClientID(ctx, record.ID, record.ClientID, database.ObservationQualityInferred)
+		if err != nil {
+			return err
+		}
+	}
+	if record.NetworkID != 0 {
+		err := client.db.UpdateNetworkID(ctx, record.ID, record.NetworkID, database.ObservationQualityInferred)
+		if err != nil {
+			return err
+		}
+	}
+	if record.EthVersion != 0 {
+		err := client.db.UpdateEthVersion(ctx, record.ID, record.EthVersion, database.ObservationQualityInferred)
+		if err != nil {
+			return err
+		}
+	}
+
+	return client.db.UpsertFederatedNode(ctx, record.ID, peerURL)
+}
@@ -0,0 +1,91 @@
+package fixtures
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/ledgerwatch/erigon/params/networkname"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir      string
+	Chain        string
+	Nodes        uint
+	Distribution string
+	Seed         int64
+}
+
+type Command struct {
+	command         cobra.Command
+	generateCommand cobra.Command
+	flags           CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "fixtures",
+		Short: "Generate synthetic test databases",
+	}
+
+	generateCommand := cobra.Command{
+		Use:   "generate",
+		Short: "Fabricate a statistically realistic node database for benchmarks and demos",
+	}
+
+	instance := Command{
+		command:         command,
+		generateCommand: generateCommand,
+	}
+
+	instance.withDatadir()
+	instance.withChain()
+	instance.withNodes()
+	instance.withDistribution()
+	instance.withSeed()
+
+	instance.command.AddCommand(&instance.generateCommand)
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.PersistentFlags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkPersistentFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withChain() {
+	flags := command.generateCommand.Flags()
+	flags.StringVar(&command.flags.Chain, utils.ChainFlag.Name, networkname.MainnetChainName, utils.ChainFlag.Usage)
+}
+
+func (command *Command) withNodes() {
+	flags := command.generateCommand.Flags()
+	flags.UintVar(&command.flags.Nodes, "nodes", 100000, "how many nodes to fabricate")
+}
+
+func (command *Command) withDistribution() {
+	flags := command.generateCommand.Flags()
+	flags.StringVar(&command.flags.Distribution, "distribution", "mainnet-like",
+		"statistical shape of the generated database (supported: mainnet-like)")
+}
+
+func (command *Command) withSeed() {
+	flags := command.generateCommand.Flags()
+	flags.Int64Var(&command.flags.Seed, "seed", 0,
+		"random seed for reproducible output (0 - derive from the current time)")
+}
+
+func (command *Command) OnGenerate(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.generateCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,164 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/observer"
+)
+
+// distribution describes the statistical shape of a generated database:
+// the client mix, the geographic mix, and the failure rates.
+// The weights within each list are relative, they do not have to sum to 1.
+type distribution struct {
+	clients     []clientShare
+	regions     []regionShare
+	ethVersions []ethVersionShare
+	errors      []handshakeErrorShare
+
+	// handshakeErrorRate is the fraction of nodes with a handshake error history.
+	handshakeErrorRate float64
+	// deadRate is the fraction of nodes that stopped responding to pings,
+	// and hence have no verified handshake facts.
+	deadRate float64
+	// geoRate is the fraction of nodes with resolved geo data,
+	// mimicking a database where the geo enrichment stage has run.
+	geoRate float64
+}
+
+// clientShare is a client implementation with the full client IDs
+// of its versions in circulation.
+type clientShare struct {
+	weight    float64
+	clientIDs []string
+}
+
+// regionShare is a country with its typical hosting networks:
+// the IP ranges the addresses are drawn from, and the matching ASN.
+type regionShare struct {
+	weight  float64
+	country string
+	cities  []string
+	cidrs   []string
+	asn     uint
+}
+
+type ethVersionShare struct {
+	weight  float64
+	version uint
+}
+
+type handshakeErrorShare struct {
+	weight float64
+	code   observer.HandshakeErrorID
+}
+
+func makeDistribution(name string) (distribution, error) {
+	switch name {
+	case "mainnet-like":
+		return mainnetLikeDistribution(), nil
+	default:
+		return distribution{}, fmt.Errorf("unknown distribution %q (supported: mainnet-like)", name)
+	}
+}
+
+// mainnetLikeDistribution approximates the observable Ethereum mainnet:
+// a geth-dominated client mix, nodes concentrated in a few cloud
+// and dedicated hosting regions, and realistic failure rates.
+// The numbers are rounded by hand from past crawl reports;
+// they only need to be plausible, not exact.
+func mainnetLikeDistribution() distribution {
+	return distribution{
+		clients: []clientShare{
+			{0.78, []string{
+				"Geth/v1.10.17-stable-25c9b49f/linux-amd64/go1.18",
+				"Geth/v1.10.16-stable-20356e57/linux-amd64/go1.17.5",
+				"Geth/v1.10.15-stable-8be800ff/linux-amd64/go1.17.5",
+				"Geth/v1.10.13-stable-7a0c19f8/windows-amd64/go1.17.2",
+			}},
+			{0.08, []string{
+				"Nethermind/v1.12.4+2be1890e/linux-x64/dotnet6.0.3",
+				"Nethermind/v1.12.3+fc4e1e85/linux-x64/dotnet6.0.2",
+			}},
+			{0.06, []string{
+				"erigon/v2022.04.2/linux-amd64/go1.18",
+				"erigon/v2022.03.1/linux-amd64/go1.17.8",
+			}},
+			{0.04, []string{
+				"besu/v22.4.0/linux-x86_64/openjdk-java-11",
+				"besu/v22.1.3/linux-x86_64/openjdk-java-11",
+			}},
+			{0.03, []string{
+				"OpenEthereum/v3.3.5-stable/x86_64-linux-gnu/rustc1.59.0",
+			}},
+			{0.01, []string{
+				"CoreGeth/v1.12.7-stable/linux-amd64/go1.17.8",
+			}},
+		},
+		regions: []regionShare{
+			{0.38, "US", []string{"Ashburn", "Columbus", "Portland"},
+				[]string{"3.80.0.0/12", "34.192.0.0/12", "54.144.0.0/14"}, 14618},
+			{0.14, "DE", []string{"Frankfurt am Main", "Nuremberg", "Falkenstein"},
+				[]string{"49.12.0.0/16", "78.46.0.0/15", "138.201.0.0/16"}, 24940},
+			{0.05, "FI", []string{"Helsinki"},
+				[]string{"65.108.0.0/16", "95.216.0.0/16"}, 24940},
+			{0.05, "SG", []string{"Singapore"},
+				[]string{"13.212.0.0/15", "18.136.0.0/16"}, 16509},
+			{0.05, "GB", []string{"London"},
+				[]string{"3.8.0.0/14", "18.130.0.0/16"}, 16509},
+			{0.05, "FR", []string{"Gravelines", "Strasbourg"},
+				[]string{"51.68.0.0/16", "141.94.0.0/16"}, 16276},
+			{0.04, "NL", []string{"Amsterdam"},
+				[]string{"146.190.0.0/16"}, 14061},
+			{0.03, "CA", []string{"Montreal"},
+				[]string{"35.182.0.0/15"}, 16509},
+			{0.03, "JP", []string{"Tokyo"},
+				[]string{"13.112.0.0/14"}, 16509},
+			{0.02, "AU", []string{"Sydney"},
+				[]string{"13.54.0.0/15"}, 16509},
+		},
+		ethVersions: []ethVersionShare{
+			{0.85, 66},
+			{0.12, 65},
+			{0.03, 64},
+		},
+		errors: []handshakeErrorShare{
+			{0.45, observer.HandshakeErrorIDConnect},
+			{0.20, observer.HandshakeErrorIDDisconnect},
+			{0.15, observer.HandshakeErrorIDAuth},
+			{0.12, observer.HandshakeErrorIDRead},
+			{0.08, observer.HandshakeErrorIDHelloDecode},
+		},
+		handshakeErrorRate: 0.35,
+		deadRate:           0.25,
+		geoRate:            0.9,
+	}
+}
+
+// pickWeighted returns a random index distributed by the relative weights.
+func pickWeighted(rng *rand.Rand, weights []float64) int {
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	point := rng.Float64() * total
+	for i, weight := range weights {
+		point -= weight
+		if point < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
+
+func (share *clientShare) pickClientID(rng *rand.Rand) string {
+	return share.clientIDs[rng.Intn(len(share.clientIDs))]
+}
+
+func (share *regionShare) pickCity(rng *rand.Rand) string {
+	return share.cities[rng.Intn(len(share.cities))]
+}
+
+func (share *regionShare) pickCIDR(rng *rand.Rand) string {
+	return share.cidrs[rng.Intn(len(share.cidrs))]
+}
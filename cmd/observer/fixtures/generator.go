@@ -0,0 +1,211 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/bootnodes"
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/params"
+)
+
+// generatorProgressInterval is how many nodes to generate between progress log lines.
+const generatorProgressInterval = 1000
+
+// Generator fabricates a statistically realistic crawler database:
+// random nodes whose client mix, geographic mix, and failure rates
+// follow a named distribution (see makeDistribution).
+// The output is meant for benchmarking queries, demoing dashboards,
+// and reproducing performance bugs without a multi-day crawl,
+// so the node IDs are random bytes rather than real keys,
+// and no ENRs or handshake attempt histories are fabricated.
+// The same seed produces the same database.
+type Generator struct {
+	db    database.DB
+	chain string
+	dist  distribution
+	rng   *rand.Rand
+	log   log.Logger
+}
+
+func NewGenerator(db database.DB, chain string, distributionName string, seed int64, logger log.Logger) (*Generator, error) {
+	dist, err := makeDistribution(distributionName)
+	if err != nil {
+		return nil, err
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	instance := Generator{
+		db:    db,
+		chain: chain,
+		dist:  dist,
+		rng:   rand.New(rand.NewSource(seed)),
+		log:   logger,
+	}
+	return &instance, nil
+}
+
+func (generator *Generator) Generate(ctx context.Context, nodeCount uint) error {
+	forkIDHashes, err := bootnodes.ChainForkIDHashes(generator.chain)
+	if err != nil {
+		return err
+	}
+	networkID := uint(params.NetworkIDByChainName(generator.chain))
+
+	startTime := time.Now()
+	for i := uint(0); i < nodeCount; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := generator.generateNode(ctx, networkID, forkIDHashes); err != nil {
+			return fmt.Errorf("failed to generate node %d: %w", i, err)
+		}
+		if (i+1)%generatorProgressInterval == 0 {
+			generator.log.Info("Fixtures progress", "nodes", i+1, "elapsed", time.Since(startTime).Truncate(time.Second))
+		}
+	}
+	generator.log.Info("Fixtures done", "nodes", nodeCount, "elapsed", time.Since(startTime).Truncate(time.Second))
+	return nil
+}
+
+func (generator *Generator) generateNode(ctx context.Context, networkID uint, forkIDHashes []string) error {
+	rng := generator.rng
+	dist := &generator.dist
+	id := generator.randomNodeID()
+	region := &dist.regions[pickWeighted(rng, regionWeights(dist.regions))]
+
+	var addr database.NodeAddr
+	addr.IP = randomIPInCIDR(rng, region.pickCIDR(rng))
+	addr.PortDisc = 30303
+	addr.PortRLPx = 30303
+	if rng.Float64() < 0.2 {
+		addr.PortRLPx = uint16(30000 + rng.Intn(5000))
+		addr.PortDisc = addr.PortRLPx
+	}
+	if err := generator.db.UpsertNodeAddr(ctx, id, addr); err != nil {
+		return err
+	}
+
+	// every node was discovered, so it has at least one ping sighting
+	if err := generator.db.InsertNodeSighting(ctx, id, database.NodeSightingPing); err != nil {
+		return err
+	}
+
+	if rng.Float64() < dist.deadRate {
+		// a dead node: ping failures and no verified handshake facts
+		pingFails := 1 + rng.Intn(5)
+		for i := 0; i < pingFails; i++ {
+			if err := generator.db.UpdatePingError(ctx, id); err != nil {
+				return err
+			}
+		}
+	} else {
+		client := &dist.clients[pickWeighted(rng, clientWeights(dist.clients))]
+		if _, err := generator.db.UpdateClientID(ctx, id, client.pickClientID(rng), database.ObservationQualityVerified); err != nil {
+			return err
+		}
+		if err := generator.db.UpdateNetworkID(ctx, id, networkID, database.ObservationQualityVerified); err != nil {
+			return err
+		}
+		ethVersion := dist.ethVersions[pickWeighted(rng, ethVersionWeights(dist.ethVersions))].version
+		if err := generator.db.UpdateEthVersion(ctx, id, ethVersion, database.ObservationQualityVerified); err != nil {
+			return err
+		}
+		if len(forkIDHashes) > 0 {
+			if err := generator.db.UpdateForkID(ctx, id, forkIDHashes[0], 0, database.ObservationQualityVerified); err != nil {
+				return err
+			}
+		}
+		if err := generator.db.InsertNodeSighting(ctx, id, database.NodeSightingHandshake); err != nil {
+			return err
+		}
+	}
+
+	if rng.Float64() < dist.handshakeErrorRate {
+		errorCount := 1 + rng.Intn(3)
+		for i := 0; i < errorCount; i++ {
+			code := dist.errors[pickWeighted(rng, errorWeights(dist.errors))].code
+			if err := generator.db.InsertHandshakeError(ctx, id, string(code)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if rng.Float64() < dist.geoRate {
+		geo := database.NodeGeo{
+			Country: region.country,
+			City:    region.pickCity(rng),
+			ASN:     region.asn,
+		}
+		if err := generator.db.UpdateNodeGeo(ctx, id, geo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// randomNodeID fabricates a well-formed node ID out of random bytes.
+// It is not a point on the secp256k1 curve, which is fine for fixtures:
+// nothing in the reports needs a usable key.
+func (generator *Generator) randomNodeID() database.NodeID {
+	data := make([]byte, database.NodeIDByteLen)
+	generator.rng.Read(data)
+	return database.NodeID(hex.EncodeToString(data))
+}
+
+// randomIPInCIDR draws a random host address from an IPv4 range.
+func randomIPInCIDR(rng *rand.Rand, cidr string) net.IP {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		// the ranges are hardcoded in the distribution tables
+		panic(fmt.Sprintf("invalid fixture CIDR %q: %v", cidr, err))
+	}
+	base := binary.BigEndian.Uint32(ipNet.IP.To4())
+	mask := binary.BigEndian.Uint32(net.IP(ipNet.Mask).To4())
+	value := base | (rng.Uint32() &^ mask)
+
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, value)
+	return ip
+}
+
+func clientWeights(shares []clientShare) []float64 {
+	weights := make([]float64, len(shares))
+	for i, share := range shares {
+		weights[i] = share.weight
+	}
+	return weights
+}
+
+func regionWeights(shares []regionShare) []float64 {
+	weights := make([]float64, len(shares))
+	for i, share := range shares {
+		weights[i] = share.weight
+	}
+	return weights
+}
+
+func ethVersionWeights(shares []ethVersionShare) []float64 {
+	weights := make([]float64, len(shares))
+	for i, share := range shares {
+		weights[i] = share.weight
+	}
+	return weights
+}
+
+func errorWeights(shares []handshakeErrorShare) []float64 {
+	weights := make([]float64, len(shares))
+	for i, share := range shares {
+		weights[i] = share.weight
+	}
+	return weights
+}
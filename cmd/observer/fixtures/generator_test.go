@@ -0,0 +1,68 @@
+package fixtures_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/fixtures"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/log/v3"
+)
+
+func TestGenerateAndReport(t *testing.T) {
+	ctx := context.Background()
+	nodeCount := uint(50)
+
+	db, err := database.NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	logger := log.New()
+	logger.SetHandler(log.DiscardHandler())
+
+	generator, err := fixtures.NewGenerator(db, "mainnet", "mainnet-like", 1, logger)
+	require.NoError(t, err)
+	require.NoError(t, generator.Generate(ctx, nodeCount))
+
+	networkID := uint(params.NetworkIDByChainName("mainnet"))
+
+	statusReport, err := reports.CreateStatusReport(ctx, db, 3, networkID)
+	require.NoError(t, err)
+	// the dead nodes of the distribution fall out through the ping try cap
+	assert.Positive(t, statusReport.TotalCount)
+	assert.LessOrEqual(t, statusReport.TotalCount, nodeCount)
+	assert.NotEmpty(t, statusReport.String())
+
+	clientsReport, err := reports.CreateClientsReport(ctx, db, reports.NewCanonicalizer(), 10, 3, networkID, 0)
+	require.NoError(t, err)
+	assert.NotEmpty(t, clientsReport.Clients)
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+
+	render := func(t *testing.T, seed int64) string {
+		db, err := database.NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+		require.NoError(t, err)
+		defer func() { _ = db.Close() }()
+
+		logger := log.New()
+		logger.SetHandler(log.DiscardHandler())
+
+		generator, err := fixtures.NewGenerator(db, "mainnet", "mainnet-like", seed, logger)
+		require.NoError(t, err)
+		require.NoError(t, generator.Generate(ctx, 20))
+
+		report, err := reports.CreateClientsReport(ctx, db, reports.NewCanonicalizer(), 10, 3, 0, 0)
+		require.NoError(t, err)
+		return report.String()
+	}
+
+	assert.Equal(t, render(t, 1), render(t, 1))
+}
@@ -0,0 +1,279 @@
+package grpcapi
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The Go shapes of the observer.proto messages (see wire.go for the encoding).
+
+type Node struct {
+	ID          string
+	IP          string
+	PortRLPx    uint32
+	ClientID    string
+	NetworkID   uint64
+	EthVersion  uint32
+	ASN         uint32
+	AddrUpdated int64
+	ChangeSeq   uint64
+}
+
+func (m *Node) marshal() []byte {
+	var buffer []byte
+	buffer = appendStringField(buffer, 1, m.ID)
+	buffer = appendStringField(buffer, 2, m.IP)
+	buffer = appendVarintField(buffer, 3, uint64(m.PortRLPx))
+	buffer = appendStringField(buffer, 4, m.ClientID)
+	buffer = appendVarintField(buffer, 5, m.NetworkID)
+	buffer = appendVarintField(buffer, 6, uint64(m.EthVersion))
+	buffer = appendVarintField(buffer, 7, uint64(m.ASN))
+	buffer = appendVarintField(buffer, 8, uint64(m.AddrUpdated))
+	buffer = appendVarintField(buffer, 9, m.ChangeSeq)
+	return buffer
+}
+
+func (m *Node) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			m.ID = decoder.stringValue(fieldType)
+		case 2:
+			m.IP = decoder.stringValue(fieldType)
+		case 3:
+			m.PortRLPx = uint32(decoder.varintValue(fieldType))
+		case 4:
+			m.ClientID = decoder.stringValue(fieldType)
+		case 5:
+			m.NetworkID = decoder.varintValue(fieldType)
+		case 6:
+			m.EthVersion = uint32(decoder.varintValue(fieldType))
+		case 7:
+			m.ASN = uint32(decoder.varintValue(fieldType))
+		case 8:
+			m.AddrUpdated = int64(decoder.varintValue(fieldType))
+		case 9:
+			m.ChangeSeq = decoder.varintValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
+
+type ListNodesRequest struct {
+	NetworkID      uint64
+	ClientIDPrefix string
+	SeenSince      int64
+	PageSize       uint32
+	PageToken      uint64
+}
+
+func (m *ListNodesRequest) marshal() []byte {
+	var buffer []byte
+	buffer = appendVarintField(buffer, 1, m.NetworkID)
+	buffer = appendStringField(buffer, 2, m.ClientIDPrefix)
+	buffer = appendVarintField(buffer, 3, uint64(m.SeenSince))
+	buffer = appendVarintField(buffer, 4, uint64(m.PageSize))
+	buffer = appendVarintField(buffer, 5, m.PageToken)
+	return buffer
+}
+
+func (m *ListNodesRequest) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			m.NetworkID = decoder.varintValue(fieldType)
+		case 2:
+			m.ClientIDPrefix = decoder.stringValue(fieldType)
+		case 3:
+			m.SeenSince = int64(decoder.varintValue(fieldType))
+		case 4:
+			m.PageSize = uint32(decoder.varintValue(fieldType))
+		case 5:
+			m.PageToken = decoder.varintValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
+
+type ListNodesResponse struct {
+	Nodes         []*Node
+	NextPageToken uint64
+}
+
+func (m *ListNodesResponse) marshal() []byte {
+	var buffer []byte
+	for _, node := range m.Nodes {
+		buffer = appendMessageField(buffer, 1, node)
+	}
+	buffer = appendVarintField(buffer, 2, m.NextPageToken)
+	return buffer
+}
+
+func (m *ListNodesResponse) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			node := new(Node)
+			if err := node.unmarshal(decoder.bytesValue(fieldType)); err != nil {
+				return err
+			}
+			m.Nodes = append(m.Nodes, node)
+		case 2:
+			m.NextPageToken = decoder.varintValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
+
+type GetNodeRequest struct {
+	ID string
+}
+
+func (m *GetNodeRequest) marshal() []byte {
+	return appendStringField(nil, 1, m.ID)
+}
+
+func (m *GetNodeRequest) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			m.ID = decoder.stringValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
+
+type StreamNewNodesRequest struct {
+	NetworkID      uint64
+	SinceChangeSeq uint64
+}
+
+func (m *StreamNewNodesRequest) marshal() []byte {
+	var buffer []byte
+	buffer = appendVarintField(buffer, 1, m.NetworkID)
+	buffer = appendVarintField(buffer, 2, m.SinceChangeSeq)
+	return buffer
+}
+
+func (m *StreamNewNodesRequest) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			m.NetworkID = decoder.varintValue(fieldType)
+		case 2:
+			m.SinceChangeSeq = decoder.varintValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
+
+type GetStatsRequest struct {
+	NetworkID uint64
+}
+
+func (m *GetStatsRequest) marshal() []byte {
+	return appendVarintField(nil, 1, m.NetworkID)
+}
+
+func (m *GetStatsRequest) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			m.NetworkID = decoder.varintValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
+
+type GetStatsResponse struct {
+	TotalNodes       uint64
+	OperatorEstimate uint64
+	ClientCounts     map[string]uint64
+	CountryCounts    map[string]uint64
+}
+
+func (m *GetStatsResponse) marshal() []byte {
+	var buffer []byte
+	buffer = appendVarintField(buffer, 1, m.TotalNodes)
+	buffer = appendVarintField(buffer, 2, m.OperatorEstimate)
+	for key, value := range m.ClientCounts {
+		buffer = appendMapEntry(buffer, 3, key, value)
+	}
+	for key, value := range m.CountryCounts {
+		buffer = appendMapEntry(buffer, 4, key, value)
+	}
+	return buffer
+}
+
+func (m *GetStatsResponse) unmarshal(data []byte) error {
+	decoder := fieldDecoder{data: data}
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			m.TotalNodes = decoder.varintValue(fieldType)
+		case 2:
+			m.OperatorEstimate = decoder.varintValue(fieldType)
+		case 3, 4:
+			key, value, err := decodeMapEntry(decoder.bytesValue(fieldType))
+			if err != nil {
+				return err
+			}
+			counts := &m.ClientCounts
+			if num == protowire.Number(4) {
+				counts = &m.CountryCounts
+			}
+			if *counts == nil {
+				*counts = make(map[string]uint64)
+			}
+			(*counts)[key] = value
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return decoder.err
+}
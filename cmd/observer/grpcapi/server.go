@@ -0,0 +1,241 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves the crawl data over gRPC (see observer.proto),
+// so other Erigon components and external tools can consume it
+// programmatically without touching the database directly.
+type Server struct {
+	db  database.DB
+	log log.Logger
+}
+
+const (
+	// pageSizeDefault and pageSizeMax bound the ListNodes pages.
+	pageSizeDefault = 100
+	pageSizeMax     = 1000
+
+	// streamPollPeriod is how often StreamNewNodes polls for changes
+	// once the replay has caught up.
+	streamPollPeriod = 5 * time.Second
+)
+
+func NewServer(db database.DB, logger log.Logger) *Server {
+	instance := Server{
+		db:  db,
+		log: logger,
+	}
+	return &instance
+}
+
+// Listen serves the gRPC API until the context is cancelled.
+func (server *Server) Listen(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	grpcServer := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	grpcServer.RegisterService(&serviceDesc, server)
+
+	go func() {
+		<-ctx.Done()
+		grpcServer.GracefulStop()
+	}()
+
+	server.log.Info("gRPC server listening", "addr", addr)
+	return grpcServer.Serve(listener)
+}
+
+func (server *Server) ListNodes(ctx context.Context, request *ListNodesRequest) (*ListNodesResponse, error) {
+	pageSize := uint(request.PageSize)
+	if pageSize == 0 {
+		pageSize = pageSizeDefault
+	}
+	if pageSize > pageSizeMax {
+		pageSize = pageSizeMax
+	}
+
+	filter := database.NodeExportFilter{
+		ClientIDPrefix: request.ClientIDPrefix,
+		NetworkID:      uint(request.NetworkID),
+		Limit:          pageSize,
+	}
+	if request.SeenSince > 0 {
+		filter.SeenSince = time.Unix(request.SeenSince, 0)
+	}
+
+	var response ListNodesResponse
+	err := server.db.EnumerateNodeExports(ctx, request.PageToken, filter, func(node database.NodeExport) {
+		response.Nodes = append(response.Nodes, makeNode(node))
+	})
+	if err != nil {
+		server.log.Error("Failed to list the nodes", "err", err)
+		return nil, status.Error(codes.Internal, "failed to list the nodes")
+	}
+
+	// a full page may have more changes behind it
+	if uint(len(response.Nodes)) == pageSize {
+		response.NextPageToken = response.Nodes[len(response.Nodes)-1].ChangeSeq
+	}
+	return &response, nil
+}
+
+func (server *Server) GetNode(ctx context.Context, request *GetNodeRequest) (*Node, error) {
+	id, err := database.ParseNodeID(request.ID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid node ID")
+	}
+
+	addr, err := server.db.FindNodeAddr(ctx, id)
+	if err != nil {
+		server.log.Error("Failed to find the node address", "err", err)
+		return nil, status.Error(codes.Internal, "failed to find the node")
+	}
+	if addr == nil {
+		return nil, status.Error(codes.NotFound, "unknown node")
+	}
+
+	node := Node{
+		ID:       string(id),
+		PortRLPx: uint32(addr.PortRLPx),
+	}
+	if addr.IP != nil {
+		node.IP = addr.IP.String()
+	}
+
+	clientID, err := server.db.FindClientID(ctx, id)
+	if err != nil {
+		server.log.Error("Failed to find the node client ID", "err", err)
+		return nil, status.Error(codes.Internal, "failed to find the node")
+	}
+	if clientID != nil {
+		node.ClientID = *clientID
+	}
+
+	freshness, err := server.db.FindNodeFreshness(ctx, id)
+	if err != nil {
+		server.log.Error("Failed to find the node freshness", "err", err)
+		return nil, status.Error(codes.Internal, "failed to find the node")
+	}
+	if freshness != nil {
+		node.AddrUpdated = freshness.AddrUpdated.Unix()
+	}
+	return &node, nil
+}
+
+// StreamNewNodes replays the nodes changed after the requested change sequence
+// number and then keeps following the new changes until the client hangs up.
+func (server *Server) StreamNewNodes(request *StreamNewNodesRequest, stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	filter := database.NodeExportFilter{
+		NetworkID: uint(request.NetworkID),
+		Limit:     pageSizeMax,
+	}
+
+	sinceChangeSeq := request.SinceChangeSeq
+	for ctx.Err() == nil {
+		var sendErr error
+		err := server.db.EnumerateNodeExports(ctx, sinceChangeSeq, filter, func(node database.NodeExport) {
+			if sendErr != nil {
+				return
+			}
+			sendErr = stream.SendMsg(makeNode(node))
+			sinceChangeSeq = node.ChangeSeq
+		})
+		if err != nil {
+			server.log.Error("Failed to stream the node changes", "err", err)
+			return status.Error(codes.Internal, "failed to stream the node changes")
+		}
+		if sendErr != nil {
+			return sendErr
+		}
+		if err := utils.Sleep(ctx, streamPollPeriod); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (server *Server) GetStats(ctx context.Context, request *GetStatsRequest) (*GetStatsResponse, error) {
+	networkID := uint(request.NetworkID)
+
+	totalNodes, err := server.db.CountAllNodes(ctx, networkID)
+	if err != nil {
+		server.log.Error("Failed to count the nodes", "err", err)
+		return nil, status.Error(codes.Internal, "failed to aggregate the stats")
+	}
+
+	operatorEstimate, err := server.db.EstimateOperatorCount(ctx, networkID)
+	if err != nil {
+		server.log.Error("Failed to estimate the operator count", "err", err)
+		return nil, status.Error(codes.Internal, "failed to aggregate the stats")
+	}
+
+	clientGroups, err := server.db.CountClientGroups(ctx, networkID)
+	if err != nil {
+		server.log.Error("Failed to count the client groups", "err", err)
+		return nil, status.Error(codes.Internal, "failed to aggregate the stats")
+	}
+	clientCounts := make(map[string]uint64)
+	for clientID, count := range clientGroups {
+		clientCounts[reports.NameFromClientID(clientID)] += uint64(count)
+	}
+
+	countryGroups, err := server.db.CountNodesByCountry(ctx, networkID)
+	if err != nil {
+		server.log.Error("Failed to count the nodes by country", "err", err)
+		return nil, status.Error(codes.Internal, "failed to aggregate the stats")
+	}
+	countryCounts := make(map[string]uint64)
+	for country, count := range countryGroups {
+		countryCounts[country] = uint64(count)
+	}
+
+	response := GetStatsResponse{
+		TotalNodes:       uint64(totalNodes),
+		OperatorEstimate: uint64(operatorEstimate),
+		ClientCounts:     clientCounts,
+		CountryCounts:    countryCounts,
+	}
+	return &response, nil
+}
+
+func makeNode(node database.NodeExport) *Node {
+	result := Node{
+		ID:          string(node.ID),
+		AddrUpdated: node.AddrUpdated.Unix(),
+		ChangeSeq:   node.ChangeSeq,
+	}
+	if node.IP != nil {
+		result.IP = *node.IP
+	}
+	if node.PortRLPx != nil {
+		result.PortRLPx = uint32(*node.PortRLPx)
+	}
+	if node.ClientID != nil {
+		result.ClientID = *node.ClientID
+	}
+	if node.NetworkID != nil {
+		result.NetworkID = uint64(*node.NetworkID)
+	}
+	if node.EthVersion != nil {
+		result.EthVersion = uint32(*node.EthVersion)
+	}
+	if node.ASN != nil {
+		result.ASN = uint32(*node.ASN)
+	}
+	return &result
+}
@@ -0,0 +1,98 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// serviceDesc describes the Observer service of observer.proto to the grpc
+// server, mirroring what protoc-gen-go-grpc would have generated.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "observer.Observer",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNodes",
+			Handler:    listNodesHandler,
+		},
+		{
+			MethodName: "GetNode",
+			Handler:    getNodeHandler,
+		},
+		{
+			MethodName: "GetStats",
+			Handler:    getStatsHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamNewNodes",
+			Handler:       streamNewNodesHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "observer.proto",
+}
+
+func listNodesHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	request := new(ListNodesRequest)
+	if err := dec(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).ListNodes(ctx, request)
+	}
+	info := grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/observer.Observer/ListNodes",
+	}
+	handler := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return srv.(*Server).ListNodes(ctx, request.(*ListNodesRequest))
+	}
+	return interceptor(ctx, request, &info, handler)
+}
+
+func getNodeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	request := new(GetNodeRequest)
+	if err := dec(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).GetNode(ctx, request)
+	}
+	info := grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/observer.Observer/GetNode",
+	}
+	handler := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return srv.(*Server).GetNode(ctx, request.(*GetNodeRequest))
+	}
+	return interceptor(ctx, request, &info, handler)
+}
+
+func getStatsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	request := new(GetStatsRequest)
+	if err := dec(request); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).GetStats(ctx, request)
+	}
+	info := grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/observer.Observer/GetStats",
+	}
+	handler := func(ctx context.Context, request interface{}) (interface{}, error) {
+		return srv.(*Server).GetStats(ctx, request.(*GetStatsRequest))
+	}
+	return interceptor(ctx, request, &info, handler)
+}
+
+func streamNewNodesHandler(srv interface{}, stream grpc.ServerStream) error {
+	request := new(StreamNewNodesRequest)
+	if err := stream.RecvMsg(request); err != nil {
+		return err
+	}
+	return srv.(*Server).StreamNewNodes(request, stream)
+}
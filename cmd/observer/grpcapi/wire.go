@@ -0,0 +1,172 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of observer.proto are marshalled by hand with protowire
+// instead of protoc-generated code, so the build doesn't depend on protoc.
+// observer.proto stays the wire contract, and the clients generate
+// their stubs from it as usual.
+
+// message is a hand-marshalled protobuf message (see the codec).
+type message interface {
+	marshal() []byte
+	unmarshal(data []byte) error
+}
+
+// codec plugs the hand-marshalled messages into the grpc server.
+// It keeps the standard "proto" wire name, since the encoding is
+// wire-compatible with the generated stubs of the clients.
+type codec struct{}
+
+func (codec) Marshal(value interface{}) ([]byte, error) {
+	encoded, ok := value.(message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", value)
+	}
+	return encoded.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, value interface{}) error {
+	decoded, ok := value.(message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", value)
+	}
+	return decoded.unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+// appendStringField encodes a string field, omitting the zero value.
+func appendStringField(buffer []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return buffer
+	}
+	buffer = protowire.AppendTag(buffer, num, protowire.BytesType)
+	return protowire.AppendString(buffer, value)
+}
+
+// appendVarintField encodes an integer field, omitting the zero value.
+func appendVarintField(buffer []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return buffer
+	}
+	buffer = protowire.AppendTag(buffer, num, protowire.VarintType)
+	return protowire.AppendVarint(buffer, value)
+}
+
+// appendMessageField encodes an embedded message field.
+func appendMessageField(buffer []byte, num protowire.Number, value message) []byte {
+	buffer = protowire.AppendTag(buffer, num, protowire.BytesType)
+	return protowire.AppendBytes(buffer, value.marshal())
+}
+
+// appendMapEntry encodes one entry of a map<string, uint64> field:
+// an embedded message with the key as field 1 and the value as field 2.
+func appendMapEntry(buffer []byte, num protowire.Number, key string, value uint64) []byte {
+	entry := appendStringField(nil, 1, key)
+	entry = appendVarintField(entry, 2, value)
+	buffer = protowire.AppendTag(buffer, num, protowire.BytesType)
+	return protowire.AppendBytes(buffer, entry)
+}
+
+// fieldDecoder iterates the fields of an encoded message.
+// The first malformed field stops the iteration and surfaces in err.
+type fieldDecoder struct {
+	data []byte
+	err  error
+}
+
+// next consumes the tag of the next field.
+func (decoder *fieldDecoder) next() (protowire.Number, protowire.Type, bool) {
+	if (decoder.err != nil) || (len(decoder.data) == 0) {
+		return 0, 0, false
+	}
+	num, fieldType, n := protowire.ConsumeTag(decoder.data)
+	if n < 0 {
+		decoder.err = protowire.ParseError(n)
+		return 0, 0, false
+	}
+	decoder.data = decoder.data[n:]
+	return num, fieldType, true
+}
+
+func (decoder *fieldDecoder) stringValue(fieldType protowire.Type) string {
+	if fieldType != protowire.BytesType {
+		decoder.err = fmt.Errorf("unexpected wire type %d for a string field", fieldType)
+		return ""
+	}
+	value, n := protowire.ConsumeString(decoder.data)
+	if n < 0 {
+		decoder.err = protowire.ParseError(n)
+		return ""
+	}
+	decoder.data = decoder.data[n:]
+	return value
+}
+
+func (decoder *fieldDecoder) varintValue(fieldType protowire.Type) uint64 {
+	if fieldType != protowire.VarintType {
+		decoder.err = fmt.Errorf("unexpected wire type %d for an integer field", fieldType)
+		return 0
+	}
+	value, n := protowire.ConsumeVarint(decoder.data)
+	if n < 0 {
+		decoder.err = protowire.ParseError(n)
+		return 0
+	}
+	decoder.data = decoder.data[n:]
+	return value
+}
+
+func (decoder *fieldDecoder) bytesValue(fieldType protowire.Type) []byte {
+	if fieldType != protowire.BytesType {
+		decoder.err = fmt.Errorf("unexpected wire type %d for a message field", fieldType)
+		return nil
+	}
+	value, n := protowire.ConsumeBytes(decoder.data)
+	if n < 0 {
+		decoder.err = protowire.ParseError(n)
+		return nil
+	}
+	decoder.data = decoder.data[n:]
+	return value
+}
+
+// skip consumes an unknown field.
+func (decoder *fieldDecoder) skip(num protowire.Number, fieldType protowire.Type) {
+	n := protowire.ConsumeFieldValue(num, fieldType, decoder.data)
+	if n < 0 {
+		decoder.err = protowire.ParseError(n)
+		return
+	}
+	decoder.data = decoder.data[n:]
+}
+
+// decodeMapEntry decodes one entry of a map<string, uint64> field
+// (see appendMapEntry).
+func decodeMapEntry(data []byte) (string, uint64, error) {
+	decoder := fieldDecoder{data: data}
+	var key string
+	var value uint64
+	for {
+		num, fieldType, ok := decoder.next()
+		if !ok {
+			break
+		}
+		switch num {
+		case 1:
+			key = decoder.stringValue(fieldType)
+		case 2:
+			value = decoder.varintValue(fieldType)
+		default:
+			decoder.skip(num, fieldType)
+		}
+	}
+	return key, value, decoder.err
+}
@@ -0,0 +1,202 @@
+package grpcapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The tests check the hand-marshalled encoding (see wire.go) against
+// the official protobuf runtime: the messages are decoded and re-encoded
+// through dynamicpb with a descriptor mirroring observer.proto,
+// standing in for the protoc-generated stubs of the clients.
+
+func descField(name string, num int32, fieldType descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(num),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   fieldType.Enum(),
+	}
+}
+
+func descMessageField(name string, num int32, typeName string) *descriptorpb.FieldDescriptorProto {
+	field := descField(name, num, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	field.TypeName = proto.String(typeName)
+	return field
+}
+
+func descRepeated(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return field
+}
+
+// descMapEntry is the implicit nested message behind a map<string, uint64> field.
+func descMapEntry(name string) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{
+		Name:    proto.String(name),
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		Field: []*descriptorpb.FieldDescriptorProto{
+			descField("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			descField("value", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+		},
+	}
+}
+
+// observerDescriptor mirrors the messages of observer.proto.
+func observerDescriptor(t *testing.T) protoreflect.FileDescriptor {
+	fileProto := descriptorpb.FileDescriptorProto{
+		Name:    proto.String("observer.proto"),
+		Package: proto.String("observer"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Node"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					descField("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					descField("ip", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					descField("port_rlpx", 3, descriptorpb.FieldDescriptorProto_TYPE_UINT32),
+					descField("client_id", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+					descField("network_id", 5, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+					descField("eth_version", 6, descriptorpb.FieldDescriptorProto_TYPE_UINT32),
+					descField("asn", 7, descriptorpb.FieldDescriptorProto_TYPE_UINT32),
+					descField("addr_updated", 8, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+					descField("change_seq", 9, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+				},
+			},
+			{
+				Name: proto.String("ListNodesResponse"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					descRepeated(descMessageField("nodes", 1, ".observer.Node")),
+					descField("next_page_token", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+				},
+			},
+			{
+				Name: proto.String("GetStatsResponse"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					descField("total_nodes", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+					descField("operator_estimate", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+					descRepeated(descMessageField("client_counts", 3, ".observer.GetStatsResponse.ClientCountsEntry")),
+					descRepeated(descMessageField("country_counts", 4, ".observer.GetStatsResponse.CountryCountsEntry")),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{
+					descMapEntry("ClientCountsEntry"),
+					descMapEntry("CountryCountsEntry"),
+				},
+			},
+		},
+	}
+	file, err := protodesc.NewFile(&fileProto, nil)
+	require.NoError(t, err)
+	return file
+}
+
+func stubMessage(t *testing.T, name protoreflect.Name) *dynamicpb.Message {
+	descriptor := observerDescriptor(t).Messages().ByName(name)
+	require.NotNil(t, descriptor)
+	return dynamicpb.NewMessage(descriptor)
+}
+
+func stubField(m *dynamicpb.Message, name protoreflect.Name) protoreflect.Value {
+	return m.Get(m.Descriptor().Fields().ByName(name))
+}
+
+func TestNodeWireCompatibility(t *testing.T) {
+	node := Node{
+		ID:          "aabb",
+		IP:          "10.0.0.1",
+		PortRLPx:    30303,
+		ClientID:    "erigon/v2022.04.1",
+		NetworkID:   1,
+		EthVersion:  66,
+		ASN:         24940,
+		AddrUpdated: 1648771200,
+		ChangeSeq:   42,
+	}
+
+	// the stub decodes the hand-marshalled bytes field by field
+	stub := stubMessage(t, "Node")
+	require.NoError(t, proto.Unmarshal(node.marshal(), stub))
+	assert.Equal(t, node.ID, stubField(stub, "id").String())
+	assert.Equal(t, node.IP, stubField(stub, "ip").String())
+	assert.Equal(t, uint64(node.PortRLPx), stubField(stub, "port_rlpx").Uint())
+	assert.Equal(t, node.ClientID, stubField(stub, "client_id").String())
+	assert.Equal(t, node.NetworkID, stubField(stub, "network_id").Uint())
+	assert.Equal(t, uint64(node.EthVersion), stubField(stub, "eth_version").Uint())
+	assert.Equal(t, uint64(node.ASN), stubField(stub, "asn").Uint())
+	assert.Equal(t, node.AddrUpdated, stubField(stub, "addr_updated").Int())
+	assert.Equal(t, node.ChangeSeq, stubField(stub, "change_seq").Uint())
+
+	// the hand codec decodes the stub-marshalled bytes back to the original
+	stubData, err := proto.Marshal(stub)
+	require.NoError(t, err)
+	var decoded Node
+	require.NoError(t, decoded.unmarshal(stubData))
+	assert.Equal(t, node, decoded)
+}
+
+func TestListNodesResponseWireCompatibility(t *testing.T) {
+	response := ListNodesResponse{
+		Nodes: []*Node{
+			{ID: "aa", ChangeSeq: 1},
+			{ID: "bb", ChangeSeq: 2},
+		},
+		NextPageToken: 2,
+	}
+
+	stub := stubMessage(t, "ListNodesResponse")
+	require.NoError(t, proto.Unmarshal(response.marshal(), stub))
+	nodes := stubField(stub, "nodes").List()
+	require.Equal(t, 2, nodes.Len())
+	assert.Equal(t, "bb", nodes.Get(1).Message().Get(
+		nodes.Get(1).Message().Descriptor().Fields().ByName("id")).String())
+	assert.Equal(t, uint64(2), stubField(stub, "next_page_token").Uint())
+
+	stubData, err := proto.Marshal(stub)
+	require.NoError(t, err)
+	var decoded ListNodesResponse
+	require.NoError(t, decoded.unmarshal(stubData))
+	assert.Equal(t, response, decoded)
+}
+
+func TestGetStatsResponseWireCompatibility(t *testing.T) {
+	response := GetStatsResponse{
+		TotalNodes:       100,
+		OperatorEstimate: 40,
+		ClientCounts:     map[string]uint64{"erigon": 60, "geth": 40},
+		CountryCounts:    map[string]uint64{"DE": 70, "US": 30},
+	}
+
+	stub := stubMessage(t, "GetStatsResponse")
+	require.NoError(t, proto.Unmarshal(response.marshal(), stub))
+	clientCounts := stubField(stub, "client_counts").Map()
+	assert.Equal(t, 2, clientCounts.Len())
+	assert.Equal(t, uint64(60), clientCounts.Get(protoreflect.ValueOfString("erigon").MapKey()).Uint())
+
+	stubData, err := proto.Marshal(stub)
+	require.NoError(t, err)
+	var decoded GetStatsResponse
+	require.NoError(t, decoded.unmarshal(stubData))
+	assert.Equal(t, response, decoded)
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	// a newer client may send fields this server version doesn't know
+	node := Node{ID: "aa", NetworkID: 1}
+	data := node.marshal()
+	data = protowire.AppendTag(data, 100, protowire.VarintType)
+	data = protowire.AppendVarint(data, 7)
+	data = protowire.AppendTag(data, 101, protowire.BytesType)
+	data = protowire.AppendString(data, "future")
+
+	var decoded Node
+	require.NoError(t, decoded.unmarshal(data))
+	assert.Equal(t, node, decoded)
+}
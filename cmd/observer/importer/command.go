@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+
+	// EnodesFile is a text file with one enode:// URL or enr: record per line.
+	EnodesFile string
+	// DNSTreeURL is an enrtree:// URL of an EIP-1459 DNS tree.
+	DNSTreeURL string
+	// CSVFile is an ethernodes-style CSV file.
+	CSVFile string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "import",
+		Short: "Seed the nodes table from enode lists, EIP-1459 DNS trees or ethernodes-style CSV files",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withEnodesFile()
+	instance.withDNSTreeURL()
+	instance.withCSVFile()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withEnodesFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.EnodesFile, "enodes", "", "text file with one enode:// URL or enr: record per line")
+}
+
+func (command *Command) withDNSTreeURL() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DNSTreeURL, "dns-tree", "", "enrtree:// URL of an EIP-1459 DNS tree")
+}
+
+func (command *Command) withCSVFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.CSVFile, "csv", "", "ethernodes-style CSV file with node ID, host and port columns")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,182 @@
+// Package importer seeds the nodes table from external sources,
+// so that a fresh crawl can start from a known peer set instead of only the bootnodes.
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/p2p/dnsdisc"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+)
+
+// ImportEnodeFile seeds the nodes from a text file
+// with one enode:// URL or enr: record per line.
+// Blank lines and lines starting with # are skipped.
+// A malformed line is an error: the file is operator-provided,
+// and silently dropping entries would hide a typo.
+func ImportEnodeFile(ctx context.Context, db database.DB, path string) (uint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open the enode list: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var imported uint
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		node, err := enode.Parse(enode.ValidSchemes, line)
+		if err != nil {
+			return imported, fmt.Errorf("failed to parse line %d: %w", lineNum, err)
+		}
+
+		stored, err := storeNode(ctx, db, node)
+		if err != nil {
+			return imported, err
+		}
+		if stored {
+			imported++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imported, fmt.Errorf("failed to read the enode list: %w", err)
+	}
+	return imported, nil
+}
+
+// ImportDNSTree seeds the nodes from an EIP-1459 DNS tree
+// at the given enrtree:// URL.
+func ImportDNSTree(ctx context.Context, db database.DB, url string) (uint, error) {
+	client := dnsdisc.NewClient(dnsdisc.Config{})
+	tree, err := client.SyncTree(url)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sync the DNS tree: %w", err)
+	}
+
+	var imported uint
+	for _, node := range tree.Nodes() {
+		stored, err := storeNode(ctx, db, node)
+		if err != nil {
+			return imported, err
+		}
+		if stored {
+			imported++
+		}
+	}
+	return imported, nil
+}
+
+// ImportCSV seeds the nodes from an ethernodes-style CSV file.
+// The header row names the columns; the node ID, host and port columns
+// are recognized by a few common names. Unlike an enode list,
+// the rows are third-party bulk data, so the unusable ones
+// (a malformed ID, a hostname instead of an IP) are skipped, not fatal.
+func ImportCSV(ctx context.Context, db database.DB, path string) (uint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open the CSV file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the CSV header: %w", err)
+	}
+
+	idCol := findColumn(header, "id", "node_id", "nodeid")
+	hostCol := findColumn(header, "host", "ip", "address")
+	portCol := findColumn(header, "port", "tcp_port", "port_rlpx")
+	if (idCol < 0) || (hostCol < 0) || (portCol < 0) {
+		return 0, fmt.Errorf("failed to recognize the CSV columns in header: %s", strings.Join(header, ","))
+	}
+
+	var imported uint
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, fmt.Errorf("failed to read a CSV row: %w", err)
+		}
+
+		id, err := database.ParseNodeID(row[idCol])
+		if err != nil {
+			continue
+		}
+		if _, err := node_utils.ParseHexPublicKey(id); err != nil {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(row[hostCol]))
+		if ip == nil {
+			continue
+		}
+		port, err := strconv.ParseUint(strings.TrimSpace(row[portCol]), 10, 16)
+		if err != nil {
+			continue
+		}
+
+		var addr database.NodeAddr
+		if ip.To4() != nil {
+			addr.IP = ip
+			addr.PortRLPx = uint16(port)
+			addr.PortDisc = uint16(port)
+		} else {
+			addr.IPv6.IP = ip
+			addr.IPv6.PortRLPx = uint16(port)
+			addr.IPv6.PortDisc = uint16(port)
+		}
+
+		if err := db.UpsertNodeAddr(ctx, id, addr); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// storeNode upserts a parsed node. Incomplete nodes (without an IP address)
+// are skipped: the crawler could neither ping nor handshake them.
+func storeNode(ctx context.Context, db database.DB, node *enode.Node) (bool, error) {
+	if node.Incomplete() {
+		return false, nil
+	}
+	id, err := node_utils.NodeID(node)
+	if err != nil {
+		return false, err
+	}
+	if err := db.UpsertNodeAddr(ctx, id, node_utils.MakeNodeAddr(node)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func findColumn(header []string, names ...string) int {
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		for _, name := range names {
+			if column == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
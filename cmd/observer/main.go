@@ -0,0 +1,1292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/cmd/observer/admin"
+	"github.com/ledgerwatch/erigon/cmd/observer/api"
+	"github.com/ledgerwatch/erigon/cmd/observer/archive"
+	"github.com/ledgerwatch/erigon/cmd/observer/bootnodes"
+	"github.com/ledgerwatch/erigon/cmd/observer/compact"
+	"github.com/ledgerwatch/erigon/cmd/observer/ctl"
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/dbtool"
+	"github.com/ledgerwatch/erigon/cmd/observer/dnstree"
+	"github.com/ledgerwatch/erigon/cmd/observer/enrich"
+	"github.com/ledgerwatch/erigon/cmd/observer/events"
+	"github.com/ledgerwatch/erigon/cmd/observer/export"
+	"github.com/ledgerwatch/erigon/cmd/observer/federation"
+	"github.com/ledgerwatch/erigon/cmd/observer/fixtures"
+	"github.com/ledgerwatch/erigon/cmd/observer/grpcapi"
+	"github.com/ledgerwatch/erigon/cmd/observer/importer"
+	"github.com/ledgerwatch/erigon/cmd/observer/monitor"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/cmd/observer/peerlist"
+	"github.com/ledgerwatch/erigon/cmd/observer/policy"
+	"github.com/ledgerwatch/erigon/cmd/observer/prune"
+	"github.com/ledgerwatch/erigon/cmd/observer/replay"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/cmd/observer/simulate"
+	"github.com/ledgerwatch/erigon/cmd/observer/sqltool"
+	observerutils "github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// openDB connects to the selected database backend.
+func openDB(driver string, dsn string, readDSNs []string, dataDir string) (database.DB, error) {
+	switch driver {
+	case "sqlite":
+		if len(readDSNs) > 0 {
+			return nil, fmt.Errorf("read replicas are only supported with the postgres driver")
+		}
+		if dsn == "" {
+			dsn = filepath.Join(dataDir, "observer.sqlite")
+		}
+		return database.NewDBSQLite(dsn)
+	case "postgres":
+		return database.NewDBPostgres(dsn, readDSNs)
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+}
+
+// parseDBURI splits a database URI like "sqlite:observer.sqlite" or "postgres://..."
+// into the driver and DSN accepted by openDB.
+func parseDBURI(uri string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(uri, "sqlite:"):
+		return "sqlite", strings.TrimPrefix(uri, "sqlite:"), nil
+	case strings.HasPrefix(uri, "postgres://"):
+		return "postgres", uri, nil
+	default:
+		return "", "", fmt.Errorf("unsupported database URI: %s (expected sqlite:<path> or postgres://...)", uri)
+	}
+}
+
+func mainWithFlags(ctx context.Context, flags observer.CommandFlags) error {
+	meter := observer.NewBandwidthMeter(flags.BandwidthLimit)
+
+	server, err := observer.NewServer(flags, meter, log.Root())
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB(flags.DatabaseDriver, flags.DatabaseDSN, flags.DatabaseReadDSNs, flags.DataDir)
+	if err != nil {
+		return err
+	}
+	if flags.StrictRead {
+		db.EnableStrictRead()
+	}
+	if flags.WriteCachePeriod > 0 {
+		db = database.NewDBWriteCache(db, flags.WriteCachePeriod, log.Root())
+	}
+	defer func() { _ = db.Close() }()
+
+	networkID := uint(params.NetworkIDByChainName(flags.Chain))
+	go observer.StatusLoggerLoop(ctx, db, networkID, flags.MaxPingTries, meter, flags.StatusLogPeriod, log.Root())
+	go observer.SLOMonitorLoop(ctx, db, flags.SLOMonitorPeriod, log.Root())
+	go observer.ContentionLoggerLoop(ctx, flags.ContentionLogPeriod, log.Root())
+	go observer.SeenWindowsLoop(ctx, db, flags.SeenWindowsPeriod, log.Root())
+	go observer.AnalyticsLoop(ctx, db, flags.AnalyticsPeriod, log.Root())
+	go observer.StatsSnapshotsLoop(ctx, db, flags.MaxPingTries, networkID,
+		flags.StatsSnapshotsPeriod, flags.StatsSnapshotsRetention, log.Root())
+	if flags.PruneMaxAge > 0 {
+		go observer.PruneLoop(ctx, db, flags.PruneMaxAge, flags.MaxPingTries, flags.PrunePeriod, log.Root())
+	}
+	if (flags.ArchiveDir != "") && (flags.ArchiveMaxAge > 0) {
+		go archive.Loop(ctx, db, flags.ArchiveDir, flags.ArchiveMaxAge, flags.ArchivePeriod, log.Root())
+	}
+
+	if (flags.GeoIPCityFile != "") || (flags.GeoIPASNFile != "") {
+		stageOptions := enrich.StageOptions{
+			GeoIPCityFile: flags.GeoIPCityFile,
+			GeoIPASNFile:  flags.GeoIPASNFile,
+		}
+		geoStages, err := enrich.MakeStages([]string{"geo"}, stageOptions)
+		if err != nil {
+			return err
+		}
+		go enrich.BackgroundLoop(ctx, db, geoStages, 1000, time.Hour, log.Root())
+	}
+
+	pauseControl := observer.NewPauseControl()
+
+	var control *observer.ControlServer
+	if flags.ControlSocket != "" {
+		control = observer.NewControlServer(flags.ControlSocket, log.Root())
+		control.Handle("pause", func(ctx context.Context, arg string) (string, error) {
+			pauseControl.Pause()
+			return "paused", nil
+		})
+		control.Handle("resume", func(ctx context.Context, arg string) (string, error) {
+			pauseControl.Resume()
+			return "resumed", nil
+		})
+		control.Handle("bandwidth", func(ctx context.Context, arg string) (string, error) {
+			limit, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("expected a bandwidth cap in bytes per second: %w", err)
+			}
+			meter.SetLimit(limit)
+			if limit == 0 {
+				return "bandwidth cap removed", nil
+			}
+			return fmt.Sprintf("bandwidth cap set to %d bytes per second", limit), nil
+		})
+		control.Handle("snapshot", func(ctx context.Context, arg string) (string, error) {
+			if err := observer.TakeStatsSnapshot(ctx, db, flags.MaxPingTries, networkID,
+				flags.StatsSnapshotsRetention); err != nil {
+				return "", err
+			}
+			return "snapshot taken", nil
+		})
+		control.Handle("export", func(ctx context.Context, arg string) (string, error) {
+			if arg == "" {
+				return "", fmt.Errorf("expected an output file path")
+			}
+			file, err := os.Create(arg)
+			if err != nil {
+				return "", fmt.Errorf("failed to create the export file: %w", err)
+			}
+			defer func() { _ = file.Close() }()
+			filter := database.NodeExportFilter{NetworkID: networkID}
+			result, err := export.Export(ctx, db, file, export.FormatJSONLines, filter, "", false)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("exported %d nodes to %s", result.ExportedRows, arg), nil
+		})
+		go func() {
+			if err := control.Listen(ctx); err != nil {
+				log.Error("Control socket server failed", "err", err)
+			}
+		}()
+	}
+
+	var eventBus *events.Bus
+	if (flags.APIListenAddr != "") || (flags.EventsFile != "") {
+		eventBus = events.NewBus(log.Root())
+		if flags.EventsFile != "" {
+			eventsFile, err := os.OpenFile(flags.EventsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return fmt.Errorf("failed to open the events file: %w", err)
+			}
+			defer func() { _ = eventsFile.Close() }()
+			eventBus.AddSink(events.NewJSONLinesSink(eventsFile))
+		}
+		go events.PublishLoop(ctx, db, eventBus, flags.EventsPollPeriod, log.Root())
+	}
+
+	if flags.APIListenAddr != "" {
+		diagnose := func() []observer.DiagnosisFinding {
+			return observer.Diagnose(observer.CollectDiagnosisStats(meter))
+		}
+		apiServer := api.NewServer(flags.APIListenAddr, db, flags.APIToken, flags.Chain, diagnose, pauseControl,
+			flags.APIPrivacyThreshold, eventBus, log.Root())
+		go func() {
+			if err := apiServer.Listen(ctx); err != nil {
+				log.Error("API server failed", "err", err)
+			}
+		}()
+	}
+
+	if flags.GRPCListenAddr != "" {
+		grpcServer := grpcapi.NewServer(db, log.Root())
+		go func() {
+			if err := grpcServer.Listen(ctx, flags.GRPCListenAddr); err != nil {
+				log.Error("gRPC server failed", "err", err)
+			}
+		}()
+	}
+
+	if len(flags.FederationPeers) > 0 {
+		federationClient := federation.NewClient(db, flags.FederationPeers,
+			flags.FederationToken, flags.FederationPeriod, log.Root())
+		go federationClient.Run(ctx)
+	}
+
+	sourceIPs, err := observer.ParseSourceIPs(flags.SourceIPs)
+	if err != nil {
+		return err
+	}
+	dialer, err := observer.NewDialer(sourceIPs, observer.SourceAffinity(flags.SourceAffinity), 5*time.Second, meter)
+	if err != nil {
+		return err
+	}
+
+	var capture *observer.Capture
+	if flags.CaptureFile != "" {
+		capture, err = observer.NewCapture(flags.CaptureFile, flags.CaptureFileMaxSize, log.Root())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = capture.Close() }()
+	}
+
+	var replayLog *observer.ReplayLog
+	if flags.ReplayLogFile != "" {
+		replayLog, err = observer.NewReplayLog(flags.ReplayLogFile, flags.Chain, networkID, log.Root())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = replayLog.Close() }()
+	}
+
+	shard, err := observer.ParseShard(flags.Shard)
+	if err != nil {
+		return err
+	}
+
+	var denyList *observer.DenyList
+	if flags.DenyListURL != "" {
+		denyList = observer.NewDenyList()
+		go observer.RemoteResourceLoop(ctx, flags.DenyListURL, flags.RemoteRefreshPeriod,
+			denyList.Load, log.Root())
+	}
+
+	forkVerifier, err := observer.NewForkVerifier(flags.Chain)
+	if err != nil {
+		return err
+	}
+	if flags.ForkScheduleURL != "" {
+		go observer.RemoteResourceLoop(ctx, flags.ForkScheduleURL, flags.RemoteRefreshPeriod,
+			forkVerifier.ApplyForkSchedule, log.Root())
+	}
+
+	if (flags.DiscVersion != 4) && (flags.DiscVersion != 5) {
+		return fmt.Errorf("unsupported discovery version: %d", flags.DiscVersion)
+	}
+
+	crawlerConfig := observer.CrawlerConfig{
+		Chain:      flags.Chain,
+		Bootnodes:  server.Bootnodes(),
+		PrivateKey: server.PrivateKey(),
+		NetworkID:  networkID,
+
+		CrawlWorkers:     flags.CrawlWorkers,
+		HandshakeWorkers: flags.HandshakeWorkers,
+		RefreshTimeout:   flags.RefreshTimeout,
+		MaxPingTries:     flags.MaxPingTries,
+		KeygenCount:      flags.KeygenCount,
+
+		Duration: flags.Duration,
+		MaxNodes: flags.MaxNodes,
+		MaxDepth: flags.MaxDepth,
+		Shard:    shard,
+
+		InstanceID: flags.InstanceID,
+
+		HandshakeRefreshTimeout: flags.HandshakeRefreshTimeout,
+		HandshakeRetryDelay:     flags.HandshakeRetryDelay,
+		HandshakeMaxTries:       flags.HandshakeMaxTries,
+		HandshakeASNLimit:       flags.HandshakeASNLimit,
+		MaxDialsPerSec:          flags.CrawlMaxDialsPerSec,
+		MaxConnsPerIP:           flags.CrawlMaxConnsPerIP,
+		PortScanPorts:           flags.PortScanPorts,
+		IPv6First:               flags.DialIPv6First,
+		TxObserveSample:         flags.TxObserveSample,
+		TxObserveWindow:         flags.TxObserveWindow,
+
+		Dialer:  dialer,
+		Capture: capture,
+		Replay:  replayLog,
+		Pause:   pauseControl,
+
+		DenyList:     denyList,
+		ForkVerifier: forkVerifier,
+	}
+
+	var summary *observer.SessionSummary
+	if flags.DiscVersion == 5 {
+		discV5, err := server.ListenV5(ctx)
+		if err != nil {
+			return err
+		}
+
+		crawler, err := observer.NewCrawlerV5(discV5, db, crawlerConfig, log.Root())
+		if err != nil {
+			return err
+		}
+		if err := crawler.Run(ctx); err != nil {
+			return err
+		}
+		summary = crawler.SessionSummary()
+	} else {
+		discV4, err := server.Listen(ctx)
+		if err != nil {
+			return err
+		}
+
+		crawler, err := observer.NewCrawler(discV4, db, crawlerConfig, log.Root())
+		if err != nil {
+			return err
+		}
+		if control != nil {
+			control.Handle("queues", func(ctx context.Context, arg string) (string, error) {
+				return crawler.QueueStats(), nil
+			})
+		}
+		if err := crawler.Run(ctx); err != nil {
+			return err
+		}
+		summary = crawler.SessionSummary()
+	}
+
+	if (flags.Duration > 0) || (flags.MaxNodes > 0) {
+		fmt.Println(summary)
+	}
+	return nil
+}
+
+func reportWithFlags(ctx context.Context, flags reports.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	if flags.StrictRead {
+		db.EnableStrictRead()
+	}
+
+	if flags.EnrKey != "" {
+		enrReport, err := reports.CreateEnrReport(ctx, db, flags.EnrKey, flags.EnrValue, flags.EnrNodesMax)
+		if err != nil {
+			return err
+		}
+		fmt.Println(enrReport)
+		return nil
+	}
+
+	networkID := uint(params.NetworkIDByChainName(flags.Chain))
+
+	canonicalizer := reports.NewCanonicalizer()
+	if flags.ClientMappingsFile != "" {
+		if err := canonicalizer.LoadMappings(flags.ClientMappingsFile); err != nil {
+			return err
+		}
+	}
+
+	if flags.Survival {
+		survivalReport, err := reports.CreateSurvivalReport(ctx, db, canonicalizer, flags.ClientsLimit)
+		if err != nil {
+			return err
+		}
+		fmt.Println(survivalReport)
+		return nil
+	}
+
+	if flags.HandshakeErrors {
+		handshakeErrorsReport, err := reports.CreateHandshakeErrorsReport(ctx, db, canonicalizer, flags.ClientsLimit, networkID)
+		if err != nil {
+			return err
+		}
+		fmt.Println(handshakeErrorsReport)
+		return nil
+	}
+
+	if flags.Latency {
+		latencyReport, err := reports.CreateLatencyReport(ctx, db, canonicalizer, flags.ClientsLimit)
+		if err != nil {
+			return err
+		}
+		fmt.Println(latencyReport)
+		return nil
+	}
+
+	if flags.TimeOfDay {
+		timeOfDayReport, err := reports.CreateTimeOfDayReport(ctx, db, flags.ClientsLimit)
+		if err != nil {
+			return err
+		}
+		fmt.Println(timeOfDayReport)
+		return nil
+	}
+
+	if flags.BlockPropagation {
+		propagationReport, err := reports.CreateBlockPropagationReport(ctx, db, flags.BlockPropagationMinBlocks, flags.ClientsLimit)
+		if err != nil {
+			return err
+		}
+		fmt.Println(propagationReport)
+		return nil
+	}
+
+	if flags.NodeHistory != "" {
+		nodeHistoryReport, err := reports.CreateNodeHistoryReport(ctx, db, flags.ArchiveDir, flags.NodeHistory)
+		if err != nil {
+			return err
+		}
+		fmt.Println(nodeHistoryReport)
+		return nil
+	}
+
+	if flags.KeyRotations {
+		keyRotationReport, err := reports.CreateKeyRotationReport(ctx, db, flags.KeyRotationsMinKeys)
+		if err != nil {
+			return err
+		}
+		fmt.Println(keyRotationReport)
+		return nil
+	}
+
+	if flags.BadRows {
+		badRowsReport, err := reports.CreateBadRowsReport(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Println(badRowsReport)
+		return nil
+	}
+
+	if flags.Blocklist {
+		blocklistReport, err := reports.CreateBlocklistReport(ctx, db, flags.BlocklistThreshold)
+		if err != nil {
+			return err
+		}
+		fmt.Println(blocklistReport)
+		return nil
+	}
+
+	if flags.AsOf != "" {
+		asOf, err := time.Parse(time.RFC3339, flags.AsOf)
+		if err != nil {
+			return fmt.Errorf("failed to parse --as-of: %w", err)
+		}
+		asOfReport, err := reports.CreateAsOfReport(ctx, db, canonicalizer, flags.ClientsLimit, asOf, flags.AsOfWindow)
+		if err != nil {
+			return err
+		}
+		fmt.Println(asOfReport)
+		return nil
+	}
+
+	format, err := reports.ParseFormat(flags.Format)
+	if err != nil {
+		return err
+	}
+	structuredReport, err := reports.CreateStructuredStatusReport(ctx, db, canonicalizer,
+		flags.ClientsLimit, flags.MaxPingTries, networkID)
+	if err != nil {
+		return err
+	}
+	output, err := structuredReport.Render(format)
+	if err != nil {
+		return err
+	}
+	fmt.Println(output)
+
+	// the machine formats stay parseable: the classic text reports below
+	// are only appended to the human table output
+	if format != reports.FormatTable {
+		return nil
+	}
+
+	minQuality := database.ObservationQualityInferred
+	if flags.VerifiedOnly {
+		minQuality = database.ObservationQualityVerified
+	}
+
+	clientsReport, err := reports.CreateClientsReport(ctx, db, canonicalizer, flags.ClientsLimit, flags.MaxPingTries, networkID, minQuality)
+	if err != nil {
+		return err
+	}
+	fmt.Println(clientsReport)
+
+	vantagePointsReport, err := reports.CreateVantagePointsReport(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(vantagePointsReport.VantagePoints) > 0 {
+		fmt.Println(vantagePointsReport)
+	}
+
+	return nil
+}
+
+func reportCustomWithFlags(ctx context.Context, flags reports.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	reportParams, err := reports.ParseReportParams(flags.SQLParams)
+	if err != nil {
+		return err
+	}
+
+	customReport, err := reports.CreateCustomReport(ctx, db, flags.Template, reportParams)
+	if err != nil {
+		return err
+	}
+	fmt.Println(customReport)
+	return nil
+}
+
+func enrichWithFlags(ctx context.Context, flags enrich.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	stageOptions := enrich.StageOptions{
+		GeoIPCityFile:       flags.GeoIPCityFile,
+		GeoIPASNFile:        flags.GeoIPASNFile,
+		RulesFile:           flags.RulesFile,
+		ReputationProviders: flags.ReputationProviders,
+		AbuseIPDBKey:        flags.AbuseIPDBKey,
+		GreyNoiseKey:        flags.GreyNoiseKey,
+		ReputationListFile:  flags.ReputationListFile,
+	}
+	stages, err := enrich.MakeStages(flags.Stages, stageOptions)
+	if err != nil {
+		return err
+	}
+
+	enricher := enrich.NewEnricher(db, stages, flags.BatchSize, log.Root())
+	return enricher.Run(ctx)
+}
+
+func monitorAddWithFlags(ctx context.Context, flags monitor.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	nodeID, err := database.ParseNodeID(flags.NodeID)
+	if err != nil {
+		return err
+	}
+	if _, err := node_utils.ParseHexPublicKey(nodeID); err != nil {
+		return fmt.Errorf("invalid node ID: %w", err)
+	}
+
+	node := database.MonitoredNode{
+		ID:         nodeID,
+		Label:      flags.Label,
+		SLOTarget:  flags.SLOTarget / 100,
+		WindowDays: flags.WindowDays,
+	}
+	return db.UpsertMonitoredNode(ctx, node)
+}
+
+func monitorRemoveWithFlags(ctx context.Context, flags monitor.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	nodeID, err := database.ParseNodeID(flags.NodeID)
+	if err != nil {
+		return err
+	}
+	return db.DeleteMonitoredNode(ctx, nodeID)
+}
+
+func monitorStatusWithFlags(ctx context.Context, flags monitor.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	nodes, err := db.EnumerateMonitoredNodes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if _, err := observer.UpdateSLOStatus(ctx, db, node); err != nil {
+			return err
+		}
+	}
+
+	sloReport, err := reports.CreateSLOReport(ctx, db)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sloReport)
+	return nil
+}
+
+func policySetWithFlags(ctx context.Context, flags policy.CommandFlags) error {
+	if (flags.Tag == "") && (flags.Subnet == "") && (flags.Country == "") {
+		return fmt.Errorf("a policy needs at least one of --tag, --subnet or --country")
+	}
+	if flags.Subnet != "" {
+		if _, _, err := net.ParseCIDR(flags.Subnet); err != nil {
+			return fmt.Errorf("invalid subnet: %w", err)
+		}
+	}
+	for _, hour := range []int{flags.QuietStartHour, flags.QuietEndHour} {
+		if (hour < 0) || (hour > 23) {
+			return fmt.Errorf("a quiet hour must be between 0 and 23, got %d", hour)
+		}
+	}
+
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	probePolicy := database.ProbePolicy{
+		Name:           flags.Name,
+		Tag:            flags.Tag,
+		Subnet:         flags.Subnet,
+		Country:        flags.Country,
+		MinInterval:    flags.MinInterval,
+		QuietStartHour: flags.QuietStartHour,
+		QuietEndHour:   flags.QuietEndHour,
+		UTCOffset:      flags.UTCOffset,
+	}
+	return db.UpsertProbePolicy(ctx, probePolicy)
+}
+
+func policyRemoveWithFlags(ctx context.Context, flags policy.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	return db.DeleteProbePolicy(ctx, flags.Name)
+}
+
+func policyListWithFlags(ctx context.Context, flags policy.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	policies, err := db.EnumerateProbePolicies(ctx)
+	if err != nil {
+		return err
+	}
+	for _, probePolicy := range policies {
+		fmt.Printf("%s: tag=%q subnet=%q country=%q min-interval=%s quiet=%d-%d utc-offset=%s\n",
+			probePolicy.Name, probePolicy.Tag, probePolicy.Subnet, probePolicy.Country,
+			probePolicy.MinInterval, probePolicy.QuietStartHour, probePolicy.QuietEndHour,
+			probePolicy.UTCOffset)
+	}
+	return nil
+}
+
+func dbSchemaWithFlags(ctx context.Context, flags dbtool.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	tables, err := db.DescribeSchema(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(dbtool.FormatSchemaDoc(tables, flags.Markdown))
+	return nil
+}
+
+func dbGCWithFlags(ctx context.Context, flags dbtool.CommandFlags) error {
+	filePath := filepath.Join(flags.DataDir, "observer.sqlite")
+	db, err := database.NewDBSQLite(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	sizeBefore := fileSize(filePath)
+
+	counts, err := db.CleanupOrphanRows(ctx)
+	if err != nil {
+		return err
+	}
+	for table, count := range counts {
+		if count > 0 {
+			log.Info("Removed orphan rows", "table", table, "rows", count)
+		}
+	}
+
+	if err := db.Vacuum(ctx); err != nil {
+		return err
+	}
+
+	reclaimed := sizeBefore - fileSize(filePath)
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	log.Info("GC done", "reclaimedBytes", reclaimed)
+	return nil
+}
+
+func fileSize(filePath string) int64 {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func dbSnapshotWithFlags(ctx context.Context, flags dbtool.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	progress := func(table string, copiedRows uint, totalRows uint) {
+		log.Info("Snapshotting", "table", table, "rows", copiedRows, "total", totalRows)
+	}
+	if err := database.SnapshotDB(ctx, db, flags.SnapshotFile, progress); err != nil {
+		return err
+	}
+	log.Info("Snapshot done", "file", flags.SnapshotFile, "bytes", fileSize(flags.SnapshotFile))
+	return nil
+}
+
+func dbAdviseWithFlags(ctx context.Context, flags dbtool.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	advices, err := database.AdviseIndexes(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(advices) == 0 {
+		fmt.Println("no full scans found in the known query set")
+		return nil
+	}
+
+	for _, advice := range advices {
+		fmt.Printf("query %s: %s (%d rows)\n", advice.Query, advice.Detail, advice.Rows)
+		if advice.DDL != "" {
+			fmt.Printf("    suggest: %s\n", advice.DDL)
+		} else {
+			fmt.Println("    no index to suggest (the table is small, or no advised index fits)")
+		}
+	}
+
+	if flags.CreateIndexes {
+		created, err := database.CreateAdvisedIndexes(ctx, db, advices)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %d indexes\n", created)
+	}
+	return nil
+}
+
+func adminPauseWithFlags(ctx context.Context, flags admin.CommandFlags) error {
+	if err := admin.Post(ctx, flags.APIURL, flags.APIToken, "pause"); err != nil {
+		return err
+	}
+	log.Info("Crawl paused", "api", flags.APIURL)
+	return nil
+}
+
+func adminResumeWithFlags(ctx context.Context, flags admin.CommandFlags) error {
+	if err := admin.Post(ctx, flags.APIURL, flags.APIToken, "resume"); err != nil {
+		return err
+	}
+	log.Info("Crawl resumed", "api", flags.APIURL)
+	return nil
+}
+
+func ctlWithFlags(ctx context.Context, flags ctl.CommandFlags, action string, arg string) error {
+	response, err := ctl.Call(ctx, flags.Socket, action, arg)
+	if err != nil {
+		return err
+	}
+	if response != "" {
+		fmt.Println(response)
+	}
+	return nil
+}
+
+func dbCopyWithFlags(ctx context.Context, flags dbtool.CommandFlags) error {
+	fromDriver, fromDSN, err := parseDBURI(flags.FromURI)
+	if err != nil {
+		return err
+	}
+	toDriver, toDSN, err := parseDBURI(flags.ToURI)
+	if err != nil {
+		return err
+	}
+
+	source, err := openDB(fromDriver, fromDSN, nil, flags.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open the source database: %w", err)
+	}
+	defer func() { _ = source.Close() }()
+
+	target, err := openDB(toDriver, toDSN, nil, flags.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to open the target database: %w", err)
+	}
+	defer func() { _ = target.Close() }()
+
+	progress := func(table string, copiedRows uint, totalRows uint) {
+		log.Info("Copying", "table", table, "rows", copiedRows, "total", totalRows)
+	}
+	if err := database.CopyDB(ctx, source, target, progress); err != nil {
+		return err
+	}
+	log.Info("Copy done")
+	return nil
+}
+
+func simulateHelloWithFlags(ctx context.Context, flags simulate.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	caps, err := observer.ParseHelloCaps(flags.Caps)
+	if err != nil {
+		return err
+	}
+
+	simulator, err := simulate.NewSimulator(db, caps, flags.SampleSize, flags.Concurrency, flags.Timeout, log.Root())
+	if err != nil {
+		return err
+	}
+
+	if len(flags.ClientIDs) > 0 {
+		capSets := [][]p2p.Cap{caps}
+		for _, capSet := range flags.CapSets {
+			parsedCaps, err := observer.ParseHelloCaps(strings.Split(capSet, ","))
+			if err != nil {
+				return err
+			}
+			capSets = append(capSets, parsedCaps)
+		}
+
+		var identities []simulate.MatrixIdentity
+		for _, clientID := range flags.ClientIDs {
+			for _, identityCaps := range capSets {
+				identities = append(identities, simulate.MatrixIdentity{ClientID: clientID, Caps: identityCaps})
+			}
+		}
+
+		matrixResult, err := simulator.RunMatrix(ctx, identities)
+		if err != nil {
+			return err
+		}
+		fmt.Println(matrixResult)
+		return nil
+	}
+
+	result, err := simulator.Run(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(result)
+	return nil
+}
+
+func replayWithFlags(ctx context.Context, flags replay.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	replayer := replay.NewReplayer(db, flags.LogFile, log.Root())
+	summary, err := replayer.Run(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(summary)
+	return nil
+}
+
+func exportWithFlags(ctx context.Context, flags export.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	output := os.Stdout
+	if flags.OutputFile != "-" {
+		output, err = os.Create(flags.OutputFile)
+		if err != nil {
+			return fmt.Errorf("failed to create the output file: %w", err)
+		}
+		defer func() { _ = output.Close() }()
+	}
+
+	if flags.SnapPeers {
+		// the preset defaults differ from the row export: the output is enode
+		// URLs rather than rows, and unbounded freshness would defeat the point
+		format := export.Format(flags.Format)
+		if format == export.FormatJSONLines {
+			format = export.FormatEnodeLines
+		}
+		handshakedWithin := flags.SeenWithin
+		if handshakedWithin == 0 {
+			handshakedWithin = 24 * time.Hour
+		}
+
+		peers, err := export.ExportSnapPeers(ctx, db, output, format, flags.NetworkID, handshakedWithin, flags.Limit)
+		if err != nil {
+			return err
+		}
+		log.Info("Export done", "peers", peers)
+		return nil
+	}
+
+	filter := database.NodeExportFilter{
+		ClientIDPrefix: flags.Client,
+		NetworkID:      flags.NetworkID,
+	}
+	if flags.SeenWithin > 0 {
+		filter.SeenSince = time.Now().Add(-flags.SeenWithin)
+	}
+
+	result, err := export.Export(ctx, db, output, export.Format(flags.Format), filter, flags.Checkpoint, flags.SinceLast)
+	if err != nil {
+		return err
+	}
+	log.Info("Export done", "rows", result.ExportedRows, "changeSeq", result.LastChangeSeq)
+	return nil
+}
+
+func pruneWithFlags(ctx context.Context, flags prune.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	counts, err := db.DeleteStaleNodes(ctx, time.Now().Add(-flags.OlderThan), flags.MaxPingTries)
+	if err != nil {
+		return err
+	}
+	for table, count := range counts {
+		if count > 0 {
+			log.Info("Pruned rows", "table", table, "rows", count)
+		}
+	}
+	log.Info("Prune done", "nodes", counts["nodes"])
+	return nil
+}
+
+func sqlWithFlags(ctx context.Context, flags sqltool.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	result, err := database.RunSandboxedQuery(ctx, db, flags.Query, flags.Timeout, flags.MaxRows)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(strings.Join(result.Columns, "\t"))
+	for _, row := range result.Rows {
+		fmt.Println(strings.Join(row, "\t"))
+	}
+	if result.Truncated {
+		fmt.Printf("(truncated at %d rows)\n", flags.MaxRows)
+	}
+	return nil
+}
+
+func bootnodesWithFlags(ctx context.Context, flags bootnodes.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	lines, err := bootnodes.List(ctx, db, flags.Chain, flags.Limit, bootnodes.Format(flags.Format))
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func dnstreeWithFlags(ctx context.Context, flags dnstree.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	keyFile := flags.KeyFile
+	if keyFile == "" {
+		keyFile = filepath.Join(flags.DataDir, "dnstree-key")
+	}
+	outputFile := flags.OutputFile
+	if outputFile == "" {
+		outputFile = filepath.Join(flags.DataDir, "dnstree.json")
+	}
+
+	key, err := observerutils.LoadOrGenerateKey(keyFile)
+	if err != nil {
+		return err
+	}
+
+	result, err := dnstree.Publish(ctx, db, flags.Chain, flags.Domain, flags.Limit, key, outputFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(result.URL)
+	if result.Changed {
+		fmt.Printf("published %d nodes at seq %d to %s\n", result.NodeCount, result.Seq, outputFile)
+	} else {
+		fmt.Printf("unchanged: %d nodes at seq %d\n", result.NodeCount, result.Seq)
+	}
+	return nil
+}
+
+func peerlistWithFlags(ctx context.Context, flags peerlist.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	keyFile := flags.KeyFile
+	if keyFile == "" {
+		keyFile = filepath.Join(flags.DataDir, "observer-key")
+	}
+	outputFile := flags.OutputFile
+	if outputFile == "" {
+		outputFile = filepath.Join(flags.DataDir, "peerlist.json")
+	}
+
+	key, err := observerutils.LoadOrGenerateKey(keyFile)
+	if err != nil {
+		return err
+	}
+
+	list, err := peerlist.Build(ctx, db, flags.Chain, flags.Limit, observerutils.SystemClock{})
+	if err != nil {
+		return err
+	}
+	signedList, err := peerlist.Sign(list, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(signedList, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode the signed peer list: %w", err)
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(outputFile, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write the signed peer list: %w", err)
+	}
+
+	fmt.Printf("signed %d peers to %s (pubkey %s)\n", len(list.Peers), outputFile, signedList.Pubkey)
+	return nil
+}
+
+func importWithFlags(ctx context.Context, flags importer.CommandFlags) error {
+	if (flags.EnodesFile == "") && (flags.DNSTreeURL == "") && (flags.CSVFile == "") {
+		return fmt.Errorf("at least one of --enodes, --dns-tree or --csv is required")
+	}
+
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if flags.EnodesFile != "" {
+		imported, err := importer.ImportEnodeFile(ctx, db, flags.EnodesFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d nodes from %s\n", imported, flags.EnodesFile)
+	}
+	if flags.DNSTreeURL != "" {
+		imported, err := importer.ImportDNSTree(ctx, db, flags.DNSTreeURL)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d nodes from %s\n", imported, flags.DNSTreeURL)
+	}
+	if flags.CSVFile != "" {
+		imported, err := importer.ImportCSV(ctx, db, flags.CSVFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d nodes from %s\n", imported, flags.CSVFile)
+	}
+	return nil
+}
+
+func fixturesGenerateWithFlags(ctx context.Context, flags fixtures.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	generator, err := fixtures.NewGenerator(db, flags.Chain, flags.Distribution, flags.Seed, log.Root())
+	if err != nil {
+		return err
+	}
+	return generator.Generate(ctx, flags.Nodes)
+}
+
+func compactWithFlags(ctx context.Context, flags compact.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	before := time.Now().Add(-time.Duration(flags.KeepDays) * 24 * time.Hour)
+	compacted, err := db.CompactHandshakeAttempts(ctx, before)
+	if err != nil {
+		return err
+	}
+	log.Info("Compaction done", "attempts", compacted)
+	return nil
+}
+
+func archiveWithFlags(ctx context.Context, flags archive.CommandFlags) error {
+	db, err := database.NewDBSQLite(filepath.Join(flags.DataDir, "observer.sqlite"))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	archiveDir := flags.ArchiveDir
+	if archiveDir == "" {
+		archiveDir = filepath.Join(flags.DataDir, "observer-archive")
+	}
+	archiver, err := archive.NewArchiver(db, archiveDir, log.Root())
+	if err != nil {
+		return err
+	}
+
+	before := time.Now().Add(-time.Duration(flags.KeepDays) * 24 * time.Hour)
+	return archiver.Run(ctx, before)
+}
+
+func main() {
+	ctx, cancel := common.RootContext()
+	defer cancel()
+
+	command := observer.NewCommand()
+
+	reportCommand := reports.NewCommand()
+	reportCommand.OnRun(reportWithFlags)
+	reportCommand.OnCustom(reportCustomWithFlags)
+	command.AddSubCommand(reportCommand.RawCommand())
+
+	enrichCommand := enrich.NewCommand()
+	enrichCommand.OnRun(enrichWithFlags)
+	command.AddSubCommand(enrichCommand.RawCommand())
+
+	monitorCommand := monitor.NewCommand()
+	monitorCommand.OnAdd(monitorAddWithFlags)
+	monitorCommand.OnRemove(monitorRemoveWithFlags)
+	monitorCommand.OnStatus(monitorStatusWithFlags)
+	command.AddSubCommand(monitorCommand.RawCommand())
+
+	policyCommand := policy.NewCommand()
+	policyCommand.OnSet(policySetWithFlags)
+	policyCommand.OnRemove(policyRemoveWithFlags)
+	policyCommand.OnList(policyListWithFlags)
+	command.AddSubCommand(policyCommand.RawCommand())
+
+	compactCommand := compact.NewCommand()
+	compactCommand.OnRun(compactWithFlags)
+	command.AddSubCommand(compactCommand.RawCommand())
+
+	archiveCommand := archive.NewCommand()
+	archiveCommand.OnRun(archiveWithFlags)
+	command.AddSubCommand(archiveCommand.RawCommand())
+
+	exportCommand := export.NewCommand()
+	exportCommand.OnRun(exportWithFlags)
+	command.AddSubCommand(exportCommand.RawCommand())
+
+	simulateCommand := simulate.NewCommand()
+	simulateCommand.OnRun(simulateHelloWithFlags)
+	command.AddSubCommand(simulateCommand.RawCommand())
+
+	replayCommand := replay.NewCommand()
+	replayCommand.OnRun(replayWithFlags)
+	command.AddSubCommand(replayCommand.RawCommand())
+
+	sqlCommand := sqltool.NewCommand()
+	sqlCommand.OnRun(sqlWithFlags)
+	command.AddSubCommand(sqlCommand.RawCommand())
+
+	pruneCommand := prune.NewCommand()
+	pruneCommand.OnRun(pruneWithFlags)
+	command.AddSubCommand(pruneCommand.RawCommand())
+
+	bootnodesCommand := bootnodes.NewCommand()
+	bootnodesCommand.OnRun(bootnodesWithFlags)
+	command.AddSubCommand(bootnodesCommand.RawCommand())
+
+	dnstreeCommand := dnstree.NewCommand()
+	dnstreeCommand.OnRun(dnstreeWithFlags)
+	command.AddSubCommand(dnstreeCommand.RawCommand())
+
+	peerlistCommand := peerlist.NewCommand()
+	peerlistCommand.OnRun(peerlistWithFlags)
+	command.AddSubCommand(peerlistCommand.RawCommand())
+
+	importCommand := importer.NewCommand()
+	importCommand.OnRun(importWithFlags)
+	command.AddSubCommand(importCommand.RawCommand())
+
+	fixturesCommand := fixtures.NewCommand()
+	fixturesCommand.OnGenerate(fixturesGenerateWithFlags)
+	command.AddSubCommand(fixturesCommand.RawCommand())
+
+	dbCommand := dbtool.NewCommand()
+	dbCommand.OnSchema(dbSchemaWithFlags)
+	dbCommand.OnCopy(dbCopyWithFlags)
+	dbCommand.OnGC(dbGCWithFlags)
+	dbCommand.OnSnapshot(dbSnapshotWithFlags)
+	dbCommand.OnAdvise(dbAdviseWithFlags)
+	command.AddSubCommand(dbCommand.RawCommand())
+
+	adminCommand := admin.NewCommand()
+	adminCommand.OnPause(adminPauseWithFlags)
+	adminCommand.OnResume(adminResumeWithFlags)
+	command.AddSubCommand(adminCommand.RawCommand())
+
+	ctlCommand := ctl.NewCommand()
+	ctlCommand.OnRun(ctlWithFlags)
+	command.AddSubCommand(ctlCommand.RawCommand())
+
+	if err := command.ExecuteContext(ctx, mainWithFlags); err != nil {
+		log.Error("observer failed", "err", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDBURI(t *testing.T) {
+	driver, dsn, err := parseDBURI("sqlite:observer.sqlite")
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite", driver)
+	assert.Equal(t, "observer.sqlite", dsn)
+
+	driver, dsn, err = parseDBURI("postgres://observer@db.example.com/observer")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", driver)
+	assert.Equal(t, "postgres://observer@db.example.com/observer", dsn)
+
+	_, _, err = parseDBURI("observer.sqlite")
+	assert.Error(t, err)
+}
+
+func TestOpenDB(t *testing.T) {
+	db, err := openDB("sqlite", "", nil, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = openDB("sqlite", "", []string{"sqlite:replica.sqlite"}, t.TempDir())
+	assert.Error(t, err)
+
+	_, err = openDB("mysql", "", nil, t.TempDir())
+	assert.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir    string
+	NodeID     string
+	Label      string
+	SLOTarget  float64 // target availability in percent, e.g. 99.5
+	WindowDays uint
+}
+
+type Command struct {
+	command       cobra.Command
+	addCommand    cobra.Command
+	removeCommand cobra.Command
+	statusCommand cobra.Command
+	flags         CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "monitor",
+		Short: "Manage the list of monitored nodes and inspect their SLO status",
+	}
+
+	addCommand := cobra.Command{
+		Use:   "add",
+		Short: "Add a node to the monitored list",
+	}
+
+	removeCommand := cobra.Command{
+		Use:   "rm",
+		Short: "Remove a node from the monitored list",
+	}
+
+	statusCommand := cobra.Command{
+		Use:   "status",
+		Short: "Show the SLO status of the monitored nodes",
+	}
+
+	instance := Command{
+		command:       command,
+		addCommand:    addCommand,
+		removeCommand: removeCommand,
+		statusCommand: statusCommand,
+	}
+
+	instance.withDatadir()
+	instance.withNodeID()
+	instance.withLabel()
+	instance.withSLOTarget()
+	instance.withWindowDays()
+
+	instance.command.AddCommand(&instance.addCommand)
+	instance.command.AddCommand(&instance.removeCommand)
+	instance.command.AddCommand(&instance.statusCommand)
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.PersistentFlags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkPersistentFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withNodeID() {
+	for _, subCommand := range []*cobra.Command{&command.addCommand, &command.removeCommand} {
+		flags := subCommand.Flags()
+		flags.StringVar(&command.flags.NodeID, "node", "", "node public key in hex")
+		if err := subCommand.MarkFlagRequired("node"); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (command *Command) withLabel() {
+	flags := command.addCommand.Flags()
+	flags.StringVar(&command.flags.Label, "label", "", "human-readable node label")
+}
+
+func (command *Command) withSLOTarget() {
+	flags := command.addCommand.Flags()
+	flags.Float64Var(&command.flags.SLOTarget, "slo", 99.5, "target availability in percent")
+}
+
+func (command *Command) withWindowDays() {
+	flags := command.addCommand.Flags()
+	flags.UintVar(&command.flags.WindowDays, "window-days", 30, "availability window in days")
+}
+
+func (command *Command) OnAdd(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.addCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) OnRemove(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.removeCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) OnStatus(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.statusCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,75 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// The analytics categories materialized for dashboards
+// (see database.DB.ReplaceAnalyticsAggregates).
+const (
+	AnalyticsCategoryClient    = "client"
+	AnalyticsCategoryCountry   = "country"
+	AnalyticsCategoryPort      = "port"
+	AnalyticsCategoryIPVersion = "ip_version"
+)
+
+// AnalyticsLoop periodically materializes the dashboard aggregates
+// (client, country, port and IP version distributions),
+// so that dashboards query small pre-aggregated tables
+// instead of scanning the whole nodes table on every panel refresh.
+func AnalyticsLoop(ctx context.Context, db database.DB, period time.Duration, logger log.Logger) {
+	for ctx.Err() == nil {
+		if err := refreshAnalyticsAggregates(ctx, db); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to refresh the analytics aggregates", "err", err)
+			}
+		}
+
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+	}
+}
+
+func refreshAnalyticsAggregates(ctx context.Context, db database.DB) error {
+	clientGroups, err := db.CountClientGroups(ctx, 0)
+	if err != nil {
+		return err
+	}
+	clientCounts := make(map[string]uint)
+	for clientID, count := range clientGroups {
+		clientCounts[reports.NameFromClientID(clientID)] += count
+	}
+	if err := db.ReplaceAnalyticsAggregates(ctx, AnalyticsCategoryClient, clientCounts); err != nil {
+		return err
+	}
+
+	countryCounts, err := db.CountNodesByCountry(ctx, 0)
+	if err != nil {
+		return err
+	}
+	if err := db.ReplaceAnalyticsAggregates(ctx, AnalyticsCategoryCountry, countryCounts); err != nil {
+		return err
+	}
+
+	portCounts, err := db.CountRLPxPortGroups(ctx)
+	if err != nil {
+		return err
+	}
+	if err := db.ReplaceAnalyticsAggregates(ctx, AnalyticsCategoryPort, portCounts); err != nil {
+		return err
+	}
+
+	ipVersionCounts, err := db.CountIPVersions(ctx)
+	if err != nil {
+		return err
+	}
+	return db.ReplaceAnalyticsAggregates(ctx, AnalyticsCategoryIPVersion, ipVersionCounts)
+}
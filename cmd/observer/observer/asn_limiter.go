@@ -0,0 +1,57 @@
+package observer
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// ASNLimiter caps concurrent handshakes per remote autonomous system,
+// so that the rate limiting of a single big hosting provider
+// doesn't distort the success-rate statistics,
+// and the crawler doesn't trigger provider-wide blocks.
+// A nil limiter imposes no limits.
+type ASNLimiter struct {
+	limit uint
+
+	mutex sync.Mutex
+	sems  map[uint]*semaphore.Weighted
+}
+
+func NewASNLimiter(limit uint) *ASNLimiter {
+	if limit == 0 {
+		return nil
+	}
+	instance := ASNLimiter{
+		limit: limit,
+		sems:  make(map[uint]*semaphore.Weighted),
+	}
+	return &instance
+}
+
+func (limiter *ASNLimiter) sem(asn uint) *semaphore.Weighted {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	sem, ok := limiter.sems[asn]
+	if !ok {
+		sem = semaphore.NewWeighted(int64(limiter.limit))
+		limiter.sems[asn] = sem
+	}
+	return sem
+}
+
+func (limiter *ASNLimiter) Acquire(ctx context.Context, asn uint) error {
+	if limiter == nil {
+		return nil
+	}
+	return limiter.sem(asn).Acquire(ctx, 1)
+}
+
+func (limiter *ASNLimiter) Release(asn uint) {
+	if limiter == nil {
+		return
+	}
+	limiter.sem(asn).Release(1)
+}
@@ -0,0 +1,229 @@
+package observer
+
+import (
+	"context"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/ledgerwatch/erigon/p2p/discover"
+	"golang.org/x/time/rate"
+)
+
+// BandwidthMeter accounts the traffic the observer exchanges with the network,
+// in total and per remote subnet, and optionally enforces a global bandwidth cap
+// so that the observer can run politely on metered or shared links.
+// The totals are also exported as the observer_traffic_bytes metrics.
+type BandwidthMeter struct {
+	bytesSent     uint64
+	bytesReceived uint64
+
+	// limiter caps the combined send and receive rate
+	// (an infinite rate means no cap, see SetLimit).
+	limiter *rate.Limiter
+
+	mutex   sync.Mutex
+	subnets map[string]*SubnetTraffic
+
+	sentCounter     *metrics.Counter
+	receivedCounter *metrics.Counter
+}
+
+// SubnetTraffic is the traffic exchanged with a single remote /24 (IPv6: /48) subnet.
+type SubnetTraffic struct {
+	Subnet        string
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// NewBandwidthMeter creates a meter capping the combined send and receive bandwidth
+// at limitBytesPerSec. Zero means accounting only, without a cap.
+// The cap can be changed at runtime (see SetLimit): an uncapped meter
+// still carries a limiter, set to an infinite rate.
+func NewBandwidthMeter(limitBytesPerSec uint64) *BandwidthMeter {
+	limit := rate.Inf
+	burst := math.MaxInt32
+	if limitBytesPerSec > 0 {
+		limit = rate.Limit(limitBytesPerSec)
+		burst = int(limitBytesPerSec)
+	}
+	limiter := rate.NewLimiter(limit, burst)
+	instance := BandwidthMeter{
+		limiter:         limiter,
+		subnets:         make(map[string]*SubnetTraffic),
+		sentCounter:     metrics.GetOrCreateCounter(`observer_traffic_bytes{dir="sent"}`),
+		receivedCounter: metrics.GetOrCreateCounter(`observer_traffic_bytes{dir="received"}`),
+	}
+	return &instance
+}
+
+func (meter *BandwidthMeter) BytesSent() uint64 {
+	if meter == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&meter.bytesSent)
+}
+
+func (meter *BandwidthMeter) BytesReceived() uint64 {
+	if meter == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&meter.bytesReceived)
+}
+
+// TopSubnets lists the remote subnets which exchanged the most traffic with the observer.
+func (meter *BandwidthMeter) TopSubnets(limit uint) []SubnetTraffic {
+	if meter == nil {
+		return nil
+	}
+
+	meter.mutex.Lock()
+	subnets := make([]SubnetTraffic, 0, len(meter.subnets))
+	for _, traffic := range meter.subnets {
+		subnets = append(subnets, *traffic)
+	}
+	meter.mutex.Unlock()
+
+	sort.Slice(subnets, func(i, j int) bool {
+		return (subnets[i].BytesSent + subnets[i].BytesReceived) >
+			(subnets[j].BytesSent + subnets[j].BytesReceived)
+	})
+	if limit < uint(len(subnets)) {
+		subnets = subnets[:limit]
+	}
+	return subnets
+}
+
+// MeterConn wraps a TCP connection so that its traffic is accounted and capped.
+func (meter *BandwidthMeter) MeterConn(conn net.Conn, remoteIP net.IP) net.Conn {
+	if meter == nil {
+		return conn
+	}
+	return &meteredConn{conn, meter, remoteIP}
+}
+
+// MeterUDPConn wraps the discovery UDP listener so that its traffic is accounted and capped.
+func (meter *BandwidthMeter) MeterUDPConn(conn *net.UDPConn) discover.UDPConn {
+	if meter == nil {
+		return conn
+	}
+	return &meteredUDPConn{conn, meter}
+}
+
+func (meter *BandwidthMeter) accountSent(remoteIP net.IP, byteCount int) {
+	if byteCount <= 0 {
+		return
+	}
+	atomic.AddUint64(&meter.bytesSent, uint64(byteCount))
+	meter.sentCounter.Add(byteCount)
+	meter.addSubnetTraffic(remoteIP, uint64(byteCount), 0)
+}
+
+func (meter *BandwidthMeter) accountReceived(remoteIP net.IP, byteCount int) {
+	if byteCount <= 0 {
+		return
+	}
+	atomic.AddUint64(&meter.bytesReceived, uint64(byteCount))
+	meter.receivedCounter.Add(byteCount)
+	meter.addSubnetTraffic(remoteIP, 0, uint64(byteCount))
+}
+
+func (meter *BandwidthMeter) addSubnetTraffic(remoteIP net.IP, sent uint64, received uint64) {
+	subnet := trafficSubnet(remoteIP)
+
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+
+	traffic := meter.subnets[subnet]
+	if traffic == nil {
+		traffic = &SubnetTraffic{Subnet: subnet}
+		meter.subnets[subnet] = traffic
+	}
+	traffic.BytesSent += sent
+	traffic.BytesReceived += received
+}
+
+// SetLimit changes the bandwidth cap at runtime (see the ctl command).
+// A zero limit removes the cap.
+func (meter *BandwidthMeter) SetLimit(limitBytesPerSec uint64) {
+	if limitBytesPerSec == 0 {
+		meter.limiter.SetLimit(rate.Inf)
+		meter.limiter.SetBurst(math.MaxInt32)
+		return
+	}
+	meter.limiter.SetLimit(rate.Limit(limitBytesPerSec))
+	meter.limiter.SetBurst(int(limitBytesPerSec))
+}
+
+// waitBudget blocks until the bandwidth cap allows to transfer the given amount.
+func (meter *BandwidthMeter) waitBudget(byteCount int) {
+	if meter.limiter.Limit() == rate.Inf {
+		return
+	}
+	for byteCount > 0 {
+		chunk := byteCount
+		if chunk > meter.limiter.Burst() {
+			chunk = meter.limiter.Burst()
+		}
+		if err := meter.limiter.WaitN(context.Background(), chunk); err != nil {
+			return
+		}
+		byteCount -= chunk
+	}
+}
+
+func trafficSubnet(ip net.IP) string {
+	if ip == nil {
+		return "unknown"
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+type meteredConn struct {
+	net.Conn
+	meter    *BandwidthMeter
+	remoteIP net.IP
+}
+
+func (conn *meteredConn) Read(buf []byte) (int, error) {
+	byteCount, err := conn.Conn.Read(buf)
+	conn.meter.accountReceived(conn.remoteIP, byteCount)
+	// applying the cap after the read slows the reader down,
+	// and the TCP flow control propagates the backpressure to the remote side
+	conn.meter.waitBudget(byteCount)
+	return byteCount, err
+}
+
+func (conn *meteredConn) Write(buf []byte) (int, error) {
+	conn.meter.waitBudget(len(buf))
+	byteCount, err := conn.Conn.Write(buf)
+	conn.meter.accountSent(conn.remoteIP, byteCount)
+	return byteCount, err
+}
+
+type meteredUDPConn struct {
+	*net.UDPConn
+	meter *BandwidthMeter
+}
+
+func (conn *meteredUDPConn) ReadFromUDP(buf []byte) (int, *net.UDPAddr, error) {
+	byteCount, addr, err := conn.UDPConn.ReadFromUDP(buf)
+	if addr != nil {
+		conn.meter.accountReceived(addr.IP, byteCount)
+	}
+	conn.meter.waitBudget(byteCount)
+	return byteCount, addr, err
+}
+
+func (conn *meteredUDPConn) WriteToUDP(buf []byte, addr *net.UDPAddr) (int, error) {
+	conn.meter.waitBudget(len(buf))
+	byteCount, err := conn.UDPConn.WriteToUDP(buf, addr)
+	conn.meter.accountSent(addr.IP, byteCount)
+	return byteCount, err
+}
@@ -0,0 +1,129 @@
+package observer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Capture appends the plaintext RLPx handshake messages exchanged with remote nodes
+// to a capture file as JSON lines, keyed by node ID,
+// so that protocol-level anomalies found in the data can be analyzed after the fact.
+// Payloads are recorded after decryption and framing, and no key material is written.
+// The file is size-capped: when it grows past maxSize it is rotated once,
+// keeping at most two generations (a simple ring).
+// A nil Capture records nothing.
+type Capture struct {
+	mutex sync.Mutex
+	file  *os.File
+	size  uint64
+
+	path    string
+	maxSize uint64
+
+	log log.Logger
+}
+
+const (
+	CaptureDirectionIn  = "in"
+	CaptureDirectionOut = "out"
+)
+
+type captureRecord struct {
+	Time      string `json:"time"`
+	NodeID    string `json:"node"`
+	Direction string `json:"dir"`
+	MessageID uint64 `json:"msg"`
+	Data      string `json:"data"`
+}
+
+func NewCapture(path string, maxSize uint64, logger log.Logger) (*Capture, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the capture file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to stat the capture file: %w", err)
+	}
+
+	instance := Capture{
+		file:    file,
+		size:    uint64(info.Size()),
+		path:    path,
+		maxSize: maxSize,
+		log:     logger,
+	}
+	return &instance, nil
+}
+
+// Record appends a message to the capture file. It never fails the caller:
+// capturing is diagnostics, and write errors are only logged.
+func (capture *Capture) Record(nodeID string, direction string, messageID uint64, data []byte) {
+	if capture == nil {
+		return
+	}
+
+	record := captureRecord{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		NodeID:    nodeID,
+		Direction: direction,
+		MessageID: messageID,
+		Data:      hex.EncodeToString(data),
+	}
+	line, err := json.Marshal(&record)
+	if err != nil {
+		capture.log.Error("Failed to encode a capture record", "err", err)
+		return
+	}
+	line = append(line, '\n')
+
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+
+	if capture.size+uint64(len(line)) > capture.maxSize {
+		if err := capture.rotate(); err != nil {
+			capture.log.Error("Failed to rotate the capture file", "err", err)
+			return
+		}
+	}
+
+	n, err := capture.file.Write(line)
+	capture.size += uint64(n)
+	if err != nil {
+		capture.log.Error("Failed to write a capture record", "err", err)
+	}
+}
+
+func (capture *Capture) rotate() error {
+	if err := capture.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(capture.path, capture.path+".1"); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(capture.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	capture.file = file
+	capture.size = 0
+	return nil
+}
+
+func (capture *Capture) Close() error {
+	if capture == nil {
+		return nil
+	}
+	capture.mutex.Lock()
+	defer capture.mutex.Unlock()
+	return capture.file.Close()
+}
@@ -0,0 +1,87 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// contentionTopOps is how many of the most conflicting operations the summary names.
+const contentionTopOps = 3
+
+// ContentionLoggerLoop periodically logs a summary of the database contention:
+// how many operations ran since the last summary, how many hit a conflict error,
+// and which operations conflicted the most (see database.DBConflictStats).
+// The summary makes the effect of batching and locking changes measurable.
+func ContentionLoggerLoop(ctx context.Context, period time.Duration, logger log.Logger) {
+	lastOps, lastConflicts := database.DBOpStats()
+	lastConflictsByOp := database.DBConflictStats()
+
+	for ctx.Err() == nil {
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+
+		ops, conflicts := database.DBOpStats()
+		conflictsByOp := database.DBConflictStats()
+
+		opsDelta := ops - lastOps
+		conflictsDelta := conflicts - lastConflicts
+
+		rate := "0.00%"
+		if opsDelta > 0 {
+			rate = fmt.Sprintf("%.2f%%", float64(conflictsDelta)*100/float64(opsDelta))
+		}
+
+		logger.Info("DB contention",
+			"ops", opsDelta,
+			"conflicts", conflictsDelta,
+			"rate", rate,
+			"top", topConflictOps(conflictsByOp, lastConflictsByOp))
+
+		lastOps, lastConflicts = ops, conflicts
+		lastConflictsByOp = conflictsByOp
+	}
+}
+
+// topConflictOps formats the operations with the most conflicts in the window,
+// e.g. "UpsertNodeAddr:12 UpdateCrawlRetryTime:3".
+func topConflictOps(conflictsByOp map[string]uint64, lastConflictsByOp map[string]uint64) string {
+	type opConflicts struct {
+		opName string
+		count  uint64
+	}
+
+	var deltas []opConflicts
+	for opName, count := range conflictsByOp {
+		delta := count - lastConflictsByOp[opName]
+		if delta > 0 {
+			deltas = append(deltas, opConflicts{opName, delta})
+		}
+	}
+	sort.Slice(deltas, func(i int, j int) bool {
+		if deltas[i].count != deltas[j].count {
+			return deltas[i].count > deltas[j].count
+		}
+		return deltas[i].opName < deltas[j].opName
+	})
+
+	if len(deltas) > contentionTopOps {
+		deltas = deltas[:contentionTopOps]
+	}
+
+	var builder strings.Builder
+	for i, entry := range deltas {
+		if i > 0 {
+			builder.WriteRune(' ')
+		}
+		builder.WriteString(fmt.Sprintf("%s:%d", entry.opName, entry.count))
+	}
+	return builder.String()
+}
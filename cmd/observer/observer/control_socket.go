@@ -0,0 +1,139 @@
+package observer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+// ControlServer serves the control socket of a running observer:
+// a unix socket speaking a line protocol, so the ctl command can drive
+// the process (pause/resume, rate changes, snapshots, exports, queue dumps)
+// without restarts or direct database pokes.
+// A request is one line "action [argument]"; the response starts with
+// an "ok" or "error: <message>" line, followed by the optional body.
+// The actions are registered by the startup code (see Handle),
+// so the set reflects what the running configuration actually supports.
+type ControlServer struct {
+	socketPath string
+
+	mu       sync.RWMutex
+	handlers map[string]ControlHandler
+
+	log log.Logger
+}
+
+// ControlHandler runs one control action and returns the response body.
+type ControlHandler func(ctx context.Context, arg string) (string, error)
+
+// controlRequestTimeout bounds one control connection,
+// so a stuck client can't pin a handler forever.
+const controlRequestTimeout = 30 * time.Second
+
+func NewControlServer(socketPath string, logger log.Logger) *ControlServer {
+	instance := ControlServer{
+		socketPath: socketPath,
+		handlers:   make(map[string]ControlHandler),
+		log:        logger,
+	}
+	return &instance
+}
+
+// Handle registers an action. Registering is allowed while the server
+// is listening: some actions (e.g. the queue dump) only become available
+// once the crawler exists.
+func (server *ControlServer) Handle(action string, handler ControlHandler) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.handlers[action] = handler
+}
+
+// Listen accepts control connections until the context is cancelled.
+// A stale socket file left by a crashed process is replaced.
+func (server *ControlServer) Listen(ctx context.Context) error {
+	_ = os.Remove(server.socketPath)
+	listener, err := net.Listen("unix", server.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on the control socket: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+		_ = os.Remove(server.socketPath)
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept a control connection: %w", err)
+		}
+		go server.serveConn(ctx, conn)
+	}
+}
+
+func (server *ControlServer) serveConn(ctx context.Context, conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(controlRequestTimeout))
+
+	requestCtx, cancel := context.WithTimeout(ctx, controlRequestTimeout)
+	defer cancel()
+
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		server.log.Debug("Failed to read a control request", "err", err)
+		return
+	}
+
+	action := strings.TrimSpace(request)
+	arg := ""
+	if separator := strings.IndexByte(action, ' '); separator >= 0 {
+		arg = strings.TrimSpace(action[separator+1:])
+		action = action[:separator]
+	}
+
+	body, err := server.runAction(requestCtx, action, arg)
+	if err != nil {
+		_, _ = fmt.Fprintf(conn, "error: %s\n", err.Error())
+		return
+	}
+	if _, err := fmt.Fprintln(conn, "ok"); err != nil {
+		return
+	}
+	if body != "" {
+		_, _ = fmt.Fprintln(conn, body)
+	}
+}
+
+func (server *ControlServer) runAction(ctx context.Context, action string, arg string) (string, error) {
+	server.mu.RLock()
+	handler := server.handlers[action]
+	known := make([]string, 0, len(server.handlers))
+	for name := range server.handlers {
+		known = append(known, name)
+	}
+	server.mu.RUnlock()
+
+	if handler == nil {
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown action %q (known: %s)", action, strings.Join(known, ", "))
+	}
+
+	body, err := handler(ctx, arg)
+	if (err != nil) && errors.Is(err, context.Canceled) {
+		return "", fmt.Errorf("the observer is shutting down")
+	}
+	return body, err
+}
@@ -0,0 +1,56 @@
+package observer
+
+import (
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// The crawl statistics are exported on the Prometheus /metrics endpoint
+// when the metrics server is enabled (see the --metrics flags).
+// The gauges are refreshed by StatusLoggerLoop
+// (except the operator estimate, which is refreshed by StatsSnapshotsLoop),
+// and the handshake counters are incremented by Diplomat.
+var (
+	handshakeSuccessCounter = metrics.GetOrCreateCounter(`observer_handshakes_total{result="success"}`)
+	handshakeFailureCounter = metrics.GetOrCreateCounter(`observer_handshakes_total{result="failure"}`)
+
+	nodesDiscoveredCounter = metrics.GetOrCreateCounter(`observer_nodes_discovered_total`)
+
+	statusNodeCount        uint64
+	statusDistinctIPCount  uint64
+	statusFrontierCount    uint64
+	statusOperatorEstimate uint64
+)
+
+func init() {
+	metrics.GetOrCreateGauge(`observer_nodes`, func() float64 {
+		return float64(atomic.LoadUint64(&statusNodeCount))
+	})
+	metrics.GetOrCreateGauge(`observer_distinct_ips`, func() float64 {
+		return float64(atomic.LoadUint64(&statusDistinctIPCount))
+	})
+	metrics.GetOrCreateGauge(`observer_candidates_queue`, func() float64 {
+		return float64(atomic.LoadUint64(&statusFrontierCount))
+	})
+	metrics.GetOrCreateGauge(`observer_operators_estimate`, func() float64 {
+		return float64(atomic.LoadUint64(&statusOperatorEstimate))
+	})
+}
+
+// updateStatusMetrics refreshes the crawl gauges with the latest counts.
+func updateStatusMetrics(totalCount uint, distinctIPCount uint, frontierCount uint) {
+	lastTotalCount := atomic.SwapUint64(&statusNodeCount, uint64(totalCount))
+	if uint64(totalCount) > lastTotalCount {
+		nodesDiscoveredCounter.Add(int(uint64(totalCount) - lastTotalCount))
+	}
+	atomic.StoreUint64(&statusDistinctIPCount, uint64(distinctIPCount))
+	atomic.StoreUint64(&statusFrontierCount, uint64(frontierCount))
+}
+
+// updateOperatorEstimateMetric refreshes the operator estimate gauge
+// with the value computed for the latest stats snapshot
+// (see database.DB.EstimateOperatorCount).
+func updateOperatorEstimateMetric(operatorEstimate uint) {
+	atomic.StoreUint64(&statusOperatorEstimate, uint64(operatorEstimate))
+}
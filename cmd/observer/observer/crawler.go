@@ -0,0 +1,746 @@
+package observer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type Crawler struct {
+	transport DiscV4Transport
+
+	db             database.DBRetrier
+	saveQueue      chan nodeSaveTask
+	handshakeQueue chan handshakeTask
+
+	config       CrawlerConfig
+	diplomat     *Diplomat
+	forkVerifier *ForkVerifier
+	clock        utils.Clock
+
+	sessionStart          time.Time
+	crawledCount          uint64
+	savedCount            uint64
+	handshakeCount        uint64
+	handshakeSuccessCount uint64
+
+	// starvedWorkers counts the crawl workers whose last candidate lease
+	// came back empty, and boostedInterrogations how many interrogations
+	// ran with boosted discovery effort (see interrogationKeygenCount).
+	starvedWorkers        int64
+	boostedInterrogations uint64
+
+	log log.Logger
+}
+
+type CrawlerConfig struct {
+	Chain      string
+	Bootnodes  []*enode.Node
+	PrivateKey *ecdsa.PrivateKey
+
+	// NetworkID restricts the candidates to the crawled chain,
+	// so that one database can hold the nodes of multiple chains.
+	// Nodes with an unknown network are still crawled.
+	NetworkID uint
+
+	// CrawlWorkers and HandshakeWorkers size the bounded worker pools:
+	// each crawl worker leases its own candidate batch and interrogates the nodes,
+	// while the handshake workers drain the queued RLPx handshakes separately,
+	// so that slow handshakes don't stall the discovery crawl.
+	CrawlWorkers     uint
+	HandshakeWorkers uint
+
+	RefreshTimeout time.Duration
+	MaxPingTries   uint
+
+	// Duration and MaxNodes bound the crawl session: zero means unlimited.
+	Duration time.Duration
+	MaxNodes uint
+
+	// MaxDepth bounds how many hops from the bootnodes the crawl explores: zero means unlimited.
+	MaxDepth uint
+
+	// Shard restricts this worker to a slice of the node ID keyspace (see Shard).
+	Shard Shard
+
+	// InstanceID names this observer instance in the candidate lease owners:
+	// empty means derived from the host name and the PID.
+	InstanceID string
+
+	KeygenCount uint
+
+	HandshakeRefreshTimeout time.Duration
+	HandshakeRetryDelay     time.Duration
+	HandshakeMaxTries       uint
+	// HandshakeASNLimit caps concurrent handshakes per remote ASN: zero means unlimited.
+	HandshakeASNLimit uint
+	// MaxDialsPerSec caps the global outgoing dial rate and MaxConnsPerIP
+	// the concurrent connections to a single remote IP (see RateLimiter):
+	// zero means unlimited.
+	MaxDialsPerSec uint
+	MaxConnsPerIP  uint
+	// PortScanPorts are alternate RLPx ports to try when the handshake
+	// at the advertised port fails to connect (empty - no port scan).
+	PortScanPorts []uint
+	// IPv6First prefers the advertised IPv6 address of a dual-stack node
+	// over the IPv4 one when dialing.
+	IPv6First bool
+	// TxObserveSample listens for tx announcements on every Nth successfully
+	// handshaked peer for TxObserveWindow: zero disables the sampling.
+	TxObserveSample uint
+	TxObserveWindow time.Duration
+
+	Dialer *Dialer
+	// Capture optionally records the handshake traffic for later analysis.
+	Capture *Capture
+	// Replay optionally records the session's database inputs,
+	// so the session can be re-fed against a fresh database (see ReplayLog).
+	Replay *ReplayLog
+
+	// Pause optionally lets the operators pause the crawl at runtime (see PauseControl).
+	Pause *PauseControl
+
+	// DenyList optionally lists the nodes the crawler must not probe,
+	// possibly refreshed from a remote URL at runtime (see DenyList).
+	DenyList *DenyList
+
+	// ForkVerifier overrides the verifier built from Chain when set,
+	// so that a shared instance can receive remote fork schedule updates
+	// (see ForkVerifier.ApplyForkSchedule).
+	ForkVerifier *ForkVerifier
+
+	// Clock overrides the wall clock in tests: nil means the system clock.
+	Clock utils.Clock
+}
+
+func NewCrawler(
+	transport DiscV4Transport,
+	db database.DB,
+	config CrawlerConfig,
+	logger log.Logger,
+) (*Crawler, error) {
+	dbRetrier := database.NewDBRetrier(db, logger)
+
+	var clock utils.Clock = utils.SystemClock{}
+	if config.Clock != nil {
+		clock = config.Clock
+	}
+
+	forkVerifier := config.ForkVerifier
+	if forkVerifier == nil {
+		var err error
+		forkVerifier, err = NewForkVerifier(config.Chain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	diplomat := NewDiplomat(
+		dbRetrier,
+		config.PrivateKey,
+		config.Dialer,
+		NewASNLimiter(config.HandshakeASNLimit),
+		NewRateLimiter(config.MaxDialsPerSec, config.MaxConnsPerIP),
+		config.Capture,
+		NewProbeScheduler(dbRetrier, logger),
+		forkVerifier,
+		clock,
+		config.HandshakeRefreshTimeout,
+		config.HandshakeRetryDelay,
+		config.HandshakeMaxTries,
+		config.PortScanPorts,
+		config.IPv6First,
+		config.TxObserveSample,
+		config.TxObserveWindow,
+		logger)
+
+	instance := Crawler{
+		transport:      transport,
+		db:             dbRetrier,
+		saveQueue:      make(chan nodeSaveTask, 1000),
+		handshakeQueue: make(chan handshakeTask, config.HandshakeWorkers),
+		config:         config,
+		diplomat:       diplomat,
+		forkVerifier:   forkVerifier,
+		clock:          clock,
+		log:            logger,
+	}
+	return &instance, nil
+}
+
+func (crawler *Crawler) Run(ctx context.Context) error {
+	crawler.sessionStart = crawler.clock.Now()
+
+	if crawler.config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, crawler.config.Duration)
+		defer cancel()
+	}
+
+	if err := crawler.saveBootnodes(ctx); err != nil {
+		return err
+	}
+
+	go crawler.saveLoop(ctx)
+
+	var handshakeWorkers sync.WaitGroup
+	for i := uint(0); i < crawler.config.HandshakeWorkers; i++ {
+		handshakeWorkers.Add(1)
+		go func() {
+			defer handshakeWorkers.Done()
+			crawler.handshakeWorker(ctx)
+		}()
+	}
+
+	var crawlWorkers sync.WaitGroup
+	for i := uint(0); i < crawler.config.CrawlWorkers; i++ {
+		crawlWorkers.Add(1)
+		go func(workerNum uint) {
+			defer crawlWorkers.Done()
+			crawler.crawlWorker(ctx, workerNum)
+		}(i)
+	}
+
+	// wait for the nodes being crawled before reporting the session summary;
+	// only the crawl workers enqueue handshakes, so the queue can be closed after them
+	crawlWorkers.Wait()
+	close(crawler.handshakeQueue)
+	handshakeWorkers.Wait()
+
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) && (crawler.config.Duration > 0) {
+		// a bounded session running out of time is a clean exit
+		err = nil
+	}
+	return err
+}
+
+// crawlWorkerBatchSize is how many candidates a crawl worker leases at once.
+// Small batches keep the workers busy without starving each other.
+const crawlWorkerBatchSize = 10
+
+// crawlWorkerMaxBackoff caps the exponential backoff of a worker
+// that keeps failing to lease candidates.
+const crawlWorkerMaxBackoff = 30 * time.Second
+
+// candidateLeaseDuration is how long an acquired candidate stays leased
+// without a renewal. An expired lease is reclaimed by the other workers
+// (see database.AcquireCandidates), so a crashed worker can't leave nodes stuck.
+const candidateLeaseDuration = 15 * time.Minute
+
+// leaseOwner identifies a crawl worker for the candidate leases.
+// The instance ID keeps the owners of the observer processes sharing
+// one database apart (see CrawlerConfig.InstanceID).
+func (crawler *Crawler) leaseOwner(workerNum uint) string {
+	instanceID := crawler.config.InstanceID
+	if instanceID == "" {
+		hostname, _ := os.Hostname()
+		instanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	return fmt.Sprintf("%s-crawl-worker-%d", instanceID, workerNum)
+}
+
+// crawlWorker leases candidate batches and interrogates them one by one,
+// renewing the lease of the remaining batch before each node,
+// and releasing the leases once the batch is done.
+// When the database is too busy to lease candidates, the worker backs off
+// exponentially, so that the pool doesn't pile more load on it.
+func (crawler *Crawler) crawlWorker(ctx context.Context, workerNum uint) {
+	shardIDLower, shardIDUpper := crawler.config.Shard.IDRange()
+	owner := crawler.leaseOwner(workerNum)
+	backoff := time.Second
+	starved := false
+
+	defer func() {
+		if starved {
+			atomic.AddInt64(&crawler.starvedWorkers, -1)
+		}
+	}()
+
+	for (ctx.Err() == nil) && !crawler.isMaxNodesReached() {
+		if crawler.isPaused() {
+			if utils.Sleep(ctx, 1*time.Second) != nil {
+				return
+			}
+			continue
+		}
+
+		candidates, err := crawler.db.AcquireCandidates(ctx, owner, candidateLeaseDuration,
+			crawler.config.NetworkID, crawlWorkerBatchSize, shardIDLower, shardIDUpper)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			crawler.log.Error("Failed to acquire candidates", "err", err)
+			if utils.Sleep(ctx, backoff) != nil {
+				return
+			}
+			if backoff < crawlWorkerMaxBackoff {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+
+		if len(candidates) == 0 {
+			if !starved {
+				starved = true
+				if crawler.isStarved(atomic.AddInt64(&crawler.starvedWorkers, 1)) {
+					crawler.log.Info("Candidate starvation detected: boosting the discovery effort")
+				}
+			}
+			if utils.Sleep(ctx, 1*time.Second) != nil {
+				return
+			}
+			continue
+		}
+		if starved {
+			starved = false
+			atomic.AddInt64(&crawler.starvedWorkers, -1)
+		}
+		crawler.config.Replay.RecordBatch(owner, candidates)
+
+		for i, id := range candidates {
+			if (ctx.Err() != nil) || crawler.isMaxNodesReached() {
+				// the remaining leases are left to expire: the context is gone,
+				// so a release call couldn't reach the database anyway
+				return
+			}
+			if i > 0 {
+				if err := crawler.db.RenewCandidateLeases(ctx, owner, candidateLeaseDuration, candidates[i:]); err != nil {
+					if !errors.Is(err, context.Canceled) {
+						crawler.log.Warn("Failed to renew candidate leases", "err", err)
+					}
+				}
+			}
+			atomic.AddUint64(&crawler.crawledCount, 1)
+			crawler.crawlNode(ctx, id)
+		}
+
+		if err := crawler.db.ReleaseCandidateLeases(ctx, owner, candidates); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				crawler.log.Warn("Failed to release candidate leases", "err", err)
+			}
+		}
+	}
+}
+
+// handshakeWorker drains the handshakes queued by the crawl workers (see crawlNode).
+func (crawler *Crawler) handshakeWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task, ok := <-crawler.handshakeQueue:
+			if !ok {
+				return
+			}
+			for crawler.isPaused() {
+				if utils.Sleep(ctx, 1*time.Second) != nil {
+					return
+				}
+			}
+			atomic.AddUint64(&crawler.handshakeCount, 1)
+			startTime := crawler.clock.Now()
+			hello, _, handshakeErr := crawler.diplomat.Run(ctx, task.node, task.id)
+			duration := crawler.clock.Now().Sub(startTime)
+			if handshakeErr == nil {
+				atomic.AddUint64(&crawler.handshakeSuccessCount, 1)
+				clientID := ""
+				if hello != nil {
+					clientID = hello.ClientID
+				}
+				crawler.config.Replay.RecordDial(task.id, clientID, "", duration)
+			} else {
+				crawler.config.Replay.RecordDial(task.id, "", handshakeErr.StringCode(), duration)
+			}
+		}
+	}
+}
+
+func (crawler *Crawler) isPaused() bool {
+	return (crawler.config.Pause != nil) && crawler.config.Pause.IsPaused()
+}
+
+// discoveryBoostFactor multiplies the keygen count while the crawl is starved.
+const discoveryBoostFactor = 2
+
+// isStarved tells if the given number of starved workers means candidate
+// starvation: at least half of the pool keeps leasing empty batches,
+// i.e. almost every compatible node is on its crawl cooldown.
+func (crawler *Crawler) isStarved(starvedWorkers int64) bool {
+	return 2*starvedWorkers >= int64(crawler.config.CrawlWorkers)
+}
+
+// interrogationKeygenCount is the discovery effort of the next interrogation.
+// While the crawl is starved of candidates, more target keys per interrogation
+// reach wider Kademlia buckets, refilling the frontier faster and keeping
+// the handshake workers busy. The adaptation shows up in the session stats.
+func (crawler *Crawler) interrogationKeygenCount() uint {
+	if !crawler.isStarved(atomic.LoadInt64(&crawler.starvedWorkers)) {
+		return crawler.config.KeygenCount
+	}
+	atomic.AddUint64(&crawler.boostedInterrogations, 1)
+	return crawler.config.KeygenCount * discoveryBoostFactor
+}
+
+func (crawler *Crawler) isMaxNodesReached() bool {
+	return (crawler.config.MaxNodes > 0) && (atomic.LoadUint64(&crawler.crawledCount) >= uint64(crawler.config.MaxNodes))
+}
+
+// SessionSummary describes what a single crawler run has done.
+type SessionSummary struct {
+	Duration              time.Duration
+	CrawledCount          uint64
+	SavedCount            uint64
+	HandshakeCount        uint64
+	HandshakeSuccessCount uint64
+	// BoostedInterrogations is how many interrogations ran with boosted
+	// discovery effort because the crawl was starved of candidates.
+	BoostedInterrogations uint64
+}
+
+// SessionSummary returns the summary of the current run so far.
+func (crawler *Crawler) SessionSummary() *SessionSummary {
+	summary := SessionSummary{
+		Duration:              crawler.clock.Now().Sub(crawler.sessionStart).Truncate(time.Second),
+		CrawledCount:          atomic.LoadUint64(&crawler.crawledCount),
+		SavedCount:            atomic.LoadUint64(&crawler.savedCount),
+		HandshakeCount:        atomic.LoadUint64(&crawler.handshakeCount),
+		HandshakeSuccessCount: atomic.LoadUint64(&crawler.handshakeSuccessCount),
+		BoostedInterrogations: atomic.LoadUint64(&crawler.boostedInterrogations),
+	}
+	return &summary
+}
+
+// QueueStats dumps the fill levels of the internal crawler queues
+// (see the "queues" action of the ctl command).
+func (crawler *Crawler) QueueStats() string {
+	return fmt.Sprintf("save queue: %d of %d\nhandshake queue: %d of %d",
+		len(crawler.saveQueue), cap(crawler.saveQueue),
+		len(crawler.handshakeQueue), cap(crawler.handshakeQueue))
+}
+
+func (summary *SessionSummary) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("session duration: %s", summary.Duration))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("nodes crawled: %d", summary.CrawledCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("peers saved: %d", summary.SavedCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("handshakes: %d", summary.HandshakeCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("successful handshakes: %d", summary.HandshakeSuccessCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("boosted interrogations: %d", summary.BoostedInterrogations))
+	builder.WriteRune('\n')
+	return builder.String()
+}
+
+// nodeSaveTask is a discovered peer queued for saving,
+// together with where and how deep it was discovered.
+type nodeSaveTask struct {
+	node   *enode.Node
+	source string
+	depth  uint
+}
+
+// handshakeTask is a node due for an RLPx handshake,
+// queued by the crawl workers for the handshake worker pool.
+type handshakeTask struct {
+	node *enode.Node
+	id   database.NodeID
+}
+
+// frontierPriority ranks shallow nodes first so that the crawl expands breadth-first.
+func frontierPriority(depth uint) float64 {
+	return 1.0 / float64(depth+1)
+}
+
+func (crawler *Crawler) saveBootnodes(ctx context.Context) error {
+	for _, node := range crawler.config.Bootnodes {
+		if err := crawler.saveNode(ctx, nodeSaveTask{node, "bootnode", 0}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (crawler *Crawler) saveLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task := <-crawler.saveQueue:
+			if err := crawler.saveNode(ctx, task); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					crawler.log.Error("Failed to save a node", "err", err)
+				}
+			} else {
+				atomic.AddUint64(&crawler.savedCount, 1)
+			}
+		}
+	}
+}
+
+func (crawler *Crawler) saveNode(ctx context.Context, task nodeSaveTask) error {
+	id, err := node_utils.NodeID(task.node)
+	if err != nil {
+		return fmt.Errorf("failed to get a node ID: %w", err)
+	}
+
+	// a sharded worker leaves the discovered out-of-shard nodes to the other workers;
+	// the bootnodes are kept so that every worker can seed its crawl
+	if (task.source != "bootnode") && !crawler.config.Shard.Contains(id) {
+		return nil
+	}
+	addr := node_utils.MakeNodeAddr(task.node)
+	if err := crawler.db.UpsertNodeAddr(ctx, id, addr); err != nil {
+		return err
+	}
+	if err := crawler.db.UpdateNodeDiscVersion(ctx, id, database.DiscVersionV4); err != nil {
+		return err
+	}
+	if err := crawler.db.UpdateNodeDepth(ctx, id, task.depth); err != nil {
+		return err
+	}
+
+	entry := database.FrontierEntry{
+		ID:       id,
+		Priority: frontierPriority(task.depth),
+		Source:   task.source,
+		Depth:    task.depth,
+	}
+	if err := crawler.db.UpsertFrontierEntry(ctx, entry); err != nil {
+		return err
+	}
+	crawler.config.Replay.RecordFound(id, addr, task.source, task.depth, entry.Priority)
+	return nil
+}
+
+func (crawler *Crawler) crawlNode(ctx context.Context, id database.NodeID) {
+	logger := crawler.log.New("node", id)
+
+	addr, err := crawler.db.FindNodeAddr(ctx, id)
+	if err != nil {
+		logger.Error("Failed to find the node address", "err", err)
+		return
+	}
+	if addr == nil {
+		logger.Error("Unknown node ID")
+		return
+	}
+
+	node, err := node_utils.MakeNodeFromAddr(id, *addr)
+	if err != nil {
+		logger.Error("Failed to make a node from its address", "err", err)
+		return
+	}
+
+	if crawler.config.DenyList.IsDenied(id, node.IP()) {
+		logger.Debug("Skipping a denied node")
+		// push the node to the back of the schedule,
+		// so it is not immediately re-leased while denied
+		retryTime := crawler.clock.Now().Add(crawler.config.RefreshTimeout)
+		if err := crawler.db.UpdateCrawlRetryTime(ctx, id, retryTime); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to update the crawl retry time", "err", err)
+			}
+		}
+		return
+	}
+
+	if err := crawler.pingNode(ctx, node, id, logger); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Debug("Ping failed", "err", err)
+			// the normal retry scheduling takes over from here
+			if err := crawler.db.DeleteFrontierEntry(ctx, id); err != nil {
+				logger.Error("Failed to delete the frontier entry", "err", err)
+			}
+		}
+		return
+	}
+
+	depth, err := crawler.db.FindNodeDepth(ctx, id)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to find the node depth", "err", err)
+		}
+		return
+	}
+	var childDepth uint = 1
+	if depth != nil {
+		childDepth = *depth + 1
+	}
+
+	// peers beyond the radius are not explored
+	if (crawler.config.MaxDepth > 0) && (childDepth > crawler.config.MaxDepth) {
+		logger.Debug("Not interrogating a node at the crawl radius", "depth", childDepth-1)
+	} else {
+		probedBucketList, err := crawler.db.FindProbedBuckets(ctx, id)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to find the probed buckets", "err", err)
+			}
+			return
+		}
+		probedBuckets := make(map[uint]bool, len(probedBucketList))
+		for _, bucket := range probedBucketList {
+			probedBuckets[bucket] = true
+		}
+
+		targetKeys, targetBuckets := keygen(ctx, node, crawler.interrogationKeygenCount(), probedBuckets, logger)
+		interrogator := NewInterrogator(node, crawler.transport, targetKeys, logger)
+
+		result, err := interrogator.Run(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Debug("Interrogation failed", "err", err)
+			}
+		} else {
+			logger.Debug("Interrogation done", "peers", len(result.Peers))
+			for _, peer := range result.Peers {
+				select {
+				case crawler.saveQueue <- nodeSaveTask{peer, string(id), childDepth}:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if result.Enr != nil {
+				entries := node_utils.ExtractEnrEntries(result.Enr)
+				if err := crawler.db.UpdateEnrEntries(ctx, id, entries); err != nil {
+					if !errors.Is(err, context.Canceled) {
+						logger.Error("Failed to save the ENR entries", "err", err)
+					}
+				}
+				recordEnrForkID(ctx, crawler.db, crawler.forkVerifier, id, entries, logger)
+
+				if nodeENR, err := node_utils.EncodeEnrRecord(result.Enr); err != nil {
+					logger.Error("Failed to encode the ENR record", "err", err)
+				} else if err := crawler.db.UpsertNodeENR(ctx, id, nodeENR); err != nil {
+					if !errors.Is(err, context.Canceled) {
+						logger.Error("Failed to save the ENR record", "err", err)
+					}
+				}
+			}
+
+			if err := crawler.db.MarkProbedBuckets(ctx, id, targetBuckets); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					logger.Error("Failed to mark the probed buckets", "err", err)
+				}
+			}
+		}
+	}
+
+	isDue, err := crawler.diplomat.IsDueForHandshake(ctx, id)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to check the handshake retry time", "err", err)
+		}
+	} else if isDue {
+		select {
+		case crawler.handshakeQueue <- handshakeTask{node, id}:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if err := crawler.db.UpdateCrawlRetryTime(ctx, id, crawler.clock.Now().Add(crawler.config.RefreshTimeout)); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to update the crawl retry time", "err", err)
+		}
+	}
+	if err := crawler.db.DeleteFrontierEntry(ctx, id); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			logger.Error("Failed to delete the frontier entry", "err", err)
+		}
+	}
+}
+
+const pingRetries = 2
+
+// maxCrawlBackoffFactor caps the exponential refresh timeout growth
+// of the nodes which keep failing to respond to pings,
+// so they are still re-checked eventually.
+const maxCrawlBackoffFactor = 16
+
+func (crawler *Crawler) pingNode(ctx context.Context, node *enode.Node, id database.NodeID, logger log.Logger) error {
+	delayForAttempt := func(attempt int) time.Duration { return time.Second }
+	startTime := crawler.clock.Now()
+	_, err := utils.Retry(ctx, pingRetries, delayForAttempt, isPingTimeoutError, logger, "Ping", func(ctx context.Context) (interface{}, error) {
+		attemptStart := crawler.clock.Now()
+		pingErr := crawler.transport.Ping(node)
+		if pingErr == nil {
+			rtt := crawler.clock.Now().Sub(attemptStart)
+			if dbErr := crawler.db.InsertNodeLatency(ctx, id, database.NodeLatencyPing, rtt); dbErr != nil {
+				logger.Error("Failed to record the ping RTT", "err", dbErr)
+			}
+		}
+		return nil, pingErr
+	})
+	duration := crawler.clock.Now().Sub(startTime)
+	recordPingSymptom(isPingTimeoutError(err))
+
+	if err != nil {
+		// a failed ping means even discovery can't reach the node;
+		// the diplomat upgrades the class if a later handshake connects
+		if dbErr := crawler.db.UpdateReachability(ctx, id, database.ReachabilityUnreachable); dbErr != nil {
+			logger.Error("Failed to update the reachability", "err", dbErr)
+		}
+		if dbErr := crawler.db.UpdatePingError(ctx, id); dbErr != nil {
+			return dbErr
+		}
+
+		// Nodes that keep failing to respond are deprioritized:
+		// they are still re-checked, but the refresh timeout doubles
+		// with every failed try past the limit (capped by maxCrawlBackoffFactor).
+		tries, dbErr := crawler.db.CountPingErrors(ctx, id)
+		if dbErr != nil {
+			return dbErr
+		}
+		refreshTimeout := crawler.config.RefreshTimeout
+		if (tries != nil) && (*tries >= crawler.config.MaxPingTries) {
+			factor := time.Duration(1)
+			for try := crawler.config.MaxPingTries; try < *tries; try++ {
+				factor *= 2
+				if factor >= maxCrawlBackoffFactor {
+					factor = maxCrawlBackoffFactor
+					break
+				}
+			}
+			refreshTimeout *= factor
+		}
+		retryTime := crawler.clock.Now().Add(refreshTimeout)
+		if dbErr := crawler.db.UpdateCrawlRetryTime(ctx, id, retryTime); dbErr != nil {
+			return dbErr
+		}
+		crawler.config.Replay.RecordPing(id, false, duration, retryTime)
+		return err
+	}
+
+	if dbErr := crawler.db.InsertNodeSighting(ctx, id, database.NodeSightingPing); dbErr != nil {
+		return dbErr
+	}
+	// the recorded retry time anticipates the one crawlNode schedules after the
+	// interrogation; the difference is seconds, and the replay uses the recorded value
+	crawler.config.Replay.RecordPing(id, true, duration, crawler.clock.Now().Add(crawler.config.RefreshTimeout))
+	return crawler.db.ResetPingError(ctx, id)
+}
+
+func isPingTimeoutError(err error) bool {
+	return (err != nil) && (err.Error() == "RPC timeout")
+}
@@ -0,0 +1,162 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/p2p/discover"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// CrawlerV5 walks the discv5 DHT by draining a random walk iterator,
+// and stores the found ENR records in the same nodes table as the discv4 crawler.
+// The nodes are marked with their discovery protocol version (see database.DiscVersionV5),
+// so that the v4 and v5 populations can be compared.
+type CrawlerV5 struct {
+	transport *discover.UDPv5
+
+	db           database.DBRetrier
+	config       CrawlerConfig
+	forkVerifier *ForkVerifier
+
+	sessionStart time.Time
+	crawledCount uint64
+	savedCount   uint64
+
+	log log.Logger
+}
+
+func NewCrawlerV5(
+	transport *discover.UDPv5,
+	db database.DB,
+	config CrawlerConfig,
+	logger log.Logger,
+) (*CrawlerV5, error) {
+	forkVerifier := config.ForkVerifier
+	if forkVerifier == nil {
+		var err error
+		forkVerifier, err = NewForkVerifier(config.Chain)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	instance := CrawlerV5{
+		transport:    transport,
+		db:           database.NewDBRetrier(db, logger),
+		config:       config,
+		forkVerifier: forkVerifier,
+		log:          logger,
+	}
+	return &instance, nil
+}
+
+func (crawler *CrawlerV5) Run(ctx context.Context) error {
+	crawler.sessionStart = time.Now()
+
+	if crawler.config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, crawler.config.Duration)
+		defer cancel()
+	}
+
+	iter := crawler.transport.RandomNodes()
+	defer iter.Close()
+
+	// the iterator blocks in Next, so it is closed when the session ends
+	go func() {
+		<-ctx.Done()
+		iter.Close()
+	}()
+
+	for iter.Next() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		for (crawler.config.Pause != nil) && crawler.config.Pause.IsPaused() {
+			if utils.Sleep(ctx, 1*time.Second) != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		atomic.AddUint64(&crawler.crawledCount, 1)
+		if err := crawler.saveNode(ctx, iter.Node()); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				crawler.log.Error("Failed to save a node", "err", err)
+			}
+		}
+
+		if crawler.isMaxNodesReached() {
+			break
+		}
+	}
+
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) && (crawler.config.Duration > 0) {
+		// a bounded session running out of time is a clean exit
+		err = nil
+	}
+	return err
+}
+
+func (crawler *CrawlerV5) isMaxNodesReached() bool {
+	return (crawler.config.MaxNodes > 0) && (atomic.LoadUint64(&crawler.crawledCount) >= uint64(crawler.config.MaxNodes))
+}
+
+func (crawler *CrawlerV5) saveNode(ctx context.Context, node *enode.Node) error {
+	id, err := node_utils.NodeID(node)
+	if err != nil {
+		return fmt.Errorf("failed to get a node ID: %w", err)
+	}
+
+	// a sharded worker leaves the out-of-shard nodes to the other workers
+	if !crawler.config.Shard.Contains(id) {
+		return nil
+	}
+	if err := crawler.db.UpsertNodeAddr(ctx, id, node_utils.MakeNodeAddr(node)); err != nil {
+		return err
+	}
+	if err := crawler.db.UpdateNodeDiscVersion(ctx, id, database.DiscVersionV5); err != nil {
+		return err
+	}
+
+	// unlike discv4, the walk responses carry the full records,
+	// so the ENR entries come for free
+	entries := node_utils.ExtractEnrEntries(node.Record())
+	if err := crawler.db.UpdateEnrEntries(ctx, id, entries); err != nil {
+		return err
+	}
+	recordEnrForkID(ctx, crawler.db, crawler.forkVerifier, id, entries, crawler.log)
+	nodeENR, err := node_utils.EncodeEnrRecord(node.Record())
+	if err != nil {
+		return fmt.Errorf("failed to encode the ENR record: %w", err)
+	}
+	if err := crawler.db.UpsertNodeENR(ctx, id, nodeENR); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&crawler.savedCount, 1)
+	return nil
+}
+
+// SessionSummary returns the summary of the current run so far.
+// The discv5 walk makes no RLPx handshakes, so the handshake counts stay zero.
+func (crawler *CrawlerV5) SessionSummary() *SessionSummary {
+	summary := SessionSummary{
+		Duration:     time.Since(crawler.sessionStart).Truncate(time.Second),
+		CrawledCount: atomic.LoadUint64(&crawler.crawledCount),
+		SavedCount:   atomic.LoadUint64(&crawler.savedCount),
+	}
+	return &summary
+}
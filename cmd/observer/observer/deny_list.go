@@ -0,0 +1,106 @@
+package observer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// DenyList is the set of nodes the crawler must not probe: node IDs,
+// IP addresses and CIDR subnets, typically distributed fleet-wide
+// from a remote URL (see RemoteResourceLoop and the --deny-list-url flag).
+// Denied nodes are still stored when the discovery walk finds them,
+// but they are never pinged or handshaked.
+// The content is swapped atomically on reload, and a nil DenyList denies nothing.
+type DenyList struct {
+	mu      sync.RWMutex
+	nodeIDs map[database.NodeID]struct{}
+	ips     map[string]struct{}
+	subnets []*net.IPNet
+}
+
+func NewDenyList() *DenyList {
+	instance := DenyList{
+		nodeIDs: make(map[database.NodeID]struct{}),
+		ips:     make(map[string]struct{}),
+	}
+	return &instance
+}
+
+// Load replaces the deny list content. The format is line-based:
+// a hex node ID, an IP address or a CIDR subnet per line,
+// with empty lines and #-comments skipped.
+// A malformed line rejects the whole document, keeping the previous content,
+// so a half-written upload can't blank the list.
+func (list *DenyList) Load(data []byte) error {
+	nodeIDs := make(map[database.NodeID]struct{})
+	ips := make(map[string]struct{})
+	var subnets []*net.IPNet
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.Contains(line, "/") {
+			_, subnet, err := net.ParseCIDR(line)
+			if err != nil {
+				return fmt.Errorf("invalid deny list subnet %q: %w", line, err)
+			}
+			subnets = append(subnets, subnet)
+			continue
+		}
+		if ip := net.ParseIP(line); ip != nil {
+			ips[ip.String()] = struct{}{}
+			continue
+		}
+		id, err := database.ParseNodeID(line)
+		if err != nil {
+			return fmt.Errorf("invalid deny list entry %q: %w", line, err)
+		}
+		nodeIDs[id] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read the deny list: %w", err)
+	}
+
+	list.mu.Lock()
+	defer list.mu.Unlock()
+	list.nodeIDs = nodeIDs
+	list.ips = ips
+	list.subnets = subnets
+	return nil
+}
+
+// IsDenied tells if the node ID or its IP is on the deny list.
+func (list *DenyList) IsDenied(id database.NodeID, ip net.IP) bool {
+	if list == nil {
+		return false
+	}
+
+	list.mu.RLock()
+	defer list.mu.RUnlock()
+
+	if _, denied := list.nodeIDs[id]; denied {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	if _, denied := list.ips[ip.String()]; denied {
+		return true
+	}
+	for _, subnet := range list.subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package observer
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// The crawl self-diagnosis detects common failure modes of the observer host itself
+// (a firewalled UDP port, clock skew, database lock contention, an unmapped NAT,
+// a banned source IP) from symptoms observed while crawling.
+// The symptom counters are fed by the crawler and the diplomat,
+// and the findings are reported by StatusLoggerLoop and on the API /diagnosis endpoint.
+var (
+	symptomPingCount        uint64
+	symptomPingTimeoutCount uint64
+
+	symptomHandshakeCount        uint64
+	symptomHandshakeRefusedCount uint64
+
+	symptomNATConfigured uint32
+)
+
+func recordPingSymptom(isTimeout bool) {
+	atomic.AddUint64(&symptomPingCount, 1)
+	if isTimeout {
+		atomic.AddUint64(&symptomPingTimeoutCount, 1)
+	}
+}
+
+func recordHandshakeSymptom(handshakeErr *HandshakeError) {
+	atomic.AddUint64(&symptomHandshakeCount, 1)
+	if (handshakeErr != nil) && (handshakeErr.StringCode() == string(HandshakeErrorIDConnect)) {
+		atomic.AddUint64(&symptomHandshakeRefusedCount, 1)
+	}
+}
+
+func recordNATConfigured(isConfigured bool) {
+	var value uint32
+	if isConfigured {
+		value = 1
+	}
+	atomic.StoreUint32(&symptomNATConfigured, value)
+}
+
+// DiagnosisStats are the observed symptoms the diagnosis works from.
+type DiagnosisStats struct {
+	PingCount        uint64
+	PingTimeoutCount uint64
+
+	HandshakeCount        uint64
+	HandshakeRefusedCount uint64
+
+	DBOpCount       uint64
+	DBConflictCount uint64
+
+	BytesReceived uint64
+
+	NATConfigured bool
+}
+
+// CollectDiagnosisStats snapshots the symptom counters of the running crawl.
+func CollectDiagnosisStats(meter *BandwidthMeter) DiagnosisStats {
+	dbOpCount, dbConflictCount := database.DBOpStats()
+	return DiagnosisStats{
+		PingCount:        atomic.LoadUint64(&symptomPingCount),
+		PingTimeoutCount: atomic.LoadUint64(&symptomPingTimeoutCount),
+
+		HandshakeCount:        atomic.LoadUint64(&symptomHandshakeCount),
+		HandshakeRefusedCount: atomic.LoadUint64(&symptomHandshakeRefusedCount),
+
+		DBOpCount:       dbOpCount,
+		DBConflictCount: dbConflictCount,
+
+		BytesReceived: meter.BytesReceived(),
+
+		NATConfigured: atomic.LoadUint32(&symptomNATConfigured) != 0,
+	}
+}
+
+// DiagnosisFinding is one detected failure mode of the crawl, with an actionable advice.
+type DiagnosisFinding struct {
+	Code    string `json:"code"`
+	Summary string `json:"summary"`
+	Advice  string `json:"advice"`
+}
+
+// The minimum sample sizes before a diagnosis rule may fire, to avoid noise at startup.
+const (
+	diagnosisMinPings      = 20
+	diagnosisMinHandshakes = 20
+	diagnosisMinDBOps      = 1000
+)
+
+// Diagnose matches the observed symptoms against the known failure modes.
+// A healthy crawl produces no findings.
+func Diagnose(stats DiagnosisStats) []DiagnosisFinding {
+	var findings []DiagnosisFinding
+
+	allPingsTimeOut := (stats.PingCount >= diagnosisMinPings) && (stats.PingTimeoutCount == stats.PingCount)
+
+	if allPingsTimeOut && (stats.BytesReceived == 0) {
+		if stats.NATConfigured {
+			findings = append(findings, DiagnosisFinding{
+				Code:    "udp-blocked",
+				Summary: "no discovery responses: every ping timed out and no UDP traffic was received",
+				Advice:  "check that the firewall allows UDP on the listen port in both directions",
+			})
+		} else {
+			findings = append(findings, DiagnosisFinding{
+				Code:    "nat-no-mapping",
+				Summary: "no discovery responses, and no NAT port mapping is configured",
+				Advice:  "configure the NAT with --nat extip:<public IP> or --nat upnp, or open the UDP port",
+			})
+		}
+	}
+
+	if allPingsTimeOut && (stats.BytesReceived > 0) {
+		findings = append(findings, DiagnosisFinding{
+			Code:    "clock-skew",
+			Summary: "UDP traffic is coming in, but every ping timed out; remote nodes silently drop packets with expired timestamps",
+			Advice:  "check that the system clock is synchronized (NTP)",
+		})
+	}
+
+	if (stats.DBOpCount >= diagnosisMinDBOps) && (stats.DBConflictCount*10 >= stats.DBOpCount) {
+		findings = append(findings, DiagnosisFinding{
+			Code:    "db-contention",
+			Summary: "over 10% of the database operations hit a lock conflict",
+			Advice:  "reduce --crawler-concurrency, or move the database to faster storage",
+		})
+	}
+
+	if (stats.HandshakeCount >= diagnosisMinHandshakes) && (stats.HandshakeRefusedCount*10 >= stats.HandshakeCount*9) {
+		findings = append(findings, DiagnosisFinding{
+			Code:    "banned-source-ip",
+			Summary: "over 90% of the RLPx dials fail to connect; the nodes might have banned or rate limited this source IP",
+			Advice:  "dial from a different address (see --source-ips), or slow down the crawl",
+		})
+	}
+
+	return findings
+}
+
+// diagnosisCodes joins the finding codes so that a change in the finding set can be detected.
+func diagnosisCodes(findings []DiagnosisFinding) string {
+	codes := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		codes = append(codes, finding.Code)
+	}
+	return strings.Join(codes, ",")
+}
@@ -0,0 +1,110 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// SourceAffinity selects how a Dialer with multiple source IPs
+// distributes outgoing connections between them.
+type SourceAffinity string
+
+const (
+	// SourceAffinityRoundRobin cycles through the source IPs attempt by attempt.
+	SourceAffinityRoundRobin SourceAffinity = "round-robin"
+	// SourceAffinitySubnet pins all targets within the same /24 (or /48 for IPv6)
+	// to the same source IP, so that repeated probes of a subnet
+	// are observed from a stable vantage point.
+	SourceAffinitySubnet SourceAffinity = "subnet"
+)
+
+// Dialer makes outgoing TCP connections.
+// On multi-homed hosts it can be configured with several source IPs,
+// and spreads the dials between them according to the chosen SourceAffinity.
+type Dialer struct {
+	sourceIPs []net.IP
+	affinity  SourceAffinity
+	rrCounter uint32
+	timeout   time.Duration
+	meter     *BandwidthMeter
+}
+
+func NewDialer(sourceIPs []net.IP, affinity SourceAffinity, timeout time.Duration, meter *BandwidthMeter) (*Dialer, error) {
+	switch affinity {
+	case SourceAffinityRoundRobin, SourceAffinitySubnet:
+	default:
+		return nil, fmt.Errorf("unknown source affinity mode: %s", affinity)
+	}
+	return &Dialer{sourceIPs: sourceIPs, affinity: affinity, timeout: timeout, meter: meter}, nil
+}
+
+// Dial connects to the given TCP address.
+// It returns the connection together with the source IP the dial was made from.
+// The source IP is nil if no source IPs are configured
+// (in which case the OS picks the source address as usual).
+func (dialer *Dialer) Dial(ctx context.Context, addr *net.TCPAddr) (net.Conn, net.IP, error) {
+	sourceIP := dialer.selectSourceIP(addr.IP)
+
+	// a source IP of the wrong family cannot originate the dial:
+	// an IPv6 target is then dialed from the OS-picked address instead
+	if (sourceIP != nil) && ((sourceIP.To4() == nil) != (addr.IP.To4() == nil)) {
+		sourceIP = nil
+	}
+
+	netDialer := net.Dialer{Timeout: dialer.timeout}
+	if sourceIP != nil {
+		netDialer.LocalAddr = &net.TCPAddr{IP: sourceIP}
+	}
+
+	conn, err := netDialer.DialContext(ctx, "tcp", addr.String())
+	if err != nil {
+		return nil, sourceIP, err
+	}
+	return dialer.meter.MeterConn(conn, addr.IP), sourceIP, nil
+}
+
+func (dialer *Dialer) selectSourceIP(targetIP net.IP) net.IP {
+	if len(dialer.sourceIPs) == 0 {
+		return nil
+	}
+	if len(dialer.sourceIPs) == 1 {
+		return dialer.sourceIPs[0]
+	}
+
+	var index uint32
+	switch dialer.affinity {
+	case SourceAffinitySubnet:
+		index = subnetHash(targetIP) % uint32(len(dialer.sourceIPs))
+	default:
+		index = (atomic.AddUint32(&dialer.rrCounter, 1) - 1) % uint32(len(dialer.sourceIPs))
+	}
+	return dialer.sourceIPs[index]
+}
+
+func subnetHash(ip net.IP) uint32 {
+	var subnet []byte
+	if ip4 := ip.To4(); ip4 != nil {
+		subnet = ip4[:3] // a /24
+	} else {
+		subnet = ip[:6] // a /48
+	}
+	hasher := fnv.New32a()
+	_, _ = hasher.Write(subnet)
+	return hasher.Sum32()
+}
+
+func ParseSourceIPs(sourceIPStrs []string) ([]net.IP, error) {
+	sourceIPs := make([]net.IP, 0, len(sourceIPStrs))
+	for _, str := range sourceIPStrs {
+		ip := net.ParseIP(str)
+		if ip == nil {
+			return nil, fmt.Errorf("failed to parse source IP: %s", str)
+		}
+		sourceIPs = append(sourceIPs, ip)
+	}
+	return sourceIPs, nil
+}
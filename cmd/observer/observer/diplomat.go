@@ -0,0 +1,461 @@
+package observer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/eth/protocols/eth"
+	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Diplomat runs RLPx handshakes against nodes and records the outcomes.
+type Diplomat struct {
+	db         database.DBRetrier
+	privateKey *ecdsa.PrivateKey
+	dialer     *Dialer
+	asnLimiter *ASNLimiter
+	// rateLimiter paces the outgoing dials and caps the concurrent
+	// connections per remote IP (see RateLimiter).
+	rateLimiter *RateLimiter
+	capture     *Capture
+	scheduler   *ProbeScheduler
+	// forkVerifier evaluates the fork IDs received in the Status messages
+	// against the canonical chain config (nil - verdicts are not recorded).
+	forkVerifier *ForkVerifier
+	clock        utils.Clock
+
+	handshakeRefreshTimeout time.Duration
+	handshakeRetryDelay     time.Duration
+	handshakeMaxTries       uint
+
+	// scanPorts are alternate RLPx ports to try when the handshake
+	// at the advertised port fails to connect (empty - no port scan).
+	scanPorts []uint
+
+	// ipv6First prefers the advertised IPv6 address of a dual-stack node
+	// over the IPv4 one when dialing (see dialCandidates).
+	ipv6First bool
+
+	// txObserveSample keeps every Nth successfully handshaked session open
+	// to observe the tx announcement rate (zero disables the sampling),
+	// and txObserveWindow is how long one such session listens.
+	txObserveSample  uint
+	txObserveWindow  time.Duration
+	txObserveCounter uint64
+
+	log log.Logger
+}
+
+func NewDiplomat(
+	db database.DBRetrier,
+	privateKey *ecdsa.PrivateKey,
+	dialer *Dialer,
+	asnLimiter *ASNLimiter,
+	rateLimiter *RateLimiter,
+	capture *Capture,
+	scheduler *ProbeScheduler,
+	forkVerifier *ForkVerifier,
+	clock utils.Clock,
+	handshakeRefreshTimeout time.Duration,
+	handshakeRetryDelay time.Duration,
+	handshakeMaxTries uint,
+	scanPorts []uint,
+	ipv6First bool,
+	txObserveSample uint,
+	txObserveWindow time.Duration,
+	logger log.Logger,
+) *Diplomat {
+	instance := Diplomat{
+		db,
+		privateKey,
+		dialer,
+		asnLimiter,
+		rateLimiter,
+		capture,
+		scheduler,
+		forkVerifier,
+		clock,
+		handshakeRefreshTimeout,
+		handshakeRetryDelay,
+		handshakeMaxTries,
+		scanPorts,
+		ipv6First,
+		txObserveSample,
+		txObserveWindow,
+		0,
+		logger,
+	}
+	return &instance
+}
+
+const diplomatHandshakeTimeout = 15 * time.Second
+
+// dialCandidates lists the RLPx endpoints of a node in dial order:
+// the address enode prefers plus the advertised IPv6 address (when distinct),
+// with ipv6First putting the IPv6 endpoint before the IPv4 one.
+func dialCandidates(node *enode.Node, ipv6First bool) []net.TCPAddr {
+	primary := net.TCPAddr{IP: node.IP(), Port: node.TCP()}
+
+	addr := node_utils.MakeNodeAddr(node)
+	if (addr.IPv6.IP == nil) || addr.IPv6.IP.Equal(primary.IP) {
+		return []net.TCPAddr{primary}
+	}
+
+	port := int(addr.IPv6.PortRLPx)
+	if port == 0 {
+		port = primary.Port
+	}
+	candidateV6 := net.TCPAddr{IP: addr.IPv6.IP, Port: port}
+
+	if ipv6First {
+		return []net.TCPAddr{candidateV6, primary}
+	}
+	return []net.TCPAddr{primary, candidateV6}
+}
+
+func (diplomat *Diplomat) handshake(ctx context.Context, node *enode.Node, id database.NodeID) (*HelloMessage, *StatusMessage, net.IP, *TxActivity, *HandshakeError) {
+	handshakeContext, cancel := context.WithTimeout(ctx, diplomatHandshakeTimeout)
+	defer cancel()
+
+	// a dual-stack node is dialed family by family in preference order,
+	// and each attempt counts into the per-family outcome stats
+	var conn net.Conn
+	var sourceIP net.IP
+	var err error
+	for _, addr := range dialCandidates(node, diplomat.ipv6First) {
+		isIPv6 := addr.IP.To4() == nil
+		connectStart := diplomat.clock.Now()
+		conn, sourceIP, err = diplomat.dialer.Dial(handshakeContext, &addr)
+		if dbErr := diplomat.db.UpdateDialStats(ctx, id, isIPv6, err == nil); dbErr != nil {
+			diplomat.log.Debug("Failed to update the dial stats", "err", dbErr)
+		}
+		if err == nil {
+			diplomat.recordLatency(ctx, id, database.NodeLatencyConnect, diplomat.clock.Now().Sub(connectStart))
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, sourceIP, nil, NewHandshakeError(HandshakeErrorIDConnect, err, 0)
+	}
+
+	if !diplomat.shouldObserveTxActivity() {
+		handshakeStart := diplomat.clock.Now()
+		hello, status, handshakeErr := HandshakeWithCaps(handshakeContext, conn, node.Pubkey(), diplomat.privateKey,
+			DefaultHelloCaps(), diplomat.capture, string(id))
+		if handshakeErr == nil {
+			diplomat.recordLatency(ctx, id, database.NodeLatencyHandshake, diplomat.clock.Now().Sub(handshakeStart))
+		}
+		return hello, status, sourceIP, nil, handshakeErr
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	handshakeStart := diplomat.clock.Now()
+	hello, status, rlpxConn, handshakeErr := handshakeConn(handshakeContext, conn, node.Pubkey(), diplomat.privateKey,
+		DefaultHelloCaps(), "", diplomat.capture, string(id))
+	if handshakeErr != nil {
+		return hello, status, sourceIP, nil, handshakeErr
+	}
+	diplomat.recordLatency(ctx, id, database.NodeLatencyHandshake, diplomat.clock.Now().Sub(handshakeStart))
+
+	activity, listenErr := ListenTxAnnouncements(conn, rlpxConn, diplomat.txObserveWindow, diplomat.capture, string(id))
+	disconnect(rlpxConn)
+	if listenErr != nil {
+		diplomat.log.Debug("Failed to listen for tx announcements", "err", listenErr)
+	}
+	return hello, status, sourceIP, activity, nil
+}
+
+// handshakeWithPortScan handshakes at the advertised RLPx port, and if the
+// connection itself fails (the port is closed or filtered), retries on the
+// configured alternate ports (see the --port-scan-ports flag): many nodes
+// run RLPx on a non-default port. It returns the port that actually worked,
+// or the original failure if no port did. Failures past the connect stage
+// don't trigger the scan: the advertised port is evidently the right one.
+func (diplomat *Diplomat) handshakeWithPortScan(
+	ctx context.Context,
+	node *enode.Node,
+	id database.NodeID,
+) (*HelloMessage, *StatusMessage, net.IP, *TxActivity, uint16, *HandshakeError) {
+	hello, status, sourceIP, activity, handshakeErr := diplomat.handshake(ctx, node, id)
+	if (handshakeErr == nil) || (handshakeErr.id != HandshakeErrorIDConnect) || (len(diplomat.scanPorts) == 0) {
+		return hello, status, sourceIP, activity, uint16(node.TCP()), handshakeErr
+	}
+
+	for _, port := range diplomat.scanPorts {
+		if (port == uint(node.TCP())) || (port == 0) || (port > 65535) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		altNode := enode.NewV4(node.Pubkey(), node.IP(), int(port), node.UDP())
+		altHello, altStatus, altSourceIP, altActivity, altErr := diplomat.handshake(ctx, altNode, id)
+		if altErr == nil {
+			diplomat.log.Debug("Port scan found an alternate RLPx port", "node", id, "port", port)
+			return altHello, altStatus, altSourceIP, altActivity, uint16(port), nil
+		}
+	}
+	return hello, status, sourceIP, activity, uint16(node.TCP()), handshakeErr
+}
+
+// recordLatency stores a latency sample of the node (see database.DB.InsertNodeLatency).
+// Failures are only logged: a missed measurement must not fail the handshake path.
+func (diplomat *Diplomat) recordLatency(ctx context.Context, id database.NodeID, kind string, rtt time.Duration) {
+	if err := diplomat.db.InsertNodeLatency(ctx, id, kind, rtt); err != nil {
+		diplomat.log.Error("Failed to record a latency sample", "kind", kind, "err", err)
+	}
+}
+
+// shouldObserveTxActivity samples every Nth handshake for tx announcement listening.
+func (diplomat *Diplomat) shouldObserveTxActivity() bool {
+	if diplomat.txObserveSample == 0 {
+		return false
+	}
+	return atomic.AddUint64(&diplomat.txObserveCounter, 1)%uint64(diplomat.txObserveSample) == 0
+}
+
+// Run handshakes the given node and updates the node record in the database.
+func (diplomat *Diplomat) Run(ctx context.Context, node *enode.Node, id database.NodeID) (*HelloMessage, *StatusMessage, *HandshakeError) {
+	diplomat.log.Debug("Handshaking with a node", "node", node.URLv4())
+
+	asn, dbErr := diplomat.db.FindNodeASN(ctx, id)
+	if dbErr != nil {
+		diplomat.log.Error("Failed to find the node ASN", "err", dbErr)
+	}
+	if asn != nil {
+		if err := diplomat.asnLimiter.Acquire(ctx, *asn); err != nil {
+			return nil, nil, NewHandshakeError(HandshakeErrorIDConnect, err, 0)
+		}
+		defer diplomat.asnLimiter.Release(*asn)
+	}
+
+	if err := diplomat.rateLimiter.Acquire(ctx, node.IP()); err != nil {
+		return nil, nil, NewHandshakeError(HandshakeErrorIDConnect, err, 0)
+	}
+	defer diplomat.rateLimiter.Release(node.IP())
+
+	startTime := diplomat.clock.Now()
+	hello, status, sourceIP, txActivity, verifiedPort, handshakeErr := diplomat.handshakeWithPortScan(ctx, node, id)
+
+	attempt := database.HandshakeAttempt{
+		Time:     startTime,
+		SourceIP: sourceIP,
+		Success:  handshakeErr == nil,
+		Duration: diplomat.clock.Now().Sub(startTime),
+	}
+	if handshakeErr != nil {
+		attempt.Err = handshakeErr.StringCode()
+	}
+	if attempt.Success {
+		handshakeSuccessCounter.Inc()
+	} else {
+		handshakeFailureCounter.Inc()
+	}
+	recordHandshakeSymptom(handshakeErr)
+	if dbErr := diplomat.db.InsertHandshakeAttempt(ctx, id, attempt); dbErr != nil {
+		diplomat.log.Error("Failed to insert a handshake attempt", "err", dbErr)
+	}
+	if dbErr := diplomat.db.IncrementHandshakeHourStats(ctx, id, attempt.Success, startTime); dbErr != nil {
+		diplomat.log.Error("Failed to count the handshake hour stats", "err", dbErr)
+	}
+
+	if code, isViolation := ClassifyProtocolViolation(handshakeErr); isViolation {
+		violation := database.ProtocolViolation{
+			Time: startTime,
+			Code: code,
+		}
+		if wrappedErr := handshakeErr.Unwrap(); wrappedErr != nil {
+			violation.Detail = wrappedErr.Error()
+		}
+		if dbErr := diplomat.db.InsertProtocolViolation(ctx, id, violation); dbErr != nil {
+			diplomat.log.Error("Failed to insert a protocol violation", "err", dbErr)
+		}
+	}
+
+	if (hello != nil) && (hello.ClientID != "") {
+		clientChanged, dbErr := diplomat.db.UpdateClientID(ctx, id, hello.ClientID, database.ObservationQualityVerified)
+		if dbErr != nil {
+			diplomat.log.Error("Failed to update the client ID", "err", dbErr)
+		}
+		if clientChanged {
+			diplomat.log.Debug("Node client changed", "node", id, "clientID", hello.ClientID)
+		}
+		// the Status message carries the actually negotiated version (stored below);
+		// the Hello capabilities are only a fallback when no Status arrived
+		ethVersion := findMaxEthVersion(hello.Caps)
+		if (status == nil) && (ethVersion > 0) {
+			if dbErr := diplomat.db.UpdateEthVersion(ctx, id, ethVersion, database.ObservationQualityVerified); dbErr != nil {
+				diplomat.log.Error("Failed to update the eth version", "err", dbErr)
+			}
+		}
+		if dbErr := diplomat.db.UpdateSupportsSnap(ctx, id, SupportsSnap(hello.Caps)); dbErr != nil {
+			diplomat.log.Error("Failed to update the snap support flag", "err", dbErr)
+		}
+		if len(hello.Caps) > 0 {
+			caps := make([]database.NodeCapability, 0, len(hello.Caps))
+			for _, capability := range hello.Caps {
+				caps = append(caps, database.NodeCapability{Name: capability.Name, Version: capability.Version})
+			}
+			if dbErr := diplomat.db.UpsertNodeCapabilities(ctx, id, caps); dbErr != nil {
+				diplomat.log.Error("Failed to update the node capabilities", "err", dbErr)
+			}
+		}
+	}
+	if status != nil {
+		if dbErr := diplomat.db.UpdateNetworkID(ctx, id, uint(status.NetworkID), database.ObservationQualityVerified); dbErr != nil {
+			diplomat.log.Error("Failed to update the network ID", "err", dbErr)
+		}
+		if dbErr := diplomat.db.UpdateEthVersion(ctx, id, uint(status.ProtocolVersion), database.ObservationQualityVerified); dbErr != nil {
+			diplomat.log.Error("Failed to update the eth version", "err", dbErr)
+		}
+		if forkID, err := status.ForkID(); err != nil {
+			diplomat.log.Debug("Failed to parse the fork ID", "err", err)
+		} else if forkID != nil {
+			forkIDHash := fmt.Sprintf("%x", forkID.Hash)
+			if dbErr := diplomat.db.UpdateForkID(ctx, id, forkIDHash, forkID.Next, database.ObservationQualityVerified); dbErr != nil {
+				diplomat.log.Error("Failed to update the fork ID", "err", dbErr)
+			}
+			if diplomat.forkVerifier != nil {
+				compatible, verdict := diplomat.forkVerifier.Verify(*forkID)
+				if dbErr := diplomat.db.UpdateForkCompatibility(ctx, id, compatible, verdict); dbErr != nil {
+					diplomat.log.Error("Failed to update the fork compatibility", "err", dbErr)
+				}
+			}
+		}
+		if status.TD != nil {
+			if dbErr := diplomat.db.UpdateChainHead(ctx, id, status.Head.Hex(), status.TD.String()); dbErr != nil {
+				diplomat.log.Error("Failed to update the chain head", "err", dbErr)
+			}
+		}
+	}
+
+	if txActivity != nil {
+		if dbErr := diplomat.db.UpdateTxAnnounceRate(ctx, id, txActivity.RatePerMinute()); dbErr != nil {
+			diplomat.log.Error("Failed to update the tx announce rate", "err", dbErr)
+		}
+		if len(txActivity.BlockAnnouncements) > 0 {
+			if dbErr := diplomat.db.InsertBlockAnnouncements(ctx, id, txActivity.BlockAnnouncements); dbErr != nil {
+				diplomat.log.Error("Failed to insert the block announcements", "err", dbErr)
+			}
+		}
+	}
+
+	if handshakeErr == nil {
+		if dbErr := diplomat.db.InsertNodeSighting(ctx, id, database.NodeSightingHandshake); dbErr != nil {
+			diplomat.log.Error("Failed to insert a node sighting", "err", dbErr)
+		}
+		if dbErr := diplomat.db.DeleteHandshakeErrors(ctx, id); dbErr != nil {
+			diplomat.log.Error("Failed to delete the handshake errors", "err", dbErr)
+		}
+		if dbErr := diplomat.db.UpdatePortRLPxVerified(ctx, id, verifiedPort); dbErr != nil {
+			diplomat.log.Error("Failed to update the verified RLPx port", "err", dbErr)
+		}
+	} else {
+		if dbErr := diplomat.db.InsertHandshakeError(ctx, id, handshakeErr.StringCode()); dbErr != nil {
+			diplomat.log.Error("Failed to insert the handshake error", "err", dbErr)
+		}
+	}
+
+	// the handshake attempt settles the reachability class:
+	// an established connection proves the node is publicly reachable
+	// even if the handshake then failed at the protocol level,
+	// while a connect failure after the successful ping means discovery-only
+	// (typically a NATed node without port forwarding)
+	reachability := database.ReachabilityPublic
+	if (handshakeErr != nil) && (handshakeErr.id == HandshakeErrorIDConnect) {
+		reachability = database.ReachabilityDiscoveryOnly
+	}
+	if dbErr := diplomat.db.UpdateReachability(ctx, id, reachability); dbErr != nil {
+		diplomat.log.Error("Failed to update the reachability", "err", dbErr)
+	}
+
+	retryTime := diplomat.nextRetryTime(ctx, id, handshakeErr)
+	if dbErr := diplomat.db.UpdateHandshakeRetryTime(ctx, id, retryTime); dbErr != nil {
+		diplomat.log.Error("Failed to update the handshake retry time", "err", dbErr)
+	}
+
+	return hello, status, handshakeErr
+}
+
+// nextRetryTime schedules the next handshake of the node.
+// A success is refreshed after the long refresh timeout,
+// while a failure backs off exponentially with the per-node try count,
+// so flaky nodes aren't retried too aggressively.
+func (diplomat *Diplomat) nextRetryTime(ctx context.Context, id database.NodeID, handshakeErr *HandshakeError) time.Time {
+	if handshakeErr == nil {
+		return diplomat.clock.Now().Add(diplomat.handshakeRefreshTimeout)
+	}
+
+	delay := diplomat.handshakeRetryDelay
+	tries, err := diplomat.db.CountHandshakeRetries(ctx, id)
+	if err != nil {
+		diplomat.log.Error("Failed to count the handshake retries", "err", err)
+	} else if tries != nil {
+		// the try counter already includes this failure
+		for try := uint(1); try < *tries; try++ {
+			delay *= 2
+			if delay >= diplomat.handshakeRefreshTimeout {
+				delay = diplomat.handshakeRefreshTimeout
+				break
+			}
+		}
+	}
+	return diplomat.clock.Now().Add(delay)
+}
+
+// IsDueForHandshake tells if the node needs to be handshaked (again),
+// respecting the retry time, the retry limit and the probe policies.
+func (diplomat *Diplomat) IsDueForHandshake(ctx context.Context, id database.NodeID) (bool, error) {
+	retryTime, err := diplomat.db.FindHandshakeRetryTime(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if (retryTime != nil) && retryTime.After(diplomat.clock.Now()) {
+		return false, nil
+	}
+
+	if retryTime != nil {
+		tries, err := diplomat.db.CountHandshakeRetries(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if (tries != nil) && (*tries >= diplomat.handshakeMaxTries) {
+			return false, nil
+		}
+	}
+
+	if diplomat.scheduler != nil {
+		allowed, err := diplomat.scheduler.AllowsProbe(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if !allowed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func findMaxEthVersion(caps []p2p.Cap) uint {
+	var maxVersion uint
+	for _, capability := range caps {
+		if capability.Name != eth.ProtocolName {
+			continue
+		}
+		if capability.Version > maxVersion {
+			maxVersion = capability.Version
+		}
+	}
+	return maxVersion
+}
@@ -0,0 +1,127 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/params"
+)
+
+// ForkVerifier evaluates the EIP-2124 fork IDs received in handshakes and ENRs
+// against the canonical chain config of the crawled chain,
+// so the compatibility verdict is computed by the observer itself
+// rather than trusted from a caller.
+// The verdict is stored next to the raw fork ID (see database.DB.UpdateForkCompatibility),
+// keeping both auditable.
+// The filter can be swapped at runtime by a remote fork schedule
+// (see ApplyForkSchedule), so a newly scheduled fork reaches a running fleet
+// without a binary update.
+type ForkVerifier struct {
+	mu     sync.RWMutex
+	filter forkid.Filter
+}
+
+// ForkVerdictCompatible is the verdict recorded for a fork ID the filter accepts.
+// An incompatible fork ID records the filter error text instead.
+const ForkVerdictCompatible = "compatible"
+
+// NewForkVerifier builds the verifier for a chain name (see the --chain flag):
+// the chain config and the genesis hash are the canonical ones of that chain.
+// The filter is static (evaluated at block zero), since the observer
+// doesn't follow the chain head: a remote on a scheduled future fork
+// is still considered compatible.
+func NewForkVerifier(chain string) (*ForkVerifier, error) {
+	chainConfig := params.ChainConfigByChainName(chain)
+	genesisHash := params.GenesisHashByChainName(chain)
+	if (chainConfig == nil) || (genesisHash == nil) {
+		return nil, fmt.Errorf("unknown chain %s", chain)
+	}
+
+	instance := ForkVerifier{
+		filter: forkid.NewStaticFilter(chainConfig, *genesisHash),
+	}
+	return &instance, nil
+}
+
+// Verify evaluates a remote fork ID, and returns the compatibility flag
+// together with the verdict text to store.
+func (verifier *ForkVerifier) Verify(remoteForkID forkid.ID) (bool, string) {
+	verifier.mu.RLock()
+	filter := verifier.filter
+	verifier.mu.RUnlock()
+
+	if err := filter(remoteForkID); err != nil {
+		return false, err.Error()
+	}
+	return true, ForkVerdictCompatible
+}
+
+// ForkSchedule is the remote fork schedule document (see the --fork-schedule-url flag):
+// the genesis hash of the chain and its fork block numbers, e.g.
+// {"genesisHash": "0xd4e5...", "forks": [1150000, 1920000]}.
+// It overrides the built-in chain config of the verifier.
+type ForkSchedule struct {
+	GenesisHash common.Hash `json:"genesisHash"`
+	Forks       []uint64    `json:"forks"`
+}
+
+// ApplyForkSchedule swaps the filter for one built from a remote fork schedule.
+// Like the built-in filter it is evaluated at block zero.
+// A malformed document is rejected, keeping the previous filter in effect.
+func (verifier *ForkVerifier) ApplyForkSchedule(data []byte) error {
+	var schedule ForkSchedule
+	if err := json.Unmarshal(data, &schedule); err != nil {
+		return fmt.Errorf("failed to parse the fork schedule: %w", err)
+	}
+	if schedule.GenesisHash == (common.Hash{}) {
+		return fmt.Errorf("the fork schedule misses the genesis hash")
+	}
+
+	filter := forkid.NewFilterFromForks(schedule.Forks, schedule.GenesisHash, 0)
+	verifier.mu.Lock()
+	verifier.filter = filter
+	verifier.mu.Unlock()
+	return nil
+}
+
+// recordEnrForkID stores the fork ID a node announced in the "eth" entry
+// of its ENR, and the verifier's verdict on it. Unlike the fork ID of a Status
+// exchange the record is self-reported, and is marked as such.
+// Failures are only logged: the ENR entries are remote data,
+// and a malformed entry shouldn't fail the crawl of the node.
+func recordEnrForkID(
+	ctx context.Context,
+	db database.DBRetrier,
+	verifier *ForkVerifier,
+	id database.NodeID,
+	entries []database.EnrEntry,
+	logger log.Logger,
+) {
+	forkID, err := node_utils.EnrForkID(entries)
+	if err != nil {
+		logger.Debug("Failed to parse the ENR fork ID", "err", err)
+		return
+	}
+	if forkID == nil {
+		return
+	}
+
+	forkIDHash := fmt.Sprintf("%x", forkID.Hash)
+	if dbErr := db.UpdateForkID(ctx, id, forkIDHash, forkID.Next, database.ObservationQualitySelfReported); dbErr != nil {
+		logger.Error("Failed to update the fork ID", "err", dbErr)
+	}
+	if verifier != nil {
+		compatible, verdict := verifier.Verify(*forkID)
+		if dbErr := db.UpdateForkCompatibility(ctx, id, compatible, verdict); dbErr != nil {
+			logger.Error("Failed to update the fork compatibility", "err", dbErr)
+		}
+	}
+}
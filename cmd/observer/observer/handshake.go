@@ -0,0 +1,390 @@
+package observer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/eth/protocols/eth"
+	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/ledgerwatch/erigon/p2p/rlpx"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// HelloMessage is the RLPx protocol handshake message.
+// https://github.com/ethereum/devp2p/blob/master/rlpx.md#hello-0x00
+type HelloMessage struct {
+	Version    uint64
+	ClientID   string
+	Caps       []p2p.Cap
+	ListenPort uint64
+	Pubkey     []byte // secp256k1 public key
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// StatusMessage is the eth sub-protocol status message.
+// https://github.com/ethereum/devp2p/blob/master/caps/eth.md#status-0x00
+type StatusMessage struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+
+	// The tail holds the fork ID (eth/64 and later),
+	// and anything unknown (for forward compatibility).
+	// Keeping it raw lets the same struct decode pre-fork-ID statuses too (see ForkID).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ForkID decodes the fork ID from the Status message tail.
+// It returns nil for pre-eth/64 statuses that don't carry one.
+func (message *StatusMessage) ForkID() (*forkid.ID, error) {
+	if len(message.Rest) == 0 {
+		return nil, nil
+	}
+	var id forkid.ID
+	if err := rlp.DecodeBytes(message.Rest[0], &id); err != nil {
+		return nil, fmt.Errorf("failed to parse fork ID: %w", err)
+	}
+	return &id, nil
+}
+
+type HandshakeErrorID string
+
+const (
+	HandshakeErrorIDConnect           HandshakeErrorID = "connect"
+	HandshakeErrorIDSetTimeout        HandshakeErrorID = "set-timeout"
+	HandshakeErrorIDAuth              HandshakeErrorID = "auth"
+	HandshakeErrorIDRead              HandshakeErrorID = "read"
+	HandshakeErrorIDUnexpectedMessage HandshakeErrorID = "unexpected-message"
+	HandshakeErrorIDDisconnectDecode  HandshakeErrorID = "disconnect-decode"
+	HandshakeErrorIDDisconnect        HandshakeErrorID = "disconnect"
+	HandshakeErrorIDHelloEncode       HandshakeErrorID = "hello-encode"
+	HandshakeErrorIDHelloDecode       HandshakeErrorID = "hello-decode"
+	HandshakeErrorIDStatusDecode      HandshakeErrorID = "status-decode"
+	HandshakeErrorIDStatusEncode      HandshakeErrorID = "status-encode"
+)
+
+type HandshakeError struct {
+	id         HandshakeErrorID
+	wrappedErr error
+	param      uint64
+}
+
+func NewHandshakeError(id HandshakeErrorID, wrappedErr error, param uint64) *HandshakeError {
+	instance := HandshakeError{
+		id,
+		wrappedErr,
+		param,
+	}
+	return &instance
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.wrappedErr
+}
+
+func (e *HandshakeError) Error() string {
+	switch e.id {
+	case HandshakeErrorIDConnect:
+		return fmt.Sprintf("handshake failed to connect: %v", e.wrappedErr)
+	case HandshakeErrorIDSetTimeout:
+		return fmt.Sprintf("handshake failed to set timeout: %v", e.wrappedErr)
+	case HandshakeErrorIDAuth:
+		return fmt.Sprintf("handshake RLPx auth failed: %v", e.wrappedErr)
+	case HandshakeErrorIDRead:
+		return fmt.Sprintf("handshake failed to read a message: %v", e.wrappedErr)
+	case HandshakeErrorIDUnexpectedMessage:
+		return fmt.Sprintf("handshake got unexpected message ID: %d", e.param)
+	case HandshakeErrorIDDisconnectDecode:
+		return fmt.Sprintf("handshake failed to parse disconnect reason: %v", e.wrappedErr)
+	case HandshakeErrorIDDisconnect:
+		return fmt.Sprintf("handshake got disconnected: %v", e.wrappedErr)
+	case HandshakeErrorIDHelloEncode:
+		return fmt.Sprintf("handshake failed to encode outgoing Hello message: %v", e.wrappedErr)
+	case HandshakeErrorIDHelloDecode:
+		return fmt.Sprintf("handshake failed to parse Hello message: %v", e.wrappedErr)
+	case HandshakeErrorIDStatusDecode:
+		return fmt.Sprintf("handshake failed to parse Status message: %v", e.wrappedErr)
+	case HandshakeErrorIDStatusEncode:
+		return fmt.Sprintf("handshake failed to encode outgoing Status message: %v", e.wrappedErr)
+	default:
+		return "<unhandled HandshakeErrorID>"
+	}
+}
+
+func (e *HandshakeError) StringCode() string {
+	return string(e.id)
+}
+
+const (
+	// https://github.com/ethereum/devp2p/blob/master/rlpx.md#p2p-capability
+	RLPxMessageIDHello      = 0
+	RLPxMessageIDDisconnect = 1
+	RLPxMessageIDPing       = 2
+	RLPxMessageIDPong       = 3
+
+	// https://github.com/ethereum/devp2p/blob/master/caps/eth.md#status-0x00
+	// the message ID offset is equal to the length of the p2p capability message space
+	RLPxMessageIDStatus = 16
+
+	// https://github.com/ethereum/devp2p/blob/master/caps/eth.md#newblockhashes-0x01
+	RLPxMessageIDNewBlockHashes = 16 + 0x01
+
+	// https://github.com/ethereum/devp2p/blob/master/caps/eth.md#newpooledtransactionhashes-0x08
+	RLPxMessageIDNewPooledTransactionHashes = 16 + 0x08
+)
+
+// Handshake performs an RLPx handshake over the given connection:
+// it exchanges Hello messages, and then waits for the remote eth Status message,
+// which it mirrors back to keep the remote node happy.
+func Handshake(
+	ctx context.Context,
+	conn net.Conn,
+	pubkey *ecdsa.PublicKey,
+	myPrivateKey *ecdsa.PrivateKey,
+) (*HelloMessage, *StatusMessage, *HandshakeError) {
+	return HandshakeWithCaps(ctx, conn, pubkey, myPrivateKey, DefaultHelloCaps(), nil, "")
+}
+
+// HandshakeWithCaps is Handshake with custom advertised capabilities.
+// If the capabilities don't include eth, no Status message is expected,
+// and the handshake completes after the Hello exchange.
+// The exchanged messages are optionally recorded to a capture.
+func HandshakeWithCaps(
+	ctx context.Context,
+	conn net.Conn,
+	pubkey *ecdsa.PublicKey,
+	myPrivateKey *ecdsa.PrivateKey,
+	caps []p2p.Cap,
+	capture *Capture,
+	captureID string,
+) (*HelloMessage, *StatusMessage, *HandshakeError) {
+	return HandshakeWithHello(ctx, conn, pubkey, myPrivateKey, caps, "", capture, captureID)
+}
+
+// HandshakeWithHello is HandshakeWithCaps with a custom advertised client ID.
+// An empty clientID advertises the default observer client ID.
+func HandshakeWithHello(
+	ctx context.Context,
+	conn net.Conn,
+	pubkey *ecdsa.PublicKey,
+	myPrivateKey *ecdsa.PrivateKey,
+	caps []p2p.Cap,
+	clientID string,
+	capture *Capture,
+	captureID string,
+) (*HelloMessage, *StatusMessage, *HandshakeError) {
+	defer func() { _ = conn.Close() }()
+
+	hello, status, rlpxConn, handshakeErr := handshakeConn(ctx, conn, pubkey, myPrivateKey, caps, clientID, capture, captureID)
+	if handshakeErr == nil {
+		disconnect(rlpxConn)
+	}
+	return hello, status, handshakeErr
+}
+
+// handshakeConn is the handshake itself: on success it hands the live session
+// back to the caller, who owns disconnecting and closing the connection
+// (e.g. to keep listening, see ListenTxAnnouncements).
+func handshakeConn(
+	ctx context.Context,
+	conn net.Conn,
+	pubkey *ecdsa.PublicKey,
+	myPrivateKey *ecdsa.PrivateKey,
+	caps []p2p.Cap,
+	clientID string,
+	capture *Capture,
+	captureID string,
+) (*HelloMessage, *StatusMessage, *rlpx.Conn, *HandshakeError) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, nil, nil, NewHandshakeError(HandshakeErrorIDSetTimeout, err, 0)
+		}
+	}
+
+	rlpxConn := rlpx.NewConn(conn, pubkey)
+
+	if _, err := rlpxConn.Handshake(myPrivateKey); err != nil {
+		return nil, nil, nil, NewHandshakeError(HandshakeErrorIDAuth, err, 0)
+	}
+
+	expectStatus := false
+	for _, capability := range caps {
+		if capability.Name == eth.ProtocolName {
+			expectStatus = true
+			break
+		}
+	}
+
+	ourHello := makeOurHelloMessage(&myPrivateKey.PublicKey, caps, clientID)
+	ourHelloData, err := rlp.EncodeToBytes(&ourHello)
+	if err != nil {
+		return nil, nil, nil, NewHandshakeError(HandshakeErrorIDHelloEncode, err, 0)
+	}
+	if _, err := rlpxConn.Write(RLPxMessageIDHello, ourHelloData); err != nil {
+		return nil, nil, nil, NewHandshakeError(HandshakeErrorIDHelloEncode, err, 0)
+	}
+	capture.Record(captureID, CaptureDirectionOut, RLPxMessageIDHello, ourHelloData)
+
+	var helloMessage *HelloMessage
+	var statusMessage *StatusMessage
+
+	for (helloMessage == nil) || (expectStatus && (statusMessage == nil)) {
+		messageID, data, _, err := rlpxConn.Read()
+		if err != nil {
+			return helloMessage, statusMessage, nil, NewHandshakeError(HandshakeErrorIDRead, err, 0)
+		}
+		capture.Record(captureID, CaptureDirectionIn, messageID, data)
+
+		switch messageID {
+		case RLPxMessageIDHello:
+			helloMessage = new(HelloMessage)
+			if err := rlp.DecodeBytes(data, helloMessage); err != nil {
+				return nil, nil, nil, NewHandshakeError(HandshakeErrorIDHelloDecode, err, 0)
+			}
+			// All messages following Hello are compressed with Snappy.
+			// https://github.com/ethereum/devp2p/blob/master/rlpx.md#message-framing
+			rlpxConn.SetSnappy(helloMessage.Version >= 5)
+
+		case RLPxMessageIDDisconnect:
+			reason, err := decodeDisconnectReason(data)
+			if err != nil {
+				return helloMessage, statusMessage, nil, NewHandshakeError(HandshakeErrorIDDisconnectDecode, err, 0)
+			}
+			return helloMessage, statusMessage, nil, NewHandshakeError(HandshakeErrorIDDisconnect, reason, 0)
+
+		case RLPxMessageIDPing:
+			if err := writeEmptyMessage(rlpxConn, RLPxMessageIDPong); err != nil {
+				return helloMessage, statusMessage, nil, err
+			}
+
+		case RLPxMessageIDStatus:
+			statusMessage = new(StatusMessage)
+			if err := rlp.DecodeBytes(data, statusMessage); err != nil {
+				return helloMessage, nil, nil, NewHandshakeError(HandshakeErrorIDStatusDecode, err, 0)
+			}
+			// Reflect the same status back to avoid "status mismatch" disconnects.
+			if _, err := rlpxConn.Write(RLPxMessageIDStatus, data); err != nil {
+				return helloMessage, statusMessage, nil, NewHandshakeError(HandshakeErrorIDStatusEncode, err, 0)
+			}
+			capture.Record(captureID, CaptureDirectionOut, RLPxMessageIDStatus, data)
+
+		default:
+			return helloMessage, statusMessage, nil, NewHandshakeError(HandshakeErrorIDUnexpectedMessage, nil, messageID)
+		}
+	}
+
+	return helloMessage, statusMessage, rlpxConn, nil
+}
+
+// DefaultHelloCaps are the capabilities the crawler normally advertises.
+func DefaultHelloCaps() []p2p.Cap {
+	return []p2p.Cap{
+		{Name: eth.ProtocolName, Version: 63},
+		{Name: eth.ProtocolName, Version: 64},
+		{Name: eth.ProtocolName, Version: 65},
+		{Name: eth.ProtocolName, Version: eth.ETH66},
+		// eth/67 and eth/68 keep the eth/64 Status message format,
+		// so the observer can negotiate them by mirroring the status back,
+		// even though this node doesn't implement the rest of those protocols.
+		{Name: eth.ProtocolName, Version: 67},
+		{Name: eth.ProtocolName, Version: 68},
+		// snap/1 has no handshake message of its own:
+		// advertising it makes the peer reveal in its Hello whether it serves snap sync data.
+		{Name: snapProtocolName, Version: snapProtocolVersion},
+	}
+}
+
+// https://github.com/ethereum/devp2p/blob/master/caps/snap.md
+const (
+	snapProtocolName    = "snap"
+	snapProtocolVersion = 1
+)
+
+// SupportsSnap tells if the advertised capabilities include snap sync serving.
+func SupportsSnap(caps []p2p.Cap) bool {
+	for _, capability := range caps {
+		if (capability.Name == snapProtocolName) && (capability.Version >= snapProtocolVersion) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHelloCaps parses capability strings like "eth/66" or "snap/1".
+func ParseHelloCaps(values []string) ([]p2p.Cap, error) {
+	caps := make([]p2p.Cap, 0, len(values))
+	for _, value := range values {
+		parts := strings.Split(value, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("failed to parse capability %q: expecting a name/version pair", value)
+		}
+		version, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse capability %q version: %w", value, err)
+		}
+		caps = append(caps, p2p.Cap{Name: parts[0], Version: uint(version)})
+	}
+	return caps, nil
+}
+
+func makeOurHelloMessage(myPublicKey *ecdsa.PublicKey, caps []p2p.Cap, clientID string) HelloMessage {
+	if clientID == "" {
+		version := params.VersionWithCommit(params.GitCommit, "")
+		clientID = common.MakeName("observer", version)
+	}
+
+	return HelloMessage{
+		Version:    5,
+		ClientID:   clientID,
+		Caps:       caps,
+		ListenPort: 0, // not listening
+		Pubkey:     crypto.MarshalPubkey(myPublicKey),
+	}
+}
+
+func decodeDisconnectReason(data []byte) (error, error) {
+	var disconnectReason [1]p2p.DiscReason
+	if err := rlp.DecodeBytes(data, &disconnectReason); err != nil {
+		// some clients send the reason as a plain integer instead of an RLP list
+		var reason p2p.DiscReason
+		if err2 := rlp.DecodeBytes(data, &reason); err2 != nil {
+			return nil, err
+		}
+		return reason, nil
+	}
+	return disconnectReason[0], nil
+}
+
+func disconnect(rlpxConn *rlpx.Conn) {
+	reasonData, err := rlp.EncodeToBytes([]p2p.DiscReason{p2p.DiscQuitting})
+	if err == nil {
+		_, _ = rlpxConn.Write(RLPxMessageIDDisconnect, reasonData)
+	}
+	// grace period for the disconnect message delivery
+	time.Sleep(time.Millisecond * 100)
+}
+
+func writeEmptyMessage(rlpxConn *rlpx.Conn, messageID uint64) *HandshakeError {
+	data, err := rlp.EncodeToBytes(make([]string, 0))
+	if err != nil {
+		return NewHandshakeError(HandshakeErrorIDHelloEncode, err, 0)
+	}
+	if _, err := rlpxConn.Write(messageID, data); err != nil {
+		return NewHandshakeError(HandshakeErrorIDHelloEncode, err, 0)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package observer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/erigon/p2p/enr"
+	"github.com/ledgerwatch/log/v3"
+)
+
+type DiscV4Transport interface {
+	Ping(*enode.Node) error
+	RequestENR(*enode.Node) (*enode.Node, error)
+	FindNode(*enode.Node, *ecdsa.PublicKey) ([]*enode.Node, error)
+}
+
+// Interrogator queries a single node about its neighbors using the discovery protocol.
+type Interrogator struct {
+	node       *enode.Node
+	transport  DiscV4Transport
+	targetKeys []*ecdsa.PublicKey
+	log        log.Logger
+}
+
+type InterrogationResult struct {
+	Node  *enode.Node
+	Peers []*enode.Node
+
+	// Enr is the node record obtained with an ENRRequest,
+	// or nil if the node doesn't support it.
+	Enr *enr.Record
+}
+
+func NewInterrogator(
+	node *enode.Node,
+	transport DiscV4Transport,
+	targetKeys []*ecdsa.PublicKey,
+	logger log.Logger,
+) *Interrogator {
+	instance := Interrogator{
+		node,
+		transport,
+		targetKeys,
+		logger,
+	}
+	return &instance
+}
+
+const findNodeRetries = 2
+
+func (interrogator *Interrogator) Run(ctx context.Context) (*InterrogationResult, error) {
+	interrogator.log.Debug("Interrogating a node")
+
+	// filter out duplicates and the node itself
+	peersByID := make(map[enode.ID]*enode.Node)
+
+	for _, targetKey := range interrogator.targetKeys {
+		neighbors, err := interrogator.findNode(ctx, targetKey)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, neighbor := range neighbors {
+			if neighbor.Incomplete() || (neighbor.ID() == interrogator.node.ID()) {
+				continue
+			}
+			peersByID[neighbor.ID()] = neighbor
+		}
+	}
+
+	peers := make([]*enode.Node, 0, len(peersByID))
+	for _, peer := range peersByID {
+		peers = append(peers, peer)
+	}
+
+	result := InterrogationResult{interrogator.node, peers, interrogator.tryRequestENR()}
+	return &result, nil
+}
+
+// tryRequestENR fetches the node record. This is best-effort:
+// not all the clients answer an ENRRequest.
+func (interrogator *Interrogator) tryRequestENR() *enr.Record {
+	enrNode, err := interrogator.transport.RequestENR(interrogator.node)
+	if err != nil {
+		interrogator.log.Debug("ENR request failed", "err", err)
+		return nil
+	}
+	return enrNode.Record()
+}
+
+func (interrogator *Interrogator) findNode(ctx context.Context, targetKey *ecdsa.PublicKey) ([]*enode.Node, error) {
+	delayForAttempt := func(attempt int) time.Duration { return 2 * time.Second }
+	resultAny, err := utils.Retry(ctx, findNodeRetries, delayForAttempt, isFindNodeTimeoutError, interrogator.log, "FindNode", func(ctx context.Context) (interface{}, error) {
+		return interrogator.transport.FindNode(interrogator.node, targetKey)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("FindNode failed: %w", err)
+	}
+	return resultAny.([]*enode.Node), nil
+}
+
+func isFindNodeTimeoutError(err error) bool {
+	return (err != nil) && (err.Error() == "RPC timeout")
+}
@@ -0,0 +1,68 @@
+package observer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"time"
+
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// keygenTimeout bounds the time spent generating keys for distinct unseen buckets.
+// Since random keys mostly land in the top few buckets of a node,
+// finding an unseen bucket gets exponentially harder, and a cutoff is needed.
+const keygenTimeout = 2 * time.Second
+
+// keygen generates a set of public keys to use as FindNode targets for a given node.
+// Targets are chosen to land in distinct Kademlia buckets of the node,
+// preferring buckets which haven't been probed on previous crawls,
+// which reduces duplicate Neighbors replies.
+// It returns the target keys, and the buckets they land in.
+func keygen(
+	ctx context.Context,
+	node *enode.Node,
+	count uint,
+	probedBuckets map[uint]bool,
+	logger log.Logger,
+) ([]*ecdsa.PublicKey, []uint) {
+	deadline := time.Now().Add(keygenTimeout)
+
+	keys := make([]*ecdsa.PublicKey, 0, count)
+	buckets := make([]uint, 0, count)
+	chosenBuckets := make(map[uint]bool, count)
+
+	// keys falling into already probed or already chosen buckets,
+	// kept in case unseen buckets can't be found in time
+	var fallbackKeys []*ecdsa.PublicKey
+	var fallbackBuckets []uint
+
+	for (uint(len(keys)) < count) && (ctx.Err() == nil) && time.Now().Before(deadline) {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			logger.Error("keygen has failed to generate a key", "err", err)
+			continue
+		}
+
+		bucket := uint(enode.LogDist(node.ID(), enode.PubkeyToIDV4(&key.PublicKey)))
+		if chosenBuckets[bucket] || probedBuckets[bucket] {
+			if uint(len(fallbackKeys)) < count {
+				fallbackKeys = append(fallbackKeys, &key.PublicKey)
+				fallbackBuckets = append(fallbackBuckets, bucket)
+			}
+			continue
+		}
+
+		chosenBuckets[bucket] = true
+		keys = append(keys, &key.PublicKey)
+		buckets = append(buckets, bucket)
+	}
+
+	for i := 0; (uint(len(keys)) < count) && (i < len(fallbackKeys)); i++ {
+		keys = append(keys, fallbackKeys[i])
+		buckets = append(buckets, fallbackBuckets[i])
+	}
+
+	return keys, buckets
+}
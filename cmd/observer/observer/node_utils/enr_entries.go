@@ -0,0 +1,59 @@
+package node_utils
+
+import (
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/p2p/enr"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// ExtractEnrEntries lists the key-value pairs of a node record
+// with the values kept in their raw RLP encoding.
+func ExtractEnrEntries(record *enr.Record) []database.EnrEntry {
+	// AppendElements produces: [seq, key 1, value 1, key 2, value 2, ...]
+	elements := record.AppendElements(nil)
+
+	var entries []database.EnrEntry
+	for i := 1; i+1 < len(elements); i += 2 {
+		key, ok := elements[i].(string)
+		if !ok {
+			continue
+		}
+		value, ok := elements[i+1].(rlp.RawValue)
+		if !ok {
+			continue
+		}
+		entries = append(entries, database.EnrEntry{Key: key, Value: value})
+	}
+	return entries
+}
+
+// EnrForkID extracts the EIP-2124 fork ID announced in the "eth" entry
+// of a node record, if present (see eth/protocols/eth.ENREntry).
+// It returns nil if the record has no "eth" entry.
+func EnrForkID(entries []database.EnrEntry) (*forkid.ID, error) {
+	for _, entry := range entries {
+		if entry.Key != "eth" {
+			continue
+		}
+		var ethEntry struct {
+			ForkID forkid.ID
+			Rest   []rlp.RawValue `rlp:"tail"`
+		}
+		if err := rlp.DecodeBytes(entry.Value, &ethEntry); err != nil {
+			return nil, err
+		}
+		return &ethEntry.ForkID, nil
+	}
+	return nil, nil
+}
+
+// EncodeEnrRecord produces the raw signed RLP encoding of a node record,
+// suitable for storing and re-verifying later (see database.NodeENR).
+func EncodeEnrRecord(record *enr.Record) (database.NodeENR, error) {
+	encoded, err := rlp.EncodeToBytes(record)
+	if err != nil {
+		return database.NodeENR{}, err
+	}
+	return database.NodeENR{Seq: record.Seq(), Record: encoded}, nil
+}
@@ -0,0 +1,27 @@
+package node_utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+)
+
+func NodeID(node *enode.Node) (database.NodeID, error) {
+	if node.Incomplete() {
+		return "", errors.New("NodeID not implemented for incomplete nodes")
+	}
+	keyHex := utils.MarshalHexPublicKey(node.Pubkey())
+	return database.NodeID(keyHex), nil
+}
+
+func NodePubkeyHex(id database.NodeID) (*utils.PubkeyHex, error) {
+	pubkeyHex := utils.PubkeyHex(id)
+	// verify that it parses
+	if _, err := pubkeyHex.Unmarshal(); err != nil {
+		return nil, fmt.Errorf("invalid node ID: %w", err)
+	}
+	return &pubkeyHex, nil
+}
@@ -0,0 +1,29 @@
+package observer
+
+import "sync/atomic"
+
+// PauseControl lets the operators pause and resume the crawl at runtime
+// without killing the process (see the /admin API endpoints).
+// While paused, the crawl workers stop taking candidates
+// and the handshake workers stop dialing,
+// but the API and the reports stay available.
+type PauseControl struct {
+	paused int32
+}
+
+func NewPauseControl() *PauseControl {
+	instance := PauseControl{}
+	return &instance
+}
+
+func (control *PauseControl) Pause() {
+	atomic.StoreInt32(&control.paused, 1)
+}
+
+func (control *PauseControl) Resume() {
+	atomic.StoreInt32(&control.paused, 0)
+}
+
+func (control *PauseControl) IsPaused() bool {
+	return atomic.LoadInt32(&control.paused) != 0
+}
@@ -0,0 +1,145 @@
+package observer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// ProbeScheduler enforces the stored probe policies (see database.ProbePolicy):
+// it tells the Diplomat to hold off probing a node whose matching policy
+// is within its quiet hours, or whose minimum probe interval hasn't elapsed.
+// The policies are cached and re-read periodically, so the operators can
+// adjust them while the crawler is running.
+type ProbeScheduler struct {
+	db database.DBRetrier
+
+	mu        sync.Mutex
+	policies  []database.ProbePolicy
+	refreshed time.Time
+
+	log log.Logger
+}
+
+const probePolicyRefreshPeriod = 5 * time.Minute
+
+func NewProbeScheduler(db database.DBRetrier, logger log.Logger) *ProbeScheduler {
+	instance := ProbeScheduler{
+		db:  db,
+		log: logger,
+	}
+	return &instance
+}
+
+// AllowsProbe tells if the probe policies permit handshaking the node now.
+// A node matched by no policy is always allowed.
+func (scheduler *ProbeScheduler) AllowsProbe(ctx context.Context, id database.NodeID) (bool, error) {
+	policies, err := scheduler.currentPolicies(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(policies) == 0 {
+		return true, nil
+	}
+
+	traits, err := scheduler.db.FindProbeTraits(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if traits == nil {
+		return true, nil
+	}
+
+	now := time.Now()
+	for _, policy := range policies {
+		if policyMatches(policy, traits) && !policyAllowsAt(policy, traits, now) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (scheduler *ProbeScheduler) currentPolicies(ctx context.Context) ([]database.ProbePolicy, error) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	if time.Since(scheduler.refreshed) < probePolicyRefreshPeriod {
+		return scheduler.policies, nil
+	}
+
+	policies, err := scheduler.db.EnumerateProbePolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.policies = policies
+	scheduler.refreshed = time.Now()
+	return policies, nil
+}
+
+// policyMatches tells if the node belongs to the policy group:
+// every configured criterion must match, and a policy
+// with no criteria at all matches no node.
+func policyMatches(policy database.ProbePolicy, traits *database.ProbeTraits) bool {
+	if (policy.Tag == "") && (policy.Subnet == "") && (policy.Country == "") {
+		return false
+	}
+	if policy.Tag != "" {
+		if (traits.Tag == nil) || (*traits.Tag != policy.Tag) {
+			return false
+		}
+	}
+	if policy.Subnet != "" {
+		if !subnetContains(policy.Subnet, traits.IP) {
+			return false
+		}
+	}
+	if policy.Country != "" {
+		if (traits.Country == nil) || (*traits.Country != policy.Country) {
+			return false
+		}
+	}
+	return true
+}
+
+func subnetContains(subnet string, ipStr *string) bool {
+	if ipStr == nil {
+		return false
+	}
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(*ipStr)
+	if ip == nil {
+		return false
+	}
+	return ipNet.Contains(ip)
+}
+
+// policyAllowsAt applies the policy throttles at the given moment.
+func policyAllowsAt(policy database.ProbePolicy, traits *database.ProbeTraits, now time.Time) bool {
+	if (policy.MinInterval > 0) && (traits.LastAttempt != nil) &&
+		(now.Sub(*traits.LastAttempt) < policy.MinInterval) {
+		return false
+	}
+	if policy.QuietStartHour != policy.QuietEndHour {
+		localHour := now.UTC().Add(policy.UTCOffset).Hour()
+		if hourWithin(localHour, policy.QuietStartHour, policy.QuietEndHour) {
+			return false
+		}
+	}
+	return true
+}
+
+// hourWithin tells if the hour falls in the [start, end) window,
+// which may wrap past midnight (e.g. 22 to 6).
+func hourWithin(hour int, start int, end int) bool {
+	if start < end {
+		return (hour >= start) && (hour < end)
+	}
+	return (hour >= start) || (hour < end)
+}
@@ -0,0 +1,52 @@
+package observer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/golang/snappy"
+)
+
+// Protocol violation codes recorded per node (see database.ProtocolViolation).
+// They categorize wire protocol non-conformances of remote implementations.
+const (
+	// ProtocolViolationOversizedFrame - a message exceeding the 16MB RLPx frame limit.
+	ProtocolViolationOversizedFrame = "oversized-frame"
+	// ProtocolViolationBadRLP - a handshake message that fails to parse as RLP.
+	ProtocolViolationBadRLP = "bad-rlp"
+	// ProtocolViolationInvalidSnappy - a message with corrupt snappy compression.
+	ProtocolViolationInvalidSnappy = "invalid-snappy"
+)
+
+// ClassifyProtocolViolation tells if a handshake failure was caused
+// by a wire protocol non-conformance of the remote node, and categorizes it.
+// Ordinary failures (refused connections, timeouts, disconnects) are not violations.
+func ClassifyProtocolViolation(handshakeErr *HandshakeError) (string, bool) {
+	if handshakeErr == nil {
+		return "", false
+	}
+
+	switch handshakeErr.id {
+	case HandshakeErrorIDHelloDecode, HandshakeErrorIDStatusDecode, HandshakeErrorIDDisconnectDecode:
+		return ProtocolViolationBadRLP, true
+
+	case HandshakeErrorIDRead:
+		wrappedErr := handshakeErr.wrappedErr
+		if wrappedErr == nil {
+			return "", false
+		}
+		if errors.Is(wrappedErr, snappy.ErrCorrupt) || errors.Is(wrappedErr, snappy.ErrTooLarge) {
+			return ProtocolViolationInvalidSnappy, true
+		}
+		message := wrappedErr.Error()
+		// rlpx does not export errPlainMessageTooLarge
+		if strings.Contains(message, "message length >= 16MB") {
+			return ProtocolViolationOversizedFrame, true
+		}
+		// rlpx reports an unparseable message code as "invalid message code"
+		if strings.Contains(message, "invalid message code") {
+			return ProtocolViolationBadRLP, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,40 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// PruneLoop periodically deletes the nodes unreachable for longer than maxAge
+// and their dependent rows (see database.DB.DeleteStaleNodes),
+// so that a long-running crawl database doesn't grow without bound.
+func PruneLoop(
+	ctx context.Context,
+	db database.DB,
+	maxAge time.Duration,
+	maxPingTries uint,
+	period time.Duration,
+	logger log.Logger,
+) {
+	for ctx.Err() == nil {
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+
+		counts, err := db.DeleteStaleNodes(ctx, time.Now().Add(-maxAge), maxPingTries)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to prune stale nodes", "err", err)
+			}
+			continue
+		}
+		if counts["nodes"] > 0 {
+			logger.Info("Pruned stale nodes", "nodes", counts["nodes"])
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package observer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+)
+
+// RateLimiter paces the outgoing handshake dials of the crawl:
+// a token bucket caps the global dial rate, and a per-IP counter caps
+// the concurrent connections to a single remote host,
+// so that a heavy crawl doesn't look like an attack against
+// the probed hosts and doesn't trip intrusion detection systems.
+// A nil limiter imposes no limits.
+type RateLimiter struct {
+	dialRate *rate.Limiter
+
+	maxConnsPerIP uint
+	mutex         sync.Mutex
+	conns         map[string]uint
+}
+
+// connSlotPollPeriod is how often a dial blocked on a busy IP
+// re-checks for a free connection slot.
+const connSlotPollPeriod = 20 * time.Millisecond
+
+func NewRateLimiter(maxDialsPerSec uint, maxConnsPerIP uint) *RateLimiter {
+	if (maxDialsPerSec == 0) && (maxConnsPerIP == 0) {
+		return nil
+	}
+	instance := RateLimiter{
+		maxConnsPerIP: maxConnsPerIP,
+		conns:         make(map[string]uint),
+	}
+	if maxDialsPerSec > 0 {
+		// the burst of a second's worth of tokens lets a paused crawl
+		// catch up briefly without exceeding the rate on average
+		instance.dialRate = rate.NewLimiter(rate.Limit(maxDialsPerSec), int(maxDialsPerSec))
+	}
+	return &instance
+}
+
+// Acquire blocks until the IP has a free connection slot and a dial token
+// is available. A successful acquire must be paired with a Release of the same IP.
+func (limiter *RateLimiter) Acquire(ctx context.Context, ip net.IP) error {
+	if limiter == nil {
+		return nil
+	}
+	if err := limiter.acquireConnSlot(ctx, ip); err != nil {
+		return err
+	}
+	if limiter.dialRate != nil {
+		if err := limiter.dialRate.Wait(ctx); err != nil {
+			limiter.Release(ip)
+			return err
+		}
+	}
+	return nil
+}
+
+// acquireConnSlot polls for a free connection slot of the IP.
+// Unlike the per-group semaphores of ASNLimiter, the counts live in a map
+// keyed by the active IPs only, so the tracked state stays bounded
+// no matter how many addresses a long crawl visits.
+func (limiter *RateLimiter) acquireConnSlot(ctx context.Context, ip net.IP) error {
+	if limiter.maxConnsPerIP == 0 {
+		return nil
+	}
+	key := ip.String()
+	for {
+		limiter.mutex.Lock()
+		if limiter.conns[key] < limiter.maxConnsPerIP {
+			limiter.conns[key]++
+			limiter.mutex.Unlock()
+			return nil
+		}
+		limiter.mutex.Unlock()
+
+		if err := utils.Sleep(ctx, connSlotPollPeriod); err != nil {
+			return err
+		}
+	}
+}
+
+func (limiter *RateLimiter) Release(ip net.IP) {
+	if (limiter == nil) || (limiter.maxConnsPerIP == 0) {
+		return
+	}
+	key := ip.String()
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if limiter.conns[key] <= 1 {
+		delete(limiter.conns, key)
+	} else {
+		limiter.conns[key]--
+	}
+}
@@ -0,0 +1,92 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+const remoteResourceTimeout = 30 * time.Second
+
+// RemoteResourceLoop periodically re-fetches a remote resource (see the
+// --deny-list-url and --fork-schedule-url flags) and applies it on change,
+// so fleet-wide policy updates don't require restarting every observer.
+// The ETag of the last applied fetch is replayed in If-None-Match,
+// so an unchanged resource costs a 304 round trip instead of a download.
+// If applyFunc rejects the content, the previous content stays in effect
+// and the fetch is retried on the next tick.
+func RemoteResourceLoop(
+	ctx context.Context,
+	resourceURL string,
+	period time.Duration,
+	applyFunc func(data []byte) error,
+	logger log.Logger,
+) {
+	client := http.Client{Timeout: remoteResourceTimeout}
+	var etag string
+
+	for ctx.Err() == nil {
+		newETag, err := fetchRemoteResource(ctx, &client, resourceURL, etag, applyFunc)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Warn("Failed to refresh a remote resource", "url", resourceURL, "err", err)
+			}
+		} else {
+			if newETag != etag {
+				logger.Info("Applied a remote resource update", "url", resourceURL)
+			}
+			etag = newETag
+		}
+
+		if utils.Sleep(ctx, period) != nil {
+			break
+		}
+	}
+}
+
+// fetchRemoteResource makes one conditional GET, and returns the ETag
+// to replay on the next fetch. The old ETag is kept on failures,
+// so rejected content keeps being re-fetched until it is fixed.
+func fetchRemoteResource(
+	ctx context.Context,
+	client *http.Client,
+	resourceURL string,
+	etag string,
+	applyFunc func(data []byte) error,
+) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return etag, fmt.Errorf("failed to make a request: %w", err)
+	}
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return etag, fmt.Errorf("failed to fetch: %w", err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode == http.StatusNotModified {
+		return etag, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return etag, fmt.Errorf("unexpected status: %s", response.Status)
+	}
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return etag, fmt.Errorf("failed to read the response: %w", err)
+	}
+	if err := applyFunc(data); err != nil {
+		return etag, fmt.Errorf("failed to apply the content: %w", err)
+	}
+	return response.Header.Get("ETag"), nil
+}
@@ -0,0 +1,152 @@
+package observer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// ReplayLog records what a crawl session fed into the database layer:
+// the leased candidate batches, the discovered peers, and the ping and dial
+// outcomes with their timings, as JSON lines.
+// The log can later be re-fed through the pipeline against a fresh database
+// (see the replay package), so that DB-layer and scoring bugs found in a long
+// session can be reproduced deterministically without the network.
+// Unlike Capture the log is a reproduction artifact, so it is never rotated:
+// a truncated log would replay a different session.
+// A nil ReplayLog records nothing.
+type ReplayLog struct {
+	mutex sync.Mutex
+	file  *os.File
+
+	log log.Logger
+}
+
+// The replay record kinds (see ReplayRecord.Kind).
+const (
+	ReplayKindSession = "session"
+	ReplayKindBatch   = "batch"
+	ReplayKindFound   = "found"
+	ReplayKindPing    = "ping"
+	ReplayKindDial    = "dial"
+)
+
+// ReplayRecord is one replay log line. Kind selects which fields are set:
+// a "session" header carries the chain facts, a "batch" the leased candidates,
+// a "found" a discovered peer with its frontier entry,
+// and a "ping" or "dial" the outcome of probing a single node.
+type ReplayRecord struct {
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+
+	Chain     string `json:"chain,omitempty"`
+	NetworkID uint   `json:"network,omitempty"`
+
+	Owner string            `json:"owner,omitempty"`
+	Batch []database.NodeID `json:"batch,omitempty"`
+
+	NodeID   database.NodeID    `json:"node,omitempty"`
+	Addr     *database.NodeAddr `json:"addr,omitempty"`
+	Source   string             `json:"source,omitempty"`
+	Depth    uint               `json:"depth,omitempty"`
+	Priority float64            `json:"priority,omitempty"`
+
+	OK       bool   `json:"ok,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	ErrCode  string `json:"err,omitempty"`
+	Duration int64  `json:"duration_ms,omitempty"`
+	// RetryTime is the crawl retry time the session scheduled after the ping,
+	// replayed verbatim so that the candidate scoring sees the same timestamps.
+	RetryTime int64 `json:"retry,omitempty"`
+}
+
+// NewReplayLog opens the log for writing and records the session header.
+// An existing file is truncated: appending to an older session would
+// produce a log that replays neither session.
+func NewReplayLog(path string, chain string, networkID uint, logger log.Logger) (*ReplayLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the replay log: %w", err)
+	}
+
+	instance := ReplayLog{
+		file: file,
+		log:  logger,
+	}
+	instance.record(ReplayRecord{Kind: ReplayKindSession, Chain: chain, NetworkID: networkID})
+	return &instance, nil
+}
+
+// RecordBatch notes a candidate batch leased by a crawl worker.
+func (replayLog *ReplayLog) RecordBatch(owner string, ids []database.NodeID) {
+	if replayLog == nil {
+		return
+	}
+	replayLog.record(ReplayRecord{Kind: ReplayKindBatch, Owner: owner, Batch: ids})
+}
+
+// RecordFound notes a discovered peer together with its frontier entry.
+func (replayLog *ReplayLog) RecordFound(id database.NodeID, addr database.NodeAddr, source string, depth uint, priority float64) {
+	if replayLog == nil {
+		return
+	}
+	replayLog.record(ReplayRecord{Kind: ReplayKindFound, NodeID: id, Addr: &addr,
+		Source: source, Depth: depth, Priority: priority})
+}
+
+// RecordPing notes a ping outcome. retryTime is the crawl retry time
+// the session scheduled for the node (zero means none was scheduled).
+func (replayLog *ReplayLog) RecordPing(id database.NodeID, ok bool, duration time.Duration, retryTime time.Time) {
+	if replayLog == nil {
+		return
+	}
+	record := ReplayRecord{Kind: ReplayKindPing, NodeID: id, OK: ok, Duration: duration.Milliseconds()}
+	if !retryTime.IsZero() {
+		record.RetryTime = retryTime.Unix()
+	}
+	replayLog.record(record)
+}
+
+// RecordDial notes an RLPx handshake outcome:
+// the observed client ID on success, or the handshake error code on failure.
+func (replayLog *ReplayLog) RecordDial(id database.NodeID, clientID string, errCode string, duration time.Duration) {
+	if replayLog == nil {
+		return
+	}
+	replayLog.record(ReplayRecord{Kind: ReplayKindDial, NodeID: id, OK: errCode == "",
+		ClientID: clientID, ErrCode: errCode, Duration: duration.Milliseconds()})
+}
+
+// record appends a line to the log. Like Capture it never fails the caller:
+// write errors are only logged, and show up as a divergence during replay.
+func (replayLog *ReplayLog) record(record ReplayRecord) {
+	record.Time = time.Now().UTC()
+	line, err := json.Marshal(&record)
+	if err != nil {
+		replayLog.log.Error("Failed to encode a replay record", "err", err)
+		return
+	}
+	line = append(line, '\n')
+
+	replayLog.mutex.Lock()
+	defer replayLog.mutex.Unlock()
+
+	if _, err := replayLog.file.Write(line); err != nil {
+		replayLog.log.Error("Failed to write a replay record", "err", err)
+	}
+}
+
+func (replayLog *ReplayLog) Close() error {
+	if replayLog == nil {
+		return nil
+	}
+	replayLog.mutex.Lock()
+	defer replayLog.mutex.Unlock()
+	return replayLog.file.Close()
+}
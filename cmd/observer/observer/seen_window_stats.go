@@ -0,0 +1,38 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// seenWindows are the rolling windows whose reachable-node sets are materialized
+// (see database.SeenWindowStats).
+var seenWindows = []time.Duration{
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// SeenWindowsLoop periodically refreshes the materialized "nodes seen in window" stats,
+// so that the reports read them instead of scanning the history tables.
+func SeenWindowsLoop(ctx context.Context, db database.DB, period time.Duration, logger log.Logger) {
+	for ctx.Err() == nil {
+		for _, window := range seenWindows {
+			if err := db.RefreshSeenWindowStats(ctx, window); err != nil {
+				if !errors.Is(err, context.Canceled) {
+					logger.Error("Failed to refresh the seen window stats", "window", window, "err", err)
+				}
+				break
+			}
+		}
+
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+	}
+}
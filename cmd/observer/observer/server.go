@@ -0,0 +1,204 @@
+package observer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"path/filepath"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/debug"
+	"github.com/ledgerwatch/erigon/core/forkid"
+	"github.com/ledgerwatch/erigon/eth/protocols/eth"
+	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/ledgerwatch/erigon/p2p/discover"
+	"github.com/ledgerwatch/erigon/p2p/enode"
+	"github.com/ledgerwatch/erigon/p2p/enr"
+	"github.com/ledgerwatch/erigon/p2p/nat"
+	"github.com/ledgerwatch/erigon/p2p/netutil"
+	"github.com/ledgerwatch/erigon/params"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// Server is a stripped down p2p.Server, which only runs a discv4 UDP listener:
+// the observer never accepts incoming RLPx connections.
+type Server struct {
+	localNode *enode.LocalNode
+
+	listenAddr   string
+	natInterface nat.Interface
+	discConfig   discover.Config
+
+	meter *BandwidthMeter
+
+	log log.Logger
+}
+
+func NewServer(flags CommandFlags, meter *BandwidthMeter, logger log.Logger) (*Server, error) {
+	nodeDBPath := filepath.Join(flags.DataDir, "nodes", "eth66")
+
+	nodeKeyConfig := p2p.NodeKeyConfig{}
+	privateKey, err := nodeKeyConfig.LoadOrGenerateAndSave(nodeKeyConfig.DefaultPath(flags.DataDir))
+	if err != nil {
+		return nil, err
+	}
+
+	localNode, err := makeLocalNode(nodeDBPath, privateKey, flags.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	listenAddr := fmt.Sprintf(":%d", flags.ListenPort)
+
+	natInterface, err := nat.Parse(flags.NATDesc)
+	if err != nil {
+		return nil, fmt.Errorf("NAT parse error: %w", err)
+	}
+
+	var netRestrictList *netutil.Netlist
+	if flags.NetRestrict != "" {
+		netRestrictList, err = netutil.ParseNetlist(flags.NetRestrict)
+		if err != nil {
+			return nil, fmt.Errorf("net restrict parse error: %w", err)
+		}
+	}
+
+	bootnodes, err := utils.GetBootnodesFromFlags(flags.Bootnodes, flags.Chain)
+	if err != nil {
+		return nil, fmt.Errorf("bootnodes parse error: %w", err)
+	}
+
+	discConfig := discover.Config{
+		PrivateKey:  privateKey,
+		NetRestrict: netRestrictList,
+		Bootnodes:   bootnodes,
+		Log:         logger,
+	}
+
+	instance := Server{
+		localNode,
+		listenAddr,
+		natInterface,
+		discConfig,
+		meter,
+		logger,
+	}
+	return &instance, nil
+}
+
+func makeLocalNode(nodeDBPath string, privateKey *ecdsa.PrivateKey, chain string) (*enode.LocalNode, error) {
+	db, err := enode.OpenDB(nodeDBPath)
+	if err != nil {
+		return nil, err
+	}
+	localNode := enode.NewLocalNode(db, privateKey)
+	localNode.SetFallbackIP(net.IP{127, 0, 0, 1})
+
+	forksEntry, err := makeForksENREntry(chain)
+	if err != nil {
+		return nil, err
+	}
+	localNode.Set(forksEntry)
+
+	return localNode, nil
+}
+
+func makeForksENREntry(chain string) (enr.Entry, error) {
+	chainConfig := params.ChainConfigByChainName(chain)
+	genesisHash := params.GenesisHashByChainName(chain)
+	if (chainConfig == nil) || (genesisHash == nil) {
+		return nil, fmt.Errorf("unknown chain %s", chain)
+	}
+
+	heightForks := forkid.GatherForks(chainConfig)
+	return eth.CurrentENREntryFromForks(heightForks, *genesisHash, 0), nil
+}
+
+func (server *Server) PrivateKey() *ecdsa.PrivateKey {
+	return server.discConfig.PrivateKey
+}
+
+func (server *Server) Bootnodes() []*enode.Node {
+	return server.discConfig.Bootnodes
+}
+
+func (server *Server) mapNATPort(ctx context.Context, realAddr *net.UDPAddr) {
+	if server.natInterface == nil {
+		return
+	}
+	if realAddr.IP.IsLoopback() {
+		return
+	}
+	if !server.natInterface.SupportsMapping() {
+		return
+	}
+
+	go func() {
+		defer debug.LogPanic()
+		nat.Map(server.natInterface, ctx.Done(), "udp", realAddr.Port, realAddr.Port, "ethereum discovery")
+	}()
+}
+
+func (server *Server) detectNATExternalIP() {
+	switch server.natInterface.(type) {
+	case nil:
+	case nat.ExtIP:
+		// ExtIP doesn't block, set the IP right away.
+		ip, _ := server.natInterface.ExternalIP()
+		server.localNode.SetStaticIP(ip)
+	default:
+		// Ask the router about the IP. This takes a while and blocks startup,
+		// do it in the background.
+		go func() {
+			defer debug.LogPanic()
+			if ip, err := server.natInterface.ExternalIP(); err == nil {
+				server.localNode.SetStaticIP(ip)
+			}
+		}()
+	}
+}
+
+// Listen starts the discv4 UDP listener and returns its transport.
+func (server *Server) Listen(ctx context.Context) (*discover.UDPv4, error) {
+	addr, err := net.ResolveUDPAddr("udp", server.listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("ResolveUDPAddr error: %w", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ListenUDP error: %w", err)
+	}
+
+	realAddr := conn.LocalAddr().(*net.UDPAddr)
+	server.log.Debug("UDP listener up", "addr", realAddr)
+
+	server.mapNATPort(ctx, realAddr)
+	server.detectNATExternalIP()
+	server.localNode.SetFallbackUDP(realAddr.Port)
+	recordNATConfigured(server.natInterface != nil)
+
+	return discover.ListenV4(ctx, server.meter.MeterUDPConn(conn), server.localNode, server.discConfig)
+}
+
+// ListenV5 starts the discv5 UDP listener and returns its transport.
+func (server *Server) ListenV5(ctx context.Context) (*discover.UDPv5, error) {
+	addr, err := net.ResolveUDPAddr("udp", server.listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("ResolveUDPAddr error: %w", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ListenUDP error: %w", err)
+	}
+
+	realAddr := conn.LocalAddr().(*net.UDPAddr)
+	server.log.Debug("UDP listener up", "addr", realAddr)
+
+	server.mapNATPort(ctx, realAddr)
+	server.detectNATExternalIP()
+	server.localNode.SetFallbackUDP(realAddr.Port)
+	recordNATConfigured(server.natInterface != nil)
+
+	return discover.ListenV5(ctx, server.meter.MeterUDPConn(conn), server.localNode, server.discConfig)
+}
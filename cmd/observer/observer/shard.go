@@ -0,0 +1,92 @@
+package observer
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// Shard restricts a worker process to a slice of the node ID keyspace,
+// so that several observers can cover the network cooperatively without a coordinator.
+// Index is 1-based: shard "2/4" covers the second quarter of the keyspace.
+type Shard struct {
+	Index uint
+	Count uint
+}
+
+// WholeKeyspace is the default single-worker shard.
+func WholeKeyspace() Shard {
+	return Shard{Index: 1, Count: 1}
+}
+
+// ParseShard parses a shard spec like "2/4".
+func ParseShard(value string) (Shard, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return Shard{}, fmt.Errorf("failed to parse shard %q: expecting an i/n pair", value)
+	}
+	index, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return Shard{}, fmt.Errorf("failed to parse shard %q index: %w", value, err)
+	}
+	count, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return Shard{}, fmt.Errorf("failed to parse shard %q count: %w", value, err)
+	}
+	if (count == 0) || (index == 0) || (index > count) {
+		return Shard{}, fmt.Errorf("shard %q is out of range", value)
+	}
+	return Shard{Index: uint(index), Count: uint(count)}, nil
+}
+
+// shardIDPrefixLen is how many leading hex digits of a node ID place it in the keyspace.
+const shardIDPrefixLen = 16
+
+// boundPrefix is the lower keyspace bound of the k-th slice (0-based) as a 64-bit prefix.
+func (shard Shard) boundPrefix(k uint) uint64 {
+	bound := new(big.Int).Lsh(big.NewInt(int64(k)), 64)
+	bound.Div(bound, big.NewInt(int64(shard.Count)))
+	return bound.Uint64()
+}
+
+// IDRange returns the node ID bounds of the shard slice: lower inclusive, upper exclusive.
+// The bounds are hex prefixes compared lexicographically against the full IDs.
+// The last shard returns "g" as the upper bound, which is greater than any hex string.
+func (shard Shard) IDRange() (lower database.NodeID, upper database.NodeID) {
+	if shard.Count <= 1 {
+		return "", "g"
+	}
+	lower = database.NodeID(fmt.Sprintf("%016x", shard.boundPrefix(shard.Index-1)))
+	if shard.Index == shard.Count {
+		upper = "g"
+	} else {
+		upper = database.NodeID(fmt.Sprintf("%016x", shard.boundPrefix(shard.Index)))
+	}
+	return lower, upper
+}
+
+// Contains tells if the node ID falls into the shard slice.
+func (shard Shard) Contains(id database.NodeID) bool {
+	if shard.Count <= 1 {
+		return true
+	}
+	idPrefix := string(id)
+	if len(idPrefix) > shardIDPrefixLen {
+		idPrefix = idPrefix[:shardIDPrefixLen]
+	}
+	value, err := strconv.ParseUint(idPrefix, 16, 64)
+	if err != nil {
+		return false
+	}
+	if value < shard.boundPrefix(shard.Index-1) {
+		return false
+	}
+	return (shard.Index == shard.Count) || (value < shard.boundPrefix(shard.Index))
+}
+
+func (shard Shard) String() string {
+	return fmt.Sprintf("%d/%d", shard.Index, shard.Count)
+}
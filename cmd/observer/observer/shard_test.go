@@ -0,0 +1,81 @@
+package observer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+func shardTestID(prefix string) database.NodeID {
+	return database.NodeID(prefix + strings.Repeat("0", 128-len(prefix)))
+}
+
+func TestParseShard(t *testing.T) {
+	shard, err := ParseShard("2/4")
+	require.NoError(t, err)
+	assert.Equal(t, Shard{Index: 2, Count: 4}, shard)
+
+	for _, value := range []string{"", "2", "0/4", "5/4", "2/0", "x/4", "2/x"} {
+		_, err := ParseShard(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestShardIDRange(t *testing.T) {
+	lower, upper := WholeKeyspace().IDRange()
+	assert.Equal(t, database.NodeID(""), lower)
+	assert.Equal(t, database.NodeID("g"), upper)
+
+	lower, upper = Shard{Index: 1, Count: 4}.IDRange()
+	assert.Equal(t, database.NodeID("0000000000000000"), lower)
+	assert.Equal(t, database.NodeID("4000000000000000"), upper)
+
+	lower, upper = Shard{Index: 2, Count: 4}.IDRange()
+	assert.Equal(t, database.NodeID("4000000000000000"), lower)
+	assert.Equal(t, database.NodeID("8000000000000000"), upper)
+
+	// the last shard covers the keyspace up to and including ffff...
+	lower, upper = Shard{Index: 4, Count: 4}.IDRange()
+	assert.Equal(t, database.NodeID("c000000000000000"), lower)
+	assert.Equal(t, database.NodeID("g"), upper)
+}
+
+func TestShardIDRangesCoverKeyspace(t *testing.T) {
+	// consecutive shards share their bounds, so every ID lands in exactly one shard
+	for _, count := range []uint{2, 3, 7} {
+		var prevUpper database.NodeID
+		for index := uint(1); index <= count; index++ {
+			lower, upper := Shard{Index: index, Count: count}.IDRange()
+			if index == 1 {
+				assert.Less(t, string(lower), "0000000000000001")
+			} else {
+				assert.Equal(t, prevUpper, lower)
+			}
+			assert.Less(t, string(lower), string(upper))
+			prevUpper = upper
+		}
+		assert.Equal(t, database.NodeID("g"), prevUpper)
+	}
+}
+
+func TestShardContains(t *testing.T) {
+	assert.True(t, WholeKeyspace().Contains(shardTestID("ff")))
+
+	shard := Shard{Index: 2, Count: 4}
+	assert.False(t, shard.Contains(shardTestID("3fffffffffffffff")))
+	assert.True(t, shard.Contains(shardTestID("4000000000000000")))
+	assert.True(t, shard.Contains(shardTestID("7fffffffffffffff")))
+	assert.False(t, shard.Contains(shardTestID("8000000000000000")))
+
+	// the last shard includes the top of the keyspace
+	last := Shard{Index: 4, Count: 4}
+	assert.True(t, last.Contains(shardTestID("ffffffffffffffff")))
+	assert.False(t, last.Contains(shardTestID("bfffffffffffffff")))
+
+	// a malformed ID belongs to no shard
+	assert.False(t, shard.Contains(database.NodeID("not-hex")))
+}
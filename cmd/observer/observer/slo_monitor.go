@@ -0,0 +1,91 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// sloBudgetBurnWarnThreshold is the consumed error budget fraction
+// above which a monitored node is considered at risk.
+const sloBudgetBurnWarnThreshold = 0.8
+
+// UpdateSLOStatus recomputes the availability of a monitored node within its SLO window,
+// persists the error budget burn, and returns the new status.
+func UpdateSLOStatus(ctx context.Context, db database.DB, node database.MonitoredNode) (database.SLOStatus, error) {
+	windowStart := time.Now().Add(-time.Duration(node.WindowDays) * 24 * time.Hour)
+
+	attempts, successes, err := db.CountHandshakeOutcomesSince(ctx, node.ID, windowStart)
+	if err != nil {
+		return database.SLOStatus{}, err
+	}
+
+	status := database.SLOStatus{
+		ID:           node.ID,
+		WindowStart:  windowStart,
+		AttemptCount: attempts,
+		SuccessCount: successes,
+		Availability: 1.0,
+	}
+	if attempts > 0 {
+		status.Availability = float64(successes) / float64(attempts)
+	}
+
+	errorBudget := 1.0 - node.SLOTarget
+	if errorBudget > 0 {
+		status.BudgetBurn = (1.0 - status.Availability) / errorBudget
+	} else if status.Availability < 1.0 {
+		status.BudgetBurn = 1.0
+	}
+
+	if err := db.UpsertSLOStatus(ctx, status); err != nil {
+		return database.SLOStatus{}, err
+	}
+	return status, nil
+}
+
+// SLOMonitorLoop tracks the availability of the monitored nodes against their SLOs,
+// and logs an alert when an error budget is at risk or exhausted.
+func SLOMonitorLoop(ctx context.Context, db database.DB, period time.Duration, logger log.Logger) {
+	for ctx.Err() == nil {
+		nodes, err := db.EnumerateMonitoredNodes(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to enumerate monitored nodes", "err", err)
+			}
+			break
+		}
+
+		for _, node := range nodes {
+			status, err := UpdateSLOStatus(ctx, db, node)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					logger.Error("Failed to update SLO status", "node", node.ID, "err", err)
+				}
+				continue
+			}
+
+			logCtx := []interface{}{
+				"node", node.ID,
+				"label", node.Label,
+				"availability", status.Availability,
+				"target", node.SLOTarget,
+				"budgetBurn", status.BudgetBurn,
+			}
+			switch {
+			case status.BudgetBurn >= 1.0:
+				logger.Error("SLO violated", logCtx...)
+			case status.BudgetBurn >= sloBudgetBurnWarnThreshold:
+				logger.Warn("SLO error budget at risk", logCtx...)
+			}
+		}
+
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+	}
+}
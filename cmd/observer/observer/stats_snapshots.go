@@ -0,0 +1,87 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+// StatsSnapshotsLoop periodically records a snapshot of the network statistics
+// (see database.StatsSnapshot) and prunes the snapshots older than the retention,
+// so that the status report can show trends over days and weeks.
+func StatsSnapshotsLoop(
+	ctx context.Context,
+	db database.DB,
+	maxPingTries uint,
+	networkID uint,
+	period time.Duration,
+	retention time.Duration,
+	logger log.Logger,
+) {
+	for ctx.Err() == nil {
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+
+		if err := TakeStatsSnapshot(ctx, db, maxPingTries, networkID, retention); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to take a stats snapshot", "err", err)
+			}
+		}
+	}
+}
+
+func TakeStatsSnapshot(
+	ctx context.Context,
+	db database.DB,
+	maxPingTries uint,
+	networkID uint,
+	retention time.Duration,
+) error {
+	totalNodes, err := db.CountAllNodes(ctx, networkID)
+	if err != nil {
+		return err
+	}
+	compatibleNodes, err := db.CountNodes(ctx, maxPingTries, networkID)
+	if err != nil {
+		return err
+	}
+	distinctIPs, err := db.CountIPs(ctx, maxPingTries, networkID)
+	if err != nil {
+		return err
+	}
+	operatorEstimate, err := db.EstimateOperatorCount(ctx, networkID)
+	if err != nil {
+		return err
+	}
+
+	clientGroups, err := db.CountClientGroups(ctx, networkID)
+	if err != nil {
+		return err
+	}
+	clientCounts := make(map[string]uint)
+	for clientID, count := range clientGroups {
+		clientCounts[reports.NameFromClientID(clientID)] += count
+	}
+
+	snapshot := database.StatsSnapshot{
+		Time:             time.Now(),
+		TotalNodes:       totalNodes,
+		CompatibleNodes:  compatibleNodes,
+		DistinctIPs:      distinctIPs,
+		OperatorEstimate: operatorEstimate,
+		ClientCounts:     clientCounts,
+	}
+	if err := db.InsertStatsSnapshot(ctx, snapshot); err != nil {
+		return err
+	}
+	updateOperatorEstimateMetric(operatorEstimate)
+
+	_, err = db.DeleteStatsSnapshotsBefore(ctx, time.Now().Add(-retention))
+	return err
+}
@@ -0,0 +1,79 @@
+package observer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/log/v3"
+)
+
+func StatusLoggerLoop(
+	ctx context.Context,
+	db database.DB,
+	networkID uint,
+	maxPingTries uint,
+	meter *BandwidthMeter,
+	period time.Duration,
+	logger log.Logger,
+) {
+	var lastTotalCount uint
+	var lastDistinctIPCount uint
+	var lastFrontierCount uint
+	var lastDiagnosisCodes string
+
+	for ctx.Err() == nil {
+		if err := utils.Sleep(ctx, period); err != nil {
+			break
+		}
+
+		totalCount, err := db.CountNodes(ctx, maxPingTries, networkID)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to count nodes", "err", err)
+			}
+			continue
+		}
+
+		distinctIPCount, err := db.CountIPs(ctx, maxPingTries, networkID)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to count IPs", "err", err)
+			}
+			continue
+		}
+
+		frontierCount, err := db.CountFrontier(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logger.Error("Failed to count the frontier", "err", err)
+			}
+			continue
+		}
+
+		updateStatusMetrics(totalCount, distinctIPCount, frontierCount)
+
+		if (totalCount != lastTotalCount) || (distinctIPCount != lastDistinctIPCount) || (frontierCount != lastFrontierCount) {
+			logger.Info("Status", "nodes", totalCount, "IPs", distinctIPCount, "frontier", frontierCount,
+				"sentMB", meter.BytesSent()/1024/1024, "receivedMB", meter.BytesReceived()/1024/1024)
+		}
+		lastTotalCount = totalCount
+		lastDistinctIPCount = distinctIPCount
+		lastFrontierCount = frontierCount
+
+		// report the self-diagnosis findings when the finding set changes
+		findings := Diagnose(CollectDiagnosisStats(meter))
+		codes := diagnosisCodes(findings)
+		if codes != lastDiagnosisCodes {
+			for _, finding := range findings {
+				logger.Warn("Crawl health", "finding", finding.Code, "summary", finding.Summary, "advice", finding.Advice)
+			}
+			if len(findings) == 0 {
+				logger.Info("Crawl health findings resolved")
+			}
+		}
+		lastDiagnosisCodes = codes
+	}
+}
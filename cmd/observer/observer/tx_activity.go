@@ -0,0 +1,106 @@
+package observer
+
+import (
+	"net"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/common"
+	"github.com/ledgerwatch/erigon/p2p/rlpx"
+	"github.com/ledgerwatch/erigon/rlp"
+)
+
+// TxActivity summarizes a tx announcement listening session
+// (see ListenTxAnnouncements).
+type TxActivity struct {
+	// Announcements is how many NewPooledTransactionHashes messages arrived.
+	Announcements uint
+	// BlockAnnouncements are the NewBlockHashes announcements that arrived,
+	// timestamped for block propagation measurement.
+	BlockAnnouncements []database.BlockAnnouncement
+	// Window is how long the session listened
+	// (shorter than requested if the peer hung up early).
+	Window time.Duration
+}
+
+// NewBlockHashesMessage is the eth block announcement message.
+// https://github.com/ethereum/devp2p/blob/master/caps/eth.md#newblockhashes-0x01
+type NewBlockHashesMessage []struct {
+	Hash   common.Hash
+	Number uint64
+}
+
+// RatePerMinute is the announcement frequency observed during the session.
+func (activity *TxActivity) RatePerMinute() float64 {
+	minutes := activity.Window.Minutes()
+	if minutes <= 0 {
+		return 0
+	}
+	return float64(activity.Announcements) / minutes
+}
+
+// ListenTxAnnouncements keeps a handshaked session open for the given window,
+// counting the NewPooledTransactionHashes announcements of the peer:
+// a live synced node relays its tx pool traffic steadily,
+// while an idle shell that merely answers handshakes stays silent.
+// NewBlockHashes announcements are timestamped along the way,
+// so block propagation can be compared across the sampled peers.
+// Pings are answered to keep the session alive, other messages are ignored,
+// and a peer hanging up early just shortens the recorded window.
+func ListenTxAnnouncements(
+	conn net.Conn,
+	rlpxConn *rlpx.Conn,
+	window time.Duration,
+	capture *Capture,
+	captureID string,
+) (*TxActivity, *HandshakeError) {
+	startTime := time.Now()
+	if err := conn.SetDeadline(startTime.Add(window)); err != nil {
+		return nil, NewHandshakeError(HandshakeErrorIDSetTimeout, err, 0)
+	}
+
+	activity := TxActivity{Window: window}
+
+	for {
+		messageID, data, _, err := rlpxConn.Read()
+		if err != nil {
+			// the window deadline surfaces as a read timeout and ends the session normally;
+			// any other error means the peer ended the session early
+			if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+				activity.Window = time.Since(startTime)
+			}
+			return &activity, nil
+		}
+		capture.Record(captureID, CaptureDirectionIn, messageID, data)
+
+		switch messageID {
+		case RLPxMessageIDNewPooledTransactionHashes:
+			activity.Announcements++
+
+		case RLPxMessageIDNewBlockHashes:
+			var message NewBlockHashesMessage
+			if err := rlp.DecodeBytes(data, &message); err != nil {
+				// a malformed announcement carries no usable timestamp
+				break
+			}
+			announced := time.Now()
+			for _, block := range message {
+				activity.BlockAnnouncements = append(activity.BlockAnnouncements, database.BlockAnnouncement{
+					Hash:   block.Hash.Hex(),
+					Number: block.Number,
+					Time:   announced,
+				})
+			}
+
+		case RLPxMessageIDPing:
+			if handshakeErr := writeEmptyMessage(rlpxConn, RLPxMessageIDPong); handshakeErr != nil {
+				activity.Window = time.Since(startTime)
+				return &activity, nil
+			}
+
+		case RLPxMessageIDDisconnect:
+			activity.Window = time.Since(startTime)
+			return &activity, nil
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package peerlist
+
+import (
+	"context"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/ledgerwatch/erigon/params/networkname"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+	Chain   string
+
+	// Limit is how many top peers to include in the list.
+	Limit uint
+	// KeyFile is the observer signing key file.
+	// An empty value defaults to "observer-key" in the data directory.
+	KeyFile string
+	// OutputFile is where the signed artifact is written.
+	// An empty value defaults to "peerlist.json" in the data directory.
+	OutputFile string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "peerlist",
+		Short: "Export a signed timestamped list of vetted reachable peers for light clients",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withChain()
+	instance.withLimit()
+	instance.withKeyFile()
+	instance.withOutputFile()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withChain() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Chain, utils.ChainFlag.Name, networkname.MainnetChainName, utils.ChainFlag.Usage)
+}
+
+func (command *Command) withLimit() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.Limit, "limit", 50, "how many top peers to include in the list")
+}
+
+func (command *Command) withKeyFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.KeyFile, "key-file", "",
+		"observer signing key file (default \"observer-key\" in the data directory)")
+}
+
+func (command *Command) withOutputFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.OutputFile, "output", "",
+		"signed peer list output file (default \"peerlist.json\" in the data directory)")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
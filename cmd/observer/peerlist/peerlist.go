@@ -0,0 +1,143 @@
+// Package peerlist exports a signed, timestamped list of vetted reachable peers,
+// which embedded and light Go clients can fetch and verify at startup
+// as an alternative to hard-coded bootnodes.
+package peerlist
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/bootnodes"
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/utils"
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+// Version is the format version of the peer list payload.
+// Verifiers reject payloads with a version they do not know.
+const Version = 1
+
+// PeerList is the signed payload: the vetted peers of a chain at a point in time.
+type PeerList struct {
+	Version uint   `json:"version"`
+	Chain   string `json:"chain"`
+	// GeneratedAt is the signing time in RFC 3339,
+	// so that clients can reject stale lists by their own freshness policy.
+	GeneratedAt string `json:"generated_at"`
+	// Peers are enode:// URLs, the most reliable first
+	// (see database.DB.FindBootnodeCandidates).
+	Peers []string `json:"peers"`
+}
+
+// SignedPeerList is the artifact format: the payload is kept as raw JSON,
+// so that the signature stays valid regardless of how the wrapper is re-encoded.
+type SignedPeerList struct {
+	PeerList json.RawMessage `json:"peer_list"`
+	// Pubkey is the hex-encoded public key of the observer (see utils.PubkeyHex).
+	Pubkey utils.PubkeyHex `json:"pubkey"`
+	// Signature is the hex-encoded secp256k1 signature
+	// of the Keccak-256 hash of the compacted peer_list bytes (see payloadHash).
+	Signature string `json:"signature"`
+}
+
+// payloadHash hashes the payload with the insignificant JSON whitespace stripped,
+// so that pretty-printing the artifact does not invalidate the signature.
+func payloadHash(payload []byte) ([]byte, error) {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, payload); err != nil {
+		return nil, fmt.Errorf("failed to compact the peer list payload: %w", err)
+	}
+	return crypto.Keccak256(compacted.Bytes()), nil
+}
+
+// Build collects the top-limit most reliable, fork-compatible nodes of the chain
+// into a timestamped peer list payload.
+func Build(ctx context.Context, db database.DB, chain string, limit uint, clock utils.Clock) (*PeerList, error) {
+	peers, err := bootnodes.List(ctx, db, chain, limit, bootnodes.FormatEnode)
+	if err != nil {
+		return nil, err
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no compatible reachable peers to list")
+	}
+
+	list := PeerList{
+		Version:     Version,
+		Chain:       chain,
+		GeneratedAt: clock.Now().UTC().Format(time.RFC3339),
+		Peers:       peers,
+	}
+	return &list, nil
+}
+
+// Sign wraps the peer list into the signed artifact.
+func Sign(list *PeerList, key *ecdsa.PrivateKey) (*SignedPeerList, error) {
+	payload, err := json.Marshal(list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the peer list: %w", err)
+	}
+
+	hash, err := payloadHash(payload)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the peer list: %w", err)
+	}
+
+	signedList := SignedPeerList{
+		PeerList:  payload,
+		Pubkey:    utils.MarshalHexPublicKey(&key.PublicKey),
+		Signature: hex.EncodeToString(signature),
+	}
+	return &signedList, nil
+}
+
+// Verify checks the signature of a signed peer list artifact
+// against the expected observer public key, and returns the verified payload.
+// This is the startup-time entry point for the client side:
+// the client hard-codes the observer pubkey instead of the bootnodes.
+func Verify(data []byte, pubkey utils.PubkeyHex) (*PeerList, error) {
+	var signedList SignedPeerList
+	if err := json.Unmarshal(data, &signedList); err != nil {
+		return nil, fmt.Errorf("failed to decode the signed peer list: %w", err)
+	}
+	if signedList.Pubkey != pubkey {
+		return nil, fmt.Errorf("peer list is signed by an unexpected key")
+	}
+
+	key, err := signedList.Pubkey.Unmarshal()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := hex.DecodeString(signedList.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the peer list signature: %w", err)
+	}
+	if len(signature) != crypto.SignatureLength {
+		return nil, fmt.Errorf("unexpected peer list signature length: %d", len(signature))
+	}
+
+	hash, err := payloadHash(signedList.PeerList)
+	if err != nil {
+		return nil, err
+	}
+	if !crypto.VerifySignature(crypto.MarshalPubkeyStd(key), hash, signature[:crypto.RecoveryIDOffset]) {
+		return nil, fmt.Errorf("peer list signature is invalid")
+	}
+
+	var list PeerList
+	if err := json.Unmarshal(signedList.PeerList, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode the peer list payload: %w", err)
+	}
+	if list.Version != Version {
+		return nil, fmt.Errorf("unsupported peer list version: %d", list.Version)
+	}
+	return &list, nil
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir        string
+	Name           string
+	Tag            string
+	Subnet         string
+	Country        string
+	MinInterval    time.Duration
+	QuietStartHour int
+	QuietEndHour   int
+	UTCOffset      time.Duration
+}
+
+type Command struct {
+	command       cobra.Command
+	setCommand    cobra.Command
+	removeCommand cobra.Command
+	listCommand   cobra.Command
+	flags         CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "policy",
+		Short: "Manage the probe scheduling policies (quiet hours and probe frequency per tag, subnet or country)",
+	}
+
+	setCommand := cobra.Command{
+		Use:   "set",
+		Short: "Add or update a probe policy",
+	}
+
+	removeCommand := cobra.Command{
+		Use:   "rm",
+		Short: "Remove a probe policy",
+	}
+
+	listCommand := cobra.Command{
+		Use:   "ls",
+		Short: "List the probe policies",
+	}
+
+	instance := Command{
+		command:       command,
+		setCommand:    setCommand,
+		removeCommand: removeCommand,
+		listCommand:   listCommand,
+	}
+
+	instance.withDatadir()
+	instance.withName()
+	instance.withMatchers()
+	instance.withSchedule()
+
+	instance.command.AddCommand(&instance.setCommand)
+	instance.command.AddCommand(&instance.removeCommand)
+	instance.command.AddCommand(&instance.listCommand)
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.PersistentFlags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkPersistentFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withName() {
+	for _, subCommand := range []*cobra.Command{&command.setCommand, &command.removeCommand} {
+		flags := subCommand.Flags()
+		flags.StringVar(&command.flags.Name, "name", "", "policy name, e.g. partner-infra")
+		if err := subCommand.MarkFlagRequired("name"); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (command *Command) withMatchers() {
+	flags := command.setCommand.Flags()
+	flags.StringVar(&command.flags.Tag, "tag", "", "match nodes monitored with this label")
+	flags.StringVar(&command.flags.Subnet, "subnet", "", "match nodes in this subnet, e.g. 10.2.0.0/16")
+	flags.StringVar(&command.flags.Country, "country", "", "match nodes located in this ISO country code, e.g. DE")
+}
+
+func (command *Command) withSchedule() {
+	flags := command.setCommand.Flags()
+	flags.DurationVar(&command.flags.MinInterval, "min-interval", 0,
+		"do not probe the matched nodes more often than this (0 - no limit)")
+	flags.IntVar(&command.flags.QuietStartHour, "quiet-start", 0,
+		"local hour from which probing pauses (with --quiet-end)")
+	flags.IntVar(&command.flags.QuietEndHour, "quiet-end", 0,
+		"local hour at which probing resumes (equal to --quiet-start - no quiet hours)")
+	flags.DurationVar(&command.flags.UTCOffset, "utc-offset", 0,
+		"the local timezone of the matched nodes as a UTC offset, e.g. -5h")
+}
+
+func (command *Command) OnSet(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.setCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) OnRemove(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.removeCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) OnList(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.listCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,72 @@
+package prune
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+
+	// OlderThan selects the nodes unreachable for longer than this.
+	OlderThan time.Duration
+	// MaxPingTries is the ping try count at which a node counts as dead
+	// (matching the --max-ping-tries of the crawl).
+	MaxPingTries uint
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "prune",
+		Short: "Delete nodes unreachable for too long, with their dependent rows",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withOlderThan()
+	instance.withMaxPingTries()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withOlderThan() {
+	flags := command.command.Flags()
+	flags.DurationVar(&command.flags.OlderThan, "older-than", 30*24*time.Hour,
+		"delete nodes unreachable for longer than this")
+}
+
+func (command *Command) withMaxPingTries() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.MaxPingTries, "max-ping-tries", 3,
+		"the ping try count at which a node counts as dead")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,64 @@
+package replay
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+)
+
+type CommandFlags struct {
+	DataDir string
+	// LogFile is the replay log recorded by a crawl session (see --replay-log).
+	LogFile string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "replay",
+		Short: "Re-feed a recorded crawl session through the pipeline against a fresh database",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withLogFile()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withLogFile() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.LogFile, "log-file", "",
+		"the replay log recorded by a crawl session started with --replay-log")
+	if err := command.command.MarkFlagRequired("log-file"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,223 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer"
+)
+
+// Replayer re-feeds a recorded crawl session (see observer.ReplayLog)
+// through the database layer against a fresh database.
+// The discovered peers, ping outcomes and dial outcomes are applied
+// exactly as the session recorded them, including the scheduled retry times,
+// and each recorded candidate batch is re-leased through AcquireCandidates:
+// a batch that comes back different from the recording is a divergence,
+// pinpointing a DB-layer or scoring bug without re-running the crawl.
+type Replayer struct {
+	db      database.DB
+	logPath string
+
+	log log.Logger
+}
+
+func NewReplayer(db database.DB, logPath string, logger log.Logger) *Replayer {
+	instance := Replayer{
+		db:      db,
+		logPath: logPath,
+		log:     logger,
+	}
+	return &instance
+}
+
+// ReplaySummary counts what a replay run has applied and found.
+type ReplaySummary struct {
+	Records     uint
+	Batches     uint
+	Divergences uint
+	Found       uint
+	Pings       uint
+	Dials       uint
+}
+
+// replayLeaseDuration matches the candidate lease duration of a live crawl;
+// the exact value doesn't matter for a replay, since nothing contends for the leases.
+const replayLeaseDuration = 15 * time.Minute
+
+func (replayer *Replayer) Run(ctx context.Context) (*ReplaySummary, error) {
+	file, err := os.Open(replayer.logPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the replay log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var summary ReplaySummary
+	var networkID uint
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		if ctx.Err() != nil {
+			return &summary, ctx.Err()
+		}
+
+		var record observer.ReplayRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return &summary, fmt.Errorf("failed to parse replay log line %d: %w", lineNum, err)
+		}
+		summary.Records++
+
+		switch record.Kind {
+		case observer.ReplayKindSession:
+			networkID = record.NetworkID
+		case observer.ReplayKindFound:
+			err = replayer.replayFound(ctx, &record, &summary)
+		case observer.ReplayKindBatch:
+			err = replayer.replayBatch(ctx, &record, networkID, &summary)
+		case observer.ReplayKindPing:
+			err = replayer.replayPing(ctx, &record, &summary)
+		case observer.ReplayKindDial:
+			err = replayer.replayDial(ctx, &record, &summary)
+		default:
+			return &summary, fmt.Errorf("unknown replay record kind %q at line %d", record.Kind, lineNum)
+		}
+		if err != nil {
+			return &summary, fmt.Errorf("failed to replay line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &summary, fmt.Errorf("failed to read the replay log: %w", err)
+	}
+	return &summary, nil
+}
+
+func (replayer *Replayer) replayFound(ctx context.Context, record *observer.ReplayRecord, summary *ReplaySummary) error {
+	if record.Addr == nil {
+		return fmt.Errorf("a found record without an address")
+	}
+	if err := replayer.db.UpsertNodeAddr(ctx, record.NodeID, *record.Addr); err != nil {
+		return err
+	}
+	if err := replayer.db.UpdateNodeDepth(ctx, record.NodeID, record.Depth); err != nil {
+		return err
+	}
+	entry := database.FrontierEntry{
+		ID:       record.NodeID,
+		Priority: record.Priority,
+		Source:   record.Source,
+		Depth:    record.Depth,
+	}
+	if err := replayer.db.UpsertFrontierEntry(ctx, entry); err != nil {
+		return err
+	}
+	summary.Found++
+	return nil
+}
+
+// replayBatch re-leases a candidate batch and compares it with the recording.
+// The leases are released right away: the retry times applied by the ping
+// records are what keeps a replayed node from being leased again,
+// just like in the recorded session.
+func (replayer *Replayer) replayBatch(ctx context.Context, record *observer.ReplayRecord, networkID uint, summary *ReplaySummary) error {
+	summary.Batches++
+
+	candidates, err := replayer.db.AcquireCandidates(ctx, record.Owner, replayLeaseDuration,
+		networkID, uint(len(record.Batch)), "", "g")
+	if err != nil {
+		return err
+	}
+	if !sameIDSet(record.Batch, candidates) {
+		summary.Divergences++
+		replayer.log.Warn("Replay divergence: a candidate batch differs from the recording",
+			"owner", record.Owner, "recorded", joinIDs(record.Batch), "replayed", joinIDs(candidates))
+	}
+	return replayer.db.ReleaseCandidateLeases(ctx, record.Owner, candidates)
+}
+
+func (replayer *Replayer) replayPing(ctx context.Context, record *observer.ReplayRecord, summary *ReplaySummary) error {
+	summary.Pings++
+
+	if record.OK {
+		if err := replayer.db.InsertNodeSighting(ctx, record.NodeID, database.NodeSightingPing); err != nil {
+			return err
+		}
+		if err := replayer.db.ResetPingError(ctx, record.NodeID); err != nil {
+			return err
+		}
+	} else {
+		if err := replayer.db.UpdatePingError(ctx, record.NodeID); err != nil {
+			return err
+		}
+	}
+
+	if record.RetryTime > 0 {
+		retryTime := time.Unix(record.RetryTime, 0)
+		if err := replayer.db.UpdateCrawlRetryTime(ctx, record.NodeID, retryTime); err != nil {
+			return err
+		}
+	}
+	return replayer.db.DeleteFrontierEntry(ctx, record.NodeID)
+}
+
+func (replayer *Replayer) replayDial(ctx context.Context, record *observer.ReplayRecord, summary *ReplaySummary) error {
+	summary.Dials++
+
+	if !record.OK {
+		return replayer.db.InsertHandshakeError(ctx, record.NodeID, record.ErrCode)
+	}
+	if _, err := replayer.db.UpdateClientID(ctx, record.NodeID, record.ClientID, database.ObservationQualityVerified); err != nil {
+		return err
+	}
+	return replayer.db.InsertNodeSighting(ctx, record.NodeID, database.NodeSightingHandshake)
+}
+
+// sameIDSet compares two ID lists ignoring the order:
+// AcquireCandidates ranks by priority, and equal priorities may tie-break differently.
+func sameIDSet(recorded []database.NodeID, replayed []database.NodeID) bool {
+	if len(recorded) != len(replayed) {
+		return false
+	}
+	set := make(map[database.NodeID]bool, len(recorded))
+	for _, id := range recorded {
+		set[id] = true
+	}
+	for _, id := range replayed {
+		if !set[id] {
+			return false
+		}
+	}
+	return true
+}
+
+func joinIDs(ids []database.NodeID) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = string(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (summary *ReplaySummary) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("records replayed: %d", summary.Records))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("peers found: %d", summary.Found))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("pings: %d", summary.Pings))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("dials: %d", summary.Dials))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("candidate batches: %d", summary.Batches))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("batch divergences: %d", summary.Divergences))
+	builder.WriteRune('\n')
+	return builder.String()
+}
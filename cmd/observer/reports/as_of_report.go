@@ -0,0 +1,73 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// AsOfReport reconstructs the node set and client distribution
+// as it was at a past moment, replaying the client ID history.
+type AsOfReport struct {
+	AsOf      time.Time
+	NodeCount uint
+	Clients   []ClientsReportEntry
+}
+
+func CreateAsOfReport(
+	ctx context.Context,
+	db database.DB,
+	canonicalizer *Canonicalizer,
+	limit uint,
+	asOf time.Time,
+	window time.Duration,
+) (*AsOfReport, error) {
+	groups := make(map[string]uint)
+	nodeCount := uint(0)
+
+	enumFunc := func(clientID *string) {
+		if clientID == nil {
+			return
+		}
+		nodeCount++
+		clientName := canonicalizer.Canonicalize(NameFromClientID(*clientID))
+		groups[clientName]++
+	}
+	if err := db.EnumerateClientIDsAsOf(ctx, asOf, window, enumFunc); err != nil {
+		return nil, err
+	}
+
+	clients := make([]ClientsReportEntry, 0, len(groups))
+	for clientName, count := range groups {
+		clients = append(clients, ClientsReportEntry{clientName, count})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Count > clients[j].Count
+	})
+
+	if limit < uint(len(clients)) {
+		clients = clients[:limit]
+	}
+
+	report := AsOfReport{asOf, nodeCount, clients}
+	return &report, nil
+}
+
+func (report *AsOfReport) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("as of %s:", report.AsOf.Format(time.RFC3339)))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("nodes: %d", report.NodeCount))
+	builder.WriteRune('\n')
+	builder.WriteString("clients:")
+	builder.WriteRune('\n')
+	for _, entry := range report.Clients {
+		builder.WriteString(fmt.Sprintf("%6d %s", entry.Count, entry.Name))
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
@@ -0,0 +1,48 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// BadRowsReport lists the values quarantined by the strict read validation
+// (see database.DB.EnableStrictRead): unparsable IPs, malformed client IDs
+// and inconsistent timestamps found while reading the database.
+type BadRowsReport struct {
+	Rows []database.BadRow
+}
+
+// badRowsMax is how many quarantined rows the report shows.
+const badRowsMax = 100
+
+func CreateBadRowsReport(ctx context.Context, db database.DB) (*BadRowsReport, error) {
+	rows, err := db.FindBadRows(ctx, badRowsMax)
+	if err != nil {
+		return nil, err
+	}
+
+	report := BadRowsReport{
+		Rows: rows,
+	}
+	return &report, nil
+}
+
+func (report *BadRowsReport) String() string {
+	var builder strings.Builder
+	if len(report.Rows) == 0 {
+		builder.WriteString("no quarantined rows")
+		builder.WriteRune('\n')
+		return builder.String()
+	}
+	for _, row := range report.Rows {
+		builder.WriteString(fmt.Sprintf("%s  %s  %s=%s  %s",
+			row.Detected.Format("2006-01-02 15:04:05"), row.Reason,
+			row.Field, strconv.Quote(row.Value), row.NodeID))
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
@@ -0,0 +1,70 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// BlockPropagationReport ranks the tx-observed nodes by how early they announce
+// new blocks relative to the first announcement of each block (see --tx-observe-sample).
+// The delays are relative to this observer's vantage point, not to the block producers,
+// but comparing them across peers still tells the well-connected nodes from the laggards.
+type BlockPropagationReport struct {
+	MinBlocks  uint
+	TotalNodes uint
+	Fastest    []database.BlockPropagationStats
+	Slowest    []database.BlockPropagationStats
+}
+
+func CreateBlockPropagationReport(ctx context.Context, db database.DB, minBlocks uint, limit uint) (*BlockPropagationReport, error) {
+	stats, err := db.EnumerateBlockPropagationStats(ctx, minBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	report := BlockPropagationReport{
+		MinBlocks:  minBlocks,
+		TotalNodes: uint(len(stats)),
+	}
+	if uint(len(stats)) <= 2*limit {
+		report.Fastest = stats
+		return &report, nil
+	}
+	report.Fastest = stats[:limit]
+	report.Slowest = stats[uint(len(stats))-limit:]
+	return &report, nil
+}
+
+func (report *BlockPropagationReport) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("block propagation: %d nodes with at least %d announced blocks",
+		report.TotalNodes, report.MinBlocks))
+	builder.WriteRune('\n')
+
+	writeEntries := func(title string, entries []database.BlockPropagationStats) {
+		builder.WriteString(title)
+		builder.WriteRune('\n')
+		for _, entry := range entries {
+			clientID := "?"
+			if entry.ClientID != nil {
+				clientID = *entry.ClientID
+			}
+			builder.WriteString(fmt.Sprintf("%s  avg delay: %-10s blocks: %4d  %s",
+				entry.NodeID,
+				entry.AvgDelay.Round(time.Millisecond),
+				entry.BlockCount,
+				clientID))
+			builder.WriteRune('\n')
+		}
+	}
+
+	writeEntries("fastest announcers:", report.Fastest)
+	if len(report.Slowest) > 0 {
+		writeEntries("slowest announcers:", report.Slowest)
+	}
+	return builder.String()
+}
@@ -0,0 +1,34 @@
+package reports
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// BlocklistReport lists the node addresses whose IP reputation score
+// (see the "reputation" enrichment stage) is at or above the threshold.
+// It prints one address per line with no header,
+// so the output can feed a firewall or a deny-list directly.
+type BlocklistReport struct {
+	Threshold float64
+	IPs       []string
+}
+
+func CreateBlocklistReport(ctx context.Context, db database.DB, threshold float64) (*BlocklistReport, error) {
+	ips, err := db.FindBlocklistIPs(ctx, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	report := BlocklistReport{
+		Threshold: threshold,
+		IPs:       ips,
+	}
+	return &report, nil
+}
+
+func (report *BlocklistReport) String() string {
+	return strings.Join(report.IPs, "\n")
+}
@@ -0,0 +1,76 @@
+package reports
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Canonicalizer folds the messy client name variations seen in the wild
+// (case differences, forks, rebrands) into canonical client names,
+// so that the reports aggregate them consistently.
+type Canonicalizer struct {
+	aliases map[string]string
+}
+
+// defaultClientNames maps a canonical client name to its known aliases.
+// User-supplied mapping files use the same layout (see LoadMappings).
+var defaultClientNames = map[string][]string{
+	"Geth":         {"geth", "go-ethereum"},
+	"Erigon":       {"erigon", "turbo-geth", "turbogeth"},
+	"Nethermind":   {"nethermind"},
+	"Besu":         {"besu", "hyperledger-besu"},
+	"OpenEthereum": {"openethereum", "open-ethereum", "parity", "parity-ethereum"},
+	"Bor":          {"bor"},
+	"CoreGeth":     {"core-geth", "coregeth"},
+}
+
+func NewCanonicalizer() *Canonicalizer {
+	instance := Canonicalizer{
+		aliases: make(map[string]string),
+	}
+	instance.addMappings(defaultClientNames)
+	return &instance
+}
+
+// LoadMappings merges a YAML file mapping a canonical client name
+// to a list of aliases, overriding the built-in defaults on conflict:
+//
+//	Erigon:
+//	  - erigon
+//	  - turbo-geth
+func (canonicalizer *Canonicalizer) LoadMappings(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read the client mappings file: %w", err)
+	}
+	var mappings map[string][]string
+	if err := yaml.Unmarshal(data, &mappings); err != nil {
+		return fmt.Errorf("failed to parse the client mappings file: %w", err)
+	}
+	canonicalizer.addMappings(mappings)
+	return nil
+}
+
+func (canonicalizer *Canonicalizer) addMappings(mappings map[string][]string) {
+	for name, aliases := range mappings {
+		canonicalizer.aliases[strings.ToLower(name)] = name
+		for _, alias := range aliases {
+			canonicalizer.aliases[strings.ToLower(alias)] = name
+		}
+	}
+}
+
+// Canonicalize maps a client name to its canonical form.
+// Unknown names are returned unchanged.
+func (canonicalizer *Canonicalizer) Canonicalize(name string) string {
+	if canonicalizer == nil {
+		return name
+	}
+	if canonical, ok := canonicalizer.aliases[strings.ToLower(name)]; ok {
+		return canonical
+	}
+	return name
+}
@@ -0,0 +1,80 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+type ClientsReportEntry struct {
+	Name  string
+	Count uint
+}
+
+type ClientsReport struct {
+	Clients []ClientsReportEntry
+}
+
+func CreateClientsReport(
+	ctx context.Context,
+	db database.DB,
+	canonicalizer *Canonicalizer,
+	limit uint,
+	maxPingTries uint,
+	networkID uint,
+	minQuality database.ObservationQuality,
+) (*ClientsReport, error) {
+	groups := make(map[string]uint)
+
+	enumFunc := func(clientID *string) {
+		if clientID == nil {
+			return
+		}
+		clientName := canonicalizer.Canonicalize(NameFromClientID(*clientID))
+		groups[clientName]++
+	}
+	if err := db.EnumerateClientIDs(ctx, maxPingTries, networkID, minQuality, enumFunc); err != nil {
+		return nil, err
+	}
+
+	totalCount := uint(0)
+	for _, count := range groups {
+		totalCount += count
+	}
+
+	clients := make([]ClientsReportEntry, 0, len(groups))
+	for clientName, count := range groups {
+		clients = append(clients, ClientsReportEntry{clientName, count})
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Count > clients[j].Count
+	})
+
+	if limit < uint(len(clients)) {
+		clients = clients[:limit]
+	}
+
+	report := ClientsReport{clients}
+	return &report, nil
+}
+
+func (report *ClientsReport) String() string {
+	var builder strings.Builder
+	builder.Grow(2 + len(report.Clients))
+	builder.WriteString("clients:")
+	builder.WriteRune('\n')
+	for _, entry := range report.Clients {
+		builder.WriteString(fmt.Sprintf("%6d %s", entry.Count, entry.Name))
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
+
+// NameFromClientID cuts the version suffix from a client ID like "Geth/v1.10.15/linux-amd64/go1.17".
+func NameFromClientID(clientID string) string {
+	parts := strings.SplitN(clientID, "/", 2)
+	return parts[0]
+}
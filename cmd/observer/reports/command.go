@@ -0,0 +1,296 @@
+package reports
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/ledgerwatch/erigon/params/networkname"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir      string
+	Chain        string
+	ClientsLimit uint
+	MaxPingTries uint
+
+	// ClientMappingsFile is an optional YAML file with extra client name mappings
+	// on top of the built-in defaults (see Canonicalizer).
+	ClientMappingsFile string
+
+	// Format renders the status report as data in the chosen format
+	// (see StructuredReport), so it can be embedded in dashboards and CI jobs.
+	Format string
+
+	// VerifiedOnly restricts the reports to facts observed in a protocol exchange,
+	// excluding self-reported and inferred data.
+	VerifiedOnly bool
+
+	// AsOf switches the report to reconstructing the network state at a past moment.
+	AsOf       string
+	AsOfWindow time.Duration
+
+	// Survival switches the report to the node lifetime survival analysis.
+	Survival bool
+
+	// HandshakeErrors switches the report to cross-tabulating
+	// the latest handshake error classes against client versions.
+	HandshakeErrors bool
+
+	// Latency switches the report to the probe latency distribution
+	// (connect, handshake and ping percentiles) per country and client.
+	Latency bool
+
+	// TimeOfDay switches the report to the handshake success rates
+	// bucketed by UTC hour-of-day and day-of-week.
+	TimeOfDay bool
+
+	// BlockPropagation switches the report to ranking nodes by how early
+	// they announce new blocks, with BlockPropagationMinBlocks filtering out
+	// nodes observed for too few blocks to rank fairly.
+	BlockPropagation          bool
+	BlockPropagationMinBlocks uint
+
+	// NodeHistory switches the report to the full handshake attempt history
+	// of the given node, merging the hot database with the cold-storage
+	// segments in ArchiveDir when set (see the archive package).
+	NodeHistory string
+	ArchiveDir  string
+
+	// KeyRotations switches the report to listing the endpoints seen
+	// with at least KeyRotationsMinKeys distinct node IDs
+	// (see the node_history table).
+	KeyRotations        bool
+	KeyRotationsMinKeys uint
+
+	// BadRows switches the report to listing the values quarantined
+	// by the strict read validation (see StrictRead).
+	BadRows bool
+
+	// StrictRead quarantines unparsable values found by the report queries
+	// into the bad_rows table (see database.DB.EnableStrictRead).
+	StrictRead bool
+
+	// Blocklist switches the report to listing the addresses whose IP reputation
+	// score is at or above BlocklistThreshold (see the reputation enrichment stage).
+	Blocklist          bool
+	BlocklistThreshold float64
+
+	// EnrKey switches the report to listing nodes advertising this ENR key,
+	// optionally narrowed by EnrValue.
+	EnrKey      string
+	EnrValue    string
+	EnrNodesMax uint
+
+	// Template and SQLParams configure the custom subcommand:
+	// a Go template file rendered over whitelisted query primitives,
+	// and its key=value parameters.
+	Template  string
+	SQLParams []string
+}
+
+type Command struct {
+	command       cobra.Command
+	customCommand cobra.Command
+	flags         CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "report",
+		Short: "P2P network crawler database report",
+	}
+
+	customCommand := cobra.Command{
+		Use:   "custom",
+		Short: "Render a user-defined Go-template report over whitelisted query primitives",
+	}
+
+	instance := Command{
+		command:       command,
+		customCommand: customCommand,
+	}
+
+	instance.withDatadir()
+	instance.withChain()
+	instance.withClientsLimit()
+	instance.withMaxPingTries()
+	instance.withClientMappings()
+	instance.withFormat()
+	instance.withVerifiedOnly()
+	instance.withAsOf()
+	instance.withSurvival()
+	instance.withHandshakeErrors()
+	instance.withLatency()
+	instance.withTimeOfDay()
+	instance.withBlockPropagation()
+	instance.withNodeHistory()
+	instance.withKeyRotations()
+	instance.withBadRows()
+	instance.withStrictRead()
+	instance.withBlocklist()
+	instance.withEnrFilter()
+	instance.withTemplate()
+
+	instance.command.AddCommand(&instance.customCommand)
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.PersistentFlags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkPersistentFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withChain() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Chain, utils.ChainFlag.Name, networkname.MainnetChainName, utils.ChainFlag.Usage)
+}
+
+func (command *Command) withClientsLimit() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.ClientsLimit, "clients-limit", 10, "how many top clients to show")
+}
+
+func (command *Command) withMaxPingTries() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.MaxPingTries, "max-ping-tries", 3, "number of failed pings to consider a node dead")
+}
+
+func (command *Command) withClientMappings() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.ClientMappingsFile, "client-mappings", "",
+		"YAML file with extra client name mappings: canonical name to a list of aliases")
+}
+
+func (command *Command) withFormat() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.Format, "format", string(FormatTable),
+		"output format of the status report: table, json, csv or markdown")
+}
+
+func (command *Command) withVerifiedOnly() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.VerifiedOnly, "verified-only", false,
+		"report only facts observed in a protocol exchange, excluding self-reported and inferred data")
+}
+
+func (command *Command) withAsOf() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.AsOf, "as-of", "",
+		"reconstruct the network state at this past moment (RFC 3339, e.g. 2024-03-13T12:00:00Z)")
+	flags.DurationVar(&command.flags.AsOfWindow, "as-of-window", 20*24*time.Hour,
+		"consider a node part of the historic node set if it was observed within this window before --as-of "+
+			"(should match --handshake-refresh-timeout of the crawl)")
+}
+
+func (command *Command) withSurvival() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.Survival, "survival", false,
+		"report how long nodes remain reachable after first being seen, by client and hosting type")
+}
+
+func (command *Command) withHandshakeErrors() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.HandshakeErrors, "handshake-errors", false,
+		"report the latest handshake error classes cross-tabulated against client versions")
+}
+
+func (command *Command) withLatency() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.Latency, "latency", false,
+		"report the connect, handshake and ping latency percentiles per country and client")
+}
+
+func (command *Command) withTimeOfDay() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.TimeOfDay, "time-of-day", false,
+		"report the handshake success rates by UTC hour of day and day of week, per country")
+}
+
+func (command *Command) withBlockPropagation() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.BlockPropagation, "block-propagation", false,
+		"report the fastest and slowest block announcers among the tx-observed nodes")
+	flags.UintVar(&command.flags.BlockPropagationMinBlocks, "block-propagation-min-blocks", 3,
+		"how many announced blocks a node needs to be ranked by --block-propagation")
+}
+
+func (command *Command) withNodeHistory() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.NodeHistory, "node-history", "",
+		"report the full handshake attempt history of this node ID")
+	flags.StringVar(&command.flags.ArchiveDir, "archive-dir", "",
+		"merge the archived history from this cold-storage directory into --node-history")
+}
+
+func (command *Command) withKeyRotations() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.KeyRotations, "key-rotations", false,
+		"list the endpoints seen with multiple node IDs (key rotations or operator changes)")
+	flags.UintVar(&command.flags.KeyRotationsMinKeys, "key-rotations-min-keys", 2,
+		"how many distinct node IDs an endpoint needs to be listed by --key-rotations")
+}
+
+func (command *Command) withBadRows() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.BadRows, "bad-rows", false,
+		"list the values quarantined by the strict read validation (see --strict-read)")
+}
+
+func (command *Command) withStrictRead() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.StrictRead, "strict-read", false,
+		"validate the data read by the report queries, quarantining bad values "+
+			"into the bad_rows table (see --bad-rows) instead of failing")
+}
+
+func (command *Command) withBlocklist() {
+	flags := command.command.Flags()
+	flags.BoolVar(&command.flags.Blocklist, "blocklist", false,
+		"list the addresses with a bad IP reputation, one per line")
+	flags.Float64Var(&command.flags.BlocklistThreshold, "blocklist-threshold", 0.8,
+		"the minimum IP reputation score (0-1) for --blocklist")
+}
+
+func (command *Command) withEnrFilter() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.EnrKey, "enr-key", "", "list nodes advertising this ENR key (e.g. les)")
+	flags.StringVar(&command.flags.EnrValue, "enr-value", "", "filter --enr-key matches by the hex of the raw RLP value")
+	flags.UintVar(&command.flags.EnrNodesMax, "enr-nodes-max", 1000, "how many --enr-key matches to show")
+}
+
+func (command *Command) withTemplate() {
+	flags := command.customCommand.Flags()
+	flags.StringVar(&command.flags.Template, "template", "",
+		"Go template file defining the report (see reports.customReportFuncs for the available primitives)")
+	flags.StringSliceVar(&command.flags.SQLParams, "sql-params", nil,
+		"key=value parameters accessible in the template as {{.Params.key}}")
+	if err := command.customCommand.MarkFlagRequired("template"); err != nil {
+		panic(err)
+	}
+	if err := command.customCommand.MarkFlagFilename("template"); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) OnCustom(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.customCommand.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,146 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// CustomReport is a user-defined report rendered from a Go template file.
+// The template queries the database through a whitelisted set of primitives
+// (see customReportFuncs), so bespoke recurring reports don't require code changes.
+type CustomReport struct {
+	Output string
+}
+
+// customReportData is the root data of a custom report template.
+type customReportData struct {
+	// Params are the --sql-params values, accessible as {{.Params.key}}.
+	Params map[string]string
+	Now    time.Time
+}
+
+// customReportFuncs are the query primitives available to a custom report template.
+// Each primitive wraps a database.DB aggregation, so templates can't run arbitrary SQL.
+func customReportFuncs(ctx context.Context, db database.DB) template.FuncMap {
+	return template.FuncMap{
+		"totalNodes": func(networkID ...int) (uint, error) {
+			return db.CountAllNodes(ctx, optionalNetworkID(networkID))
+		},
+		"frontierDepth": func() (uint, error) {
+			return db.CountFrontier(ctx)
+		},
+		"liveNodes": func(maxPingTries int, networkID int) (uint, error) {
+			return db.CountNodes(ctx, uint(maxPingTries), uint(networkID))
+		},
+		"liveIPs": func(maxPingTries int, networkID int) (uint, error) {
+			return db.CountIPs(ctx, uint(maxPingTries), uint(networkID))
+		},
+		"operatorEstimate": func(networkID ...int) (uint, error) {
+			return db.EstimateOperatorCount(ctx, optionalNetworkID(networkID))
+		},
+		"clientCounts": func(networkID ...int) (map[string]uint, error) {
+			groups, err := db.CountClientGroups(ctx, optionalNetworkID(networkID))
+			if err != nil {
+				return nil, err
+			}
+			counts := make(map[string]uint)
+			for clientID, count := range groups {
+				counts[NameFromClientID(clientID)] += count
+			}
+			return counts, nil
+		},
+		"networkCounts": func() (map[string]uint, error) {
+			groups, err := db.CountNetworkIDGroups(ctx)
+			if err != nil {
+				return nil, err
+			}
+			counts := make(map[string]uint)
+			for networkID, count := range groups {
+				counts[strconv.FormatUint(uint64(networkID), 10)] = count
+			}
+			return counts, nil
+		},
+		"handshakeErrorCounts": func(networkID ...int) (map[string]uint, error) {
+			return db.CountHandshakeErrorGroups(ctx, optionalNetworkID(networkID))
+		},
+		"countryCounts": func(networkID ...int) (map[string]uint, error) {
+			return db.CountNodesByCountry(ctx, optionalNetworkID(networkID))
+		},
+		"capabilityCounts": func(networkID ...int) (map[string]uint, error) {
+			return db.CountNodesByCapability(ctx, optionalNetworkID(networkID))
+		},
+		"violationCounts": func(networkID ...int) (map[string]uint, error) {
+			return db.CountProtocolViolations(ctx, optionalNetworkID(networkID))
+		},
+		"seenWindows": func() ([]database.SeenWindowStats, error) {
+			return db.FindSeenWindowStats(ctx)
+		},
+		"vantagePoints": func() ([]database.VantagePointStats, error) {
+			return db.EnumerateVantagePointStats(ctx)
+		},
+		"atoi": func(value string) (int, error) {
+			return strconv.Atoi(value)
+		},
+	}
+}
+
+// optionalNetworkID interprets an optional trailing network ID argument of a
+// template primitive. Without an argument the counts cover all chains.
+func optionalNetworkID(networkID []int) uint {
+	if len(networkID) == 0 {
+		return 0
+	}
+	return uint(networkID[0])
+}
+
+// ParseReportParams parses --sql-params entries of the form key=value.
+func ParseReportParams(values []string) (map[string]string, error) {
+	params := make(map[string]string)
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("failed to parse report param %q: expecting key=value", value)
+		}
+		params[parts[0]] = parts[1]
+	}
+	return params, nil
+}
+
+func CreateCustomReport(ctx context.Context, db database.DB, templateFile string, params map[string]string) (*CustomReport, error) {
+	content, err := os.ReadFile(templateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the report template: %w", err)
+	}
+
+	reportTemplate, err := template.New(filepath.Base(templateFile)).
+		Funcs(customReportFuncs(ctx, db)).
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the report template: %w", err)
+	}
+
+	data := customReportData{
+		Params: params,
+		Now:    time.Now(),
+	}
+
+	var builder strings.Builder
+	if err := reportTemplate.Execute(&builder, data); err != nil {
+		return nil, fmt.Errorf("failed to render the report template: %w", err)
+	}
+
+	instance := CustomReport{Output: builder.String()}
+	return &instance, nil
+}
+
+func (report *CustomReport) String() string {
+	return report.Output
+}
@@ -0,0 +1,54 @@
+package reports
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+type EnrReportEntry struct {
+	ID      database.NodeID
+	Entries []database.EnrEntry
+}
+
+// EnrReport lists nodes advertising a given ENR key (and optionally a value).
+type EnrReport struct {
+	Key     string
+	Value   string
+	Entries []EnrReportEntry
+}
+
+func CreateEnrReport(ctx context.Context, db database.DB, key string, valueHex string, limit uint) (*EnrReport, error) {
+	ids, err := db.EnumerateNodesByEnrKey(ctx, key, valueHex, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	report := EnrReport{Key: key, Value: valueHex}
+	for _, id := range ids {
+		entries, err := db.FindEnrEntries(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		report.Entries = append(report.Entries, EnrReportEntry{id, entries})
+	}
+	return &report, nil
+}
+
+func (report *EnrReport) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("nodes advertising %q: %d", report.Key, len(report.Entries)))
+	builder.WriteRune('\n')
+	for _, entry := range report.Entries {
+		builder.WriteString(string(entry.ID))
+		builder.WriteRune('\n')
+		for _, enrEntry := range entry.Entries {
+			builder.WriteString(fmt.Sprintf("    %s: %s", enrEntry.Key, hex.EncodeToString(enrEntry.Value)))
+			builder.WriteRune('\n')
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,115 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// HandshakeErrorsReportEntry is a row of the cross-tabulation:
+// how often the nodes running a given client version failed their last handshake,
+// broken down by the error class.
+type HandshakeErrorsReportEntry struct {
+	Client string
+	Total  uint
+	Errors map[string]uint
+}
+
+// HandshakeErrorsReport cross-tabulates the latest handshake error classes
+// against the remote client name/version.
+// It shows, for example, which client versions tend to reject with "too many peers"
+// and which tend to time out, which is useful for client developers tuning peer limits.
+type HandshakeErrorsReport struct {
+	ErrorNames []string
+	Clients    []HandshakeErrorsReportEntry
+}
+
+func CreateHandshakeErrorsReport(
+	ctx context.Context,
+	db database.DB,
+	canonicalizer *Canonicalizer,
+	limit uint,
+	networkID uint,
+) (*HandshakeErrorsReport, error) {
+	clientGroups := make(map[string]*HandshakeErrorsReportEntry)
+	errorTotals := make(map[string]uint)
+
+	enumFunc := func(clientID *string, handshakeErr string) {
+		clientName := "(unknown)"
+		if clientID != nil {
+			clientName = canonicalizer.Canonicalize(NameFromClientID(*clientID)) +
+				versionSuffixFromClientID(*clientID)
+		}
+
+		group := clientGroups[clientName]
+		if group == nil {
+			group = &HandshakeErrorsReportEntry{
+				Client: clientName,
+				Errors: make(map[string]uint),
+			}
+			clientGroups[clientName] = group
+		}
+		group.Total++
+		group.Errors[handshakeErr]++
+		errorTotals[handshakeErr]++
+	}
+	if err := db.EnumerateHandshakeErrorClientIDs(ctx, networkID, enumFunc); err != nil {
+		return nil, err
+	}
+
+	errorNames := make([]string, 0, len(errorTotals))
+	for errorName := range errorTotals {
+		errorNames = append(errorNames, errorName)
+	}
+	sort.Slice(errorNames, func(i, j int) bool {
+		return errorTotals[errorNames[i]] > errorTotals[errorNames[j]]
+	})
+
+	clients := make([]HandshakeErrorsReportEntry, 0, len(clientGroups))
+	for _, group := range clientGroups {
+		clients = append(clients, *group)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Total > clients[j].Total
+	})
+	if (limit > 0) && (limit < uint(len(clients))) {
+		clients = clients[:limit]
+	}
+
+	report := HandshakeErrorsReport{errorNames, clients}
+	return &report, nil
+}
+
+// versionSuffixFromClientID cuts the version from a client ID
+// like "Geth/v1.10.15/linux-amd64/go1.17" (returning "/v1.10.15"),
+// so it can be appended to the canonical client name.
+func versionSuffixFromClientID(clientID string) string {
+	parts := strings.Split(clientID, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return "/" + parts[1]
+}
+
+func (report *HandshakeErrorsReport) String() string {
+	var builder strings.Builder
+	builder.WriteString("handshake errors by client version:")
+	builder.WriteRune('\n')
+	for _, entry := range report.Clients {
+		builder.WriteString(fmt.Sprintf("%6d %s", entry.Total, entry.Client))
+		builder.WriteRune('\n')
+		for _, errorName := range report.ErrorNames {
+			count := entry.Errors[errorName]
+			if count == 0 {
+				continue
+			}
+			rate := float64(count) / float64(entry.Total)
+			builder.WriteString(fmt.Sprintf("%12d %3.0f%% %s", count, rate*100, errorName))
+			builder.WriteRune('\n')
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,87 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// IPClusters groups the node addresses by subnet prefix and hosting provider:
+// the IPv4 /24 and IPv6 /64 prefixes hosting more than one node,
+// and the node counts per autonomous system.
+// A few large clusters point at node concentration on a few providers.
+type IPClusters struct {
+	PrefixesV4 map[string]uint
+	PrefixesV6 map[string]uint
+	ASNs       map[string]uint
+}
+
+// ComputeIPClusters aggregates the node addresses of the chain
+// (see database.DB.EnumerateNodeIPs and CountNodesByASN).
+// Prefixes hosting a single node are left out: they are the normal case,
+// not a cluster.
+func ComputeIPClusters(ctx context.Context, db database.DB, networkID uint) (*IPClusters, error) {
+	clusters := IPClusters{
+		PrefixesV4: make(map[string]uint),
+		PrefixesV6: make(map[string]uint),
+		ASNs:       make(map[string]uint),
+	}
+
+	enumFunc := func(ip *string, ipv6 *string, asn *uint) {
+		if ip != nil {
+			if prefix := prefixV4(*ip); prefix != "" {
+				clusters.PrefixesV4[prefix]++
+			}
+		}
+		if ipv6 != nil {
+			if prefix := prefixV6(*ipv6); prefix != "" {
+				clusters.PrefixesV6[prefix]++
+			}
+		}
+	}
+	if err := db.EnumerateNodeIPs(ctx, networkID, enumFunc); err != nil {
+		return nil, err
+	}
+
+	dropSingletons(clusters.PrefixesV4)
+	dropSingletons(clusters.PrefixesV6)
+
+	asnCounts, err := db.CountNodesByASN(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+	for asn, count := range asnCounts {
+		clusters.ASNs[fmt.Sprintf("AS%d", asn)] = count
+	}
+	return &clusters, nil
+}
+
+// prefixV4 is the /24 prefix of an IPv4 address, or "" if it does not parse.
+func prefixV4(value string) string {
+	ip := net.ParseIP(value)
+	if ip = ip.To4(); ip == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.0/24", ip[0], ip[1], ip[2])
+}
+
+// prefixV6 is the /64 prefix of an IPv6 address, or "" if it does not parse.
+func prefixV6(value string) string {
+	ip := net.ParseIP(value)
+	if (ip == nil) || (ip.To4() != nil) {
+		return ""
+	}
+	prefix := make(net.IP, net.IPv6len)
+	copy(prefix, ip.To16()[:net.IPv6len/2])
+	return prefix.String() + "/64"
+}
+
+func dropSingletons(counts map[string]uint) {
+	for prefix, count := range counts {
+		if count < 2 {
+			delete(counts, prefix)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// KeyRotationReport lists the endpoints (IP and RLPx port pairs) that were
+// seen with more than one node ID over time: either the operator rotated
+// the node key, or the address changed hands
+// (see database.DB.EnumerateKeyRotations).
+type KeyRotationReport struct {
+	Rotations []KeyRotationRow
+}
+
+// KeyRotationRow is one rotated endpoint together with the node IDs
+// observed behind it, in the order they first appeared.
+type KeyRotationRow struct {
+	Stats   database.KeyRotationStats
+	History []database.NodeHistoryEntry
+}
+
+// keyRotationsMax is how many rotated endpoints the report shows.
+const keyRotationsMax = 100
+
+func CreateKeyRotationReport(ctx context.Context, db database.DB, minKeys uint) (*KeyRotationReport, error) {
+	rotations, err := db.EnumerateKeyRotations(ctx, minKeys, keyRotationsMax)
+	if err != nil {
+		return nil, err
+	}
+
+	report := KeyRotationReport{}
+	for _, stats := range rotations {
+		history, err := db.FindNodeHistory(ctx, stats.IP, stats.PortRLPx)
+		if err != nil {
+			return nil, err
+		}
+		report.Rotations = append(report.Rotations, KeyRotationRow{Stats: stats, History: history})
+	}
+	return &report, nil
+}
+
+func (report *KeyRotationReport) String() string {
+	var builder strings.Builder
+	if len(report.Rotations) == 0 {
+		builder.WriteString("no key rotations observed")
+		builder.WriteRune('\n')
+		return builder.String()
+	}
+	for _, row := range report.Rotations {
+		builder.WriteString(fmt.Sprintf("%s:%d  %d keys  %s - %s",
+			row.Stats.IP, row.Stats.PortRLPx, row.Stats.KeyCount,
+			row.Stats.FirstSeen.Format("2006-01-02"), row.Stats.LastSeen.Format("2006-01-02")))
+		builder.WriteRune('\n')
+		for _, entry := range row.History {
+			builder.WriteString(fmt.Sprintf("    %s  %s - %s",
+				entry.NodeID,
+				entry.FirstSeen.Format("2006-01-02"), entry.LastSeen.Format("2006-01-02")))
+			builder.WriteRune('\n')
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,133 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// LatencyReportGroup is the latency distribution of one group of samples:
+// the nodes of one country, or the nodes running one client.
+// The percentiles are computed over the individual samples, not per node.
+type LatencyReportGroup struct {
+	Name  string
+	Count uint
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+// LatencyReportSection is the distribution of one latency kind
+// (see database.NodeLatencyConnect and friends) under one grouping.
+type LatencyReportSection struct {
+	Title  string
+	Groups []LatencyReportGroup
+}
+
+// LatencyReport shows the distribution of the measured probe latencies
+// (TCP connect time, RLPx handshake duration, disc ping RTT)
+// per country and per client name,
+// which helps spotting regions with poor connectivity
+// and clients that are slow to complete the handshake.
+type LatencyReport struct {
+	Sections []LatencyReportSection
+}
+
+func CreateLatencyReport(
+	ctx context.Context,
+	db database.DB,
+	canonicalizer *Canonicalizer,
+	limit uint,
+) (*LatencyReport, error) {
+	kinds := []string{
+		database.NodeLatencyPing,
+		database.NodeLatencyConnect,
+		database.NodeLatencyHandshake,
+	}
+
+	var report LatencyReport
+	for _, kind := range kinds {
+		countrySamples := make(map[string][]time.Duration)
+		clientSamples := make(map[string][]time.Duration)
+
+		enumFunc := func(country *string, clientID *string, rtt time.Duration) {
+			countryName := "(unknown)"
+			if country != nil {
+				countryName = *country
+			}
+			countrySamples[countryName] = append(countrySamples[countryName], rtt)
+
+			clientName := "(unknown)"
+			if clientID != nil {
+				clientName = canonicalizer.Canonicalize(NameFromClientID(*clientID))
+			}
+			clientSamples[clientName] = append(clientSamples[clientName], rtt)
+		}
+		if err := db.EnumerateNodeLatencies(ctx, kind, enumFunc); err != nil {
+			return nil, err
+		}
+		if len(countrySamples) == 0 {
+			continue
+		}
+
+		report.Sections = append(report.Sections,
+			LatencyReportSection{kind + " latency by country", makeLatencyGroups(countrySamples, limit)},
+			LatencyReportSection{kind + " latency by client", makeLatencyGroups(clientSamples, limit)})
+	}
+	return &report, nil
+}
+
+func makeLatencyGroups(samples map[string][]time.Duration, limit uint) []LatencyReportGroup {
+	groups := make([]LatencyReportGroup, 0, len(samples))
+	for name, rtts := range samples {
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		group := LatencyReportGroup{
+			Name:  name,
+			Count: uint(len(rtts)),
+			P50:   latencyPercentile(rtts, 0.50),
+			P90:   latencyPercentile(rtts, 0.90),
+			P99:   latencyPercentile(rtts, 0.99),
+		}
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+	if (limit > 0) && (limit < uint(len(groups))) {
+		groups = groups[:limit]
+	}
+	return groups
+}
+
+// latencyPercentile picks the nearest-rank percentile of the sorted samples.
+func latencyPercentile(sortedRTTs []time.Duration, quantile float64) time.Duration {
+	rank := int(quantile * float64(len(sortedRTTs)))
+	if rank >= len(sortedRTTs) {
+		rank = len(sortedRTTs) - 1
+	}
+	return sortedRTTs[rank]
+}
+
+func (report *LatencyReport) String() string {
+	var builder strings.Builder
+	for _, section := range report.Sections {
+		builder.WriteString(section.Title)
+		builder.WriteRune(':')
+		builder.WriteRune('\n')
+		for _, group := range section.Groups {
+			builder.WriteString(fmt.Sprintf("%6d  p50 %-10s p90 %-10s p99 %-10s %s",
+				group.Count,
+				group.P50.Truncate(time.Microsecond),
+				group.P90.Truncate(time.Microsecond),
+				group.P99.Truncate(time.Microsecond),
+				group.Name))
+			builder.WriteRune('\n')
+		}
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
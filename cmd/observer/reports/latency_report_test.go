@@ -0,0 +1,33 @@
+package reports
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyPercentile(t *testing.T) {
+	samples := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond,
+		60 * time.Millisecond,
+		70 * time.Millisecond,
+		80 * time.Millisecond,
+		90 * time.Millisecond,
+		100 * time.Millisecond,
+	}
+
+	assert.Equal(t, 60*time.Millisecond, latencyPercentile(samples, 0.50))
+	assert.Equal(t, 100*time.Millisecond, latencyPercentile(samples, 0.90))
+	// the nearest rank is clamped to the last sample
+	assert.Equal(t, 100*time.Millisecond, latencyPercentile(samples, 0.99))
+	assert.Equal(t, 10*time.Millisecond, latencyPercentile(samples, 0))
+
+	single := []time.Duration{42 * time.Millisecond}
+	assert.Equal(t, 42*time.Millisecond, latencyPercentile(single, 0.50))
+	assert.Equal(t, 42*time.Millisecond, latencyPercentile(single, 0.99))
+}
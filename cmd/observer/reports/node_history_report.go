@@ -0,0 +1,121 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/archive"
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// NodeHistoryRow is one handshake attempt of the node,
+// with Archived marking the rows read back from the cold-storage segments.
+type NodeHistoryRow struct {
+	Time     time.Time
+	Success  bool
+	SourceIP string
+	Duration time.Duration
+	Err      string
+	Archived bool
+}
+
+// NodeHistoryReport lists the full handshake attempt history of a single node.
+// With an archive directory it merges the hot database with the cold-storage
+// segments (see the archive package), so archiving old history away
+// does not make it invisible to the reports.
+type NodeHistoryReport struct {
+	NodeID string
+	Rows   []NodeHistoryRow
+}
+
+func CreateNodeHistoryReport(
+	ctx context.Context,
+	db database.DB,
+	archiveDir string,
+	nodeID string,
+) (*NodeHistoryReport, error) {
+	id, err := database.ParseNodeID(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := NodeHistoryReport{NodeID: string(id)}
+
+	if archiveDir != "" {
+		reader, err := archive.NewReader(archiveDir)
+		if err != nil {
+			return nil, err
+		}
+		err = reader.EnumerateAttempts(string(id), time.Time{}, func(record archive.AttemptRecord) {
+			report.Rows = append(report.Rows, NodeHistoryRow{
+				Time:     time.Unix(record.Time, 0),
+				Success:  record.Success,
+				SourceIP: record.SourceIP,
+				Duration: time.Duration(record.DurationMs) * time.Millisecond,
+				Err:      record.Err,
+				Archived: true,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attempts, err := db.FindHandshakeAttempts(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, attempt := range attempts {
+		row := NodeHistoryRow{
+			Time:     attempt.Time,
+			Success:  attempt.Success,
+			Duration: attempt.Duration,
+			Err:      attempt.Err,
+		}
+		if attempt.SourceIP != nil {
+			row.SourceIP = attempt.SourceIP.String()
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	sort.SliceStable(report.Rows, func(i int, j int) bool {
+		return report.Rows[i].Time.Before(report.Rows[j].Time)
+	})
+	return &report, nil
+}
+
+func (report *NodeHistoryReport) String() string {
+	var builder strings.Builder
+	builder.Grow(len(report.Rows) * 64)
+
+	builder.WriteString(fmt.Sprintf("handshake attempts of %s\n", report.NodeID))
+	if len(report.Rows) == 0 {
+		builder.WriteString("no attempts recorded\n")
+		return builder.String()
+	}
+
+	for _, row := range report.Rows {
+		outcome := "fail"
+		if row.Success {
+			outcome = "ok"
+		}
+		origin := "hot"
+		if row.Archived {
+			origin = "cold"
+		}
+		line := fmt.Sprintf("%s  %-4s  %-4s  %8s",
+			row.Time.Format("2006-01-02 15:04:05"), outcome, origin, row.Duration.String())
+		if row.SourceIP != "" {
+			line += "  from " + row.SourceIP
+		}
+		if row.Err != "" {
+			line += "  " + row.Err
+		}
+		builder.WriteString(line)
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
@@ -0,0 +1,163 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Format selects how a structured report is rendered (see the --format flag).
+type Format string
+
+const (
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "markdown"
+)
+
+// ParseFormat validates a --format flag value.
+func ParseFormat(value string) (Format, error) {
+	format := Format(value)
+	if renderers[format] == nil {
+		return "", fmt.Errorf("unknown report format %q (known: table, json, csv, markdown)", value)
+	}
+	return format, nil
+}
+
+// ReportSection is one table of a structured report.
+type ReportSection struct {
+	Title   string     `json:"title"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// StructuredReport is a report as data rather than text, so it can be rendered
+// in several formats and embedded in dashboards and CI jobs.
+type StructuredReport struct {
+	Sections []ReportSection `json:"sections"`
+}
+
+// Renderer turns a structured report into one output format.
+// New formats plug in by extending the renderers map.
+type Renderer func(report *StructuredReport) (string, error)
+
+var renderers = map[Format]Renderer{
+	FormatTable:    renderTable,
+	FormatJSON:     renderJSON,
+	FormatCSV:      renderCSV,
+	FormatMarkdown: renderMarkdown,
+}
+
+// Render renders the report in the given format.
+func (report *StructuredReport) Render(format Format) (string, error) {
+	renderer := renderers[format]
+	if renderer == nil {
+		return "", fmt.Errorf("unknown report format %q", format)
+	}
+	return renderer(report)
+}
+
+func renderTable(report *StructuredReport) (string, error) {
+	var builder strings.Builder
+	for i, section := range report.Sections {
+		if i > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(section.Title)
+		builder.WriteRune('\n')
+
+		widths := make([]int, len(section.Columns))
+		for col, name := range section.Columns {
+			widths[col] = len(name)
+		}
+		for _, row := range section.Rows {
+			for col, cell := range row {
+				if len(cell) > widths[col] {
+					widths[col] = len(cell)
+				}
+			}
+		}
+
+		writeRow := func(cells []string) {
+			builder.WriteString("   ")
+			for col, cell := range cells {
+				if col > 0 {
+					builder.WriteString("  ")
+				}
+				// the last column is not padded to keep the lines free of trailing spaces
+				if col == len(cells)-1 {
+					builder.WriteString(cell)
+				} else {
+					builder.WriteString(fmt.Sprintf("%-*s", widths[col], cell))
+				}
+			}
+			builder.WriteRune('\n')
+		}
+		writeRow(section.Columns)
+		for _, row := range section.Rows {
+			writeRow(row)
+		}
+	}
+	return builder.String(), nil
+}
+
+func renderJSON(report *StructuredReport) (string, error) {
+	data, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("failed to render the report as JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// renderCSV writes each section as its own CSV block: a "# title" comment line,
+// a header row, the data rows, and a blank line between the sections.
+func renderCSV(report *StructuredReport) (string, error) {
+	var builder strings.Builder
+	for i, section := range report.Sections {
+		if i > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString("# ")
+		builder.WriteString(section.Title)
+		builder.WriteRune('\n')
+
+		writer := csv.NewWriter(&builder)
+		if err := writer.Write(section.Columns); err != nil {
+			return "", fmt.Errorf("failed to render the report as CSV: %w", err)
+		}
+		if err := writer.WriteAll(section.Rows); err != nil {
+			return "", fmt.Errorf("failed to render the report as CSV: %w", err)
+		}
+	}
+	return builder.String(), nil
+}
+
+func renderMarkdown(report *StructuredReport) (string, error) {
+	var builder strings.Builder
+	for i, section := range report.Sections {
+		if i > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString("### ")
+		builder.WriteString(section.Title)
+		builder.WriteString("\n\n")
+
+		writeRow := func(cells []string) {
+			builder.WriteString("| ")
+			builder.WriteString(strings.Join(cells, " | "))
+			builder.WriteString(" |\n")
+		}
+		writeRow(section.Columns)
+		separators := make([]string, len(section.Columns))
+		for col := range separators {
+			separators[col] = "---"
+		}
+		writeRow(separators)
+		for _, row := range section.Rows {
+			writeRow(row)
+		}
+	}
+	return builder.String(), nil
+}
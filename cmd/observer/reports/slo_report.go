@@ -0,0 +1,61 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+type SLOReportEntry struct {
+	Node   database.MonitoredNode
+	Status *database.SLOStatus
+}
+
+type SLOReport struct {
+	Entries []SLOReportEntry
+}
+
+func CreateSLOReport(ctx context.Context, db database.DB) (*SLOReport, error) {
+	nodes, err := db.EnumerateMonitoredNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := SLOReport{}
+	for _, node := range nodes {
+		status, err := db.FindSLOStatus(ctx, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		report.Entries = append(report.Entries, SLOReportEntry{node, status})
+	}
+	return &report, nil
+}
+
+func (report *SLOReport) String() string {
+	var builder strings.Builder
+	for _, entry := range report.Entries {
+		label := entry.Node.Label
+		if label == "" {
+			label = string(entry.Node.ID)
+		}
+		if entry.Status == nil {
+			builder.WriteString(fmt.Sprintf("%-40s target: %.3f%%  (no data yet)", label, entry.Node.SLOTarget*100))
+		} else {
+			builder.WriteString(fmt.Sprintf("%-40s target: %.3f%%  availability: %.3f%%  attempts: %d  budget burn: %.0f%%",
+				label,
+				entry.Node.SLOTarget*100,
+				entry.Status.Availability*100,
+				entry.Status.AttemptCount,
+				entry.Status.BudgetBurn*100))
+		}
+		builder.WriteRune('\n')
+	}
+	if len(report.Entries) == 0 {
+		builder.WriteString("no monitored nodes")
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
@@ -0,0 +1,229 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+type StatusReport struct {
+	TotalCount      uint
+	DistinctIPCount uint
+	FrontierCount   uint
+
+	// SnapNodes of the SnapKnownNodes handshaked nodes announced the snap/1 capability.
+	SnapNodes      uint
+	SnapKnownNodes uint
+
+	// OperatorEstimate is the estimated distinct operator count
+	// (see database.DB.EstimateOperatorCount).
+	OperatorEstimate uint
+
+	// Reachability counts the nodes per NAT/reachability class,
+	// quantifying the share of NATed nodes (see database.DB.CountReachability).
+	Reachability []database.ReachabilityCount
+
+	// SeenWindows are the materialized rolling window stats (refreshed by the crawler).
+	SeenWindows []database.SeenWindowStats
+
+	// TopAvailability ranks the sighted nodes by their uptime (see database.NodeUptime).
+	TopAvailability []database.NodeUptime
+
+	// Trends compare the current counts with the historical stats snapshots.
+	Trends []StatsTrend
+}
+
+// StatsTrend is the change of the node, IP and operator counts since a past moment,
+// computed against the stats snapshot closest to the window boundary
+// (see database.StatsSnapshot).
+type StatsTrend struct {
+	Window    time.Duration
+	NodeDelta int
+	IPDelta   int
+
+	// OperatorDelta is nil if the snapshot predates the operator estimate.
+	OperatorDelta *int
+}
+
+// topAvailabilityMax is how many nodes the availability ranking shows.
+const topAvailabilityMax = 5
+
+// trendWindows are the lookback windows of the status report trends.
+var trendWindows = []time.Duration{
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+func CreateStatusReport(ctx context.Context, db database.DB, maxPingTries uint, networkID uint) (*StatusReport, error) {
+	totalCount, err := db.CountNodes(ctx, maxPingTries, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	distinctIPCount, err := db.CountIPs(ctx, maxPingTries, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	frontierCount, err := db.CountFrontier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	snapNodes, snapKnownNodes, err := db.CountSnapSupport(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	operatorEstimate, err := db.EstimateOperatorCount(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	reachability, err := db.CountReachability(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	seenWindows, err := db.FindSeenWindowStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	topAvailability, err := db.FindTopAvailableNodes(ctx, topAvailabilityMax)
+	if err != nil {
+		return nil, err
+	}
+
+	trends, err := createTrends(ctx, db, totalCount, distinctIPCount, operatorEstimate)
+	if err != nil {
+		return nil, err
+	}
+
+	report := StatusReport{
+		totalCount,
+		distinctIPCount,
+		frontierCount,
+		snapNodes,
+		snapKnownNodes,
+		operatorEstimate,
+		reachability,
+		seenWindows,
+		topAvailability,
+		trends,
+	}
+	return &report, nil
+}
+
+func createTrends(ctx context.Context, db database.DB, totalCount uint, distinctIPCount uint, operatorEstimate uint) ([]StatsTrend, error) {
+	maxWindow := trendWindows[len(trendWindows)-1]
+	snapshots, err := db.FindStatsSnapshots(ctx, time.Now().Add(-maxWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	var trends []StatsTrend
+	for _, window := range trendWindows {
+		snapshot := findClosestSnapshot(snapshots, time.Now().Add(-window))
+		// skip the trend if the history doesn't reach at least half of the window back
+		if (snapshot == nil) || (time.Since(snapshot.Time) < window/2) {
+			continue
+		}
+		trend := StatsTrend{
+			Window:    window,
+			NodeDelta: int(totalCount) - int(snapshot.CompatibleNodes),
+			IPDelta:   int(distinctIPCount) - int(snapshot.DistinctIPs),
+		}
+		if snapshot.OperatorEstimate > 0 {
+			operatorDelta := int(operatorEstimate) - int(snapshot.OperatorEstimate)
+			trend.OperatorDelta = &operatorDelta
+		}
+		trends = append(trends, trend)
+	}
+	return trends, nil
+}
+
+func findClosestSnapshot(snapshots []database.StatsSnapshot, moment time.Time) *database.StatsSnapshot {
+	var closest *database.StatsSnapshot
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		if (closest == nil) ||
+			(absDuration(snapshot.Time.Sub(moment)) < absDuration(closest.Time.Sub(moment))) {
+			closest = snapshot
+		}
+	}
+	return closest
+}
+
+func absDuration(value time.Duration) time.Duration {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// formatWindow renders a window duration like "1h", "24h" or "7d".
+func formatWindow(window time.Duration) string {
+	hours := uint(window / time.Hour)
+	if (hours >= 48) && (hours%24 == 0) {
+		return fmt.Sprintf("%dd", hours/24)
+	}
+	return fmt.Sprintf("%dh", hours)
+}
+
+func (report *StatusReport) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("total: %d", report.TotalCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("distinct IPs: %d", report.DistinctIPCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("frontier: %d", report.FrontierCount))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("operators (est.): %d", report.OperatorEstimate))
+	builder.WriteRune('\n')
+	if len(report.Reachability) > 0 {
+		var classified uint
+		for _, count := range report.Reachability {
+			classified += count.Count
+		}
+		builder.WriteString("reachability:")
+		for _, count := range report.Reachability {
+			rate := float64(count.Count) / float64(classified)
+			builder.WriteString(fmt.Sprintf(" %s %d (%.0f%%)",
+				count.Reachability, count.Count, rate*100))
+		}
+		builder.WriteRune('\n')
+	}
+	if report.SnapKnownNodes > 0 {
+		rate := float64(report.SnapNodes) / float64(report.SnapKnownNodes)
+		builder.WriteString(fmt.Sprintf("snap support: %d of %d handshaked (%.0f%%)",
+			report.SnapNodes, report.SnapKnownNodes, rate*100))
+		builder.WriteRune('\n')
+	}
+	for _, window := range report.SeenWindows {
+		builder.WriteString(fmt.Sprintf("seen last %s: %d nodes, %d IPs",
+			formatWindow(window.Window), window.NodeCount, window.IPCount))
+		builder.WriteRune('\n')
+	}
+	for _, trend := range report.Trends {
+		builder.WriteString(fmt.Sprintf("trend %s: nodes %+d, IPs %+d",
+			formatWindow(trend.Window), trend.NodeDelta, trend.IPDelta))
+		if trend.OperatorDelta != nil {
+			builder.WriteString(fmt.Sprintf(", operators %+d", *trend.OperatorDelta))
+		}
+		builder.WriteRune('\n')
+	}
+	if len(report.TopAvailability) > 0 {
+		builder.WriteString("top availability:")
+		builder.WriteRune('\n')
+		for _, uptime := range report.TopAvailability {
+			builder.WriteString(fmt.Sprintf("    24h %3.0f%% 7d %3.0f%% %s",
+				uptime.UptimeDay*100, uptime.UptimeWeek*100, uptime.ID))
+			builder.WriteRune('\n')
+		}
+	}
+	return builder.String()
+}
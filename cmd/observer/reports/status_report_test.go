@@ -0,0 +1,40 @@
+package reports
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+func TestStatusReportEndToEnd(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := database.NewDBSQLite(filepath.Join(t.TempDir(), "observer.sqlite"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	id := database.NodeID(strings.Repeat("ab", 64))
+	var addr database.NodeAddr
+	addr.IP = net.ParseIP("10.0.0.1")
+	addr.PortDisc = 30303
+	addr.PortRLPx = 30303
+
+	require.NoError(t, db.UpsertNodeAddr(ctx, id, addr))
+	_, err = db.UpdateClientID(ctx, id, "erigon/v2022.04.1", database.ObservationQualityVerified)
+	require.NoError(t, err)
+	require.NoError(t, db.UpdateNetworkID(ctx, id, 1, database.ObservationQualityVerified))
+	require.NoError(t, db.InsertNodeSighting(ctx, id, database.NodeSightingHandshake))
+
+	report, err := CreateStatusReport(ctx, db, 3, 1)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), report.TotalCount)
+	assert.Equal(t, uint(1), report.DistinctIPCount)
+	assert.Contains(t, report.String(), "total: 1")
+}
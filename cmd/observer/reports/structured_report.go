@@ -0,0 +1,217 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// CreateStructuredStatusReport builds the status report as data (see StructuredReport),
+// so that --format can render it for dashboards and CI jobs: the headline counts,
+// plus the client share, country share, ASN share, fork compatibility,
+// IPv6 adoption and IP clustering aggregates.
+func CreateStructuredStatusReport(
+	ctx context.Context,
+	db database.DB,
+	canonicalizer *Canonicalizer,
+	limit uint,
+	maxPingTries uint,
+	networkID uint,
+) (*StructuredReport, error) {
+	statusReport, err := CreateStatusReport(ctx, db, maxPingTries, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCounts, err := db.CountNodesByClientID(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	countryCounts, err := db.CountNodesByCountry(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	forkCounts, err := db.CountForkCompatibility(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	ipVersionCounts, err := db.CountIPVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dialStats, err := db.CountDialFamilyStats(ctx, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	ipClusters, err := ComputeIPClusters(ctx, db, networkID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := StructuredReport{
+		Sections: []ReportSection{
+			makeSummarySection(statusReport),
+			makeShareSection("client share", "client", collapseClientCounts(clientCounts, canonicalizer), limit),
+			makeShareSection("country share", "country", countryCounts, limit),
+			makeShareSection("asn share", "asn", ipClusters.ASNs, limit),
+			makeForkCompatibilitySection(forkCounts),
+			makeIPv6AdoptionSection(ipVersionCounts, dialStats),
+		},
+	}
+	if len(ipClusters.PrefixesV4) > 0 {
+		report.Sections = append(report.Sections,
+			makeShareSection("ipv4 /24 clusters", "prefix", ipClusters.PrefixesV4, limit))
+	}
+	if len(ipClusters.PrefixesV6) > 0 {
+		report.Sections = append(report.Sections,
+			makeShareSection("ipv6 /64 clusters", "prefix", ipClusters.PrefixesV6, limit))
+	}
+	if len(statusReport.TopAvailability) > 0 {
+		report.Sections = append(report.Sections, makeTopAvailabilitySection(statusReport))
+	}
+	return &report, nil
+}
+
+func makeSummarySection(statusReport *StatusReport) ReportSection {
+	rows := [][]string{
+		{"total nodes", fmt.Sprintf("%d", statusReport.TotalCount)},
+		{"distinct IPs", fmt.Sprintf("%d", statusReport.DistinctIPCount)},
+		{"frontier", fmt.Sprintf("%d", statusReport.FrontierCount)},
+		{"operators (est.)", fmt.Sprintf("%d", statusReport.OperatorEstimate)},
+	}
+	for _, count := range statusReport.Reachability {
+		rows = append(rows, []string{"reachability " + count.Reachability, fmt.Sprintf("%d", count.Count)})
+	}
+	if statusReport.SnapKnownNodes > 0 {
+		rows = append(rows, []string{"snap support",
+			fmt.Sprintf("%d of %d handshaked", statusReport.SnapNodes, statusReport.SnapKnownNodes)})
+	}
+	for _, window := range statusReport.SeenWindows {
+		rows = append(rows, []string{"seen last " + formatWindow(window.Window),
+			fmt.Sprintf("%d nodes, %d IPs", window.NodeCount, window.IPCount)})
+	}
+	for _, trend := range statusReport.Trends {
+		value := fmt.Sprintf("nodes %+d, IPs %+d", trend.NodeDelta, trend.IPDelta)
+		if trend.OperatorDelta != nil {
+			value += fmt.Sprintf(", operators %+d", *trend.OperatorDelta)
+		}
+		rows = append(rows, []string{"trend " + formatWindow(trend.Window), value})
+	}
+	return ReportSection{
+		Title:   "summary",
+		Columns: []string{"metric", "value"},
+		Rows:    rows,
+	}
+}
+
+func makeTopAvailabilitySection(statusReport *StatusReport) ReportSection {
+	rows := make([][]string, 0, len(statusReport.TopAvailability))
+	for _, uptime := range statusReport.TopAvailability {
+		rows = append(rows, []string{
+			string(uptime.ID),
+			fmt.Sprintf("%.0f%%", uptime.UptimeDay*100),
+			fmt.Sprintf("%.0f%%", uptime.UptimeWeek*100),
+		})
+	}
+	return ReportSection{
+		Title:   "top availability",
+		Columns: []string{"node", "uptime 24h", "uptime 7d"},
+		Rows:    rows,
+	}
+}
+
+// collapseClientCounts sums the per-client-ID counts into per-client-name counts,
+// e.g. "Geth/v1.10.15/linux-amd64/go1.17" counts towards "geth".
+func collapseClientCounts(clientCounts map[string]uint, canonicalizer *Canonicalizer) map[string]uint {
+	nameCounts := make(map[string]uint)
+	for clientID, count := range clientCounts {
+		nameCounts[canonicalizer.Canonicalize(NameFromClientID(clientID))] += count
+	}
+	return nameCounts
+}
+
+// makeShareSection ranks the groups by their node count and shows the top ones
+// with their share of the counted total.
+func makeShareSection(title string, groupColumn string, counts map[string]uint, limit uint) ReportSection {
+	type groupCount struct {
+		name  string
+		count uint
+	}
+	groups := make([]groupCount, 0, len(counts))
+	var total uint
+	for name, count := range counts {
+		groups = append(groups, groupCount{name, count})
+		total += count
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].name < groups[j].name
+	})
+	if limit < uint(len(groups)) {
+		groups = groups[:limit]
+	}
+
+	rows := make([][]string, 0, len(groups))
+	for _, group := range groups {
+		rows = append(rows, []string{group.name, fmt.Sprintf("%d", group.count), formatShare(group.count, total)})
+	}
+	return ReportSection{
+		Title:   title,
+		Columns: []string{groupColumn, "nodes", "share"},
+		Rows:    rows,
+	}
+}
+
+func makeForkCompatibilitySection(forkCounts map[string]uint) ReportSection {
+	var total uint
+	for _, count := range forkCounts {
+		total += count
+	}
+	rows := make([][]string, 0, len(forkCounts))
+	for _, verdict := range []string{"compatible", "incompatible", "unknown"} {
+		count := forkCounts[verdict]
+		rows = append(rows, []string{verdict, fmt.Sprintf("%d", count), formatShare(count, total)})
+	}
+	return ReportSection{
+		Title:   "fork compatibility",
+		Columns: []string{"verdict", "nodes", "share"},
+		Rows:    rows,
+	}
+}
+
+// makeIPv6AdoptionSection quotes the IPv6 adoption rate against the IPv4 population,
+// since nearly every discovered node advertises an IPv4 address.
+// The dial success rates compare how reachable the advertised addresses
+// actually are per family (see the dual-stack handshake path).
+func makeIPv6AdoptionSection(ipVersionCounts map[string]uint, dialStats database.DialFamilyStats) ReportSection {
+	ipv4Count := ipVersionCounts["ipv4"]
+	ipv6Count := ipVersionCounts["ipv6"]
+	return ReportSection{
+		Title:   "ipv6 adoption",
+		Columns: []string{"metric", "value"},
+		Rows: [][]string{
+			{"nodes with an IPv4 address", fmt.Sprintf("%d", ipv4Count)},
+			{"nodes with an IPv6 address", fmt.Sprintf("%d", ipv6Count)},
+			{"IPv6-only nodes", fmt.Sprintf("%d", ipVersionCounts["ipv6_only"])},
+			{"IPv6 adoption", formatShare(ipv6Count, ipv4Count)},
+			{"IPv4 dial success", formatShare(dialStats.SuccessV4, dialStats.AttemptsV4)},
+			{"IPv6 dial success", formatShare(dialStats.SuccessV6, dialStats.AttemptsV6)},
+		},
+	}
+}
+
+func formatShare(count uint, total uint) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(count)/float64(total)*100)
+}
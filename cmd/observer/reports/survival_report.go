@@ -0,0 +1,169 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// survivalBuckets are the node ages (in days) the survival curve is sampled at.
+var survivalBuckets = []uint{1, 3, 7, 14, 30}
+
+// hostingASNs are the autonomous systems of the major hosting providers.
+// Nodes announced from these networks are classified as "hosting",
+// the rest as "other" (typically residential or institutional),
+// and nodes without ASN data as "unknown".
+var hostingASNs = map[uint]bool{
+	16509:  true, // Amazon
+	14618:  true, // Amazon
+	15169:  true, // Google
+	396982: true, // Google Cloud
+	8075:   true, // Microsoft
+	16276:  true, // OVH
+	24940:  true, // Hetzner
+	14061:  true, // DigitalOcean
+	63949:  true, // Linode
+	51167:  true, // Contabo
+	45102:  true, // Alibaba
+	31898:  true, // Oracle
+}
+
+// SurvivalGroupEntry is a survival curve of a group of nodes:
+// for each bucket, Eligible counts the nodes first seen at least that long ago,
+// and Survived counts how many of those were still reachable at that age.
+type SurvivalGroupEntry struct {
+	Name     string
+	Eligible []uint
+	Survived []uint
+}
+
+// SurvivalReport estimates how long nodes remain reachable after first being seen,
+// broken down by client name and by hosting type.
+type SurvivalReport struct {
+	NodeCount uint
+	Clients   []SurvivalGroupEntry
+	Hosting   []SurvivalGroupEntry
+}
+
+func CreateSurvivalReport(
+	ctx context.Context,
+	db database.DB,
+	canonicalizer *Canonicalizer,
+	limit uint,
+) (*SurvivalReport, error) {
+	clientGroups := make(map[string]*SurvivalGroupEntry)
+	hostingGroups := make(map[string]*SurvivalGroupEntry)
+	nodeCount := uint(0)
+	now := time.Now()
+
+	enumFunc := func(lifetime database.NodeLifetime) {
+		nodeCount++
+
+		clientName := "(unknown)"
+		if lifetime.ClientID != nil {
+			clientName = canonicalizer.Canonicalize(NameFromClientID(*lifetime.ClientID))
+		}
+
+		age := now.Sub(lifetime.FirstSeen)
+		survived := lifetime.LastSeen.Sub(lifetime.FirstSeen)
+
+		groups := []*SurvivalGroupEntry{
+			survivalGroup(clientGroups, clientName),
+			survivalGroup(hostingGroups, hostingClass(lifetime.ASN)),
+		}
+		for _, group := range groups {
+			for i, bucketDays := range survivalBuckets {
+				bucket := time.Duration(bucketDays) * 24 * time.Hour
+				// the survival at a given age can only be judged
+				// for nodes that were first seen at least that long ago
+				if age < bucket {
+					break
+				}
+				group.Eligible[i]++
+				if survived >= bucket {
+					group.Survived[i]++
+				}
+			}
+		}
+	}
+	if err := db.EnumerateNodeLifetimes(ctx, enumFunc); err != nil {
+		return nil, err
+	}
+
+	report := SurvivalReport{
+		NodeCount: nodeCount,
+		Clients:   sortedSurvivalGroups(clientGroups, limit),
+		Hosting:   sortedSurvivalGroups(hostingGroups, 0),
+	}
+	return &report, nil
+}
+
+func survivalGroup(groups map[string]*SurvivalGroupEntry, name string) *SurvivalGroupEntry {
+	group := groups[name]
+	if group == nil {
+		group = &SurvivalGroupEntry{
+			Name:     name,
+			Eligible: make([]uint, len(survivalBuckets)),
+			Survived: make([]uint, len(survivalBuckets)),
+		}
+		groups[name] = group
+	}
+	return group
+}
+
+func sortedSurvivalGroups(groups map[string]*SurvivalGroupEntry, limit uint) []SurvivalGroupEntry {
+	entries := make([]SurvivalGroupEntry, 0, len(groups))
+	for _, group := range groups {
+		entries = append(entries, *group)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Eligible[0] > entries[j].Eligible[0]
+	})
+	if (limit > 0) && (limit < uint(len(entries))) {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func hostingClass(asn *uint) string {
+	switch {
+	case asn == nil:
+		return "unknown"
+	case hostingASNs[*asn]:
+		return "hosting"
+	default:
+		return "other"
+	}
+}
+
+func (report *SurvivalReport) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("nodes with a known first sighting: %d", report.NodeCount))
+	builder.WriteRune('\n')
+	builder.WriteString("survival by client:")
+	builder.WriteRune('\n')
+	writeSurvivalGroups(&builder, report.Clients)
+	builder.WriteString("survival by hosting type:")
+	builder.WriteRune('\n')
+	writeSurvivalGroups(&builder, report.Hosting)
+	return builder.String()
+}
+
+func writeSurvivalGroups(builder *strings.Builder, entries []SurvivalGroupEntry) {
+	for _, entry := range entries {
+		builder.WriteString(fmt.Sprintf("%24s:", entry.Name))
+		for i, bucketDays := range survivalBuckets {
+			if entry.Eligible[i] == 0 {
+				break
+			}
+			rate := float64(entry.Survived[i]) / float64(entry.Eligible[i])
+			builder.WriteString(fmt.Sprintf("  %dd %3.0f%%", bucketDays, rate*100))
+		}
+		builder.WriteString(fmt.Sprintf("  (of %d)", entry.Eligible[0]))
+		builder.WriteRune('\n')
+	}
+}
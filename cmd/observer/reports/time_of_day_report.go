@@ -0,0 +1,140 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// TimeOfDayRow is the hourly handshake success rates of one group of nodes:
+// all of them, or the nodes of one country.
+// Rates[hour] is nil when the bucket has no attempts yet.
+type TimeOfDayRow struct {
+	Name     string
+	Attempts uint
+	Rates    [24]*float64
+}
+
+// TimeOfDayReport shows the handshake success rate per UTC hour-of-day and
+// day-of-week, overall and for the countries with the most attempts,
+// revealing diurnal patterns (e.g. residential nodes going offline at night)
+// that matter for scheduling probes.
+type TimeOfDayReport struct {
+	ByHour []TimeOfDayRow
+
+	// ByDayOfWeek are the overall success rates per UTC weekday (0 - Sunday).
+	ByDayOfWeek [7]*float64
+}
+
+func CreateTimeOfDayReport(ctx context.Context, db database.DB, limit uint) (*TimeOfDayReport, error) {
+	stats, err := db.EnumerateHandshakeHourStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type bucketTotals struct {
+		success [24]uint
+		failure [24]uint
+	}
+	countryTotals := make(map[string]*bucketTotals)
+	var overall bucketTotals
+	var daySuccess, dayFailure [7]uint
+
+	for _, entry := range stats {
+		if (entry.Hour >= 24) || (entry.DayOfWeek >= 7) {
+			continue
+		}
+		totals := countryTotals[entry.Country]
+		if totals == nil {
+			totals = &bucketTotals{}
+			countryTotals[entry.Country] = totals
+		}
+		totals.success[entry.Hour] += entry.SuccessCount
+		totals.failure[entry.Hour] += entry.FailureCount
+		overall.success[entry.Hour] += entry.SuccessCount
+		overall.failure[entry.Hour] += entry.FailureCount
+		daySuccess[entry.DayOfWeek] += entry.SuccessCount
+		dayFailure[entry.DayOfWeek] += entry.FailureCount
+	}
+
+	makeRow := func(name string, totals *bucketTotals) TimeOfDayRow {
+		row := TimeOfDayRow{Name: name}
+		for hour := 0; hour < 24; hour++ {
+			attempts := totals.success[hour] + totals.failure[hour]
+			row.Attempts += attempts
+			if attempts > 0 {
+				rate := float64(totals.success[hour]) / float64(attempts)
+				row.Rates[hour] = &rate
+			}
+		}
+		return row
+	}
+
+	var report TimeOfDayReport
+	if len(stats) > 0 {
+		report.ByHour = append(report.ByHour, makeRow("(all)", &overall))
+	}
+
+	countryRows := make([]TimeOfDayRow, 0, len(countryTotals))
+	for country, totals := range countryTotals {
+		name := country
+		if name == "" {
+			name = "(unknown)"
+		}
+		countryRows = append(countryRows, makeRow(name, totals))
+	}
+	sort.Slice(countryRows, func(i, j int) bool {
+		return countryRows[i].Attempts > countryRows[j].Attempts
+	})
+	if (limit > 0) && (limit < uint(len(countryRows))) {
+		countryRows = countryRows[:limit]
+	}
+	report.ByHour = append(report.ByHour, countryRows...)
+
+	for day := 0; day < 7; day++ {
+		attempts := daySuccess[day] + dayFailure[day]
+		if attempts > 0 {
+			rate := float64(daySuccess[day]) / float64(attempts)
+			report.ByDayOfWeek[day] = &rate
+		}
+	}
+	return &report, nil
+}
+
+func formatRate(rate *float64) string {
+	if rate == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", *rate*100)
+}
+
+func (report *TimeOfDayReport) String() string {
+	var builder strings.Builder
+	builder.WriteString("handshake success rate % by UTC hour of day:")
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("%-12s", "hour"))
+	for hour := 0; hour < 24; hour++ {
+		builder.WriteString(fmt.Sprintf("%4d", hour))
+	}
+	builder.WriteRune('\n')
+	for _, row := range report.ByHour {
+		builder.WriteString(fmt.Sprintf("%-12s", row.Name))
+		for hour := 0; hour < 24; hour++ {
+			builder.WriteString(fmt.Sprintf("%4s", formatRate(row.Rates[hour])))
+		}
+		builder.WriteRune('\n')
+	}
+
+	builder.WriteString("handshake success rate % by UTC day of week:")
+	builder.WriteRune('\n')
+	for day := 0; day < 7; day++ {
+		builder.WriteString(fmt.Sprintf("    %s %s",
+			time.Weekday(day).String()[:3], formatRate(report.ByDayOfWeek[day])))
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
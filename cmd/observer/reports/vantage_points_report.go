@@ -0,0 +1,56 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+)
+
+// VantagePointsReport compares reachability and latency of the handshaked nodes
+// as seen from the different source IPs of a multi-homed host.
+type VantagePointsReport struct {
+	VantagePoints  []database.VantagePointStats
+	DivergentNodes uint
+}
+
+func CreateVantagePointsReport(ctx context.Context, db database.DB) (*VantagePointsReport, error) {
+	stats, err := db.EnumerateVantagePointStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var divergentNodes uint
+	if len(stats) > 1 {
+		divergentNodes, err = db.CountVantageDivergentNodes(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report := VantagePointsReport{stats, divergentNodes}
+	return &report, nil
+}
+
+func (report *VantagePointsReport) String() string {
+	var builder strings.Builder
+	builder.WriteString("vantage points:")
+	builder.WriteRune('\n')
+	for _, entry := range report.VantagePoints {
+		successRate := float64(entry.SuccessCount) / float64(entry.AttemptCount)
+		builder.WriteString(fmt.Sprintf("%-40s nodes: %6d  attempts: %6d  success: %5.1f%%  avg latency: %s",
+			entry.SourceIP,
+			entry.DistinctNodeCount,
+			entry.AttemptCount,
+			successRate*100,
+			entry.AvgDuration.Round(time.Millisecond)))
+		builder.WriteRune('\n')
+	}
+	if len(report.VantagePoints) > 1 {
+		builder.WriteString(fmt.Sprintf("nodes with divergent reachability: %d", report.DivergentNodes))
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
@@ -0,0 +1,99 @@
+package simulate
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir     string
+	Caps        []string
+	SampleSize  uint
+	Concurrency uint
+	Timeout     time.Duration
+
+	// ClientIDs switches to the compatibility matrix mode (see Simulator.RunMatrix):
+	// every sampled node is handshaked once per advertised client ID,
+	// and the acceptance is reported per remote client.
+	ClientIDs []string
+	// CapSets are additional capability sets of the matrix mode,
+	// each a comma-separated list like "eth/66,snap/1" (default: the --caps set).
+	CapSets []string
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "simulate-hello",
+		Short: "Handshake sampled nodes advertising custom capabilities, and report the acceptance rate",
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withCaps()
+	instance.withSampleSize()
+	instance.withConcurrency()
+	instance.withTimeout()
+	instance.withMatrix()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withCaps() {
+	flags := command.command.Flags()
+	flags.StringSliceVar(&command.flags.Caps, "caps", []string{"eth/66"},
+		"capabilities to advertise in the Hello message, e.g. eth/66,snap/1")
+}
+
+func (command *Command) withSampleSize() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.SampleSize, "sample-size", 100, "how many nodes to handshake")
+}
+
+func (command *Command) withConcurrency() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.Concurrency, "concurrency", 16, "how many nodes to handshake in parallel")
+}
+
+func (command *Command) withTimeout() {
+	flags := command.command.Flags()
+	flags.DurationVar(&command.flags.Timeout, "timeout", 15*time.Second, "per-node handshake timeout")
+}
+
+func (command *Command) withMatrix() {
+	flags := command.command.Flags()
+	flags.StringArrayVar(&command.flags.ClientIDs, "client-ids", nil,
+		"matrix mode: advertise each of these client IDs in turn, and report the acceptance per remote client "+
+			"(an empty string advertises the default observer client ID)")
+	flags.StringArrayVar(&command.flags.CapSets, "cap-sets", nil,
+		"matrix mode: additional capability sets to advertise, each like eth/66,snap/1 (default: the --caps set)")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
@@ -0,0 +1,166 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/reports"
+	"github.com/ledgerwatch/erigon/p2p"
+	"golang.org/x/sync/semaphore"
+)
+
+// MatrixIdentity is a peer identity the observer poses as during a matrix run:
+// an advertised client ID (empty - the default observer one) and a capability set.
+type MatrixIdentity struct {
+	ClientID string
+	Caps     []p2p.Cap
+}
+
+func (identity MatrixIdentity) Label() string {
+	var capNames []string
+	for _, capability := range identity.Caps {
+		capNames = append(capNames, capability.String())
+	}
+
+	clientID := identity.ClientID
+	if clientID == "" {
+		clientID = "(default)"
+	}
+	return fmt.Sprintf("%s %s", clientID, strings.Join(capNames, ","))
+}
+
+// MatrixCell counts the trial outcomes of one advertised identity
+// against the nodes of one remote client.
+type MatrixCell struct {
+	Accepted uint
+	Rejected uint
+}
+
+// MatrixResult is a per-remote-client acceptance matrix of the advertised identities.
+// A large acceptance gap between identities against the same remote client
+// suggests that the client discriminates by peer identity.
+type MatrixResult struct {
+	Identities []MatrixIdentity
+	// Cells maps a remote client name to the outcomes per identity
+	// (in the Identities order).
+	Cells map[string][]MatrixCell
+}
+
+// RunMatrix samples nodes which handshaked successfully in the past,
+// and trials each of the given identities against every sampled node,
+// grouping the outcomes by the remote client name.
+// Each node is trialed with all identities so that the acceptance rates are comparable.
+func (simulator *Simulator) RunMatrix(ctx context.Context, identities []MatrixIdentity) (*MatrixResult, error) {
+	result := MatrixResult{
+		Identities: identities,
+		Cells:      make(map[string][]MatrixCell),
+	}
+	var resultMutex sync.Mutex
+	var attemptCount uint
+
+	sem := semaphore.NewWeighted(int64(simulator.concurrencyLimit))
+	var wg sync.WaitGroup
+
+	var afterID database.NodeID
+	for (attemptCount < simulator.sampleSize) && (ctx.Err() == nil) {
+		ids, err := simulator.db.EnumerateNodeIDsAfter(ctx, afterID, simulatorBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate nodes: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		afterID = ids[len(ids)-1]
+
+		for _, id := range ids {
+			if attemptCount >= simulator.sampleSize {
+				break
+			}
+
+			// sample from nodes which responded to a normal handshake before,
+			// since the remote client name is only known for those
+			clientID, err := simulator.db.FindClientID(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find the client ID: %w", err)
+			}
+			if clientID == nil {
+				continue
+			}
+			remoteClient := reports.NameFromClientID(*clientID)
+
+			attemptCount++
+
+			if err := sem.Acquire(ctx, 1); err != nil {
+				break
+			}
+			wg.Add(1)
+			go func(id database.NodeID, remoteClient string) {
+				defer wg.Done()
+				defer sem.Release(1)
+
+				// trial the identities one after another against the same node,
+				// so that a difference in the outcomes is down to the identity
+				outcomes := make([]trialOutcome, len(identities))
+				for i, identity := range identities {
+					outcomes[i] = simulator.trial(ctx, id, identity.Caps, identity.ClientID)
+				}
+
+				resultMutex.Lock()
+				defer resultMutex.Unlock()
+				cells := result.Cells[remoteClient]
+				if cells == nil {
+					cells = make([]MatrixCell, len(identities))
+					result.Cells[remoteClient] = cells
+				}
+				for i, outcome := range outcomes {
+					switch outcome.kind {
+					case trialOutcomeAccepted:
+						cells[i].Accepted++
+					case trialOutcomeRejected:
+						cells[i].Rejected++
+					}
+				}
+			}(id, remoteClient)
+		}
+	}
+
+	wg.Wait()
+	return &result, ctx.Err()
+}
+
+func (result *MatrixResult) String() string {
+	var builder strings.Builder
+	builder.WriteString("identities:")
+	builder.WriteRune('\n')
+	for i, identity := range result.Identities {
+		builder.WriteString(fmt.Sprintf("    [%d] %s", i+1, identity.Label()))
+		builder.WriteRune('\n')
+	}
+
+	remoteClients := make([]string, 0, len(result.Cells))
+	for remoteClient := range result.Cells {
+		remoteClients = append(remoteClients, remoteClient)
+	}
+	sort.Strings(remoteClients)
+
+	builder.WriteString("acceptance by remote client:")
+	builder.WriteRune('\n')
+	for _, remoteClient := range remoteClients {
+		builder.WriteString(fmt.Sprintf("%24s:", remoteClient))
+		for i, cell := range result.Cells[remoteClient] {
+			reachable := cell.Accepted + cell.Rejected
+			if reachable == 0 {
+				builder.WriteString(fmt.Sprintf("  [%d]    -", i+1))
+				continue
+			}
+			rate := float64(cell.Accepted) / float64(reachable) * 100
+			builder.WriteString(fmt.Sprintf("  [%d] %3.0f%% of %d", i+1, rate, reachable))
+		}
+		builder.WriteRune('\n')
+	}
+	return builder.String()
+}
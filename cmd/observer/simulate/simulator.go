@@ -0,0 +1,243 @@
+package simulate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/observer/database"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer"
+	"github.com/ledgerwatch/erigon/cmd/observer/observer/node_utils"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/ledgerwatch/erigon/p2p"
+	"github.com/ledgerwatch/log/v3"
+	"golang.org/x/sync/semaphore"
+)
+
+// Simulator performs RLPx handshakes against a sample of known nodes
+// advertising a custom set of capabilities,
+// and counts how many nodes accept or reject such a Hello message.
+type Simulator struct {
+	db         database.DB
+	privateKey *ecdsa.PrivateKey
+	caps       []p2p.Cap
+
+	sampleSize       uint
+	concurrencyLimit uint
+	timeout          time.Duration
+
+	log log.Logger
+}
+
+// SimulationResult aggregates the handshake trial outcomes.
+// Unreachable nodes didn't answer the dial or the RLPx auth, and don't count towards the acceptance rate.
+// Rejected nodes disconnected us after seeing our Hello message.
+type SimulationResult struct {
+	Caps        []p2p.Cap
+	Accepted    uint
+	Rejected    uint
+	Unreachable uint
+
+	// RejectReasons counts the disconnect reasons of the rejecting nodes.
+	RejectReasons map[string]uint
+}
+
+func NewSimulator(
+	db database.DB,
+	caps []p2p.Cap,
+	sampleSize uint,
+	concurrencyLimit uint,
+	timeout time.Duration,
+	logger log.Logger,
+) (*Simulator, error) {
+	privateKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a private key: %w", err)
+	}
+
+	instance := Simulator{
+		db:         db,
+		privateKey: privateKey,
+		caps:       caps,
+
+		sampleSize:       sampleSize,
+		concurrencyLimit: concurrencyLimit,
+		timeout:          timeout,
+
+		log: logger,
+	}
+	return &instance, nil
+}
+
+const simulatorBatchSize = 1000
+
+// Run samples nodes which handshaked successfully in the past,
+// and trials the configured Hello message against them.
+func (simulator *Simulator) Run(ctx context.Context) (*SimulationResult, error) {
+	result := SimulationResult{
+		Caps:          simulator.caps,
+		RejectReasons: make(map[string]uint),
+	}
+	var resultMutex sync.Mutex
+	var attemptCount uint
+
+	sem := semaphore.NewWeighted(int64(simulator.concurrencyLimit))
+	var wg sync.WaitGroup
+
+	var afterID database.NodeID
+	for (attemptCount < simulator.sampleSize) && (ctx.Err() == nil) {
+		ids, err := simulator.db.EnumerateNodeIDsAfter(ctx, afterID, simulatorBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enumerate nodes: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+		afterID = ids[len(ids)-1]
+
+		for _, id := range ids {
+			if attemptCount >= simulator.sampleSize {
+				break
+			}
+
+			// sample from nodes which responded to a normal handshake before
+			clientID, err := simulator.db.FindClientID(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find the client ID: %w", err)
+			}
+			if clientID == nil {
+				continue
+			}
+
+			attemptCount++
+
+			if err := sem.Acquire(ctx, 1); err != nil {
+				break
+			}
+			wg.Add(1)
+			go func(id database.NodeID) {
+				defer wg.Done()
+				defer sem.Release(1)
+
+				outcome := simulator.trial(ctx, id, simulator.caps, "")
+
+				resultMutex.Lock()
+				defer resultMutex.Unlock()
+				switch outcome.kind {
+				case trialOutcomeAccepted:
+					result.Accepted++
+				case trialOutcomeRejected:
+					result.Rejected++
+					result.RejectReasons[outcome.rejectReason]++
+				case trialOutcomeUnreachable:
+					result.Unreachable++
+				}
+			}(id)
+		}
+	}
+
+	wg.Wait()
+	return &result, ctx.Err()
+}
+
+type trialOutcomeKind int
+
+const (
+	trialOutcomeAccepted trialOutcomeKind = iota
+	trialOutcomeRejected
+	trialOutcomeUnreachable
+)
+
+type trialOutcome struct {
+	kind         trialOutcomeKind
+	rejectReason string
+}
+
+func (simulator *Simulator) trial(ctx context.Context, id database.NodeID, caps []p2p.Cap, helloClientID string) trialOutcome {
+	nodeAddr, err := simulator.db.FindNodeAddr(ctx, id)
+	if (err != nil) || (nodeAddr == nil) {
+		return trialOutcome{kind: trialOutcomeUnreachable}
+	}
+
+	node, err := node_utils.MakeNodeFromAddr(id, *nodeAddr)
+	if err != nil {
+		return trialOutcome{kind: trialOutcomeUnreachable}
+	}
+
+	trialContext, cancel := context.WithTimeout(ctx, simulator.timeout)
+	defer cancel()
+
+	addr := net.TCPAddr{IP: node.IP(), Port: node.TCP()}
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(trialContext, "tcp", addr.String())
+	if err != nil {
+		return trialOutcome{kind: trialOutcomeUnreachable}
+	}
+
+	hello, _, handshakeErr := observer.HandshakeWithHello(trialContext, conn, node.Pubkey(), simulator.privateKey,
+		caps, helloClientID, nil, "")
+
+	if handshakeErr == nil {
+		return trialOutcome{kind: trialOutcomeAccepted}
+	}
+	if hello == nil {
+		// the node went away before seeing our capabilities
+		return trialOutcome{kind: trialOutcomeUnreachable}
+	}
+	switch observer.HandshakeErrorID(handshakeErr.StringCode()) {
+	case observer.HandshakeErrorIDDisconnect:
+		reason := "unknown"
+		if wrappedErr := errors.Unwrap(handshakeErr); wrappedErr != nil {
+			reason = wrappedErr.Error()
+		}
+		return trialOutcome{kind: trialOutcomeRejected, rejectReason: reason}
+	case observer.HandshakeErrorIDRead:
+		// the Hello exchange succeeded, and the node kept the connection open
+		return trialOutcome{kind: trialOutcomeAccepted}
+	default:
+		return trialOutcome{kind: trialOutcomeUnreachable}
+	}
+}
+
+func (result *SimulationResult) String() string {
+	var capNames []string
+	for _, capability := range result.Caps {
+		capNames = append(capNames, capability.String())
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("caps: %s", strings.Join(capNames, ",")))
+	builder.WriteRune('\n')
+
+	reachable := result.Accepted + result.Rejected
+	builder.WriteString(fmt.Sprintf("reachable: %d", reachable))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("unreachable: %d", result.Unreachable))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("accepted: %d", result.Accepted))
+	builder.WriteRune('\n')
+	builder.WriteString(fmt.Sprintf("rejected: %d", result.Rejected))
+	builder.WriteRune('\n')
+	if reachable > 0 {
+		builder.WriteString(fmt.Sprintf("acceptance rate: %.1f%%", float64(result.Accepted)/float64(reachable)*100))
+		builder.WriteRune('\n')
+	}
+
+	reasons := make([]string, 0, len(result.RejectReasons))
+	for reason := range result.RejectReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		builder.WriteString(fmt.Sprintf("    rejected with %q: %d", reason, result.RejectReasons[reason]))
+		builder.WriteRune('\n')
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,74 @@
+package sqltool
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon/cmd/utils"
+	"github.com/ledgerwatch/erigon/common/paths"
+	"github.com/spf13/cobra"
+)
+
+type CommandFlags struct {
+	DataDir string
+
+	// Query is the SELECT statement given as the positional argument.
+	Query string
+
+	// Timeout aborts a query running longer.
+	Timeout time.Duration
+	// MaxRows truncates the result beyond this many rows.
+	MaxRows uint
+}
+
+type Command struct {
+	command cobra.Command
+	flags   CommandFlags
+}
+
+func NewCommand() *Command {
+	command := cobra.Command{
+		Use:   "sql <query>",
+		Short: "Run a read-only SELECT against the sandbox views of the database",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	instance := Command{
+		command: command,
+	}
+
+	instance.withDatadir()
+	instance.withTimeout()
+	instance.withMaxRows()
+
+	return &instance
+}
+
+func (command *Command) withDatadir() {
+	flags := command.command.Flags()
+	flags.StringVar(&command.flags.DataDir, utils.DataDirFlag.Name, paths.DefaultDataDir(), utils.DataDirFlag.Usage)
+	if err := command.command.MarkFlagDirname(utils.DataDirFlag.Name); err != nil {
+		panic(err)
+	}
+}
+
+func (command *Command) withTimeout() {
+	flags := command.command.Flags()
+	flags.DurationVar(&command.flags.Timeout, "timeout", 30*time.Second, "abort the query after this long")
+}
+
+func (command *Command) withMaxRows() {
+	flags := command.command.Flags()
+	flags.UintVar(&command.flags.MaxRows, "max-rows", 1000, "truncate the result after this many rows")
+}
+
+func (command *Command) OnRun(runFunc func(ctx context.Context, flags CommandFlags) error) {
+	command.command.RunE = func(cmd *cobra.Command, args []string) error {
+		command.flags.Query = args[0]
+		return runFunc(cmd.Context(), command.flags)
+	}
+}
+
+func (command *Command) RawCommand() *cobra.Command {
+	return &command.command
+}
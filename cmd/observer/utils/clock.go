@@ -0,0 +1,26 @@
+package utils
+
+import "time"
+
+// Clock abstracts the current time, so the timestamp-driven logic
+// (retry scheduling, candidate leases) can run against a fake clock in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ToTimestamp converts a time to the UTC seconds stored in the database.
+func ToTimestamp(value time.Time) int64 {
+	return value.UTC().Unix()
+}
+
+// FromTimestamp converts the stored UTC seconds back to a UTC time.
+func FromTimestamp(timestamp int64) time.Time {
+	return time.Unix(timestamp, 0).UTC()
+}
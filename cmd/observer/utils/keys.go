@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"os"
+
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+// LoadOrGenerateKey loads a persistent secp256k1 key from the given file,
+// generating and saving a fresh key on the first run.
+// The key is the signing identity of the observer (e.g. for DNS trees and peer lists),
+// so it has to stay stable across runs.
+func LoadOrGenerateKey(path string) (*ecdsa.PrivateKey, error) {
+	key, err := crypto.LoadECDSA(path)
+	if err == nil {
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load the signing key: %w", err)
+	}
+
+	key, err = crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a signing key: %w", err)
+	}
+	if err := crypto.SaveECDSA(path, key); err != nil {
+		return nil, fmt.Errorf("failed to save the signing key: %w", err)
+	}
+	return key, nil
+}
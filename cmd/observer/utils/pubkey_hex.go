@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+// PubkeyHex is a hex-encoded secp256k1 public key
+// in the 64 bytes "uncompressed" format (without the leading constant byte).
+type PubkeyHex string
+
+func MarshalHexPublicKey(key *ecdsa.PublicKey) PubkeyHex {
+	return PubkeyHex(hex.EncodeToString(crypto.MarshalPubkey(key)))
+}
+
+func (pubkeyHex PubkeyHex) Unmarshal() (*ecdsa.PublicKey, error) {
+	keyBytes, err := hex.DecodeString(string(pubkeyHex))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode a public key: %w", err)
+	}
+	key, err := crypto.UnmarshalPubkey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse a public key: %w", err)
+	}
+	return key, nil
+}
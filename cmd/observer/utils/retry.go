@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/log/v3"
+)
+
+func Retry(
+	ctx context.Context,
+	retryCount int,
+	delayForAttempt func(attempt int) time.Duration,
+	isRecoverableError func(error) bool,
+	logger log.Logger,
+	opName string,
+	op func(context.Context) (interface{}, error),
+) (interface{}, error) {
+	var result interface{}
+	var err error
+
+	for attempt := 0; attempt <= retryCount; attempt++ {
+		if attempt > 0 {
+			logger.Trace("retrying", "op", opName, "attempt", attempt, "err", err)
+			if err := Sleep(ctx, delayForAttempt(attempt)); err != nil {
+				return nil, err
+			}
+		}
+		result, err = op(ctx)
+		if (err == nil) || !isRecoverableError(err) {
+			break
+		}
+	}
+	return result, err
+}
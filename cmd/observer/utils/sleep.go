@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+func Sleep(ctx context.Context, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
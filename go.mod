@@ -44,6 +44,7 @@ require (
 	github.com/ledgerwatch/erigon-lib v0.0.0-20220413115515-f18e05186dd7
 	github.com/ledgerwatch/log/v3 v3.4.1
 	github.com/ledgerwatch/secp256k1 v1.0.0
+	github.com/lib/pq v1.10.4
 	github.com/magiconair/properties v1.8.6 // indirect
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/pelletier/go-toml v1.9.4
@@ -75,5 +76,7 @@ require (
 	google.golang.org/protobuf v1.28.0
 	gopkg.in/check.v1 v1.0.0-20200902074654-038fdea0a05b
 	gopkg.in/olebedev/go-duktape.v3 v3.0.0-20200619000410-60c24ae608a6
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+	modernc.org/sqlite v1.14.3
 	pgregory.net/rapid v0.4.7
 )
@@ -213,6 +213,17 @@ func (t *UDPv4) Ping(n *enode.Node) error {
 	return err
 }
 
+// FindNode sends a findnode request to the given node and
+// waits until the node has sent up to k neighbors.
+func (t *UDPv4) FindNode(n *enode.Node, targetKey *ecdsa.PublicKey) ([]*enode.Node, error) {
+	toaddr := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
+	nodes, err := t.findnode(n.ID(), toaddr, v4wire.EncodePubkey(targetKey))
+	if err != nil {
+		return nil, err
+	}
+	return unwrapNodes(nodes), nil
+}
+
 // ping sends a ping message to the given node and waits for a reply.
 func (t *UDPv4) ping(n *enode.Node) (seq uint64, err error) {
 	rm := t.sendPing(n.ID(), &net.UDPAddr{IP: n.IP(), Port: n.UDP()}, nil)